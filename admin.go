@@ -0,0 +1,1031 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// AdminHandler handles HTTP requests for administrative and moderation
+// operations. Permission checks are applied by the Authenticator when the
+// routes are registered.
+type AdminHandler struct {
+	boards    map[string]*adminBoard
+	rules     *RuleSet
+	rulesFile string
+	approvals *ApprovalQueue
+	blobGC    *BlobCollector
+	auditLog  *AuditLog
+	seasons   *SeasonManager
+	names     *NameFilter
+	claims    *NameClaims
+	friends   *Friends
+}
+
+// adminBoard is a board the admin endpoints manage, with the jobs that
+// maintain it
+type adminBoard struct {
+	id        string
+	store     *ScoreStore
+	file      string
+	backfill  *BackfillJob
+	retention *Retention
+}
+
+// AdminOption configures optional AdminHandler dependencies
+type AdminOption func(*AdminHandler)
+
+// WithApprovals requires dangerous actions to be confirmed by a second admin
+func WithApprovals(approvals *ApprovalQueue) AdminOption {
+	return func(h *AdminHandler) {
+		h.approvals = approvals
+	}
+}
+
+// WithBlobCollector enables running orphaned blob collection on demand
+func WithBlobCollector(collector *BlobCollector) AdminOption {
+	return func(h *AdminHandler) {
+		h.blobGC = collector
+	}
+}
+
+// WithRetention enables applying the main board's retention policy on
+// demand
+func WithRetention(retention *Retention) AdminOption {
+	return func(h *AdminHandler) {
+		h.boards[MainBoard].retention = retention
+	}
+}
+
+// WithAdminBoard lets the admin endpoints manage a named board, selected
+// with ?board=. retention may be nil.
+func WithAdminBoard(id string, store *ScoreStore, retention *Retention) AdminOption {
+	return func(h *AdminHandler) {
+		h.boards[id] = h.newBoard(id, store, retention)
+	}
+}
+
+// WithAuditLog records every admin mutation, such as deletions and imports
+func WithAuditLog(audit *AuditLog) AdminOption {
+	return func(h *AdminHandler) {
+		h.auditLog = audit
+	}
+}
+
+// WithSeasons enables scheduling and ending seasons
+func WithSeasons(seasons *SeasonManager) AdminOption {
+	return func(h *AdminHandler) {
+		h.seasons = seasons
+	}
+}
+
+// WithAdminNameFilter checks the player names of imported entries like
+// those of submissions
+func WithAdminNameFilter(names *NameFilter) AdminOption {
+	return func(h *AdminHandler) {
+		h.names = names
+	}
+}
+
+// WithPlayerAccounts lets player erasure also drop the player's name claim
+// and friend lists. Either may be nil.
+func WithPlayerAccounts(claims *NameClaims, friends *Friends) AdminOption {
+	return func(h *AdminHandler) {
+		h.claims = claims
+		h.friends = friends
+	}
+}
+
+// NewAdminHandler creates an AdminHandler managing store as the main board
+func NewAdminHandler(store *ScoreStore, rules *RuleSet, rulesFile string, opts ...AdminOption) *AdminHandler {
+	h := &AdminHandler{
+		boards:    make(map[string]*adminBoard),
+		rules:     rules,
+		rulesFile: rulesFile,
+	}
+	h.boards[MainBoard] = h.newBoard(MainBoard, store, nil)
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *AdminHandler) newBoard(id string, store *ScoreStore, retention *Retention) *adminBoard {
+	file := boardFile(id)
+	return &adminBoard{
+		id:        id,
+		store:     store,
+		file:      file,
+		backfill:  NewBackfillJob(store, h.rules, file),
+		retention: retention,
+	}
+}
+
+// boardIDs returns the IDs of every managed board in order
+func (h *AdminHandler) boardIDs() []string {
+	ids := make([]string, 0, len(h.boards))
+	for id := range h.boards {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// board returns the board a request manages: the {board} path value if
+// the route has one, else ?board=, else the main board. It answers 404
+// and returns false if there is no such board.
+func (h *AdminHandler) board(w http.ResponseWriter, r *http.Request) (*adminBoard, bool) {
+	id := r.PathValue("board")
+	if id == "" {
+		id = r.URL.Query().Get("board")
+	}
+	if id == "" {
+		id = MainBoard
+	}
+
+	b, ok := h.boards[id]
+	if !ok {
+		httpError(w, r, "Board not found", http.StatusNotFound)
+	}
+	return b, ok
+}
+
+// dryRunSampleSize is the number of affected entries shown in dry runs
+const dryRunSampleSize = 10
+
+// adminMutation describes a destructive admin operation: the entries it
+// will affect and how to perform it. Dangerous mutations, such as season
+// resets and bulk deletions, need a second admin's approval when an
+// approval queue is configured.
+type adminMutation struct {
+	kind        string
+	description string
+	dangerous   bool
+	affected    []ScoreEntry
+	// apply performs the operation with the context of the request that
+	// runs it, which is the approver's for operations needing approval
+	apply func(ctx context.Context) (interface{}, error)
+}
+
+// DryRunResult reports what a destructive operation would do
+type DryRunResult struct {
+	DryRun   bool         `json:"dryRun"`
+	Affected int          `json:"affected"`
+	Sample   []ScoreEntry `json:"sample"`
+}
+
+// commit runs a destructive operation, or with ?dryRun=true only reports
+// the affected count and a sample of affected entries. Dangerous operations
+// are queued for a second admin instead of running immediately. Every
+// admin endpoint that changes a known set of entries goes through commit
+// so these rules behave the same everywhere. Backfills and blob collection
+// don't: a backfill only learns which entries it changes as it runs in the
+// background, reporting a dry run through its progress, and collection
+// removes files rather than entries, listing them on a dry run.
+func (h *AdminHandler) commit(w http.ResponseWriter, r *http.Request, m adminMutation) {
+	if r.URL.Query().Get("dryRun") == "true" {
+		writeDryRun(w, m.affected)
+		return
+	}
+
+	if m.dangerous && h.approvals != nil {
+		requestedBy := PrincipalFrom(r.Context()).Name
+		action := h.approvals.Propose(m.kind, m.description, len(m.affected), requestedBy, m.apply)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(action)
+		return
+	}
+
+	result, err := m.apply(r.Context())
+	writeMutationResult(w, r, result, err)
+}
+
+// audit appends a record of an admin mutation to the audit log, if one is
+// configured. The mutation has already happened, so a failure to record it
+// is logged rather than returned.
+func (h *AdminHandler) audit(record AuditRecord) {
+	if h.auditLog == nil {
+		return
+	}
+	if err := h.auditLog.Record(record); err != nil {
+		log.Printf("Warning: Could not write audit record for %s by %s: %v", record.Action, record.Actor, err)
+	}
+}
+
+// entryIDs returns the IDs of entries, in order
+func entryIDs(entries []ScoreEntry) []string {
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+	return ids
+}
+
+// writeDryRun reports the count and a sample of the entries a destructive
+// operation would affect
+func writeDryRun(w http.ResponseWriter, affected []ScoreEntry) {
+	sample := affected
+	if len(sample) > dryRunSampleSize {
+		sample = sample[:dryRunSampleSize]
+	}
+	json.NewEncoder(w).Encode(DryRunResult{
+		DryRun:   true,
+		Affected: len(affected),
+		Sample:   sample,
+	})
+}
+
+// writeMutationResult encodes the outcome of an applied mutation
+func writeMutationResult(w http.ResponseWriter, r *http.Request, result interface{}, err error) {
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// Rules handles GET /api/admin/rules
+func (h *AdminHandler) Rules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(h.rules.ListRules())
+}
+
+// AddRule handles POST /api/admin/rules
+func (h *AdminHandler) AddRule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Name       string `json:"name"`
+		Expression string `json:"expression"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" || req.Expression == "" {
+		httpError(w, r, "Rule name and expression are required", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := h.rules.AddRule(req.Name, req.Expression)
+	if err != nil {
+		httpError(w, r, "Invalid expression: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	go h.rules.SaveToFile(h.rulesFile)
+	h.audit(AuditRecord{Actor: PrincipalFrom(r.Context()).Name, Action: "add-rule", Target: rule.Name})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rule)
+}
+
+// DeleteRule handles DELETE /api/admin/rules/{name}
+func (h *AdminHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !h.rules.RemoveRule(name) {
+		httpError(w, r, "Rule not found", http.StatusNotFound)
+		return
+	}
+
+	go h.rules.SaveToFile(h.rulesFile)
+	h.audit(AuditRecord{Actor: PrincipalFrom(r.Context()).Name, Action: "delete-rule", Target: name})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// FlaggedEntries handles GET /api/admin/flagged
+func (h *AdminHandler) FlaggedEntries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	b, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(b.store.GetFlaggedEntries(r.Context()))
+}
+
+// PendingEntries handles GET /api/admin/queue
+func (h *AdminHandler) PendingEntries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	b, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(b.store.GetPendingEntries(r.Context()))
+}
+
+// SearchEntries handles GET /api/admin/entries, listing every entry,
+// including hidden ones, with its provenance. Entries can be filtered by
+// ?source= and ?excludeSource= (comma-separated creation sources),
+// ?credential= and ?player=.
+func (h *AdminHandler) SearchEntries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	b, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	filter := ProvenanceFilter{
+		Sources:        parseSourceList(query.Get("source")),
+		ExcludeSources: parseSourceList(query.Get("excludeSource")),
+		Credential:     query.Get("credential"),
+	}
+	player := query.Get("player")
+
+	entries := make([]ScoreEntry, 0)
+	for _, entry := range b.store.GetAllEntries(r.Context()) {
+		if player != "" && entry.PlayerName != player {
+			continue
+		}
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	json.NewEncoder(w).Encode(entries)
+}
+
+// EntryAction handles POST /api/admin/entries/{id}/{action} where action is
+// hide, unhide, approve, reject or restore. Restore unhides an entry like
+// unhide, but records that a deletion was undone.
+func (h *AdminHandler) EntryAction(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	b, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+	id, action := r.PathValue("id"), r.PathValue("action")
+	var update func(context.Context, string) (ScoreEntry, error)
+	switch action {
+	case "hide":
+		update = func(ctx context.Context, id string) (ScoreEntry, error) { return b.store.SetHidden(ctx, id, true) }
+	case "unhide", "restore":
+		update = func(ctx context.Context, id string) (ScoreEntry, error) { return b.store.SetHidden(ctx, id, false) }
+	case "approve":
+		update = b.store.ApproveEntry
+	case "reject":
+		update = b.store.RejectEntry
+	default:
+		httpError(w, r, "Not found", http.StatusNotFound)
+		return
+	}
+
+	entry, ok := b.store.GetEntry(r.Context(), id)
+	if !ok {
+		httpError(w, r, "Entry not found", http.StatusNotFound)
+		return
+	}
+
+	h.commit(w, r, adminMutation{
+		affected: []ScoreEntry{entry},
+		apply: func(ctx context.Context) (interface{}, error) {
+			if _, err := update(ctx, id); err != nil {
+				return nil, err
+			}
+			actor := PrincipalFrom(r.Context()).Name
+			updated, err := b.store.AppendProvenance(ctx, id, ProvenanceStep{
+				Source:     SourceAdmin,
+				Action:     action,
+				Credential: actor,
+			})
+			if err != nil {
+				return nil, err
+			}
+			h.audit(AuditRecord{Actor: actor, Action: action, Board: b.id, EntryIDs: []string{id}, Reason: r.URL.Query().Get("reason")})
+			b.store.SaveInBackground(b.file)
+			return updated, nil
+		},
+	})
+}
+
+// DeleteEntry handles DELETE /api/leaderboard/{id}, removing an entry such
+// as one with an offensive name or a cheated score. Entries are hidden
+// rather than erased, so a mistaken deletion can be undone with the
+// restore action; admins can pass ?permanent=true to erase one for good.
+// An optional ?reason= is kept in the audit log along with the entry.
+func (h *AdminHandler) DeleteEntry(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	b, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	permanent := r.URL.Query().Get("permanent") == "true"
+	entry, ok := b.store.GetEntry(r.Context(), id)
+	if !ok || (entry.Hidden && !permanent) {
+		httpError(w, r, "Entry not found", http.StatusNotFound)
+		return
+	}
+
+	principal := PrincipalFrom(r.Context())
+	if permanent && principal.Role < RoleAdmin {
+		httpError(w, r, "Forbidden: permanent deletion requires admin role", http.StatusForbidden)
+		return
+	}
+	reason := r.URL.Query().Get("reason")
+
+	action, remove := "delete", h.softDelete(b, principal.Name)
+	if permanent {
+		action, remove = "delete-permanent", b.store.DeleteEntry
+	}
+
+	h.commit(w, r, adminMutation{
+		kind:        action,
+		description: "Delete entry " + id,
+		affected:    []ScoreEntry{entry},
+		apply: func(ctx context.Context) (interface{}, error) {
+			deleted, err := remove(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			h.audit(AuditRecord{Actor: principal.Name, Action: action, Board: b.id, EntryIDs: []string{id}, Reason: reason, Entries: []ScoreEntry{deleted}})
+			b.store.SaveInBackground(b.file)
+			return deleted, nil
+		},
+	})
+}
+
+// softDelete returns a function that hides an entry, recording the
+// deletion in its provenance
+func (h *AdminHandler) softDelete(b *adminBoard, actor string) func(context.Context, string) (ScoreEntry, error) {
+	return func(ctx context.Context, id string) (ScoreEntry, error) {
+		if _, err := b.store.SetHidden(ctx, id, true); err != nil {
+			return ScoreEntry{}, err
+		}
+		return b.store.AppendProvenance(ctx, id, ProvenanceStep{Source: SourceAdmin, Action: "delete", Credential: actor})
+	}
+}
+
+// HiddenEntries handles GET /api/admin/hidden, listing hidden entries,
+// whether deleted, hidden by a moderator or rejected, most recently
+// hidden first, so they can be reviewed and restored
+func (h *AdminHandler) HiddenEntries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	b, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(b.store.GetHiddenEntries(r.Context()))
+}
+
+// ErasureRequest is the reply to a player erasure that has not been
+// confirmed. Repeating the request with ?confirm= set to the token erases
+// the entries.
+type ErasureRequest struct {
+	PlayerName        string `json:"playerName"`
+	Affected          int    `json:"affected"`
+	ConfirmationToken string `json:"confirmationToken"`
+}
+
+// ErasureResult reports a completed player erasure
+type ErasureResult struct {
+	PlayerName    string `json:"playerName"`
+	Deleted       int    `json:"deleted"`
+	ClaimReleased bool   `json:"claimReleased"`
+}
+
+// erasureToken confirms the erasure of exactly these entries of a player,
+// so a token no longer matches once the player's entries change
+func erasureToken(playerName string, entries []ScoreEntry) string {
+	hash := sha256.New()
+	hash.Write([]byte(playerName))
+	for _, entry := range entries {
+		hash.Write([]byte{0})
+		hash.Write([]byte(entry.ID))
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// DeletePlayer handles DELETE /api/admin/players/{name}, erasing every
+// entry of a player on every board, along with their name claim and friend
+// list, to honor a data removal request. The first request returns a
+// confirmation token naming the entries; the erasure runs when the request
+// is repeated with ?confirm={token}. An optional ?reason= is audited with
+// the entry IDs, but not the entries themselves, which would keep the
+// erased data. Season archives and snapshots are left as they were.
+func (h *AdminHandler) DeletePlayer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	name := r.PathValue("name")
+	var entries []ScoreEntry
+	for _, id := range h.boardIDs() {
+		entries = append(entries, h.boards[id].store.GetPlayerEntries(r.Context(), name)...)
+	}
+	if name == "" || len(entries) == 0 {
+		httpError(w, r, "Player not found", http.StatusNotFound)
+		return
+	}
+
+	token := erasureToken(name, entries)
+	confirm := r.URL.Query().Get("confirm")
+	if confirm == "" && r.URL.Query().Get("dryRun") != "true" {
+		json.NewEncoder(w).Encode(ErasureRequest{PlayerName: name, Affected: len(entries), ConfirmationToken: token})
+		return
+	}
+	if confirm != "" && confirm != token {
+		writeError(w, r, newKindError(ErrConflict, "the player's entries changed since the confirmation token was issued"))
+		return
+	}
+
+	actor := PrincipalFrom(r.Context()).Name
+	reason := r.URL.Query().Get("reason")
+
+	h.commit(w, r, adminMutation{
+		kind:        "erase-player",
+		description: "Erase every entry of player " + name,
+		dangerous:   true,
+		affected:    entries,
+		apply: func(ctx context.Context) (interface{}, error) {
+			result := ErasureResult{PlayerName: name}
+			for _, id := range h.boardIDs() {
+				b := h.boards[id]
+				deleted := b.store.DeletePlayer(ctx, name)
+				if len(deleted) == 0 {
+					continue
+				}
+				h.audit(AuditRecord{Actor: actor, Action: "erase-player", Board: b.id, EntryIDs: entryIDs(deleted), Reason: reason})
+				b.store.SaveInBackground(b.file)
+				result.Deleted += len(deleted)
+			}
+			if h.claims != nil {
+				released, err := h.claims.Release(name)
+				if err != nil {
+					return nil, err
+				}
+				result.ClaimReleased = released
+			}
+			if h.friends != nil {
+				if err := h.friends.Forget(name); err != nil {
+					return nil, err
+				}
+			}
+			return result, nil
+		},
+	})
+}
+
+// PurgeResult reports a completed bulk deletion
+type PurgeResult struct {
+	Deleted int `json:"deleted"`
+}
+
+// Purge handles DELETE /api/admin/purge, permanently removing every entry
+// matching ?player=, ?minScore=, ?maxScore=, ?since= and ?until=, such as
+// the runs of a cheating spree. Nothing is deleted without ?confirm=true;
+// until then the request only reports how many entries match, like a dry
+// run. An optional ?reason= is kept in the audit log along with the
+// deleted entries.
+func (h *AdminHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	b, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+	filter, err := parseEntryFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	matched := b.store.FindEntries(r.Context(), filter)
+	if r.URL.Query().Get("confirm") != "true" {
+		writeDryRun(w, matched)
+		return
+	}
+	ids := entryIDs(matched)
+
+	actor := PrincipalFrom(r.Context()).Name
+	reason := r.URL.Query().Get("reason")
+	conditions := r.URL.Query()
+	conditions.Del("confirm")
+	conditions.Del("reason")
+
+	h.commit(w, r, adminMutation{
+		kind:        "purge",
+		description: "Delete every entry matching " + conditions.Encode(),
+		dangerous:   true,
+		affected:    matched,
+		apply: func(ctx context.Context) (interface{}, error) {
+			// Only the entries that were confirmed, and approved if
+			// approvals are on, are deleted, even if others match by now
+			deleted := b.store.DeleteEntries(ctx, ids)
+			h.audit(AuditRecord{Actor: actor, Action: "purge", Board: b.id, EntryIDs: entryIDs(deleted), Reason: reason, Entries: deleted})
+			b.store.SaveInBackground(b.file)
+			return PurgeResult{Deleted: len(deleted)}, nil
+		},
+	})
+}
+
+// GetBackfill handles GET /api/admin/backfill, reporting progress of
+// the current or most recent backfill
+func (h *AdminHandler) GetBackfill(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	b, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(b.backfill.Progress())
+}
+
+// Backfill handles POST /api/admin/backfill, which starts a backfill.
+// ?dryRun=true computes against a copy without applying changes.
+func (h *AdminHandler) Backfill(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	b, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	if !b.backfill.Start(dryRun) {
+		httpError(w, r, "Backfill already running", http.StatusConflict)
+		return
+	}
+	if !dryRun {
+		h.audit(AuditRecord{Actor: PrincipalFrom(r.Context()).Name, Action: "backfill", Board: b.id})
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(b.backfill.Progress())
+}
+
+// GetBlobGC handles GET /api/admin/gc, reporting the most recent blob
+// collection and totals
+func (h *AdminHandler) GetBlobGC(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.blobGC == nil {
+		httpError(w, r, "Blob collection is disabled", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(h.blobGC.Status())
+}
+
+// BlobGC handles POST /api/admin/gc, removing replay and ghost files whose
+// entries no longer exist. ?dryRun=true only lists them.
+func (h *AdminHandler) BlobGC(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.blobGC == nil {
+		httpError(w, r, "Blob collection is disabled", http.StatusNotFound)
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	result := h.blobGC.Collect(h.boards[MainBoard].store.Now(), dryRun)
+	if !dryRun && len(result.Orphaned) > 0 {
+		h.audit(AuditRecord{Actor: PrincipalFrom(r.Context()).Name, Action: "gc"})
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetRetention handles GET /api/admin/retention, reporting the most
+// recent retention run and totals
+func (h *AdminHandler) GetRetention(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	b, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+	if b.retention == nil {
+		httpError(w, r, "Retention is disabled", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(b.retention.Status())
+}
+
+// Retention handles POST /api/admin/retention, removing the entries the
+// retention policy no longer keeps. It needs a second admin's approval
+// when approvals are enabled.
+func (h *AdminHandler) Retention(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	b, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+	if b.retention == nil {
+		httpError(w, r, "Retention is disabled", http.StatusNotFound)
+		return
+	}
+
+	actor := PrincipalFrom(r.Context()).Name
+	h.commit(w, r, adminMutation{
+		kind:        "retention",
+		description: "Remove the entries the retention policy no longer keeps from board " + b.id,
+		dangerous:   true,
+		affected:    b.retention.Prunable(b.store.Now()),
+		apply: func(ctx context.Context) (interface{}, error) {
+			// Entries are picked again when the prune runs, which may be
+			// after a second admin approves it
+			result := b.retention.Prune(b.store.Now(), false)
+			if len(result.Pruned) > 0 {
+				h.audit(AuditRecord{Actor: actor, Action: "retention", Board: b.id, EntryIDs: result.Pruned})
+			}
+			return result, nil
+		},
+	})
+}
+
+// Import handles POST /api/admin/import, merging in entries from another
+// deployment's leaderboard. The body is a JSON array of entries, a player
+// export, or CSV with a header row as written by the exports, chosen by
+// ?format=json|csv or else the Content-Type. Every entry is validated
+// first and nothing is imported if any is invalid. Entries already on the
+// board are skipped and reported as duplicates. ?dryRun=true reports what
+// would be imported without importing it. Imports need a second admin's
+// approval when approvals are enabled.
+func (h *AdminHandler) Import(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	b, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+			format = "csv"
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+	var entries []ScoreEntry
+	var rowErrors []ImportError
+	var err error
+	switch format {
+	case "json":
+		entries, err = parseImportJSON(body)
+	case "csv":
+		entries, rowErrors, err = parseImportCSV(body)
+	default:
+		httpError(w, r, "Format must be json or csv", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httpError(w, r, "Import file too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Rows that failed to parse are already reported, so only the others
+	// are validated
+	unparsed := make(map[int]bool, len(rowErrors))
+	for _, rowError := range rowErrors {
+		unparsed[rowError.Row] = true
+	}
+	actor := PrincipalFrom(r.Context()).Name
+	config := b.store.Config()
+	now := b.store.Now()
+	for i := range entries {
+		if unparsed[i+1] {
+			continue
+		}
+		entry, err := prepareImport(entries[i], config, h.names, actor, now)
+		if err != nil {
+			rowErrors = append(rowErrors, ImportError{Row: i + 1, Message: err.Error()})
+		}
+		entries[i] = entry
+	}
+	if len(rowErrors) > 0 {
+		slices.SortFunc(rowErrors, func(a, b ImportError) int { return a.Row - b.Row })
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ImportResult{Errors: rowErrors})
+		return
+	}
+
+	reason := r.URL.Query().Get("reason")
+	toAdd, _, _ := b.store.ImportEntries(r.Context(), entries, true)
+
+	h.commit(w, r, adminMutation{
+		kind:        "import",
+		description: fmt.Sprintf("Import %d entries into board %s", len(toAdd), b.id),
+		dangerous:   true,
+		affected:    toAdd,
+		apply: func(ctx context.Context) (interface{}, error) {
+			// Duplicates are found again when the import runs, which may be
+			// after a second admin approves it
+			added, duplicates, superseded := b.store.ImportEntries(ctx, entries, false)
+			result := ImportResult{Imported: len(added)}
+			for _, i := range duplicates {
+				result.Duplicates = append(result.Duplicates, i+1)
+			}
+			for _, i := range superseded {
+				result.Superseded = append(result.Superseded, i+1)
+			}
+			if len(added) > 0 {
+				result.EntryIDs = entryIDs(added)
+				h.audit(AuditRecord{Actor: actor, Action: "import", Board: b.id, EntryIDs: result.EntryIDs, Reason: reason})
+			}
+			b.store.SaveInBackground(b.file)
+			return result, nil
+		},
+	})
+}
+
+// Audit limits for GET /api/admin/audit
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// Audit handles GET /api/admin/audit, listing recorded admin actions
+// newest first. Records can be filtered by ?actor=, ?action=, ?entry= (an
+// affected entry ID), and ?since= and ?until= (RFC3339 times); ?limit=
+// defaults to 100 and is capped at 1000.
+func (h *AdminHandler) Audit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.auditLog == nil {
+		httpError(w, r, "Audit log is disabled", http.StatusNotFound)
+		return
+	}
+
+	query := AuditQuery{
+		Actor:   r.URL.Query().Get("actor"),
+		Action:  r.URL.Query().Get("action"),
+		EntryID: r.URL.Query().Get("entry"),
+		Limit:   defaultAuditLimit,
+	}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			httpError(w, r, "Limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		query.Limit = min(limit, maxAuditLimit)
+	}
+	var ok bool
+	if query.Since, ok = parseTimeParam(r, "since"); !ok {
+		httpError(w, r, "Invalid since: use an RFC3339 time", http.StatusBadRequest)
+		return
+	}
+	if query.Until, ok = parseTimeParam(r, "until"); !ok {
+		httpError(w, r, "Invalid until: use an RFC3339 time", http.StatusBadRequest)
+		return
+	}
+
+	records, err := h.auditLog.Query(query)
+	if err != nil {
+		log.Printf("Error: Could not read audit log: %v", err)
+		httpError(w, r, "Could not read audit log", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(records)
+}
+
+// Approvals handles GET /api/admin/approvals, listing dangerous actions
+// waiting for a second admin
+func (h *AdminHandler) Approvals(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.approvals == nil {
+		json.NewEncoder(w).Encode([]PendingAction{})
+		return
+	}
+	json.NewEncoder(w).Encode(h.approvals.List())
+}
+
+// ApproveAction handles POST /api/admin/approvals/{id}/approve, running a
+// pending action approved by a second admin
+func (h *AdminHandler) ApproveAction(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.approvals == nil {
+		httpError(w, r, "Two-person approval is disabled", http.StatusNotFound)
+		return
+	}
+
+	id := r.PathValue("id")
+	approver := PrincipalFrom(r.Context()).Name
+	result, err := h.approvals.Approve(r.Context(), id, approver)
+	if errors.Is(err, ErrSameApprover) {
+		httpError(w, r, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err == nil {
+		h.audit(AuditRecord{Actor: approver, Action: "approve-action", Target: id})
+	}
+	writeMutationResult(w, r, result, err)
+}
+
+// CancelAction handles DELETE /api/admin/approvals/{id}, dropping a pending
+// action
+func (h *AdminHandler) CancelAction(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.approvals == nil {
+		httpError(w, r, "Two-person approval is disabled", http.StatusNotFound)
+		return
+	}
+
+	id := r.PathValue("id")
+	if !h.approvals.Cancel(id) {
+		httpError(w, r, "Pending action not found", http.StatusNotFound)
+		return
+	}
+	h.audit(AuditRecord{Actor: PrincipalFrom(r.Context()).Name, Action: "cancel-action", Target: id})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Seasons handles GET /api/admin/seasons
+func (h *AdminHandler) Seasons(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.seasons == nil {
+		httpError(w, r, "Seasons are not enabled", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(h.seasons.List(h.boards[MainBoard].store.Now()))
+}
+
+// CreateSeason handles POST /api/admin/seasons, which schedules a new
+// season
+func (h *AdminHandler) CreateSeason(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.seasons == nil {
+		httpError(w, r, "Seasons are not enabled", http.StatusNotFound)
+		return
+	}
+
+	var season Season
+	if err := json.NewDecoder(r.Body).Decode(&season); err != nil {
+		httpError(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.seasons.Create(season)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.audit(AuditRecord{Actor: PrincipalFrom(r.Context()).Name, Action: "create-season", Target: created.ID})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// EndSeason handles POST /api/admin/seasons/{id}/end, which archives the
+// season's board and starts a fresh one ahead of the scheduled end
+func (h *AdminHandler) EndSeason(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.seasons == nil {
+		httpError(w, r, "Seasons are not enabled", http.StatusNotFound)
+		return
+	}
+
+	id := r.PathValue("id")
+
+	season, ok := h.seasons.Get(id)
+	if !ok {
+		writeError(w, r, ErrSeasonNotFound)
+		return
+	}
+	if season.state(h.boards[MainBoard].store.Now()) != SeasonActive {
+		writeError(w, r, newKindError(ErrConflict, "season "+id+" is not active"))
+		return
+	}
+
+	actor := PrincipalFrom(r.Context()).Name
+
+	h.commit(w, r, adminMutation{
+		kind:        "season-reset",
+		description: "End season " + id + " and reset the board",
+		dangerous:   true,
+		affected:    h.boards[MainBoard].store.GetAllEntries(r.Context()),
+		apply: func(ctx context.Context) (interface{}, error) {
+			ended, err := h.seasons.End(id, h.boards[MainBoard].store.Now())
+			if err == nil {
+				h.audit(AuditRecord{Actor: actor, Action: "season-reset", Target: id})
+			}
+			return ended, err
+		},
+	})
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test destructive admin operations support dry runs
+func TestAdminDryRun(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewAdminHandler(store, NewRuleSet(), "")
+	entry := store.AddScore(context.Background(), 500, "Rude")
+
+	req := routedRequest(t, "POST /api/admin/entries/{id}/{action}", "/api/admin/entries/"+entry.ID+"/hide?dryRun=true", nil)
+	w := httptest.NewRecorder()
+	handler.EntryAction(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var result DryRunResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !result.DryRun || result.Affected != 1 || len(result.Sample) != 1 || result.Sample[0].ID != entry.ID {
+		t.Errorf("Unexpected dry run result: %+v", result)
+	}
+
+	if len(store.GetTopScores(context.Background(), 0)) != 1 {
+		t.Errorf("Expected dry run to leave the entry visible")
+	}
+}
+
+// Test admin endpoints act on the board named by the path or ?board=,
+// defaulting to the main board
+func TestAdminBoards(t *testing.T) {
+	useTempSaveFiles(t)
+
+	store, speedrun := NewScoreStore(), NewScoreStore()
+	t.Cleanup(store.Close)
+	t.Cleanup(speedrun.Close)
+	handler := NewAdminHandler(store, NewRuleSet(), "", WithAdminBoard("speedrun", speedrun, nil))
+	entry := speedrun.AddScore(context.Background(), 500, "Rude")
+
+	tests := []struct {
+		name     string
+		pattern  string
+		target   string
+		wantCode int
+	}{
+		{"main board", "POST /api/admin/entries/{id}/{action}", "/api/admin/entries/" + entry.ID + "/hide", http.StatusNotFound},
+		{"unknown board", "POST /api/admin/entries/{id}/{action}", "/api/admin/entries/" + entry.ID + "/hide?board=missing", http.StatusNotFound},
+		{"board query", "POST /api/admin/entries/{id}/{action}", "/api/admin/entries/" + entry.ID + "/hide?board=speedrun", http.StatusOK},
+		{"board path", "DELETE /api/boards/{board}/leaderboard/{id}", "/api/boards/speedrun/leaderboard/" + entry.ID, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			speedrun.SetHidden(context.Background(), entry.ID, false)
+			req := routedRequest(t, tt.pattern, tt.target, nil)
+			w := httptest.NewRecorder()
+			if strings.HasPrefix(tt.pattern, "DELETE") {
+				handler.DeleteEntry(w, req)
+			} else {
+				handler.EntryAction(w, req)
+			}
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if hidden, _ := speedrun.GetEntry(context.Background(), entry.ID); tt.wantCode == http.StatusOK && !hidden.Hidden {
+				t.Error("Expected the speedrun entry to be hidden")
+			}
+		})
+	}
+}
+
+// Test moderators can delete entries, which hides them until restored,
+// only admins can delete permanently, and deletions are audited
+func TestDeleteEntry(t *testing.T) {
+	useTempSaveFiles(t)
+	store := NewScoreStore()
+	t.Cleanup(store.Close)
+	auditFile := filepath.Join(t.TempDir(), "audit.log")
+	handler := NewAdminHandler(store, NewRuleSet(), "", WithAuditLog(NewAuditLog(auditFile)))
+	auth := NewAuthenticator()
+	auth.AddNamedToken("alice", "mod-token", RoleModerator)
+	auth.AddNamedToken("root", "admin-token", RoleAdmin)
+	deleteEntry := auth.Require(RoleModerator, handler.DeleteEntry)
+
+	cheater := store.AddScore(context.Background(), 99999, "Cheater")
+	store.AddScore(context.Background(), 100, "Honest")
+
+	tests := []struct {
+		name        string
+		query       string
+		token       string
+		wantCode    int
+		wantEntries int
+		wantHidden  int
+	}{
+		{"anonymous", "", "", http.StatusUnauthorized, 2, 0},
+		{"moderator", "", "mod-token", http.StatusOK, 2, 1},
+		{"already deleted", "", "mod-token", http.StatusNotFound, 2, 1},
+		{"permanent by moderator", "&permanent=true", "mod-token", http.StatusForbidden, 2, 1},
+		{"permanent by admin", "&permanent=true", "admin-token", http.StatusOK, 1, 0},
+		{"already erased", "&permanent=true", "admin-token", http.StatusNotFound, 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := routedRequest(t, "DELETE /api/leaderboard/{id}", "/api/leaderboard/"+cheater.ID+"?reason=cheated"+tt.query, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			deleteEntry(w, req)
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if n := len(store.GetAllEntries(context.Background())); n != tt.wantEntries {
+				t.Errorf("Expected %d entries, got %d", tt.wantEntries, n)
+			}
+			if n := len(store.GetHiddenEntries(context.Background())); n != tt.wantHidden {
+				t.Errorf("Expected %d hidden entries, got %d", tt.wantHidden, n)
+			}
+		})
+	}
+
+	if scores := store.GetAllEntries(context.Background()); len(scores) != 1 || scores[0].PlayerName != "Honest" {
+		t.Errorf("Expected only the honest entry to remain, got %v", scores)
+	}
+
+	data, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatalf("Expected an audit log, got %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 audit records, got %d", len(lines))
+	}
+	for i, wantAction := range []string{"delete", "delete-permanent"} {
+		var record AuditRecord
+		json.Unmarshal([]byte(lines[i]), &record)
+		if record.Action != wantAction || record.Reason != "cheated" ||
+			len(record.Entries) != 1 || record.Entries[0].PlayerName != "Cheater" {
+			t.Errorf("Unexpected audit record: %+v", record)
+		}
+	}
+}
+
+// Test deleted entries are listed with other hidden entries and can be
+// restored to the board
+func TestRestoreEntry(t *testing.T) {
+	useTempSaveFiles(t)
+	store := NewScoreStore()
+	t.Cleanup(store.Close)
+	handler := NewAdminHandler(store, NewRuleSet(), "")
+	mistake := store.AddScore(context.Background(), 500, "Innocent")
+	rude := store.AddScore(context.Background(), 300, "Rude")
+	store.SetHidden(context.Background(), rude.ID, true)
+
+	w := httptest.NewRecorder()
+	handler.DeleteEntry(w, routedRequest(t, "DELETE /api/leaderboard/{id}", "/api/leaderboard/"+mistake.ID, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if board := store.QueryScores(context.Background(), ScoreQuery{}); len(board) != 0 {
+		t.Fatalf("Expected deleted entries off the board, got %v", board)
+	}
+
+	w = httptest.NewRecorder()
+	handler.HiddenEntries(w, httptest.NewRequest("GET", "/api/admin/hidden", nil))
+	var hidden []ScoreEntry
+	json.NewDecoder(w.Body).Decode(&hidden)
+	if len(hidden) != 2 || hidden[0].ID != mistake.ID || hidden[1].ID != rude.ID {
+		t.Fatalf("Expected the deleted entry listed before the older hidden one, got %v", hidden)
+	}
+
+	w = httptest.NewRecorder()
+	handler.EntryAction(w, routedRequest(t, "POST /api/admin/entries/{id}/{action}", "/api/admin/entries/"+mistake.ID+"/restore", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	board := store.QueryScores(context.Background(), ScoreQuery{})
+	if len(board) != 1 || board[0].ID != mistake.ID {
+		t.Fatalf("Expected the restored entry back on the board, got %v", board)
+	}
+	restored, _ := store.GetEntry(context.Background(), mistake.ID)
+	if steps := restored.Provenance; len(steps) != 3 || steps[1].Action != "delete" || steps[2].Action != "restore" {
+		t.Errorf("Expected the deletion and restore in the provenance, got %+v", steps)
+	}
+}
+
+// Test erasing a player takes a confirmation token that names their
+// current entries on every board, drops their claim and friend lists, and
+// is audited without the erased data
+func TestDeletePlayer(t *testing.T) {
+	useTempSaveFiles(t)
+	store, weekly := NewScoreStore(), NewScoreStore()
+	t.Cleanup(store.Close)
+	t.Cleanup(weekly.Close)
+	dir := t.TempDir()
+	auditFile := filepath.Join(dir, "audit.log")
+	claims := NewNameClaims(filepath.Join(dir, "claims.json"))
+	friends := NewFriends(filepath.Join(dir, "friends.json"))
+	handler := NewAdminHandler(store, NewRuleSet(), "", WithAuditLog(NewAuditLog(auditFile)),
+		WithAdminBoard("weekly", weekly, nil), WithPlayerAccounts(claims, friends))
+
+	store.AddScore(context.Background(), 500, "Leaver")
+	store.AddEntry(context.Background(), ScoreEntry{Score: 900, PlayerName: "Leaver", Hidden: true})
+	store.AddScore(context.Background(), 100, "Stayer")
+	weekly.AddScore(context.Background(), 300, "Leaver")
+	if _, err := claims.Claim("Leaver"); err != nil {
+		t.Fatalf("Failed to claim name: %v", err)
+	}
+	friends.Add("Leaver", "Stayer")
+	friends.Add("Stayer", "Leaver")
+
+	erase := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.DeletePlayer(w, routedRequest(t, "DELETE /api/admin/players/{name}", "/api/admin/players/Leaver"+query, nil))
+		return w
+	}
+
+	w := erase("")
+	var request ErasureRequest
+	json.NewDecoder(w.Body).Decode(&request)
+	if w.Code != http.StatusOK || request.Affected != 3 || request.ConfirmationToken == "" {
+		t.Fatalf("Expected a confirmation token for 3 entries, got %d %+v", w.Code, request)
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		wantCode    int
+		wantEntries int
+	}{
+		{"dry run", "?dryRun=true", http.StatusOK, 4},
+		{"wrong token", "?confirm=nope", http.StatusConflict, 4},
+		{"confirmed", "?confirm=" + request.ConfirmationToken + "&reason=gdpr", http.StatusOK, 1},
+		{"already erased", "?confirm=" + request.ConfirmationToken, http.StatusNotFound, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := erase(tt.query); w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			n := len(store.GetAllEntries(context.Background())) + len(weekly.GetAllEntries(context.Background()))
+			if n != tt.wantEntries {
+				t.Errorf("Expected %d entries left, got %d", tt.wantEntries, n)
+			}
+		})
+	}
+
+	if err := claims.Authorize("Leaver", ""); err != nil {
+		t.Errorf("Expected the name claim to be released, got %v", err)
+	}
+	if len(friends.List("Leaver")) != 0 || len(friends.List("Stayer")) != 0 {
+		t.Errorf("Expected the friend lists to forget Leaver, got %v and %v", friends.List("Leaver"), friends.List("Stayer"))
+	}
+
+	data, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatalf("Expected an audit log, got %v", err)
+	}
+	boards := map[string]int{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record AuditRecord
+		json.Unmarshal([]byte(line), &record)
+		if record.Action != "erase-player" || record.Reason != "gdpr" || len(record.Entries) != 0 {
+			t.Errorf("Unexpected audit record: %+v", record)
+		}
+		boards[record.Board] = len(record.EntryIDs)
+	}
+	if boards[MainBoard] != 2 || boards["weekly"] != 1 {
+		t.Errorf("Expected the erased entry IDs of each board, got %v", boards)
+	}
+	if strings.Contains(string(data), "Leaver") {
+		t.Error("Expected the audit log not to keep the erased player's name")
+	}
+}
+
+// Test bulk deletion by filters only deletes once confirmed
+func TestPurge(t *testing.T) {
+	useTempSaveFiles(t)
+	store := NewScoreStore()
+	t.Cleanup(store.Close)
+	handler := NewAdminHandler(store, NewRuleSet(), "")
+
+	store.AddScore(context.Background(), 99999, "Cheater")
+	store.AddScore(context.Background(), 88888, "Cheater")
+	store.AddScore(context.Background(), 500, "Cheater")
+	store.AddScore(context.Background(), 99999, "Honest")
+
+	tests := []struct {
+		name         string
+		query        string
+		wantCode     int
+		wantAffected int
+		wantEntries  int
+	}{
+		{"no filters", "?confirm=true", http.StatusBadRequest, 0, 4},
+		{"invalid score", "?minScore=lots", http.StatusBadRequest, 0, 4},
+		{"inverted range", "?minScore=10&maxScore=5", http.StatusBadRequest, 0, 4},
+		{"unconfirmed", "?player=Cheater&minScore=10000", http.StatusOK, 2, 4},
+		{"dry run", "?player=Cheater&minScore=10000&confirm=true&dryRun=true", http.StatusOK, 2, 4},
+		{"confirmed", "?player=Cheater&minScore=10000&confirm=true", http.StatusOK, 0, 2},
+		{"future range", "?since=2999-01-01T00:00:00Z&confirm=true", http.StatusOK, 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.Purge(w, httptest.NewRequest("DELETE", "/api/admin/purge"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			var result DryRunResult
+			json.NewDecoder(w.Body).Decode(&result)
+			if result.Affected != tt.wantAffected {
+				t.Errorf("Expected %d affected, got %d", tt.wantAffected, result.Affected)
+			}
+			if n := len(store.GetAllEntries(context.Background())); n != tt.wantEntries {
+				t.Errorf("Expected %d entries left, got %d", tt.wantEntries, n)
+			}
+		})
+	}
+
+	for _, entry := range store.GetAllEntries(context.Background()) {
+		if entry.Score == 88888 || (entry.PlayerName == "Cheater" && entry.Score != 500) {
+			t.Errorf("Expected the cheated runs to be deleted, found %+v", entry)
+		}
+	}
+}
+
+// Test an approved purge deletes the entries that matched when it was
+// confirmed, not those that match when it is approved
+func TestPurgeDeletesConfirmedEntries(t *testing.T) {
+	useTempSaveFiles(t)
+	store := NewScoreStore()
+	t.Cleanup(store.Close)
+	queue := NewApprovalQueue(time.Minute, SystemClock, IDGeneratorFunc(newUUID))
+	handler := NewAdminHandler(store, NewRuleSet(), "", WithApprovals(queue))
+	cheated := store.AddScore(context.Background(), 99999, "Cheater")
+
+	req := httptest.NewRequest("DELETE", "/api/admin/purge?player=Cheater&confirm=true", nil)
+	req = req.WithContext(context.WithValue(req.Context(), principalKey{}, Principal{Name: "alice", Role: RoleAdmin}))
+	w := httptest.NewRecorder()
+	handler.Purge(w, req)
+	if w.Code != http.StatusAccepted || len(queue.List()) != 1 {
+		t.Fatalf("Expected the purge to wait for approval, got %d: %s", w.Code, w.Body.String())
+	}
+
+	later := store.AddScore(context.Background(), 500, "Cheater")
+	if _, err := queue.Approve(context.Background(), queue.List()[0].ID, "bob"); err != nil {
+		t.Fatalf("Expected the purge to be approved, got %v", err)
+	}
+	if _, ok := store.GetEntry(context.Background(), cheated.ID); ok {
+		t.Error("Expected the confirmed entry to be deleted")
+	}
+	if _, ok := store.GetEntry(context.Background(), later.ID); !ok {
+		t.Error("Expected the entry added after confirmation to be kept")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Anomaly actions
+const (
+	// AnomalyActionMark stores outliers with suspect:true
+	AnomalyActionMark = "mark"
+	// AnomalyActionQuarantine additionally holds outliers in the moderation queue
+	AnomalyActionQuarantine = "quarantine"
+)
+
+// AnomalyConfig configures statistical outlier detection on submissions
+type AnomalyConfig struct {
+	// Window is the number of most recent scores the new score is compared to
+	Window int `json:"window,omitempty"`
+	// MinSamples is the smallest window that detection is attempted on
+	MinSamples int `json:"minSamples,omitempty"`
+	// ZScore flags scores more than this many standard deviations above the
+	// window mean; zero disables the check
+	ZScore float64 `json:"zScore,omitempty"`
+	// PercentileJump flags scores above this multiple of the window's 99th
+	// percentile; zero disables the check
+	PercentileJump float64 `json:"percentileJump,omitempty"`
+	// Action is "mark" (default) or "quarantine"
+	Action string `json:"action,omitempty"`
+}
+
+// Enabled reports whether any anomaly check is configured
+func (c AnomalyConfig) Enabled() bool {
+	return c.ZScore > 0 || c.PercentileJump > 0
+}
+
+// DetectAnomaly compares a score against recent scores and returns a reason
+// if it is an outlier, or an empty string otherwise
+func DetectAnomaly(recent []int, score int, config AnomalyConfig) string {
+	minSamples := config.MinSamples
+	if minSamples <= 0 {
+		minSamples = 30
+	}
+	if !config.Enabled() || len(recent) < minSamples {
+		return ""
+	}
+
+	if config.ZScore > 0 {
+		var sum float64
+		for _, v := range recent {
+			sum += float64(v)
+		}
+		mean := sum / float64(len(recent))
+
+		var variance float64
+		for _, v := range recent {
+			variance += (float64(v) - mean) * (float64(v) - mean)
+		}
+		stddev := math.Sqrt(variance / float64(len(recent)))
+
+		if stddev > 0 {
+			if z := (float64(score) - mean) / stddev; z > config.ZScore {
+				return fmt.Sprintf("z-score %.1f exceeds %.1f", z, config.ZScore)
+			}
+		}
+	}
+
+	if config.PercentileJump > 0 {
+		sorted := make([]int, len(recent))
+		copy(sorted, recent)
+		sort.Ints(sorted)
+
+		p99 := percentileOf(sorted, 99)
+		if p99 > 0 && float64(score) > config.PercentileJump*float64(p99) {
+			return fmt.Sprintf("score is %.1fx the recent p99 of %d", float64(score)/float64(p99), p99)
+		}
+	}
+
+	return ""
+}
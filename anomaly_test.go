@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// Test outlier detection by z-score and percentile jump
+func TestDetectAnomaly(t *testing.T) {
+	recent := make([]int, 100)
+	for i := range recent {
+		recent[i] = 1000 + (i%10)*10
+	}
+
+	tests := []struct {
+		name    string
+		config  AnomalyConfig
+		recent  []int
+		score   int
+		flagged bool
+	}{
+		{"typical score", AnomalyConfig{ZScore: 4}, recent, 1050, false},
+		{"z-score outlier", AnomalyConfig{ZScore: 4}, recent, 5000, true},
+		{"percentile jump", AnomalyConfig{PercentileJump: 3}, recent, 4000, true},
+		{"below percentile jump", AnomalyConfig{PercentileJump: 3}, recent, 2000, false},
+		{"too few samples", AnomalyConfig{ZScore: 4}, recent[:10], 5000, false},
+		{"disabled", AnomalyConfig{}, recent, 999999, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := DetectAnomaly(tt.recent, tt.score, tt.config)
+			if (reason != "") != tt.flagged {
+				t.Errorf("Expected flagged=%v, got reason %q", tt.flagged, reason)
+			}
+		})
+	}
+}
+
+// Test quarantined outliers are excluded from the leaderboard
+func TestSubmitScoreAnomalyQuarantine(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{Anomaly: AnomalyConfig{ZScore: 4, Action: AnomalyActionQuarantine}})
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+	t.Cleanup(store.Close)
+
+	for i := 0; i < 50; i++ {
+		store.AddScore(context.Background(), 1000+i, "Regular")
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"score": 100000, "playerName": "Outlier"})
+	req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.SubmitScore(w, req)
+
+	var entry ScoreEntry
+	if err := json.NewDecoder(w.Body).Decode(&entry); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !entry.Suspect || !entry.Pending {
+		t.Errorf("Expected suspect pending entry, got %+v", entry)
+	}
+	if top := store.GetTopScores(context.Background(), 1); top[0].PlayerName == "Outlier" {
+		t.Errorf("Expected quarantined entry to be excluded")
+	}
+}
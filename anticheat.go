@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Verification states for entries reviewed by the anti-cheat service
+const (
+	VerificationPending  = "pending"
+	VerificationVerified = "verified"
+	VerificationRejected = "rejected"
+	VerificationReview   = "review"
+)
+
+// AntiCheatConfig configures the external anti-cheat integration
+type AntiCheatConfig struct {
+	// URL receives a POST for every flagged submission; empty disables it
+	URL            string `json:"url,omitempty"`
+	APIKey         string `json:"apiKey,omitempty"`
+	TimeoutSeconds int    `json:"timeoutSeconds,omitempty"`
+	// PublicURL is this server's address as the service reaches it, such
+	// as "https://kiro.example.com", which replay links are relative to
+	PublicURL string `json:"publicUrl,omitempty"`
+}
+
+// antiCheatReplayTTL is how long replay links sent to the anti-cheat
+// service stay valid, long enough for retries and a queue of reviews
+const antiCheatReplayTTL = 24 * time.Hour
+
+// antiCheatReport is the payload sent to the anti-cheat service
+type antiCheatReport struct {
+	Entry ScoreEntry `json:"entry"`
+	// ReplayURL is a signed link to download the entry's replay, set when
+	// it has one
+	ReplayURL       string     `json:"replayUrl,omitempty"`
+	ReplayExpiresAt *time.Time `json:"replayExpiresAt,omitempty"`
+}
+
+// antiCheatVerdict is the response expected from the anti-cheat service
+type antiCheatVerdict struct {
+	Verdict string `json:"verdict"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// AntiCheatClient forwards flagged and suspect submissions to an external
+// anti-cheat service and applies its verdicts to entries in the background.
+// Entries it cannot get a verdict for are left for a moderator to review.
+type AntiCheatClient struct {
+	config   AntiCheatConfig
+	store    LeaderboardStore
+	replays  *ReplayStore
+	file     string
+	client   *http.Client
+	queue    chan ScoreEntry
+	attempts int
+	backoff  time.Duration
+}
+
+// NewAntiCheatClient creates a client for the configured service that
+// updates entries on store, saving it to filename. Call Start to begin
+// processing submitted entries.
+func NewAntiCheatClient(config AntiCheatConfig, store LeaderboardStore, filename string) *AntiCheatClient {
+	timeout := time.Duration(config.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &AntiCheatClient{
+		config:   config,
+		store:    store,
+		file:     filename,
+		client:   &http.Client{Timeout: timeout},
+		queue:    make(chan ScoreEntry, 100),
+		attempts: 3,
+		backoff:  time.Second,
+	}
+}
+
+// SetReplays lets reports link to the replays of entries in replays
+func (c *AntiCheatClient) SetReplays(replays *ReplayStore) {
+	c.replays = replays
+}
+
+// Start launches the background worker that reports queued entries
+func (c *AntiCheatClient) Start() {
+	go func() {
+		for entry := range c.queue {
+			c.process(entry)
+		}
+	}()
+}
+
+// Submit queues an entry for review without blocking. If the queue is
+// full the entry is left for a moderator to review instead.
+func (c *AntiCheatClient) Submit(entry ScoreEntry) {
+	select {
+	case c.queue <- entry:
+	default:
+		log.Printf("Warning: anti-cheat queue full, entry %s left for moderator review", entry.ID)
+		c.settle(entry, VerificationReview)
+	}
+}
+
+// process reports an entry, retrying with exponential backoff, and stores
+// the resulting verification state. An entry the service never answers
+// for is moved to the moderation queue rather than left pending.
+func (c *AntiCheatClient) process(entry ScoreEntry) {
+	backoff := c.backoff
+	for attempt := 1; attempt <= c.attempts; attempt++ {
+		verdict, err := c.report(entry)
+		if err == nil {
+			c.settle(entry, verificationForVerdict(verdict.Verdict))
+			return
+		}
+
+		log.Printf("Warning: anti-cheat report for %s failed (attempt %d/%d): %v", entry.ID, attempt, c.attempts, err)
+		if attempt < c.attempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	log.Printf("Warning: no anti-cheat verdict for %s, left for moderator review", entry.ID)
+	c.settle(entry, VerificationReview)
+}
+
+// settle stores an entry's verification state and saves the board
+func (c *AntiCheatClient) settle(entry ScoreEntry, state string) {
+	if _, err := c.store.SetVerification(context.Background(), entry.ID, state); err == nil {
+		c.store.SaveInBackground(c.file)
+	}
+}
+
+// report sends a single report and decodes the verdict
+func (c *AntiCheatClient) report(entry ScoreEntry) (antiCheatVerdict, error) {
+	var verdict antiCheatVerdict
+
+	report := antiCheatReport{Entry: entry}
+	if entry.HasReplay && c.replays != nil {
+		link, expiresAt := c.replays.signedURL(entry.ID, c.store.Now(), antiCheatReplayTTL)
+		report.ReplayURL = strings.TrimSuffix(c.config.PublicURL, "/") + link
+		report.ReplayExpiresAt = &expiresAt
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return verdict, err
+	}
+
+	req, err := http.NewRequest("POST", c.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return verdict, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return verdict, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return verdict, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return verdict, err
+	}
+	return verdict, nil
+}
+
+// verificationForVerdict maps an anti-cheat verdict to a verification state.
+// Anything other than a clear verdict is left for a moderator to review.
+func verificationForVerdict(verdict string) string {
+	switch verdict {
+	case "clean":
+		return VerificationVerified
+	case "cheat":
+		return VerificationRejected
+	}
+	return VerificationReview
+}
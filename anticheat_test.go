@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test anti-cheat verdicts are applied to the entry's verification state
+func TestAntiCheatVerdicts(t *testing.T) {
+	tests := []struct {
+		verdict   string
+		wantState string
+		wantShown bool
+	}{
+		{"clean", VerificationVerified, true},
+		{"cheat", VerificationRejected, false},
+		{"unsure", VerificationReview, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.verdict, func(t *testing.T) {
+			var received antiCheatReport
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer key" {
+					t.Errorf("Expected API key to be sent")
+				}
+				json.NewDecoder(r.Body).Decode(&received)
+				json.NewEncoder(w).Encode(antiCheatVerdict{Verdict: tt.verdict})
+			}))
+			defer server.Close()
+
+			store := NewScoreStore()
+			client := NewAntiCheatClient(AntiCheatConfig{URL: server.URL, APIKey: "key"}, store, filepath.Join(t.TempDir(), "leaderboard.json"))
+			entry := store.AddEntry(context.Background(), ScoreEntry{Score: 99999, PlayerName: "Sus", Flags: []string{"too-good"}, Verification: VerificationPending})
+
+			client.process(entry)
+
+			if received.Entry.ID != entry.ID {
+				t.Errorf("Expected report for %s, got %s", entry.ID, received.Entry.ID)
+			}
+
+			scores := store.GetTopScores(context.Background(), 0)
+			if shown := len(scores) == 1; shown != tt.wantShown {
+				t.Fatalf("Expected shown=%v, got %d entries", tt.wantShown, len(scores))
+			}
+			if tt.wantShown && scores[0].Verification != tt.wantState {
+				t.Errorf("Expected state %s, got %s", tt.wantState, scores[0].Verification)
+			}
+		})
+	}
+}
+
+// Test reports of runs with a replay link to it for long enough to review,
+// by the board's clock
+func TestAntiCheatReplayLink(t *testing.T) {
+	var received antiCheatReport
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		json.NewEncoder(w).Encode(antiCheatVerdict{Verdict: "clean"})
+	}))
+	defer server.Close()
+
+	now := time.Now().AddDate(0, 1, 0)
+	store := NewScoreStore(WithClock(ClockFunc(func() time.Time { return now })))
+	replays := NewReplayStore(ReplayConfig{Dir: t.TempDir()})
+	client := NewAntiCheatClient(AntiCheatConfig{URL: server.URL, PublicURL: "https://kiro.example.com/"}, store, filepath.Join(t.TempDir(), "leaderboard.json"))
+	client.SetReplays(replays)
+
+	entry := store.AddEntry(context.Background(), ScoreEntry{Score: 100, PlayerName: "Replayed", HasReplay: true, Verification: VerificationPending})
+	client.process(entry)
+
+	prefix := "https://kiro.example.com/api/leaderboard/" + entry.ID + "/replay?"
+	if !strings.HasPrefix(received.ReplayURL, prefix) || received.ReplayExpiresAt == nil {
+		t.Fatalf("Expected a replay link under %s, got %q", prefix, received.ReplayURL)
+	}
+	query, _ := url.ParseQuery(strings.TrimPrefix(received.ReplayURL, prefix))
+	if !replays.CheckSignature(entry.ID, query.Get("expires"), query.Get("sig"), now.Add(12*time.Hour)) {
+		t.Error("Expected the link to stay valid for hours")
+	}
+
+	// Runs without a replay get no link
+	received = antiCheatReport{}
+	client.process(store.AddEntry(context.Background(), ScoreEntry{Score: 100, PlayerName: "Plain", Verification: VerificationPending}))
+	if received.ReplayURL != "" {
+		t.Errorf("Expected no replay link, got %q", received.ReplayURL)
+	}
+}
+
+// Test failed reports are retried
+func TestAntiCheatRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(antiCheatVerdict{Verdict: "clean"})
+	}))
+	defer server.Close()
+
+	store := NewScoreStore()
+	client := NewAntiCheatClient(AntiCheatConfig{URL: server.URL}, store, filepath.Join(t.TempDir(), "leaderboard.json"))
+	client.backoff = 0
+	entry := store.AddEntry(context.Background(), ScoreEntry{Score: 100, PlayerName: "Retry", Verification: VerificationPending})
+
+	client.process(entry)
+
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+	if got := store.GetTopScores(context.Background(), 0)[0].Verification; got != VerificationVerified {
+		t.Errorf("Expected state verified, got %s", got)
+	}
+}
+
+// Test entries without a verdict after the last attempt go to moderators
+func TestAntiCheatGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	store := NewScoreStore()
+	client := NewAntiCheatClient(AntiCheatConfig{URL: server.URL}, store, filepath.Join(t.TempDir(), "leaderboard.json"))
+	client.backoff = 0
+	entry := store.AddEntry(context.Background(), ScoreEntry{Score: 100, PlayerName: "Lost", Verification: VerificationPending})
+
+	client.process(entry)
+
+	flagged := store.GetFlaggedEntries(context.Background())
+	if len(flagged) != 1 || flagged[0].Verification != VerificationReview {
+		t.Errorf("Expected the entry in the moderation queue for review, got %+v", flagged)
+	}
+}
+
+// Test statistical outliers are sent to the anti-cheat service
+func TestSubmitSuspectForwarded(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{Anomaly: AnomalyConfig{PercentileJump: 2, MinSamples: 5}})
+	for i := 0; i < 5; i++ {
+		store.AddScore(context.Background(), 100, "Regular")
+	}
+
+	file := filepath.Join(t.TempDir(), "leaderboard.json")
+	client := NewAntiCheatClient(AntiCheatConfig{URL: "http://anticheat.invalid"}, store, file)
+	handler := NewLeaderboardHandler(store, WithAntiCheat(client), WithSaveFile(file))
+	t.Cleanup(store.Close)
+
+	w := httptest.NewRecorder()
+	handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", strings.NewReader(`{"playerName":"Outlier","score":10000}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", w.Code)
+	}
+
+	select {
+	case entry := <-client.queue:
+		if !entry.Suspect || entry.Verification != VerificationPending {
+			t.Errorf("Expected a suspect entry awaiting a verdict, got %+v", entry)
+		}
+	default:
+		t.Error("Expected the suspect entry to be queued for the anti-cheat service")
+	}
+}
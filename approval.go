@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Errors returned when approving pending actions
+var (
+	ErrApprovalNotFound = newKindError(ErrNotFound, "pending action not found")
+	ErrApprovalExpired  = newKindError(ErrNotFound, "pending action expired")
+	ErrSameApprover     = errors.New("action must be approved by a different admin")
+)
+
+// PendingAction is a dangerous admin action waiting for a second admin
+type PendingAction struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"`
+	Description string    `json:"description"`
+	Affected    int       `json:"affected"`
+	RequestedBy string    `json:"requestedBy"`
+	RequestedAt time.Time `json:"requestedAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	execute     func(ctx context.Context) (interface{}, error)
+}
+
+// ApprovalQueue holds dangerous admin actions until a second admin
+// confirms them within the approval window
+type ApprovalQueue struct {
+	window  time.Duration
+	clock   Clock
+	ids     IDGenerator
+	pending map[string]PendingAction
+	mu      sync.Mutex
+}
+
+// NewApprovalQueue creates an ApprovalQueue whose actions expire after
+// window, as told by clock, and are numbered by ids
+func NewApprovalQueue(window time.Duration, clock Clock, ids IDGenerator) *ApprovalQueue {
+	return &ApprovalQueue{
+		window:  window,
+		clock:   clock,
+		ids:     ids,
+		pending: make(map[string]PendingAction),
+	}
+}
+
+// Propose records an action requested by one admin. It runs only once a
+// different admin approves it.
+func (q *ApprovalQueue) Propose(kind, description string, affected int, requestedBy string, execute func(ctx context.Context) (interface{}, error)) PendingAction {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.clock.Now()
+	action := PendingAction{
+		ID:          q.ids.NewID(),
+		Kind:        kind,
+		Description: description,
+		Affected:    affected,
+		RequestedBy: requestedBy,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(q.window),
+		execute:     execute,
+	}
+	q.pending[action.ID] = action
+	return action
+}
+
+// Approve runs a pending action with ctx on behalf of a second admin and
+// removes it from the queue
+func (q *ApprovalQueue) Approve(ctx context.Context, id, approvedBy string) (interface{}, error) {
+	q.mu.Lock()
+	action, ok := q.pending[id]
+	if !ok {
+		q.mu.Unlock()
+		return nil, ErrApprovalNotFound
+	}
+	if q.clock.Now().After(action.ExpiresAt) {
+		delete(q.pending, id)
+		q.mu.Unlock()
+		return nil, ErrApprovalExpired
+	}
+	if action.RequestedBy == approvedBy {
+		q.mu.Unlock()
+		return nil, ErrSameApprover
+	}
+	delete(q.pending, id)
+	q.mu.Unlock()
+
+	return action.execute(ctx)
+}
+
+// Cancel removes a pending action, returning false if it did not exist
+func (q *ApprovalQueue) Cancel(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.pending[id]; !ok {
+		return false
+	}
+	delete(q.pending, id)
+	return true
+}
+
+// List returns unexpired pending actions, oldest first, dropping expired ones
+func (q *ApprovalQueue) List() []PendingAction {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.clock.Now()
+	actions := make([]PendingAction, 0, len(q.pending))
+	for id, action := range q.pending {
+		if now.After(action.ExpiresAt) {
+			delete(q.pending, id)
+			continue
+		}
+		actions = append(actions, action)
+	}
+
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].RequestedAt.Before(actions[j].RequestedAt)
+	})
+	return actions
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test dangerous actions need a different admin within the window
+func TestApprovalQueue(t *testing.T) {
+	now := time.Now()
+	next := 0
+	ids := IDGeneratorFunc(func() string { next++; return fmt.Sprintf("action-%d", next) })
+	queue := NewApprovalQueue(time.Minute, ClockFunc(func() time.Time { return now }), ids)
+	ran := false
+	action := queue.Propose("reset", "Reset the board", 3, "alice", func(ctx context.Context) (interface{}, error) {
+		ran = true
+		return "done", nil
+	})
+
+	if action.ID != "action-1" {
+		t.Errorf("Expected the action ID from the queue's generator, got %q", action.ID)
+	}
+	if len(queue.List()) != 1 {
+		t.Fatalf("Expected 1 pending action, got %d", len(queue.List()))
+	}
+
+	if _, err := queue.Approve(context.Background(), action.ID, "alice"); err != ErrSameApprover {
+		t.Errorf("Expected ErrSameApprover, got %v", err)
+	}
+	if ran {
+		t.Fatal("Expected action not to run without a second admin")
+	}
+
+	result, err := queue.Approve(context.Background(), action.ID, "bob")
+	if err != nil || result != "done" || !ran {
+		t.Errorf("Expected action to run, got %v, %v", result, err)
+	}
+
+	if _, err := queue.Approve(context.Background(), action.ID, "bob"); err != ErrApprovalNotFound {
+		t.Errorf("Expected ErrApprovalNotFound, got %v", err)
+	}
+
+	// Actions expire once the window passes on the queue's clock
+	action = queue.Propose("reset", "Reset the board", 0, "alice", nil)
+	now = now.Add(time.Minute + time.Second)
+	if _, err := queue.Approve(context.Background(), action.ID, "bob"); err != ErrApprovalExpired {
+		t.Errorf("Expected ErrApprovalExpired, got %v", err)
+	}
+}
+
+// Test dangerous mutations are queued and run after a second approval
+func TestAdminDangerousMutation(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewAdminHandler(store, NewRuleSet(), "", WithApprovals(NewApprovalQueue(time.Minute, SystemClock, IDGeneratorFunc(newUUID))))
+	auth := NewAuthenticator()
+	auth.AddNamedToken("alice", "alice-token", RoleAdmin)
+	auth.AddNamedToken("bob", "bob-token", RoleAdmin)
+
+	ran := false
+	propose := auth.Require(RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+		handler.commit(w, r, adminMutation{
+			kind:        "test",
+			description: "Dangerous test action",
+			dangerous:   true,
+			apply: func(ctx context.Context) (interface{}, error) {
+				ran = true
+				return map[string]int{"deleted": 0}, nil
+			},
+		})
+	})
+
+	req := httptest.NewRequest("POST", "/api/admin/test", nil)
+	req.Header.Set("Authorization", "Bearer alice-token")
+	w := httptest.NewRecorder()
+	propose(w, req)
+
+	if w.Code != http.StatusAccepted || ran {
+		t.Fatalf("Expected action to be queued with 202, got %d (ran=%v)", w.Code, ran)
+	}
+
+	var action PendingAction
+	json.NewDecoder(w.Body).Decode(&action)
+	if action.RequestedBy != "alice" {
+		t.Errorf("Expected requester alice, got %s", action.RequestedBy)
+	}
+
+	approve := auth.Require(RoleAdmin, handler.ApproveAction)
+	for _, tt := range []struct {
+		token    string
+		wantCode int
+	}{
+		{"alice-token", http.StatusForbidden},
+		{"bob-token", http.StatusOK},
+	} {
+		req = httptest.NewRequest("POST", "/api/admin/approvals/"+action.ID+"/approve", nil)
+		req.SetPathValue("id", action.ID)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		w = httptest.NewRecorder()
+		approve(w, req)
+		if w.Code != tt.wantCode {
+			t.Errorf("Expected status %d for %s, got %d", tt.wantCode, tt.token, w.Code)
+		}
+	}
+
+	if !ran {
+		t.Error("Expected action to run after the second approval")
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArchiveConfig configures periodic public snapshots of the leaderboard
+type ArchiveConfig struct {
+	// Dir is where snapshots are written, one subdirectory per board
+	Dir string `json:"dir,omitempty"`
+	// IntervalMinutes between snapshots; zero disables archiving
+	IntervalMinutes int `json:"intervalMinutes,omitempty"`
+	// Limit is the number of top entries included in each snapshot
+	Limit int `json:"limit,omitempty"`
+}
+
+// Snapshot is the published content of an archive file
+type Snapshot struct {
+	Board       string       `json:"board"`
+	GeneratedAt time.Time    `json:"generatedAt"`
+	Entries     []ScoreEntry `json:"entries"`
+}
+
+// ArchiveListing describes one published snapshot
+type ArchiveListing struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// archiveNamePattern restricts board and snapshot names to safe path segments
+var archiveNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Archiver writes immutable snapshots of a board's standings
+type Archiver struct {
+	store  *ScoreStore
+	board  string
+	config ArchiveConfig
+}
+
+// NewArchiver creates an Archiver for the given board
+func NewArchiver(store *ScoreStore, board string, config ArchiveConfig) *Archiver {
+	if config.Dir == "" {
+		config.Dir = "archives"
+	}
+	if config.Limit <= 0 {
+		config.Limit = 100
+	}
+
+	return &Archiver{
+		store:  store,
+		board:  board,
+		config: config,
+	}
+}
+
+// Start publishes a snapshot at the end of every configured interval in
+// the background. Intervals are aligned to UTC midnight rather than to
+// when the server started, so a daily snapshot is taken at midnight and
+// named after the period it closes.
+func (a *Archiver) Start() {
+	if a.config.IntervalMinutes <= 0 {
+		return
+	}
+	interval := a.interval()
+
+	go func() {
+		for {
+			now := a.store.Now()
+			next := nextSnapshotTime(now, interval)
+			time.Sleep(next.Sub(now))
+			if _, err := a.Snapshot(next); err != nil {
+				log.Printf("Warning: Could not publish snapshot: %v", err)
+			}
+		}
+	}()
+}
+
+// nextSnapshotTime returns the first interval boundary after now. Time is
+// truncated from the zero time, which is a UTC midnight, so intervals that
+// divide a day start at midnight.
+func nextSnapshotTime(now time.Time, interval time.Duration) time.Time {
+	return now.UTC().Truncate(interval).Add(interval)
+}
+
+// interval is the time between snapshots, a day if none is configured
+func (a *Archiver) interval() time.Duration {
+	if a.config.IntervalMinutes <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(a.config.IntervalMinutes) * time.Minute
+}
+
+// periodClosedAt returns the start of the interval that the boundary at or
+// before now closes, so the snapshot taken at midnight covers the day
+// before
+func (a *Archiver) periodClosedAt(now time.Time) time.Time {
+	interval := a.interval()
+	return now.UTC().Truncate(interval).Add(-interval)
+}
+
+// snapshotName names a snapshot by the date of the period it closes,
+// adding the time of day when snapshots are taken more than once a day
+func (a *Archiver) snapshotName(t time.Time) string {
+	t = t.UTC()
+	if a.config.IntervalMinutes > 0 && a.config.IntervalMinutes%(24*60) != 0 {
+		return t.Format("2006-01-02T1504Z")
+	}
+	return t.Format("2006-01-02")
+}
+
+// Snapshot writes the current standings to {dir}/{board}/{name}.json,
+// named after the period that closed at or before now, and returns the
+// file path. Existing snapshots are never overwritten. The
+// snapshot is written to a temporary file first and linked into place once
+// complete, so a failed write never leaves a partial snapshot to be served
+// as immutable.
+func (a *Archiver) Snapshot(now time.Time) (string, error) {
+	snapshot := Snapshot{
+		Board:       a.board,
+		GeneratedAt: now.UTC(),
+		Entries:     publicEntries(a.store.GetTopScores(context.Background(), a.config.Limit)),
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(a.config.Dir, a.board)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, a.snapshotName(a.periodClosedAt(now))+".json")
+	tmp, err := writeTempFile(dir, ".snapshot-*.tmp", data)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+
+	// Linking fails if the snapshot exists, so it is never replaced
+	if err := os.Link(tmp, path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return path, nil
+		}
+		return "", err
+	}
+	return path, syncDir(dir)
+}
+
+// ArchiveHandler serves published snapshots
+type ArchiveHandler struct {
+	dir string
+}
+
+// NewArchiveHandler creates an ArchiveHandler serving snapshots from dir
+func NewArchiveHandler(dir string) *ArchiveHandler {
+	if dir == "" {
+		dir = "archives"
+	}
+	return &ArchiveHandler{dir: dir}
+}
+
+// ServeSnapshot handles GET /archives/{board}/{name}.json
+func (h *ArchiveHandler) ServeSnapshot(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	board, file := r.PathValue("board"), r.PathValue("file")
+	name := strings.TrimSuffix(file, ".json")
+	if !archiveNamePattern.MatchString(board) || name == file || !archiveNamePattern.MatchString(name) {
+		httpError(w, r, "Not found", http.StatusNotFound)
+		return
+	}
+
+	path := filepath.Join(h.dir, board, name+".json")
+	if _, err := os.Stat(path); err != nil {
+		httpError(w, r, "Snapshot not found", http.StatusNotFound)
+		return
+	}
+
+	// Snapshots never change once published
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("Content-Type", "application/json")
+	http.ServeFile(w, r, path)
+}
+
+// ListSnapshots handles GET /archives/{board}, listing the snapshots
+// published for a board, newest first
+func (h *ArchiveHandler) ListSnapshots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	board := r.PathValue("board")
+	if !archiveNamePattern.MatchString(board) {
+		httpError(w, r, "Not found", http.StatusNotFound)
+		return
+	}
+
+	files, err := os.ReadDir(filepath.Join(h.dir, board))
+	if err != nil {
+		if os.IsNotExist(err) {
+			httpError(w, r, "Board not found", http.StatusNotFound)
+			return
+		}
+		httpError(w, r, "Could not list archives", http.StatusInternalServerError)
+		return
+	}
+
+	listings := make([]ArchiveListing, 0, len(files))
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), ".json")
+		if file.IsDir() || name == file.Name() {
+			continue
+		}
+		listings = append(listings, ArchiveListing{
+			Name: name,
+			URL:  "/archives/" + board + "/" + file.Name(),
+		})
+	}
+
+	sort.Slice(listings, func(i, j int) bool {
+		return listings[i].Name > listings[j].Name
+	})
+
+	json.NewEncoder(w).Encode(listings)
+}
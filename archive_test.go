@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test snapshots are written once and served through the archive handler
+func TestArchiveSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 300, "Third")
+	store.AddScore(context.Background(), 900, "First")
+	store.AddScore(context.Background(), 600, "Second")
+
+	archiver := NewArchiver(store, "main", ArchiveConfig{Dir: dir, IntervalMinutes: 24 * 60, Limit: 2})
+
+	// The snapshot taken at midnight closes, and is named after, the day
+	// before
+	day := time.Date(2024, 12, 2, 0, 0, 0, 0, time.UTC)
+
+	path, err := archiver.Snapshot(day)
+	if err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+
+	// A second snapshot for the same day must not replace the first
+	store.AddScore(context.Background(), 5000, "Late")
+	if _, err := archiver.Snapshot(day.Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+
+	var snapshot Snapshot
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatalf("Failed to decode snapshot: %v", err)
+	}
+	if len(snapshot.Entries) != 2 || snapshot.Entries[0].PlayerName != "First" {
+		t.Errorf("Expected original top 2 standings, got %v", snapshot.Entries)
+	}
+	if files, _ := os.ReadDir(filepath.Join(dir, "main")); len(files) != 1 {
+		t.Errorf("Expected only the snapshot to be left behind, got %d files", len(files))
+	}
+
+	handler := NewArchiveHandler(dir)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /archives/{board}", handler.ListSnapshots)
+	mux.HandleFunc("GET /archives/{board}/{file}", handler.ServeSnapshot)
+
+	req := httptest.NewRequest("GET", "/archives/main", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var listings []ArchiveListing
+	if err := json.NewDecoder(w.Body).Decode(&listings); err != nil {
+		t.Fatalf("Failed to decode listing: %v", err)
+	}
+	if len(listings) != 1 || listings[0].URL != "/archives/main/2024-12-01.json" {
+		t.Errorf("Unexpected listing: %v", listings)
+	}
+
+	req = httptest.NewRequest("GET", listings[0].URL, nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	for _, path := range []string{"/archives/main/missing.json", "/archives/main/..%2f..%2fsecret.json", "/archives/other"} {
+		req = httptest.NewRequest("GET", path, nil)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for %s, got %d", path, w.Code)
+		}
+	}
+}
+
+// Test snapshots are scheduled at interval boundaries from UTC midnight
+func TestNextSnapshotTime(t *testing.T) {
+	tests := []struct {
+		now      time.Time
+		interval time.Duration
+		want     time.Time
+	}{
+		{time.Date(2024, 12, 2, 15, 4, 5, 0, time.UTC), 24 * time.Hour, time.Date(2024, 12, 3, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 12, 2, 15, 4, 5, 0, time.UTC), 6 * time.Hour, time.Date(2024, 12, 2, 18, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 12, 2, 18, 0, 0, 0, time.UTC), 6 * time.Hour, time.Date(2024, 12, 3, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 12, 2, 15, 4, 5, 0, time.FixedZone("CET", 3600)), time.Hour, time.Date(2024, 12, 2, 15, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		if got := nextSnapshotTime(tt.now, tt.interval); !got.Equal(tt.want) {
+			t.Errorf("Expected the snapshot after %s at %s, got %s", tt.now, tt.want, got)
+		}
+	}
+}
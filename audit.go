@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes one administrative action
+type AuditRecord struct {
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+	// Board is the board whose entries the action changed
+	Board    string   `json:"board,omitempty"`
+	EntryIDs []string `json:"entryIds,omitempty"`
+	// Target names what the action changed when it isn't entries, such as
+	// a moderation rule or a season
+	Target  string       `json:"target,omitempty"`
+	Reason  string       `json:"reason,omitempty"`
+	Entries []ScoreEntry `json:"entries,omitempty"`
+}
+
+// AuditLog appends records of administrative actions to a file, one JSON
+// object per line. Records are never rewritten.
+type AuditLog struct {
+	filename string
+	mu       sync.Mutex
+}
+
+// NewAuditLog creates an AuditLog writing to filename
+func NewAuditLog(filename string) *AuditLog {
+	return &AuditLog{filename: filename}
+}
+
+// Record appends a record, stamping it with the current time if unset
+func (a *AuditLog) Record(record AuditRecord) error {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	file, err := os.OpenFile(a.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// maxAuditRecordBytes caps the length of one line of the audit log, which
+// can hold copies of many deleted entries
+const maxAuditRecordBytes = 64 << 20
+
+// AuditQuery selects records from the audit log. Empty fields match every
+// record.
+type AuditQuery struct {
+	Actor   string
+	Action  string
+	EntryID string
+	// Since and Until select records made in [Since, Until)
+	Since, Until time.Time
+	// Limit caps the number of records returned, newest first; zero or
+	// negative means no limit
+	Limit int
+}
+
+// matches reports whether a record passes the query
+func (q AuditQuery) matches(record AuditRecord) bool {
+	switch {
+	case q.Actor != "" && record.Actor != q.Actor:
+		return false
+	case q.Action != "" && record.Action != q.Action:
+		return false
+	case q.EntryID != "" && !slices.Contains(record.EntryIDs, q.EntryID):
+		return false
+	case !q.Since.IsZero() && record.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !record.Time.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// Query returns the records matching q, newest first. Lines that cannot be
+// read as records are skipped with a warning rather than hiding the rest
+// of the log.
+func (a *AuditLog) Query(q AuditQuery) ([]AuditRecord, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	records := make([]AuditRecord, 0)
+	file, err := os.Open(a.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return records, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxAuditRecordBytes)
+	for line := 1; scanner.Scan(); line++ {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Printf("Warning: %s line %d is not an audit record: %v", a.filename, line, err)
+			continue
+		}
+		if !q.matches(record) {
+			continue
+		}
+		// Only the newest records are kept, so a long log isn't held in
+		// memory for a small page
+		records = append(records, record)
+		if q.Limit > 0 && len(records) > q.Limit {
+			records = records[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(records)
+	return records, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test admin mutations are audited and the log can be queried, newest
+// first
+func TestAudit(t *testing.T) {
+	useTempSaveFiles(t)
+	store := NewScoreStore()
+	t.Cleanup(store.Close)
+	auditFile := filepath.Join(t.TempDir(), "audit.log")
+	handler := NewAdminHandler(store, NewRuleSet(), filepath.Join(t.TempDir(), "rules.json"), WithAuditLog(NewAuditLog(auditFile)))
+	auth := NewAuthenticator()
+	auth.AddNamedToken("alice", "mod-token", RoleModerator)
+	auth.AddNamedToken("root", "admin-token", RoleAdmin)
+
+	rude := store.AddScore(context.Background(), 500, "Rude")
+	store.AddScore(context.Background(), 99999, "Cheater")
+
+	do := func(pattern, path, token, body string, next http.HandlerFunc, role Role) {
+		req := routedRequest(t, pattern, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		auth.Require(role, next)(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("%s: expected success, got %d: %s", path, w.Code, w.Body.String())
+		}
+	}
+	do("POST /api/admin/entries/{id}/{action}", "/api/admin/entries/"+rude.ID+"/hide?reason=slur", "mod-token", "", handler.EntryAction, RoleModerator)
+	do("POST /api/admin/rules", "/api/admin/rules", "admin-token", `{"name":"big","expression":"score > 50000"}`, handler.AddRule, RoleAdmin)
+	do("DELETE /api/admin/purge", "/api/admin/purge?player=Cheater&confirm=true", "admin-token", "", handler.Purge, RoleAdmin)
+	do("POST /api/admin/entries/{id}/{action}", "/api/admin/entries/"+rude.ID+"/unhide", "mod-token", "", handler.EntryAction, RoleModerator)
+
+	// A damaged line doesn't hide the records around it
+	file, _ := os.OpenFile(auditFile, os.O_WRONLY|os.O_APPEND, 0644)
+	file.WriteString("not json\n")
+	file.Close()
+
+	tests := []struct {
+		name        string
+		query       string
+		wantCode    int
+		wantActions []string
+	}{
+		{"everything", "", http.StatusOK, []string{"unhide", "purge", "add-rule", "hide"}},
+		{"by actor", "?actor=alice", http.StatusOK, []string{"unhide", "hide"}},
+		{"by action", "?action=purge", http.StatusOK, []string{"purge"}},
+		{"by entry", "?entry=" + rude.ID, http.StatusOK, []string{"unhide", "hide"}},
+		{"limit", "?limit=1", http.StatusOK, []string{"unhide"}},
+		{"until", "?until=2000-01-01T00:00:00Z", http.StatusOK, []string{}},
+		{"since", "?since=" + time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), http.StatusOK, []string{"unhide", "purge", "add-rule", "hide"}},
+		{"invalid limit", "?limit=0", http.StatusBadRequest, nil},
+		{"invalid since", "?since=yesterday", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.Audit(w, httptest.NewRequest("GET", "/api/admin/audit"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var records []AuditRecord
+			json.NewDecoder(w.Body).Decode(&records)
+			actions := make([]string, len(records))
+			for i, record := range records {
+				actions[i] = record.Action
+			}
+			if strings.Join(actions, ",") != strings.Join(tt.wantActions, ",") {
+				t.Errorf("Expected actions %v, got %v", tt.wantActions, actions)
+			}
+		})
+	}
+
+	records, _ := NewAuditLog(auditFile).Query(AuditQuery{Action: "add-rule"})
+	if len(records) != 1 || records[0].Actor != "root" || records[0].Target != "big" {
+		t.Errorf("Expected the rule and who added it to be recorded, got %+v", records)
+	}
+}
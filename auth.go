@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Role is a permission level. Higher roles include the permissions of all
+// lower roles, so admins can do everything moderators can.
+type Role int
+
+const (
+	// RolePlayer is granted to every request, authenticated or not
+	RolePlayer Role = iota
+	// RoleModerator can review and hide entries
+	RoleModerator
+	// RoleAdmin can additionally change configuration and wipe data
+	RoleAdmin
+)
+
+// String returns the lowercase role name
+func (r Role) String() string {
+	switch r {
+	case RoleModerator:
+		return "moderator"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "player"
+}
+
+// Principal identifies who made a request
+type Principal struct {
+	Name string
+	Role Role
+}
+
+// anonymous is the principal of requests without a token
+var anonymous = Principal{Name: "anonymous", Role: RolePlayer}
+
+type principalKey struct{}
+
+// PrincipalFrom returns the principal stored in a request context by
+// Authenticator.Require, or the anonymous player if there is none
+func PrincipalFrom(ctx context.Context) Principal {
+	if p, ok := ctx.Value(principalKey{}).(Principal); ok {
+		return p
+	}
+	return anonymous
+}
+
+// Authenticator maps bearer tokens to principals
+type Authenticator struct {
+	tokens map[string]Principal
+	mu     sync.RWMutex
+}
+
+// NewAuthenticator creates an Authenticator with no tokens
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{
+		tokens: make(map[string]Principal),
+	}
+}
+
+// NewAuthenticatorFromEnv creates an Authenticator from the comma-separated
+// ADMIN_TOKENS and MODERATOR_TOKENS environment variables. Each item is
+// either a bare token or name:token, naming the person it belongs to.
+// ADMIN_TOKEN is accepted as a single admin token for compatibility.
+func NewAuthenticatorFromEnv() *Authenticator {
+	a := NewAuthenticator()
+	for _, item := range strings.Split(os.Getenv("MODERATOR_TOKENS"), ",") {
+		a.addTokenSpec(strings.TrimSpace(item), RoleModerator)
+	}
+	for _, item := range strings.Split(os.Getenv("ADMIN_TOKENS"), ",") {
+		a.addTokenSpec(strings.TrimSpace(item), RoleAdmin)
+	}
+	a.AddToken(os.Getenv("ADMIN_TOKEN"), RoleAdmin)
+	return a
+}
+
+// addTokenSpec adds a token given as "token" or "name:token"
+func (a *Authenticator) addTokenSpec(spec string, role Role) {
+	if name, token, ok := strings.Cut(spec, ":"); ok {
+		a.AddNamedToken(name, token, role)
+		return
+	}
+	a.AddToken(spec, role)
+}
+
+// AddToken grants a role to a bearer token. The principal is named after a
+// hash of the token. Empty tokens are ignored.
+func (a *Authenticator) AddToken(token string, role Role) {
+	sum := sha256.Sum256([]byte(token))
+	a.AddNamedToken(role.String()+"-"+hex.EncodeToString(sum[:3]), token, role)
+}
+
+// AddNamedToken grants a role to a bearer token belonging to a named
+// person. Empty tokens are ignored.
+func (a *Authenticator) AddNamedToken(name, token string, role Role) {
+	if token == "" {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tokens[token] = Principal{Name: name, Role: role}
+}
+
+// PrincipalFor returns the principal of the request's bearer token.
+// Requests without a token are anonymous players; the second return value
+// is false for unknown tokens and for credentials given with any other
+// scheme.
+func (a *Authenticator) PrincipalFor(r *http.Request) (Principal, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return anonymous, true
+	}
+
+	scheme, provided, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return anonymous, false
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for token, principal := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+			return principal, true
+		}
+	}
+	return anonymous, false
+}
+
+// RoleFor returns the role of the request's bearer token
+func (a *Authenticator) RoleFor(r *http.Request) (Role, bool) {
+	principal, ok := a.PrincipalFor(r)
+	return principal.Role, ok
+}
+
+// Identify wraps a public handler so it can tell who made the request via
+// PrincipalFrom: requests without a token run as the anonymous player, and
+// those with an unknown token are refused
+func (a *Authenticator) Identify(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := a.PrincipalFor(r)
+		if !ok {
+			httpError(w, r, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	}
+}
+
+// Require wraps a handler so it only runs for requests with at least the
+// given role. The principal is available to the handler via PrincipalFrom.
+func (a *Authenticator) Require(role Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := a.PrincipalFor(r)
+		if !ok {
+			httpError(w, r, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if principal.Role < role {
+			if principal == anonymous {
+				httpError(w, r, "Unauthorized", http.StatusUnauthorized)
+			} else {
+				httpError(w, r, "Forbidden: requires "+role.String()+" role", http.StatusForbidden)
+			}
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	}
+}
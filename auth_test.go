@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test role requirements on wrapped handlers
+func TestAuthenticatorRequire(t *testing.T) {
+	auth := NewAuthenticator()
+	auth.AddToken("mod-token", RoleModerator)
+	auth.AddToken("admin-token", RoleAdmin)
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	tests := []struct {
+		name     string
+		required Role
+		token    string
+		wantCode int
+	}{
+		{"anonymous player endpoint", RolePlayer, "", http.StatusOK},
+		{"anonymous moderator endpoint", RoleModerator, "", http.StatusUnauthorized},
+		{"unknown token", RolePlayer, "bogus", http.StatusUnauthorized},
+		{"moderator on moderator endpoint", RoleModerator, "mod-token", http.StatusOK},
+		{"moderator on admin endpoint", RoleAdmin, "mod-token", http.StatusForbidden},
+		{"admin on moderator endpoint", RoleModerator, "admin-token", http.StatusOK},
+		{"admin on admin endpoint", RoleAdmin, "admin-token", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/admin/rules", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+
+			auth.Require(tt.required, ok)(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+}
+
+// Test tokens are only accepted with the Bearer scheme
+func TestPrincipalForRequiresBearer(t *testing.T) {
+	auth := NewAuthenticator()
+	auth.AddToken("admin-token", RoleAdmin)
+
+	tests := []struct {
+		header string
+		wantOK bool
+	}{
+		{"Bearer admin-token", true},
+		{"bearer admin-token", true},
+		{"admin-token", false},
+		{"Basic admin-token", false},
+		{"Bearer", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/admin/rules", nil)
+			req.Header.Set("Authorization", tt.header)
+
+			principal, ok := auth.PrincipalFor(req)
+			if ok != tt.wantOK {
+				t.Fatalf("Expected ok %v, got %v", tt.wantOK, ok)
+			}
+			if ok && principal.Role != RoleAdmin {
+				t.Errorf("Expected the admin role, got %s", principal.Role)
+			}
+		})
+	}
+}
+
+// Test hidden entries are excluded from the leaderboard
+func TestHideEntry(t *testing.T) {
+	useTempSaveFiles(t)
+	store := NewScoreStore()
+	t.Cleanup(store.Close)
+	handler := NewAdminHandler(store, NewRuleSet(), "")
+
+	store.AddScore(context.Background(), 100, "Nice")
+	rude := store.AddScore(context.Background(), 900, "Rude")
+
+	req := routedRequest(t, "POST /api/admin/entries/{id}/{action}", "/api/admin/entries/"+rude.ID+"/hide", nil)
+	w := httptest.NewRecorder()
+	handler.EntryAction(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	scores := store.GetTopScores(context.Background(), 0)
+	if len(scores) != 1 || scores[0].PlayerName != "Nice" {
+		t.Errorf("Expected only the visible entry, got %v", scores)
+	}
+
+	req = routedRequest(t, "POST /api/admin/entries/{id}/{action}", "/api/admin/entries/missing/hide", nil)
+	w = httptest.NewRecorder()
+	handler.EntryAction(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
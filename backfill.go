@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BackfillProgress reports the state of a backfill run
+type BackfillProgress struct {
+	DryRun     bool      `json:"dryRun"`
+	Running    bool      `json:"running"`
+	Total      int       `json:"total"`
+	Processed  int       `json:"processed"`
+	Changed    int       `json:"changed"`
+	Skipped    int       `json:"skipped"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt,omitempty"`
+}
+
+// Backfill recomputes derived data (normalized scores and moderation flags)
+// for every entry. It returns the entries that changed, keyed by ID,
+// without modifying the source entries. onProgress, if set, is called
+// periodically.
+//
+// Scores are normalized first, then rules are evaluated with board
+// variables built once for the run from the public board as backfilled.
+// Player variables still describe each player's runs before the one being
+// evaluated, replaying the entries in submission order.
+func Backfill(entries []ScoreEntry, config BoardConfig, rules *RuleSet, onProgress func(BackfillProgress)) (map[string]ScoreEntry, BackfillProgress) {
+	progress := BackfillProgress{
+		Running:   true,
+		Total:     len(entries),
+		StartedAt: time.Now(),
+	}
+
+	ordered := make([]ScoreEntry, len(entries))
+	copy(ordered, entries)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Timestamp.Before(ordered[j].Timestamp)
+	})
+
+	// Normalize every score, keeping entries whose difficulty is no longer
+	// configured as they were
+	recomputed := make([]ScoreEntry, len(ordered))
+	skipped := make([]bool, len(ordered))
+	var values []int
+	for i, original := range ordered {
+		entry, ok := normalizeEntry(original, config)
+		if !ok {
+			entry = original
+			skipped[i] = true
+		}
+		recomputed[i] = entry
+		if entry.listed(progress.StartedAt) {
+			values = append(values, entry.Score)
+		}
+	}
+
+	evaluate := rules != nil && rules.Len() > 0
+	var board map[string]interface{}
+	if evaluate {
+		sort.Ints(values)
+		board = boardRuleVars(ScoreDistribution{
+			Count: len(values),
+			Max:   percentileOf(values, 100),
+			P50:   percentileOf(values, 50),
+			P90:   percentileOf(values, 90),
+			P99:   percentileOf(values, 99),
+		})
+	}
+
+	histories := make(map[string]PlayerHistory)
+	changed := make(map[string]ScoreEntry)
+	for i, entry := range recomputed {
+		history := histories[entry.PlayerName]
+		if skipped[i] {
+			progress.Skipped++
+		} else if rules != nil {
+			entry.Flags = nil
+			if evaluate {
+				entry.Flags = rules.Evaluate(ruleVars(board, entry.Score, entry.PlayerName, history, entry.Timestamp))
+			}
+		}
+
+		if !reflect.DeepEqual(entry, ordered[i]) {
+			changed[entry.ID] = entry
+			progress.Changed++
+		}
+
+		if history.Runs == 0 || entry.Timestamp.Before(history.FirstSeen) {
+			history.FirstSeen = entry.Timestamp
+		}
+		if history.Runs == 0 || config.beats(entry.Score, history.BestScore) {
+			history.BestScore = entry.Score
+		}
+		history.Runs++
+		histories[entry.PlayerName] = history
+		progress.Processed++
+
+		if onProgress != nil && progress.Processed%1000 == 0 {
+			onProgress(progress)
+		}
+	}
+
+	progress.Running = false
+	progress.FinishedAt = time.Now()
+	if onProgress != nil {
+		onProgress(progress)
+	}
+
+	return changed, progress
+}
+
+// normalizeEntry derives an entry's normalized score from its raw fields.
+// The second return value is false if the entry's difficulty is unknown.
+func normalizeEntry(entry ScoreEntry, config BoardConfig) (ScoreEntry, bool) {
+	raw := entry.Score
+	if entry.Difficulty != "" {
+		raw = entry.RawScore
+	}
+
+	score, difficulty, ok := config.NormalizeScore(raw, entry.Difficulty)
+	if !ok {
+		return ScoreEntry{}, false
+	}
+
+	entry.Score = score
+	entry.RawScore = 0
+	entry.Difficulty = difficulty
+	if difficulty != "" {
+		entry.RawScore = raw
+	}
+	return entry, true
+}
+
+// BackfillJob runs backfills against a live store in the background and
+// tracks their progress for the admin API
+type BackfillJob struct {
+	store    *ScoreStore
+	rules    *RuleSet
+	file     string
+	progress BackfillProgress
+	mu       sync.Mutex
+}
+
+// NewBackfillJob creates a BackfillJob for the board saved to file
+func NewBackfillJob(store *ScoreStore, rules *RuleSet, file string) *BackfillJob {
+	return &BackfillJob{
+		store: store,
+		rules: rules,
+		file:  file,
+	}
+}
+
+// Start launches a backfill in the background. Dry runs compute against a
+// copy of the entries and only report what would change. It returns false
+// if a backfill is already running.
+func (j *BackfillJob) Start(dryRun bool) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.progress.Running {
+		return false
+	}
+	j.progress = BackfillProgress{DryRun: dryRun, Running: true, StartedAt: time.Now()}
+
+	go func() {
+		ctx := context.Background()
+		changed, _ := Backfill(j.store.GetAllEntries(ctx), j.store.Config(), j.rules, func(p BackfillProgress) {
+			p.DryRun = dryRun
+			p.Running = true
+			j.mu.Lock()
+			j.progress = p
+			j.mu.Unlock()
+		})
+
+		if !dryRun && len(changed) > 0 {
+			j.store.ApplyBackfill(ctx, changed)
+			if err := j.store.SaveToFile(j.file); err != nil {
+				log.Printf("Warning: Could not save backfilled leaderboard: %v", err)
+			}
+		}
+
+		j.mu.Lock()
+		j.progress.Running = false
+		j.progress.FinishedAt = time.Now()
+		j.mu.Unlock()
+	}()
+
+	return true
+}
+
+// Progress returns the state of the current or most recent backfill
+func (j *BackfillJob) Progress() BackfillProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.progress
+}
+
+// runBackfillCommand backfills a leaderboard file from the command line.
+// With dryRun it only reports; with output it writes the result to a copy
+// instead of replacing the input file.
+func runBackfillCommand(input, output string, dryRun bool, config BoardConfig, rules *RuleSet) error {
+	ctx := context.Background()
+	store := NewScoreStore()
+	if err := store.LoadFromFile(input); err != nil {
+		return err
+	}
+
+	changed, progress := Backfill(store.GetAllEntries(ctx), config, rules, func(p BackfillProgress) {
+		log.Printf("Backfill: %d/%d entries processed, %d changed", p.Processed, p.Total, p.Changed)
+	})
+
+	if progress.Skipped > 0 {
+		log.Printf("Backfill: %d entries kept unchanged because their difficulty is no longer configured", progress.Skipped)
+	}
+
+	if dryRun {
+		log.Printf("Dry run: %d of %d entries would change", progress.Changed, progress.Total)
+		return nil
+	}
+
+	store.ApplyBackfill(ctx, changed)
+
+	if output == "" {
+		output = input
+		backup := fmt.Sprintf("%s.%s.bak", input, time.Now().Format("20060102T150405"))
+		data, err := os.ReadFile(input)
+		if err == nil {
+			err = os.WriteFile(backup, data, 0644)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not back up %s: %w", input, err)
+		}
+		log.Printf("Backed up %s to %s", input, backup)
+	}
+
+	if err := store.SaveToFile(output); err != nil {
+		return err
+	}
+	log.Printf("Backfill complete: %d of %d entries changed, written to %s", progress.Changed, progress.Total, output)
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test backfill recomputes normalized scores and flags after rule changes
+func TestBackfillRecomputesDerivedData(t *testing.T) {
+	store := NewScoreStore()
+	start := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	for i, score := range []int{1000, 1200, 9000} {
+		store.restoreEntry(ScoreEntry{
+			ID:         string(rune('a' + i)),
+			Score:      score,
+			PlayerName: "Player",
+			Timestamp:  start.Add(time.Duration(i) * time.Hour),
+		})
+	}
+
+	// New rules: hard multiplier by default, and flag big jumps over the
+	// board's median by returning players
+	config := BoardConfig{
+		DifficultyMultipliers: map[string]float64{"hard": 2},
+		DefaultDifficulty:     "hard",
+	}
+	rules := NewRuleSet()
+	if _, err := rules.AddRule("jump", "player.runs > 0 && score > 3*board.p50"); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	changed, progress := Backfill(store.GetAllEntries(context.Background()), config, rules, nil)
+
+	if progress.Total != 3 || progress.Processed != 3 || progress.Changed != 3 {
+		t.Errorf("Unexpected progress: %+v", progress)
+	}
+
+	last := changed["c"]
+	if last.Score != 18000 || last.RawScore != 9000 || last.Difficulty != "hard" {
+		t.Errorf("Expected normalized hard score, got %+v", last)
+	}
+	if len(last.Flags) != 1 || len(changed["b"].Flags) != 0 {
+		t.Errorf("Expected only the jump to be flagged, got %v and %v", last.Flags, changed["b"].Flags)
+	}
+
+	// The source store is untouched until changes are applied
+	if store.GetTopScores(context.Background(), 1)[0].Score != 9000 {
+		t.Errorf("Expected backfill to leave the source entries unchanged")
+	}
+
+	// Moderation while the backfill ran is kept when it is applied
+	store.SetHidden(context.Background(), "a", true)
+	if n := store.ApplyBackfill(context.Background(), changed); n != 3 {
+		t.Errorf("Expected 3 entries updated, got %d", n)
+	}
+	if store.GetTopScores(context.Background(), 1)[0].Score != 18000 {
+		t.Errorf("Expected backfilled score to be applied")
+	}
+	if entry, _ := store.GetEntry(context.Background(), "a"); !entry.Hidden || entry.Score != 2000 {
+		t.Errorf("Expected the hidden entry to stay hidden with its new score, got %+v", entry)
+	}
+}
+
+// Test dry-run jobs leave the live store unchanged
+func TestBackfillJobDryRun(t *testing.T) {
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 1000, "Player")
+	store.SetConfig(BoardConfig{DifficultyMultipliers: map[string]float64{"hard": 2}, DefaultDifficulty: "hard"})
+
+	job := NewBackfillJob(store, nil, filepath.Join(t.TempDir(), "leaderboard.json"))
+	if !job.Start(true) {
+		t.Fatal("Expected backfill to start")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Progress().Running && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	progress := job.Progress()
+	if !progress.DryRun || progress.Changed != 1 {
+		t.Errorf("Expected dry run reporting 1 change, got %+v", progress)
+	}
+	if store.GetTopScores(context.Background(), 1)[0].Score != 1000 {
+		t.Errorf("Expected dry run to leave the store unchanged")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlobGCConfig configures removal of replay and ghost files whose entries
+// no longer exist
+type BlobGCConfig struct {
+	// IntervalMinutes between collections; zero disables scheduled runs
+	IntervalMinutes int `json:"intervalMinutes,omitempty"`
+	// GraceMinutes skips files modified more recently than this, so blobs
+	// written alongside a new entry are never collected. Defaults to 60.
+	GraceMinutes int `json:"graceMinutes,omitempty"`
+	// DryRun makes scheduled runs only report what they would remove
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// GCResult reports one collection
+type GCResult struct {
+	DryRun         bool      `json:"dryRun"`
+	StartedAt      time.Time `json:"startedAt"`
+	Scanned        int       `json:"scanned"`
+	Orphaned       []string  `json:"orphaned"`
+	ReclaimedBytes int64     `json:"reclaimedBytes"`
+	Errors         int       `json:"errors,omitempty"`
+}
+
+// GCStatus reports the most recent collection and totals since startup
+type GCStatus struct {
+	LastRun             *GCResult `json:"lastRun,omitempty"`
+	TotalRemoved        int       `json:"totalRemoved"`
+	TotalReclaimedBytes int64     `json:"totalReclaimedBytes"`
+}
+
+// blobDir is a directory of blobs named after their entry ID
+type blobDir struct {
+	dir    string
+	suffix string
+}
+
+// BlobCollector removes blobs whose owning entries were deleted. Boards
+// share the blob directories, so a blob is kept while any board has its
+// entry.
+type BlobCollector struct {
+	stores []*ScoreStore
+	dirs   []blobDir
+	config BlobGCConfig
+	status GCStatus
+	mu     sync.Mutex
+}
+
+// NewBlobCollector creates a BlobCollector for the blob stores in use and
+// the boards whose entries own the blobs. Either blob store may be nil.
+func NewBlobCollector(config BlobGCConfig, replays *ReplayStore, ghosts *GhostStore, stores ...*ScoreStore) *BlobCollector {
+	if config.GraceMinutes <= 0 {
+		config.GraceMinutes = 60
+	}
+
+	c := &BlobCollector{stores: stores, config: config}
+	if replays != nil {
+		c.dirs = append(c.dirs, blobDir{dir: replays.config.Dir, suffix: ".replay.gz"})
+	}
+	if ghosts != nil {
+		c.dirs = append(c.dirs, blobDir{dir: ghosts.config.Dir, suffix: ".json"})
+	}
+	return c
+}
+
+// Start collects every configured interval in the background
+func (c *BlobCollector) Start() {
+	interval := time.Duration(c.config.IntervalMinutes) * time.Minute
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			result := c.Collect(now, c.config.DryRun)
+			if len(result.Orphaned) > 0 {
+				log.Printf("Blob GC (dry run: %v): %d orphaned blobs, %d bytes", result.DryRun, len(result.Orphaned), result.ReclaimedBytes)
+			}
+		}
+	}()
+}
+
+// Collect removes blobs older than the grace period whose entry is on no
+// board. With dryRun it only reports them.
+func (c *BlobCollector) Collect(now time.Time, dryRun bool) GCResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := GCResult{DryRun: dryRun, StartedAt: now, Orphaned: []string{}}
+	cutoff := now.Add(-time.Duration(c.config.GraceMinutes) * time.Minute)
+
+	for _, blobs := range c.dirs {
+		files, err := os.ReadDir(blobs.dir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("Warning: blob GC could not read %s: %v", blobs.dir, err)
+				result.Errors++
+			}
+			continue
+		}
+
+		for _, file := range files {
+			id, ok := strings.CutSuffix(file.Name(), blobs.suffix)
+			if !ok || file.IsDir() {
+				continue
+			}
+			result.Scanned++
+
+			info, err := file.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+			if c.owned(id) {
+				continue
+			}
+
+			path := filepath.Join(blobs.dir, file.Name())
+			if !dryRun {
+				if err := os.Remove(path); err != nil {
+					log.Printf("Warning: blob GC could not remove %s: %v", path, err)
+					result.Errors++
+					continue
+				}
+			}
+			result.Orphaned = append(result.Orphaned, path)
+			result.ReclaimedBytes += info.Size()
+		}
+	}
+
+	c.status.LastRun = &result
+	if !dryRun {
+		c.status.TotalRemoved += len(result.Orphaned)
+		c.status.TotalReclaimedBytes += result.ReclaimedBytes
+	}
+	return result
+}
+
+// owned reports whether a board has the entry with the given ID
+func (c *BlobCollector) owned(id string) bool {
+	for _, store := range c.stores {
+		if _, exists := store.GetEntry(context.Background(), id); exists {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the most recent collection and totals
+func (c *BlobCollector) Status() GCStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// WriteMetrics writes collection totals in the Prometheus text format
+func (c *BlobCollector) WriteMetrics(w io.Writer) {
+	status := c.Status()
+	fmt.Fprintln(w, "# HELP blob_gc_removed_total Orphaned replay and ghost files removed.")
+	fmt.Fprintln(w, "# TYPE blob_gc_removed_total counter")
+	fmt.Fprintf(w, "blob_gc_removed_total %d\n", status.TotalRemoved)
+	fmt.Fprintln(w, "# HELP blob_gc_reclaimed_bytes_total Bytes reclaimed by removing orphaned files.")
+	fmt.Fprintln(w, "# TYPE blob_gc_reclaimed_bytes_total counter")
+	fmt.Fprintf(w, "blob_gc_reclaimed_bytes_total %d\n", status.TotalReclaimedBytes)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test orphaned blobs past the grace period are collected, keeping those
+// of every board's entries
+func TestBlobCollector(t *testing.T) {
+	store, speedrun := NewScoreStore(), NewScoreStore()
+	replays := NewReplayStore(ReplayConfig{Dir: t.TempDir()})
+	ghosts := NewGhostStore(GhostConfig{Dir: t.TempDir()})
+	collector := NewBlobCollector(BlobGCConfig{GraceMinutes: 10}, replays, ghosts, store, speedrun)
+
+	kept := store.AddScore(context.Background(), 100, "Kept")
+	keptSpeedrun := speedrun.AddScore(context.Background(), 100, "Fast")
+	old := time.Now().Add(-time.Hour)
+	write := func(dir, name string, modified time.Time) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte("0123456789"), 0644)
+		os.Chtimes(path, modified, modified)
+		return path
+	}
+
+	keptReplay := write(replays.config.Dir, kept.ID+".replay.gz", old)
+	keptGhost := write(ghosts.config.Dir, keptSpeedrun.ID+".json", old)
+	orphanReplay := write(replays.config.Dir, "deleted.replay.gz", old)
+	orphanGhost := write(ghosts.config.Dir, "deleted.json", old)
+	recentOrphan := write(replays.config.Dir, "recent.replay.gz", time.Now())
+	unrelated := write(replays.config.Dir, "notes.txt", old)
+
+	// Dry runs report without removing anything
+	result := collector.Collect(time.Now(), true)
+	if len(result.Orphaned) != 2 || result.ReclaimedBytes != 20 {
+		t.Errorf("Expected 2 orphans totalling 20 bytes, got %+v", result)
+	}
+	if _, err := os.Stat(orphanReplay); err != nil {
+		t.Error("Expected dry run to leave files in place")
+	}
+
+	result = collector.Collect(time.Now(), false)
+	if len(result.Orphaned) != 2 {
+		t.Errorf("Expected 2 orphans removed, got %+v", result)
+	}
+	for _, path := range []string{orphanReplay, orphanGhost} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+	for _, path := range []string{keptReplay, keptGhost, recentOrphan, unrelated} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept", path)
+		}
+	}
+
+	status := collector.Status()
+	if status.TotalRemoved != 2 || status.TotalReclaimedBytes != 20 {
+		t.Errorf("Expected totals of 2 files and 20 bytes, got %+v", status)
+	}
+
+	var metrics bytes.Buffer
+	collector.WriteMetrics(&metrics)
+	if !strings.Contains(metrics.String(), "blob_gc_reclaimed_bytes_total 20") {
+		t.Errorf("Expected reclaimed bytes in metrics, got:\n%s", metrics.String())
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MainBoard is the ID of the board also served at /api/leaderboard
+const MainBoard = "main"
+
+// BoardManager hosts several named leaderboards, such as one per game mode,
+// each with its own settings and save file
+type BoardManager struct {
+	boards map[string]*LeaderboardHandler
+	mu     sync.RWMutex
+}
+
+// NewBoardManager creates a BoardManager with no boards
+func NewBoardManager() *BoardManager {
+	return &BoardManager{
+		boards: make(map[string]*LeaderboardHandler),
+	}
+}
+
+// boardFile returns where a board is saved. The main board uses the
+// leaderboard file and others are saved alongside it.
+func boardFile(id string) string {
+	if id == MainBoard {
+		return leaderboardFile
+	}
+	return strings.TrimSuffix(leaderboardFile, ".json") + "-" + id + ".json"
+}
+
+// Add serves an existing board under id
+func (m *BoardManager) Add(id string, handler *LeaderboardHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.boards[id] = handler
+}
+
+// BoardServices starts the services of a board that need its store and
+// save file, such as its write gate and retention, and returns the handler
+// options that use them
+type BoardServices func(id string, store *ScoreStore, file string) []HandlerOption
+
+// Open creates a board with its own store and settings, loads its saved
+// entries and serves it under id. The store is created with storeOpts.
+// The options services returns, if it is set, and then opts are applied
+// after the board's save file and ID are set.
+func (m *BoardManager) Open(id string, config BoardConfig, storeOpts []StoreOption, services BoardServices, opts ...HandlerOption) (*LeaderboardHandler, error) {
+	store := NewScoreStore(storeOpts...)
+	store.SetConfig(config)
+
+	file := boardFile(id)
+	if err := store.LoadFromFile(file); err != nil {
+		return nil, err
+	}
+
+	handlerOpts := []HandlerOption{WithSaveFile(file), WithBoardID(id)}
+	if services != nil {
+		handlerOpts = append(handlerOpts, services(id, store, file)...)
+	}
+	handler := NewLeaderboardHandler(store, append(handlerOpts, opts...)...)
+	m.Add(id, handler)
+	return handler, nil
+}
+
+// Board returns the handler of a board
+func (m *BoardManager) Board(id string) (*LeaderboardHandler, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	handler, ok := m.boards[id]
+	return handler, ok
+}
+
+// lookup returns the handler of a board, the main board if id is empty
+func (m *BoardManager) lookup(id string) (*LeaderboardHandler, bool) {
+	if id == "" {
+		id = MainBoard
+	}
+	return m.Board(id)
+}
+
+// IDs returns the IDs of all boards in alphabetical order
+func (m *BoardManager) IDs() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]string, 0, len(m.boards))
+	for id := range m.boards {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Close stops the background saves of every board, waiting for those
+// already requested to be written
+func (m *BoardManager) Close() {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, handler := range m.boards {
+		handler.store.Close()
+	}
+}
+
+// ListBoards handles GET /api/boards, returning the IDs of all boards
+func (m *BoardManager) ListBoards(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(m.IDs())
+}
+
+// boardEndpoints are the main board's endpoints every board serves under
+// /api/boards/{board}
+var boardEndpoints = []struct {
+	pattern  string
+	endpoint func(*LeaderboardHandler, http.ResponseWriter, *http.Request)
+}{
+	{"GET /api/boards/{board}/leaderboard", (*LeaderboardHandler).GetLeaderboard},
+	{"POST /api/boards/{board}/leaderboard", (*LeaderboardHandler).SubmitScore},
+	{"GET /api/boards/{board}/leaderboard/around", (*LeaderboardHandler).GetAround},
+	{"GET /api/boards/{board}/leaderboard/stats", (*LeaderboardHandler).GetStats},
+	{"GET /api/boards/{board}/leaderboard/search", (*LeaderboardHandler).SearchPlayers},
+	{"GET /api/boards/{board}/leaderboard/countries", (*LeaderboardHandler).GetCountries},
+	{"GET /api/boards/{board}/leaderboard/export", (*LeaderboardHandler).ExportBoard},
+	{"GET /api/boards/{board}/leaderboard/changes", (*LeaderboardHandler).GetChanges},
+}
+
+// Routes registers GET /api/boards and every board's endpoints on mux
+func (m *BoardManager) Routes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/boards", m.ListBoards)
+	for _, route := range boardEndpoints {
+		mux.HandleFunc(route.pattern, m.Serve(route.endpoint))
+	}
+}
+
+// Serve returns a handler for /api/boards/{board}/... that runs endpoint on
+// the board named by the path, so every board serves the main board's
+// endpoints, such as (*LeaderboardHandler).GetAround
+func (m *BoardManager) Serve(endpoint func(*LeaderboardHandler, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler, ok := m.Board(r.PathValue("board"))
+		if !ok {
+			httpError(w, r, "Board not found", http.StatusNotFound)
+			return
+		}
+		endpoint(handler, w, r)
+	}
+}
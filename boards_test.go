@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useTempSaveFiles points leaderboardFile, and so every board's save file,
+// into a temporary directory for the rest of the test
+func useTempSaveFiles(t *testing.T) {
+	t.Helper()
+	previous := leaderboardFile
+	leaderboardFile = filepath.Join(t.TempDir(), "leaderboard.json")
+	t.Cleanup(func() { leaderboardFile = previous })
+}
+
+// Test named boards keep their own entries and settings and load from
+// their own save files
+func TestBoardManager(t *testing.T) {
+	useTempSaveFiles(t)
+
+	saved := NewScoreStore()
+	saved.AddScore(context.Background(), 700, "Saved")
+	if err := saved.SaveToFile(boardFile("speedrun")); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	boards := NewBoardManager()
+	t.Cleanup(boards.Close)
+	boards.Add(MainBoard, NewLeaderboardHandler(NewScoreStore()))
+	if _, err := boards.Open("speedrun", BoardConfig{}, nil, nil); err != nil {
+		t.Fatalf("Failed to open board: %v", err)
+	}
+	// Services get the board's own store and file
+	services := func(id string, store *ScoreStore, file string) []HandlerOption {
+		if file != boardFile(id) {
+			t.Errorf("Expected board %s to be saved to %s, got %s", id, boardFile(id), file)
+		}
+		return []HandlerOption{WithValidators(ScoreValidatorFunc(func(s ScoreSubmission) error {
+			if s.Score%10 != 0 {
+				return errors.New("Scores are always multiples of 10")
+			}
+			return nil
+		}))}
+	}
+	if _, err := boards.Open("hardcore", BoardConfig{MaxScore: 1000}, nil, services); err != nil {
+		t.Fatalf("Failed to open board: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	boards.Routes(mux)
+	mux.HandleFunc("/api/", unrouted(mux))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{"submit to board", "POST", "/api/boards/speedrun/leaderboard", `{"score": 900, "playerName": "Kiro"}`, http.StatusCreated},
+		{"board settings apply", "POST", "/api/boards/hardcore/leaderboard", `{"score": 5000, "playerName": "Kiro"}`, http.StatusUnprocessableEntity},
+		{"board services apply", "POST", "/api/boards/hardcore/leaderboard", `{"score": 905, "playerName": "Kiro"}`, http.StatusUnprocessableEntity},
+		{"board stats", "GET", "/api/boards/speedrun/leaderboard/stats", "", http.StatusOK},
+		{"unknown board", "GET", "/api/boards/missing/leaderboard", "", http.StatusNotFound},
+		{"unknown resource", "GET", "/api/boards/speedrun/other", "", http.StatusNotFound},
+		{"wrong method", "DELETE", "/api/boards/speedrun/leaderboard", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body)))
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/boards/speedrun/leaderboard", nil))
+	var scores []ScoreEntry
+	json.NewDecoder(w.Body).Decode(&scores)
+	if len(scores) != 2 || scores[0].PlayerName != "Kiro" || scores[1].PlayerName != "Saved" {
+		t.Errorf("Expected the submitted and saved scores, got %+v", scores)
+	}
+
+	// The submission is saved to the board's own file in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		reloaded := NewScoreStore()
+		if err := reloaded.LoadFromFile(boardFile("speedrun")); err == nil && len(reloaded.GetAllEntries(context.Background())) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the submission to be saved to the board file")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	main, _ := boards.Board(MainBoard)
+	if entries := main.store.GetTopScores(context.Background(), 10); len(entries) != 0 {
+		t.Errorf("Expected the main board to be untouched, got %d entries", len(entries))
+	}
+
+	w = httptest.NewRecorder()
+	boards.ListBoards(w, httptest.NewRequest("GET", "/api/boards", nil))
+	var ids []string
+	json.NewDecoder(w.Body).Decode(&ids)
+	want := []string{"hardcore", "main", "speedrun"}
+	if len(ids) != len(want) {
+		t.Fatalf("Expected boards %v, got %v", want, ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("Expected boards %v, got %v", want, ids)
+			break
+		}
+	}
+}
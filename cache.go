@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CacheConfig lets browsers and CDNs reuse public board responses for a
+// short time, absorbing clients that poll the board
+type CacheConfig struct {
+	// MaxAgeSeconds is how long a response stays fresh; zero sends no
+	// Cache-Control header
+	MaxAgeSeconds int `json:"maxAgeSeconds,omitempty"`
+	// StaleWhileRevalidateSeconds is how much longer caches may serve a
+	// stale response while they fetch a fresh one in the background
+	StaleWhileRevalidateSeconds int `json:"staleWhileRevalidateSeconds,omitempty"`
+}
+
+// header returns the Cache-Control value for public responses, or "" if
+// caching is off
+func (c CacheConfig) header() string {
+	if c.MaxAgeSeconds <= 0 {
+		return ""
+	}
+	value := fmt.Sprintf("public, max-age=%d", c.MaxAgeSeconds)
+	if c.StaleWhileRevalidateSeconds > 0 {
+		value += fmt.Sprintf(", stale-while-revalidate=%d", c.StaleWhileRevalidateSeconds)
+	}
+	return value
+}
+
+// setCacheControl marks a successful GET response as cacheable. Responses
+// for a single player, such as their friends board, are only cached by
+// their browser.
+func (h *LeaderboardHandler) setCacheControl(w http.ResponseWriter, private bool) {
+	value := h.cache.header()
+	if value == "" {
+		return
+	}
+	if private {
+		value = "private" + strings.TrimPrefix(value, "public")
+	}
+	w.Header().Set("Cache-Control", value)
+}
+
+// leaderboardETag returns the entity tag of a leaderboard response: the
+// board version together with everything in the request that shapes the
+// response
+func leaderboardETag(version string, query ScoreQuery, envelope, clamped bool, contentType string) string {
+	shape, _ := json.Marshal(struct {
+		Query       ScoreQuery
+		Envelope    bool
+		Clamped     bool
+		ContentType string
+	}{query, envelope, clamped, contentType})
+
+	sum := sha256.Sum256(append([]byte(version+"|"), shape...))
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. Weak
+// validators match their strong form, as the header is compared weakly.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxChangesWait caps how long a poll for changes is held open
+const maxChangesWait = 30 * time.Second
+
+// BoardChanges is what changed on the public board since a version
+type BoardChanges struct {
+	// Version is the current board version, to poll from next time
+	Version string `json:"version"`
+	// Entries are the entries that joined the public board since the
+	// version polled from, oldest first
+	Entries []ScoreEntry `json:"entries"`
+	// Reset is set when the board changed in other ways, such as an entry
+	// being removed, edited or released from review, or the server
+	// restarting; the client should fetch the whole board again
+	Reset bool `json:"reset,omitempty"`
+}
+
+// parseBoardVersion splits a version returned by Version into the store
+// epoch, the state version and the number of held entries
+func parseBoardVersion(version string) (int64, uint64, int, bool) {
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return 0, 0, 0, false
+	}
+	epoch, err := strconv.ParseInt(parts[0], 16, 64)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	state, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	held, err := strconv.Atoi(parts[2])
+	if err != nil || held < 0 {
+		return 0, 0, 0, false
+	}
+	return epoch, state, held, true
+}
+
+// ChangesSince returns the entries that joined the public board after it
+// was at version, a value returned by Version. When the board changed in
+// ways other than new entries the changes are marked Reset instead.
+func (s *ScoreStore) ChangesSince(ctx context.Context, version string, now time.Time) (BoardChanges, error) {
+	st, snap := s.sortedBoard(now)
+	changes := BoardChanges{Version: s.versionOf(snap), Entries: make([]ScoreEntry, 0)}
+
+	epoch, since, held, ok := parseBoardVersion(version)
+	if !ok {
+		return changes, newKindError(ErrValidation, "since must be a board version")
+	}
+	if epoch != s.epoch || since < st.appendedSince || since > st.version {
+		changes.Reset = true
+		return changes, nil
+	}
+
+	// Entries are appended in version order, so the new ones are at the
+	// end. A held entry the client knew about being released shows up as
+	// fewer held entries among the old ones.
+	start := sort.Search(len(st.entries), func(i int) bool {
+		return st.entries[i].version > since
+	})
+	stillHeld := 0
+	for _, entry := range st.entries[:start] {
+		if entry.held(now) {
+			stillHeld++
+		}
+	}
+	if stillHeld != held {
+		changes.Reset = true
+		return changes, nil
+	}
+
+	for _, entry := range st.entries[start:] {
+		if entry.listed(now) {
+			changes.Entries = append(changes.Entries, entry.public())
+		}
+	}
+	return changes, nil
+}
+
+// WaitForChanges is ChangesSince, but when the board is still at version
+// it waits up to wait for it to change, returning early once ctx is done
+func (s *ScoreStore) WaitForChanges(ctx context.Context, version string, wait time.Duration) (BoardChanges, error) {
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	for {
+		// Take the channel first so a change made while looking is not
+		// missed
+		changed := s.load().changed
+		now := s.clock.Now()
+		changes, err := s.ChangesSince(ctx, version, now)
+		if err != nil || changes.Version != version {
+			return changes, err
+		}
+
+		// Releasing a held entry changes the version without publishing
+		// a state, so wake up for the next release too
+		var release <-chan time.Time
+		var releaseTimer *time.Timer
+		if _, snap := s.sortedBoard(now); !snap.expires.IsZero() {
+			releaseTimer = time.NewTimer(snap.expires.Sub(now))
+			release = releaseTimer.C
+		}
+
+		done := false
+		select {
+		case <-changed:
+		case <-release:
+		case <-timeout.C:
+			done = true
+		case <-ctx.Done():
+			done = true
+		}
+		if releaseTimer != nil {
+			releaseTimer.Stop()
+		}
+		if done {
+			return changes, nil
+		}
+	}
+}
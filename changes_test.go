@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test polling for changes returns only new public entries, waits for
+// them when asked to, and resets after other changes
+func TestGetChanges(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store)
+	store.AddScore(context.Background(), 100, "Mario")
+
+	w := httptest.NewRecorder()
+	handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard", nil))
+	version := w.Header().Get("X-Board-Version")
+	if version == "" {
+		t.Fatal("Expected the board version in X-Board-Version")
+	}
+
+	poll := func(query string) BoardChanges {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.GetChanges(w, httptest.NewRequest("GET", "/api/leaderboard/changes"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var changes BoardChanges
+		json.NewDecoder(w.Body).Decode(&changes)
+		return changes
+	}
+
+	// New entries are returned once, hidden ones not at all
+	kiro := store.AddScore(context.Background(), 500, "Kiro")
+	rude := store.AddScore(context.Background(), 300, "Rude")
+	store.AddEntry(context.Background(), ScoreEntry{Score: 200, PlayerName: "Pending", Pending: true})
+	changes := poll("?since=" + version)
+	if changes.Reset || len(changes.Entries) != 2 || changes.Entries[0].ID != kiro.ID || changes.Entries[1].ID != rude.ID {
+		t.Fatalf("Expected Kiro's and Rude's entries, got %+v", changes)
+	}
+	version = changes.Version
+	if changes := poll("?since=" + version); changes.Reset || len(changes.Entries) != 0 || changes.Version != version {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+
+	// A waiting poll returns as soon as an entry is added
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		store.AddScore(context.Background(), 700, "Luigi")
+	}()
+	start := time.Now()
+	changes = poll("?since=" + version + "&wait=5")
+	if len(changes.Entries) != 1 || changes.Entries[0].PlayerName != "Luigi" {
+		t.Errorf("Expected Luigi's entry, got %+v", changes)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the poll to return when the entry was added, took %v", elapsed)
+	}
+	version = changes.Version
+
+	// Removing an entry can't be described as new entries
+	store.SetHidden(context.Background(), rude.ID, true)
+	if changes := poll("?since=" + version); !changes.Reset || changes.Version == version {
+		t.Errorf("Expected a reset after hiding an entry, got %+v", changes)
+	}
+	if changes := poll("?since=0.1.0"); !changes.Reset {
+		t.Errorf("Expected a reset for a version from another run, got %+v", changes)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+	}{
+		{"missing since", "", http.StatusBadRequest},
+		{"invalid since", "?since=yesterday", http.StatusBadRequest},
+		{"wait too long", "?since=" + version + "&wait=31", http.StatusBadRequest},
+		{"negative wait", "?since=" + version + "&wait=-1", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetChanges(w, httptest.NewRequest("GET", "/api/leaderboard/changes"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrNameClaimed is returned when a name, or one that looks the same,
+// belongs to another player
+var ErrNameClaimed = newKindError(ErrConflict, "name is claimed by another player")
+
+// Each address may try to claim claimsPerWindow names per claimWindow, so
+// names can't be squatted in bulk
+const (
+	claimsPerWindow = 5
+	claimWindow     = time.Hour
+)
+
+// NameClaim records the owner of a claimed name. Only a hash of the
+// owner's token is kept.
+type NameClaim struct {
+	PlayerName string    `json:"playerName"`
+	TokenHash  string    `json:"tokenHash"`
+	ClaimedAt  time.Time `json:"claimedAt"`
+}
+
+// NameClaims tracks claimed player names. Names are compared by their
+// skeleton, so claiming "Player1" also protects "PIayer1" and "Рlayer1".
+type NameClaims struct {
+	claims map[string]NameClaim
+	// byToken maps token hashes to the keys of their claims
+	byToken  map[string]string
+	attempts map[string]claimAttempts
+	filename string
+	mu       sync.RWMutex
+}
+
+// claimAttempts counts the claims an address tried in the window starting
+// at start
+type claimAttempts struct {
+	start time.Time
+	count int
+}
+
+// NewNameClaims creates a NameClaims saved to filename
+func NewNameClaims(filename string) *NameClaims {
+	return &NameClaims{
+		claims:   make(map[string]NameClaim),
+		byToken:  make(map[string]string),
+		attempts: make(map[string]claimAttempts),
+		filename: filename,
+	}
+}
+
+// hashToken returns the hex SHA-256 of a player token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Claim reserves a name and returns the token that must accompany future
+// submissions under it
+func (c *NameClaims) Claim(name string) (string, error) {
+	var buf [32]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := NameSkeleton(name)
+	if _, ok := c.claims[key]; ok {
+		return "", ErrNameClaimed
+	}
+	hash := hashToken(token)
+	c.claims[key] = NameClaim{
+		PlayerName: name,
+		TokenHash:  hash,
+		ClaimedAt:  time.Now(),
+	}
+	if err := c.save(); err != nil {
+		delete(c.claims, key)
+		return "", fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+	}
+	c.byToken[hash] = key
+	return token, nil
+}
+
+// Allow records a claim attempt from address at now and reports whether
+// it is within the address's limit
+func (c *NameClaims) Allow(address string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Forget finished windows once there are many, so the map stays small
+	if len(c.attempts) >= 10000 {
+		for addr, attempts := range c.attempts {
+			if now.Sub(attempts.start) >= claimWindow {
+				delete(c.attempts, addr)
+			}
+		}
+	}
+
+	attempts := c.attempts[address]
+	if now.Sub(attempts.start) >= claimWindow {
+		attempts = claimAttempts{start: now}
+	}
+	attempts.count++
+	c.attempts[address] = attempts
+	return attempts.count <= claimsPerWindow
+}
+
+// Authorize checks that token may submit under name. Unclaimed names are
+// open to everyone.
+func (c *NameClaims) Authorize(name, token string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	claim, ok := c.claims[NameSkeleton(name)]
+	if !ok {
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(claim.TokenHash)) != 1 {
+		return ErrNameClaimed
+	}
+	return nil
+}
+
+// Identify returns the claimed name token belongs to. The boolean result
+// is false if the token owns no claim.
+func (c *NameClaims) Identify(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	hash := hashToken(token)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Tokens are looked up by their hash, which gives nothing away about
+	// the token itself
+	key, ok := c.byToken[hash]
+	if !ok {
+		return "", false
+	}
+	return c.claims[key].PlayerName, true
+}
+
+// Release drops the claim on name, if the player who claimed it used
+// exactly that name, so it can be claimed again. It reports whether there
+// was such a claim.
+func (c *NameClaims) Release(name string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := NameSkeleton(name)
+	claim, ok := c.claims[key]
+	if !ok || claim.PlayerName != name {
+		return false, nil
+	}
+	delete(c.claims, key)
+	if err := c.save(); err != nil {
+		c.claims[key] = claim
+		return false, fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+	}
+	delete(c.byToken, claim.TokenHash)
+	return true, nil
+}
+
+// Suggest returns an unclaimed variant of name made by appending a number,
+// trimming the name so the result fits in maxLength characters (0 for no
+// limit). It returns "" if no variant is free.
+func (c *NameClaims) Suggest(name string, maxLength int) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for n := 2; n < 1000; n++ {
+		suffix := strconv.Itoa(n)
+		base := []rune(name)
+		if maxLength > 0 && len(base)+utf8.RuneCountInString(suffix) > maxLength {
+			keep := maxLength - utf8.RuneCountInString(suffix)
+			if keep <= 0 {
+				return ""
+			}
+			base = base[:keep]
+		}
+		candidate := string(base) + suffix
+		if _, ok := c.claims[NameSkeleton(candidate)]; !ok {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// save writes the claims to the claims file. The caller must hold the lock.
+func (c *NameClaims) save() error {
+	data, err := json.MarshalIndent(c.claims, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.filename, data, 0644)
+}
+
+// LoadFromFile loads claims saved by a previous run. A missing file leaves
+// no names claimed.
+func (c *NameClaims) LoadFromFile() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	claims := make(map[string]NameClaim)
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return err
+	}
+	// Re-key by the current skeleton in case its folding rules changed
+	c.claims = make(map[string]NameClaim, len(claims))
+	c.byToken = make(map[string]string, len(claims))
+	for _, claim := range claims {
+		key := NameSkeleton(claim.PlayerName)
+		c.claims[key] = claim
+		c.byToken[claim.TokenHash] = key
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test claimed names can only be used with their token
+func TestNameClaims(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "claims.json")
+	claims := NewNameClaims(filename)
+
+	token, err := claims.Claim("Player1")
+	if err != nil {
+		t.Fatalf("Expected claim to succeed, got %v", err)
+	}
+	if _, err := claims.Claim("PIayer1"); !errors.Is(err, ErrNameClaimed) {
+		t.Errorf("Expected lookalike claim to fail with ErrNameClaimed, got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		player  string
+		token   string
+		wantErr error
+	}{
+		{"owner", "Player1", token, nil},
+		{"no token", "Player1", "", ErrNameClaimed},
+		{"wrong token", "Player1", "nope", ErrNameClaimed},
+		{"lookalike", "Рlayer1", "", ErrNameClaimed},
+		{"unclaimed", "Player2", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := claims.Authorize(tt.player, tt.token); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if got := claims.Suggest("Player1", 0); got != "Player12" {
+		t.Errorf("Expected suggestion Player12, got %q", got)
+	}
+	if got := claims.Suggest("Player1", 7); got != "Player2" {
+		t.Errorf("Expected suggestion trimmed to Player2, got %q", got)
+	}
+
+	// Claims survive a restart
+	reloaded := NewNameClaims(filename)
+	if err := reloaded.LoadFromFile(); err != nil {
+		t.Fatalf("Expected claims to load, got %v", err)
+	}
+	if err := reloaded.Authorize("Player1", ""); !errors.Is(err, ErrNameClaimed) {
+		t.Errorf("Expected reloaded claim to be enforced, got %v", err)
+	}
+	if err := reloaded.Authorize("Player1", token); err != nil {
+		t.Errorf("Expected reloaded claim to accept its token, got %v", err)
+	}
+	if name, ok := reloaded.Identify(token); !ok || name != "Player1" {
+		t.Errorf("Expected the token to identify Player1, got %q (ok=%v)", name, ok)
+	}
+	if _, ok := reloaded.Identify("nope"); ok {
+		t.Error("Expected an unknown token to identify nobody")
+	}
+}
+
+// Test each address may only try a few claims per window
+func TestNameClaimLimit(t *testing.T) {
+	claims := NewNameClaims(filepath.Join(t.TempDir(), "claims.json"))
+	now := time.Now()
+
+	for i := 0; i < claimsPerWindow; i++ {
+		if !claims.Allow("192.0.2.1", now) {
+			t.Fatalf("Expected attempt %d to be allowed", i+1)
+		}
+	}
+	if claims.Allow("192.0.2.1", now) {
+		t.Error("Expected attempts past the limit to be refused")
+	}
+	if !claims.Allow("192.0.2.2", now) {
+		t.Error("Expected other addresses to be allowed")
+	}
+	if !claims.Allow("192.0.2.1", now.Add(claimWindow)) {
+		t.Error("Expected the limit to reset after the window")
+	}
+}
+
+// Test names with scores can only be claimed by whoever submitted them
+func TestClaimNameWithScores(t *testing.T) {
+	claims := NewNameClaims(filepath.Join(t.TempDir(), "claims.json"))
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithNameClaims(claims))
+	t.Cleanup(store.Close)
+
+	submit := func(name, token string) {
+		body, _ := json.Marshal(map[string]interface{}{"score": 100, "playerName": name})
+		req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+		if token != "" {
+			req.Header.Set(PlayerTokenHeader, token)
+		}
+		handler.SubmitScore(httptest.NewRecorder(), req)
+	}
+	submit("Luigi", "")
+	submit("Mario", "device-secret")
+	submit("Mario", "device-secret")
+
+	tests := []struct {
+		name     string
+		player   string
+		token    string
+		wantCode int
+	}{
+		{"anonymous scores", "Luigi", "", http.StatusConflict},
+		{"someone else's scores", "Mario", "other-secret", http.StatusConflict},
+		{"own scores", "Mario", "device-secret", http.StatusCreated},
+		{"no scores", "Peach", "", http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(NameClaimRequest{PlayerName: tt.player})
+			req := httptest.NewRequest("POST", "/api/names/claim", bytes.NewReader(body))
+			if tt.token != "" {
+				req.Header.Set(PlayerTokenHeader, tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ClaimName(w, req)
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+}
+
+// Test a name cannot be claimed in another spelling that looks like it,
+// which would take over the player's name, whichever board their scores
+// are on
+func TestClaimNameLookalikeOfScores(t *testing.T) {
+	dir := t.TempDir()
+	filter, _ := NewNameFilter(NameConfig{})
+	claims := NewNameClaims(filepath.Join(dir, "claims.json"))
+
+	boards := NewBoardManager()
+	t.Cleanup(boards.Close)
+	handler := NewLeaderboardHandler(NewScoreStore(), WithSaveFile(filepath.Join(dir, "main.json")),
+		WithNameFilter(filter), WithNameClaims(claims), WithPlayerBoards(boards))
+	speedrun := NewLeaderboardHandler(NewScoreStore(), WithSaveFile(filepath.Join(dir, "speedrun.json")),
+		WithNameFilter(filter), WithNameClaims(claims))
+	boards.Add(MainBoard, handler)
+	boards.Add("speedrun", speedrun)
+
+	body, _ := json.Marshal(map[string]interface{}{"score": 100, "playerName": "Kiro"})
+	req := httptest.NewRequest("POST", "/api/boards/speedrun/leaderboard", bytes.NewReader(body))
+	req.Header.Set(PlayerTokenHeader, "device-secret")
+	speedrun.SubmitScore(httptest.NewRecorder(), req)
+
+	tests := []struct {
+		name     string
+		player   string
+		token    string
+		wantCode int
+	}{
+		{"same name", "Kiro", "other-secret", http.StatusConflict},
+		{"other case", "KIRO", "", http.StatusConflict},
+		{"homoglyph", "Кiro", "other-secret", http.StatusConflict},
+		{"owner in other case", "KIRO", "device-secret", http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(NameClaimRequest{PlayerName: tt.player})
+			req := httptest.NewRequest("POST", "/api/names/claim", bytes.NewReader(body))
+			if tt.token != "" {
+				req.Header.Set(PlayerTokenHeader, tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ClaimName(w, req)
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+}
+
+// Test claiming a name through the API and submitting under it
+func TestClaimNameHandler(t *testing.T) {
+	filter, _ := NewNameFilter(NameConfig{})
+	claims := NewNameClaims(filepath.Join(t.TempDir(), "claims.json"))
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithNameFilter(filter), WithNameClaims(claims))
+	t.Cleanup(store.Close)
+
+	claim := func(name string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(NameClaimRequest{PlayerName: name})
+		w := httptest.NewRecorder()
+		handler.ClaimName(w, httptest.NewRequest("POST", "/api/names/claim", bytes.NewReader(body)))
+		return w
+	}
+	submit := func(name, token string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"score": 100, "playerName": name})
+		req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+		if token != "" {
+			req.Header.Set(PlayerTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		handler.SubmitScore(w, req)
+		return w
+	}
+
+	w := claim("  Kiro  ")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	var resp NameClaimResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.PlayerName != "Kiro" || resp.Token == "" {
+		t.Fatalf("Expected a token for Kiro, got %+v", resp)
+	}
+
+	if w := claim("kiro"); w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d for a taken name, got %d", http.StatusConflict, w.Code)
+	}
+
+	if w := submit("Kiro", resp.Token); w.Code != http.StatusCreated {
+		t.Errorf("Expected owner submission status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	w = submit("KIRO", "")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != ProblemContentType {
+		t.Errorf("Expected a problem, got %s", contentType)
+	}
+	var conflict Problem
+	json.NewDecoder(w.Body).Decode(&conflict)
+	if conflict.Type != "/problems/conflict" || conflict.Suggestion != "KIRO2" {
+		t.Errorf("Expected a conflict suggesting KIRO2, got %+v", conflict)
+	}
+
+	if w := claim("5h1t"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an offensive name, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import "time"
+
+// Clock tells the time, so time-dependent behavior such as daily boards,
+// record holds and score decay can be tested at chosen times
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to the Clock interface
+type ClockFunc func() time.Time
+
+// Now calls f()
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the real time
+var SystemClock Clock = ClockFunc(time.Now)
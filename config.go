@@ -0,0 +1,561 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	// Embed the time zone database so board time zones work on hosts
+	// without one
+	_ "time/tzdata"
+)
+
+// Config holds server settings loaded from config.json
+type Config struct {
+	Board       BoardConfig       `json:"board"`
+	AntiCheat   AntiCheatConfig   `json:"antiCheat"`
+	Archive     ArchiveConfig     `json:"archive"`
+	Admin       AdminConfig       `json:"admin"`
+	Replay      ReplayConfig      `json:"replay"`
+	Ghost       GhostConfig       `json:"ghost"`
+	Names       NameConfig        `json:"names"`
+	Persistence PersistenceConfig `json:"persistence"`
+	IDs         IDConfig          `json:"ids"`
+	BlobGC      BlobGCConfig      `json:"blobGc"`
+	Geo         GeoConfig         `json:"geo"`
+	Cache       CacheConfig       `json:"cache"`
+	Retention   RetentionConfig   `json:"retention"`
+	Idempotency IdempotencyConfig `json:"idempotency"`
+	Webhooks    WebhookConfig     `json:"webhooks"`
+	Live        LiveConfig        `json:"live"`
+	// Boards configures additional named boards, such as one per game
+	// mode, served under /api/boards/{boardID}/
+	Boards map[string]BoardConfig `json:"boards,omitempty"`
+}
+
+// AdminConfig holds settings for administrative operations
+type AdminConfig struct {
+	// TwoPersonApproval requires dangerous actions such as season resets
+	// and bulk deletions to be confirmed by a second admin
+	TwoPersonApproval bool `json:"twoPersonApproval"`
+	// ApprovalWindowMinutes is how long a pending action can be approved
+	ApprovalWindowMinutes int `json:"approvalWindowMinutes,omitempty"`
+}
+
+// BoardConfig holds per-board scoring settings
+type BoardConfig struct {
+	// DifficultyMultipliers scales raw scores by the difficulty they were
+	// played on, so runs on different difficulties rank on one board.
+	// When empty, the difficulty field of submissions is ignored.
+	DifficultyMultipliers map[string]float64 `json:"difficultyMultipliers,omitempty"`
+	// DefaultDifficulty is assumed for submissions without a difficulty
+	DefaultDifficulty string `json:"defaultDifficulty,omitempty"`
+	// Direction is "desc" (the default) when higher scores are better, or
+	// "asc" for boards where lower is better, such as speedrun times in
+	// milliseconds
+	Direction string `json:"direction,omitempty"`
+	// TieBreak is "submitted" (the default) to list equal scores by who got
+	// there first, or "time" to rank them by the fastest completion time
+	TieBreak string `json:"tieBreak,omitempty"`
+	// MaxMetadataBytes caps the size of the metadata object a submission
+	// may carry. Defaults to 1024.
+	MaxMetadataBytes int `json:"maxMetadataBytes,omitempty"`
+	// MaxScore rejects submitted scores above this value; zero disables it
+	MaxScore int `json:"maxScore,omitempty"`
+	// MaxScorePerSecond rejects submissions scoring faster than this rate
+	// over their reported playtime; zero disables it
+	MaxScorePerSecond float64 `json:"maxScorePerSecond,omitempty"`
+	// MinDurationMs rejects runs reporting a shorter playtime than the
+	// fastest possible run, and runs that report none; zero disables it
+	MinDurationMs int64 `json:"minDurationMs,omitempty"`
+	// ReviewThreshold holds back scores better than this value (for example
+	// the highest score the levels make possible) for moderator approval.
+	// Zero disables the moderation queue.
+	ReviewThreshold int `json:"reviewThreshold,omitempty"`
+	// Scoring holds point values for verifying scores from run telemetry
+	Scoring ScoringConfig `json:"scoring,omitempty"`
+	// Anomaly configures statistical outlier detection on submissions
+	Anomaly AnomalyConfig `json:"anomaly,omitempty"`
+	// Timestamps controls which time submissions are attributed to
+	Timestamps TimestampConfig `json:"timestamps,omitempty"`
+	// BestPerPlayer keeps only each player's highest score instead of
+	// every run, so one player cannot fill the top of the board
+	BestPerPlayer bool `json:"bestPerPlayer,omitempty"`
+	// RecordHold briefly holds back scores that would take first place
+	RecordHold RecordHoldConfig `json:"recordHold,omitempty"`
+	// Discord announces new leaders of the board in a Discord channel
+	Discord DiscordConfig `json:"discord,omitempty"`
+	// DailyResetHour is the hour at which the daily board starts over, in
+	// Timezone. Calendar weeks and months also start at this hour.
+	DailyResetHour int `json:"dailyResetHour,omitempty"`
+	// Timezone is the IANA time zone, such as "Europe/Berlin", that
+	// periods follow. Defaults to UTC.
+	Timezone string `json:"timezone,omitempty"`
+	// PeriodWindows is "calendar" (the default) for ISO weeks and calendar
+	// months, or "rolling" for the last 7 and 30 days
+	PeriodWindows string `json:"periodWindows,omitempty"`
+	// Decay ranks boards by scores that fade with age, so the board reflects
+	// active players
+	Decay DecayConfig `json:"decay,omitempty"`
+	// DefaultLimit is the number of entries returned when a query gives no
+	// limit. Defaults to 10.
+	DefaultLimit int `json:"defaultLimit,omitempty"`
+	// MaxLimit caps the limit a query may ask for. Defaults to 100.
+	MaxLimit int `json:"maxLimit,omitempty"`
+	// DedupeWindowSeconds is how long after a run is stored a repeat of it,
+	// with the same player, score and client run ID, is answered with the
+	// stored run instead of being stored again. Defaults to 30; negative
+	// disables deduplication.
+	DedupeWindowSeconds int `json:"dedupeWindowSeconds,omitempty"`
+	// TopCacheSize is how many of the best entries are kept sorted as runs
+	// arrive, so reading the top of the board doesn't sort it. Defaults to
+	// 100.
+	TopCacheSize int `json:"topCacheSize,omitempty"`
+}
+
+// QueryLimit returns the limit to use for a requested one, where zero or
+// less asks for the default. The boolean result is true if the request
+// was above the maximum and was clamped.
+func (c BoardConfig) QueryLimit(requested int) (int, bool) {
+	defaultLimit, maxLimit := c.DefaultLimit, c.MaxLimit
+	if defaultLimit <= 0 {
+		defaultLimit = 10
+	}
+	if maxLimit <= 0 {
+		maxLimit = 100
+	}
+
+	switch {
+	case requested <= 0:
+		return min(defaultLimit, maxLimit), false
+	case requested > maxLimit:
+		return maxLimit, true
+	default:
+		return requested, false
+	}
+}
+
+// dedupeWindow returns how long repeats of a run are recognized
+func (c BoardConfig) dedupeWindow() time.Duration {
+	if c.DedupeWindowSeconds == 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.DedupeWindowSeconds) * time.Second
+}
+
+// topCacheSize returns how many of the best entries the store keeps sorted
+func (c BoardConfig) topCacheSize() int {
+	if c.TopCacheSize <= 0 {
+		return 100
+	}
+	return c.TopCacheSize
+}
+
+// DecayConfig controls the decayed ranking mode. Stored scores are never
+// changed; decay only affects how ?sort=decayed orders the board.
+type DecayConfig struct {
+	// HalfLifeDays is the age at which a score counts for half; zero
+	// disables decayed ranking
+	HalfLifeDays float64 `json:"halfLifeDays,omitempty"`
+	// Default ranks the board by decayed score when no sort is given
+	Default bool `json:"default,omitempty"`
+}
+
+// DecayedScore returns an entry's score weighted by its age at now,
+// rounded to two decimal places
+func (c BoardConfig) DecayedScore(entry ScoreEntry, now time.Time) float64 {
+	if c.Decay.HalfLifeDays <= 0 {
+		return float64(entry.Score)
+	}
+	age := max(now.Sub(c.EffectiveTime(entry)).Hours()/24, 0)
+	decayed := float64(entry.Score) * math.Pow(0.5, age/c.Decay.HalfLifeDays)
+	return math.Round(decayed*100) / 100
+}
+
+// Board directions
+const (
+	DirectionDescending = "desc"
+	DirectionAscending  = "asc"
+)
+
+// beats reports whether score a is better than score b on this board
+func (c BoardConfig) beats(a, b int) bool {
+	if c.Direction == DirectionAscending {
+		return a < b
+	}
+	return a > b
+}
+
+// Tie-breaks for entries with equal scores
+const (
+	TieBreakSubmitted = "submitted"
+	TieBreakTime      = "time"
+)
+
+// outranks reports whether a ranks strictly above b: a better score or, on
+// boards breaking ties by time, the same score in a faster time. Runs
+// without a time are slower than any timed run.
+func (c BoardConfig) outranks(a, b ScoreEntry) bool {
+	if a.Score != b.Score {
+		return c.beats(a.Score, b.Score)
+	}
+	if c.TieBreak != TieBreakTime || a.DurationMs == b.DurationMs || a.DurationMs == 0 {
+		return false
+	}
+	return b.DurationMs == 0 || a.DurationMs < b.DurationMs
+}
+
+// before reports whether a is listed above b: entries that outrank others
+// first, then the earlier submission, then by ID
+func (c BoardConfig) before(a, b ScoreEntry) bool {
+	if c.outranks(a, b) {
+		return true
+	}
+	if c.outranks(b, a) {
+		return false
+	}
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.Before(b.Timestamp)
+	}
+	return a.ID < b.ID
+}
+
+// Board periods selectable with ?period=
+const (
+	PeriodAllTime = "alltime"
+	PeriodDaily   = "daily"
+	PeriodWeekly  = "weekly"
+	PeriodMonthly = "monthly"
+)
+
+// boardPeriods lists every period, longest first
+var boardPeriods = []string{PeriodAllTime, PeriodMonthly, PeriodWeekly, PeriodDaily}
+
+// Period windows for the weekly and monthly boards
+const (
+	// PeriodWindowsCalendar resets the weekly board at the start of each
+	// ISO week (Monday) and the monthly board at the start of each month
+	PeriodWindowsCalendar = "calendar"
+	// PeriodWindowsRolling lists the last 7 or 30 days instead
+	PeriodWindowsRolling = "rolling"
+)
+
+// PeriodBounds returns when the period containing at started and when it
+// ends. The all-time period has zero bounds and rolling windows have no
+// end. The boolean result is false for an unknown period.
+func (c BoardConfig) PeriodBounds(period string, at time.Time) (time.Time, time.Time, bool) {
+	loc := c.location()
+	at = at.In(loc)
+	rolling := c.PeriodWindows == PeriodWindowsRolling
+
+	// Calendar periods start at the reset hour in the board's time zone.
+	// Building each boundary from the date, rather than adding 24 hours,
+	// keeps it at that hour across daylight saving changes.
+	resetOn := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, c.DailyResetHour, 0, 0, 0, loc)
+	}
+	year, month, date := at.Date()
+	day := resetOn(year, month, date)
+	if day.After(at) {
+		date--
+		day = resetOn(year, month, date)
+	}
+
+	switch period {
+	case "", PeriodAllTime:
+		return time.Time{}, time.Time{}, true
+	case PeriodDaily:
+		return day, resetOn(year, month, date+1), true
+	case PeriodWeekly:
+		if rolling {
+			return at.AddDate(0, 0, -7), time.Time{}, true
+		}
+		monday := date - (int(day.Weekday())+6)%7
+		return resetOn(year, month, monday), resetOn(year, month, monday+7), true
+	case PeriodMonthly:
+		if rolling {
+			return at.AddDate(0, 0, -30), time.Time{}, true
+		}
+		year, month, _ := day.Date()
+		return resetOn(year, month, 1), resetOn(year, month+1, 1), true
+	default:
+		return time.Time{}, time.Time{}, false
+	}
+}
+
+// locations caches time zones loaded by BoardConfig.location
+var locations sync.Map
+
+// location returns the board's time zone, UTC if none is set. Time zones
+// are checked when the config is loaded.
+func (c BoardConfig) location() *time.Location {
+	if c.Timezone == "" {
+		return time.UTC
+	}
+	if loc, ok := locations.Load(c.Timezone); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	locations.Store(c.Timezone, loc)
+	return loc
+}
+
+// currentBests returns the IDs of the runs that are the best of some
+// current period, such as this week's best, as of now
+func (c BoardConfig) currentBests(runs []ScoreEntry, now time.Time) map[string]bool {
+	bests := make(map[string]bool)
+	for _, period := range boardPeriods {
+		start, _, _ := c.PeriodBounds(period, now)
+		var best *ScoreEntry
+		for i, run := range runs {
+			if !c.EffectiveTime(run).Before(start) && (best == nil || c.before(run, *best)) {
+				best = &runs[i]
+			}
+		}
+		if best != nil {
+			bests[best.ID] = true
+		}
+	}
+	return bests
+}
+
+// Timestamp policies for attributing submissions to periods
+const (
+	TimestampPolicyServer = "server"
+	TimestampPolicyClient = "client"
+)
+
+// TimestampConfig controls how the run-end time claimed by clients is used
+type TimestampConfig struct {
+	// Policy is "server" to attribute submissions to the time they were
+	// received, or "client" to use the run-end time the client claims, so
+	// runs finished just before midnight count for that day despite latency
+	Policy string `json:"policy,omitempty"`
+	// MaxSkewSeconds is how far a claimed run-end time may be from the
+	// receive time; claims outside it are discarded. Defaults to 120.
+	MaxSkewSeconds int `json:"maxSkewSeconds,omitempty"`
+}
+
+// AcceptClientTime reports whether a claimed run-end time is close enough
+// to the time the submission was received to be trusted
+func (c BoardConfig) AcceptClientTime(claimed, received time.Time) bool {
+	maxSkew := time.Duration(c.Timestamps.MaxSkewSeconds) * time.Second
+	if maxSkew <= 0 {
+		maxSkew = 120 * time.Second
+	}
+
+	skew := received.Sub(claimed)
+	return skew <= maxSkew && skew >= -maxSkew
+}
+
+// EffectiveTime returns the time an entry counts for when deciding which
+// period it belongs to, according to the board's timestamp policy
+func (c BoardConfig) EffectiveTime(entry ScoreEntry) time.Time {
+	if c.Timestamps.Policy == TimestampPolicyClient && entry.ClientTimestamp != nil {
+		return *entry.ClientTimestamp
+	}
+	return entry.Timestamp
+}
+
+// validate checks settings that cannot be corrected with defaults
+func (c BoardConfig) validate() error {
+	for difficulty, multiplier := range c.DifficultyMultipliers {
+		if multiplier <= 0 {
+			return fmt.Errorf("difficulty %q: multiplier must be positive", difficulty)
+		}
+	}
+
+	switch c.Timestamps.Policy {
+	case "", TimestampPolicyServer, TimestampPolicyClient:
+	default:
+		return fmt.Errorf("unknown timestamp policy %q", c.Timestamps.Policy)
+	}
+
+	switch c.PeriodWindows {
+	case "", PeriodWindowsCalendar, PeriodWindowsRolling:
+	default:
+		return fmt.Errorf("unknown period windows %q", c.PeriodWindows)
+	}
+
+	switch c.Direction {
+	case "", DirectionDescending, DirectionAscending:
+	default:
+		return fmt.Errorf("unknown direction %q", c.Direction)
+	}
+
+	switch c.TieBreak {
+	case "", TieBreakSubmitted, TieBreakTime:
+	default:
+		return fmt.Errorf("unknown tie-break %q", c.TieBreak)
+	}
+
+	if c.Decay.HalfLifeDays < 0 {
+		return fmt.Errorf("decay half-life must not be negative")
+	}
+	if c.Decay.HalfLifeDays > 0 && c.Direction == DirectionAscending {
+		return fmt.Errorf("decay is not supported on boards where lower is better")
+	}
+
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("unknown time zone %q", c.Timezone)
+	}
+
+	if c.TopCacheSize < 0 {
+		return fmt.Errorf("top cache size must not be negative")
+	}
+
+	if c.DailyResetHour < 0 || c.DailyResetHour > 23 {
+		return fmt.Errorf("daily reset hour %d must be between 0 and 23", c.DailyResetHour)
+	}
+	return nil
+}
+
+// NormalizeScore applies the board's difficulty multiplier to a raw score.
+// An empty difficulty uses the board default. The boolean result is
+// false if the board has multipliers and the difficulty is not one of them.
+func (c BoardConfig) NormalizeScore(raw int, difficulty string) (int, string, bool) {
+	if len(c.DifficultyMultipliers) == 0 {
+		return raw, "", true
+	}
+
+	if difficulty == "" {
+		difficulty = c.DefaultDifficulty
+	}
+
+	multiplier, ok := c.DifficultyMultipliers[difficulty]
+	if !ok {
+		return 0, difficulty, false
+	}
+
+	return int(math.Round(float64(raw) * multiplier)), difficulty, true
+}
+
+// CheckScoreCap reports why a raw score is impossible under the board's
+// caps, or returns an empty string if it is plausible. durationMs is the
+// reported playtime, or zero if it is missing; boards with a minimum
+// duration or a rate cap require it.
+func (c BoardConfig) CheckScoreCap(raw int, durationMs int64) string {
+	if c.MaxScore > 0 && raw > c.MaxScore {
+		return fmt.Sprintf("Score exceeds maximum of %d", c.MaxScore)
+	}
+
+	if (c.MinDurationMs > 0 || c.MaxScorePerSecond > 0) && durationMs == 0 {
+		return "Run duration is required"
+	}
+
+	if c.MinDurationMs > 0 && durationMs < c.MinDurationMs {
+		return fmt.Sprintf("Run duration of %dms is below the minimum of %dms", durationMs, c.MinDurationMs)
+	}
+
+	if c.MaxScorePerSecond > 0 {
+		rate := float64(raw) / (float64(durationMs) / 1000)
+		if rate > c.MaxScorePerSecond {
+			return fmt.Sprintf("Score rate of %.0f points per second exceeds maximum of %.0f", rate, c.MaxScorePerSecond)
+		}
+	}
+
+	return ""
+}
+
+// Errors returned by CheckMetadata
+var (
+	ErrMetadataNotObject = newKindError(ErrValidation, "metadata must be a JSON object")
+	ErrMetadataTooLarge  = newKindError(ErrValidation, "metadata exceeds maximum size")
+)
+
+// CheckMetadata validates the metadata of a submission and returns it
+// compacted for storage. Missing or null metadata returns nil.
+func (c BoardConfig) CheckMetadata(metadata json.RawMessage) (json.RawMessage, error) {
+	metadata = bytes.TrimSpace(metadata)
+	if len(metadata) == 0 || bytes.Equal(metadata, []byte("null")) {
+		return nil, nil
+	}
+	if metadata[0] != '{' {
+		return nil, ErrMetadataNotObject
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, metadata); err != nil {
+		return nil, ErrMetadataNotObject
+	}
+	maxBytes := c.MaxMetadataBytes
+	if maxBytes <= 0 {
+		maxBytes = 1024
+	}
+	if compacted.Len() > maxBytes {
+		return nil, ErrMetadataTooLarge
+	}
+	return compacted.Bytes(), nil
+}
+
+// DefaultConfig returns the configuration used when no config file exists
+func DefaultConfig() Config {
+	return Config{
+		Admin: AdminConfig{
+			ApprovalWindowMinutes: 30,
+		},
+	}
+}
+
+// LoadConfig reads a JSON config file, falling back to defaults for a
+// missing file or omitted fields
+func LoadConfig(filename string) (Config, error) {
+	config := DefaultConfig()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return config, err
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, err
+	}
+
+	if err := config.Board.validate(); err != nil {
+		return config, err
+	}
+	for id, board := range config.Boards {
+		if id == MainBoard || !boardNamePattern.MatchString(id) {
+			return config, fmt.Errorf("invalid board ID %q", id)
+		}
+		if err := board.validate(); err != nil {
+			return config, fmt.Errorf("board %s: %w", id, err)
+		}
+	}
+
+	if config.Admin.ApprovalWindowMinutes <= 0 {
+		return config, fmt.Errorf("admin.approvalWindowMinutes must be positive, got %d", config.Admin.ApprovalWindowMinutes)
+	}
+
+	switch config.Persistence.Durability {
+	case "", DurabilityAsync, DurabilitySync, DurabilityFsync:
+	default:
+		return config, fmt.Errorf("unknown durability %q", config.Persistence.Durability)
+	}
+
+	switch config.Names.ProfanityAction {
+	case "", ProfanityReject, ProfanityMask:
+	default:
+		return config, fmt.Errorf("unknown profanity action %q", config.Names.ProfanityAction)
+	}
+
+	if _, err := compileCharset(config.Names.Charset); err != nil {
+		return config, err
+	}
+
+	if _, err := NewIDGenerator(config.IDs); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
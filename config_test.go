@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test period bounds follow the board's reset hour, time zone and period
+// windows
+func TestPeriodBounds(t *testing.T) {
+	// A Saturday
+	at := time.Date(2024, 6, 1, 5, 30, 0, 0, time.UTC)
+	date := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	// Daylight saving starts on 31 March 2024 and ends on 27 October
+	berlin := BoardConfig{Timezone: "Europe/Berlin"}
+
+	tests := []struct {
+		name      string
+		period    string
+		config    BoardConfig
+		at        time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+		wantOK    bool
+	}{
+		{"all time", PeriodAllTime, BoardConfig{}, at, time.Time{}, time.Time{}, true},
+		{"no period", "", BoardConfig{}, at, time.Time{}, time.Time{}, true},
+		{"daily at midnight", PeriodDaily, BoardConfig{}, at, date(6, 1, 0), date(6, 2, 0), true},
+		{"daily before reset", PeriodDaily, BoardConfig{DailyResetHour: 6}, at, date(5, 31, 6), date(6, 1, 6), true},
+		{"daily at reset", PeriodDaily, BoardConfig{DailyResetHour: 5}, date(6, 1, 5), date(6, 1, 5), date(6, 2, 5), true},
+		{"daily from other zone", PeriodDaily, BoardConfig{}, at.In(time.FixedZone("UTC-7", -7*3600)), date(6, 1, 0), date(6, 2, 0), true},
+		{"iso week", PeriodWeekly, BoardConfig{}, at, date(5, 27, 0), date(6, 3, 0), true},
+		{"iso week on monday", PeriodWeekly, BoardConfig{}, date(6, 3, 0), date(6, 3, 0), date(6, 10, 0), true},
+		{"iso week before reset", PeriodWeekly, BoardConfig{DailyResetHour: 6}, date(6, 3, 1), date(5, 27, 6), date(6, 3, 6), true},
+		{"calendar month", PeriodMonthly, BoardConfig{}, at, date(6, 1, 0), date(7, 1, 0), true},
+		{"calendar month before reset", PeriodMonthly, BoardConfig{DailyResetHour: 6}, at, date(5, 1, 6), date(6, 1, 6), true},
+		{"rolling week", PeriodWeekly, BoardConfig{PeriodWindows: PeriodWindowsRolling}, at, at.AddDate(0, 0, -7), time.Time{}, true},
+		{"rolling month", PeriodMonthly, BoardConfig{PeriodWindows: PeriodWindowsRolling}, at, at.AddDate(0, 0, -30), time.Time{}, true},
+		{"local reset hour", PeriodDaily, BoardConfig{Timezone: "America/New_York", DailyResetHour: 4}, at, date(5, 31, 8), date(6, 1, 8), true},
+		{"short day at spring change", PeriodDaily, berlin, date(3, 31, 12), date(3, 30, 23), date(3, 31, 22), true},
+		{"long day at autumn change", PeriodDaily, berlin, date(10, 27, 12), date(10, 26, 22), date(10, 27, 23), true},
+		{"week across spring change", PeriodWeekly, berlin, date(3, 31, 12), date(3, 24, 23), date(3, 31, 22), true},
+		{"local month", PeriodMonthly, berlin, date(3, 31, 23), date(3, 31, 22), date(4, 30, 22), true},
+		{"unknown", "hourly", BoardConfig{}, at, time.Time{}, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := tt.config.PeriodBounds(tt.period, tt.at)
+			if ok != tt.wantOK {
+				t.Fatalf("Expected ok %v, got %v", tt.wantOK, ok)
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("Expected %v to %v, got %v to %v", tt.wantStart, tt.wantEnd, start, end)
+			}
+		})
+	}
+}
+
+// Test board time zones must be known
+func TestBoardConfigTimezone(t *testing.T) {
+	if err := (BoardConfig{Timezone: "Europe/Berlin"}).validate(); err != nil {
+		t.Errorf("Expected a known time zone to be accepted, got %v", err)
+	}
+	if err := (BoardConfig{Timezone: "Mars/Olympus"}).validate(); err == nil {
+		t.Error("Expected an unknown time zone to be rejected")
+	}
+}
+
+// Test decayed scores halve every half-life
+func TestDecayedScore(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	decay := BoardConfig{Decay: DecayConfig{HalfLifeDays: 10}}
+
+	tests := []struct {
+		name   string
+		config BoardConfig
+		age    time.Duration
+		want   float64
+	}{
+		{"fresh", decay, 0, 1000},
+		{"one half-life", decay, 10 * 24 * time.Hour, 500},
+		{"two half-lives", decay, 20 * 24 * time.Hour, 250},
+		{"part of a half-life", decay, 5 * 24 * time.Hour, 707.11},
+		{"from the future", decay, -24 * time.Hour, 1000},
+		{"disabled", BoardConfig{}, 100 * 24 * time.Hour, 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := ScoreEntry{Score: 1000, Timestamp: now.Add(-tt.age)}
+			if got := tt.config.DecayedScore(entry, now); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// Test config files with an unusable approval window are rejected
+func TestLoadConfigApprovalWindow(t *testing.T) {
+	tests := []struct {
+		config  string
+		wantErr bool
+	}{
+		{`{}`, false},
+		{`{"admin": {"approvalWindowMinutes": 5}}`, false},
+		{`{"admin": {"approvalWindowMinutes": 0}}`, true},
+		{`{"admin": {"approvalWindowMinutes": -5}}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.config, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "config.json")
+			os.WriteFile(filename, []byte(tt.config), 0644)
+
+			if _, err := LoadConfig(filename); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// Test two-person approval stays off unless the config file turns it on
+func TestLoadConfigApprovalOptIn(t *testing.T) {
+	dir := t.TempDir()
+	config, err := LoadConfig(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatalf("Failed to load defaults: %v", err)
+	}
+	if config.Admin.TwoPersonApproval {
+		t.Errorf("Expected two-person approval to be off by default")
+	}
+
+	filename := filepath.Join(dir, "config.json")
+	os.WriteFile(filename, []byte(`{"admin": {"twoPersonApproval": true}}`), 0644)
+	config, err = LoadConfig(filename)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !config.Admin.TwoPersonApproval || config.Admin.ApprovalWindowMinutes != 30 {
+		t.Errorf("Expected approval on with the default window, got %+v", config.Admin)
+	}
+}
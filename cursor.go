@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// Cursor is a position in the board listing. Paging from a cursor stays
+// consistent while new scores are inserted, unlike an offset.
+type Cursor struct {
+	Score      int       `json:"s"`
+	DurationMs int64     `json:"d,omitempty"`
+	Timestamp  time.Time `json:"t"`
+	ID         string    `json:"i"`
+}
+
+// CursorAt returns the cursor just after entry
+func CursorAt(entry ScoreEntry) Cursor {
+	return Cursor{Score: entry.Score, DurationMs: entry.DurationMs, Timestamp: entry.Timestamp, ID: entry.ID}
+}
+
+// entry returns the board position the cursor points at
+func (c Cursor) entry() ScoreEntry {
+	return ScoreEntry{Score: c.Score, DurationMs: c.DurationMs, Timestamp: c.Timestamp, ID: c.ID}
+}
+
+// Encode returns the cursor as an opaque string for clients
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor returned by Encode
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, newKindError(ErrValidation, "invalid cursor")
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return c, newKindError(ErrValidation, "invalid cursor")
+	}
+	return c, nil
+}
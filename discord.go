@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiscordConfig posts to a Discord channel when a board gets a new leader
+type DiscordConfig struct {
+	// WebhookURL is the channel's Discord webhook; empty disables posting
+	WebhookURL string `json:"webhookUrl,omitempty"`
+	// Username overrides the name the webhook posts as
+	Username string `json:"username,omitempty"`
+}
+
+// discordColor is the gold side bar of leader announcements
+const discordColor = 0xF1C40F
+
+// maxDiscordRetryAfter caps how long a rate-limited post waits to retry
+const maxDiscordRetryAfter = 30 * time.Second
+
+// discordQueueSize is how many announcements can wait for a board's
+// channel before more are dropped
+const discordQueueSize = 100
+
+// discordMessage is the body of a Discord webhook post
+type discordMessage struct {
+	Username        string                 `json:"username,omitempty"`
+	Embeds          []discordEmbed         `json:"embeds"`
+	AllowedMentions discordAllowedMentions `json:"allowed_mentions"`
+}
+
+// discordEmbed is a rich message card
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Color       int            `json:"color"`
+	Timestamp   time.Time      `json:"timestamp"`
+	Fields      []discordField `json:"fields,omitempty"`
+}
+
+// discordField is a name and value shown in an embed
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// discordAllowedMentions controls which mentions in a message ping anyone;
+// empty Parse keeps names like @everyone from pinging the channel
+type discordAllowedMentions struct {
+	Parse []string `json:"parse"`
+}
+
+// discordPost is an announcement waiting to be posted to a channel
+type discordPost struct {
+	config  DiscordConfig
+	message discordMessage
+}
+
+// DiscordNotifier announces new board leaders in the Discord channel
+// configured for each board. Each board has its own queue, posted in
+// order by one worker, so a burst of leaders doesn't open a connection
+// per announcement and a channel that is rate limited or down doesn't
+// hold up the others.
+type DiscordNotifier struct {
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+	queues   map[string]chan discordPost
+	mu       sync.Mutex
+}
+
+// NewDiscordNotifier creates a DiscordNotifier
+func NewDiscordNotifier() *DiscordNotifier {
+	return &DiscordNotifier{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		attempts: 3,
+		backoff:  time.Second,
+		queues:   make(map[string]chan discordPost),
+	}
+}
+
+// ScoreAdded posts to the board's Discord channel if entry, just added,
+// took first place from another player's worse score, leader, or is the
+// first on the board. Entries held for review are announced when they are
+// released, if they are still in first place then.
+func (n *DiscordNotifier) ScoreAdded(board string, store BoardReader, entry ScoreEntry, leader *ScoreEntry) {
+	config := store.Config()
+	if config.Discord.WebhookURL == "" {
+		return
+	}
+	if leader != nil && (leader.PlayerName == entry.PlayerName || !config.outranks(entry, *leader)) {
+		return
+	}
+
+	afterRelease(entry, store.Now(), func() {
+		if standing, ok := store.GetStanding(context.Background(), entry.ID); !ok || standing.Rank != 1 {
+			return
+		}
+		n.enqueue(board, discordPost{config: store.Config().Discord, message: leaderMessage(board, entry, leader, store.Now())})
+	})
+}
+
+// enqueue queues a post to a board's channel without blocking, starting
+// the board's worker on its first post. Posts are dropped with a warning
+// if the queue is full.
+func (n *DiscordNotifier) enqueue(board string, post discordPost) {
+	n.mu.Lock()
+	queue, ok := n.queues[board]
+	if !ok {
+		queue = make(chan discordPost, discordQueueSize)
+		n.queues[board] = queue
+		go func() {
+			for post := range queue {
+				n.post(post.config, post.message)
+			}
+		}()
+	}
+	n.mu.Unlock()
+
+	select {
+	case queue <- post:
+	default:
+		log.Printf("Warning: Discord queue for board %s full, announcement dropped", board)
+	}
+}
+
+// leaderMessage is the announcement of entry taking first place from
+// leader
+func leaderMessage(board string, entry ScoreEntry, leader *ScoreEntry, now time.Time) discordMessage {
+	embed := discordEmbed{
+		Title:       "New leader on " + board,
+		Description: fmt.Sprintf("🏆 **%s** just took #1 with %s!", discordEscape(entry.PlayerName), groupDigits(entry.Score)),
+		Color:       discordColor,
+		Timestamp:   now.UTC(),
+	}
+	if leader != nil {
+		embed.Fields = []discordField{{
+			Name:   "Previous leader",
+			Value:  fmt.Sprintf("%s with %s", discordEscape(leader.PlayerName), groupDigits(leader.Score)),
+			Inline: true,
+		}}
+	}
+	return discordMessage{Embeds: []discordEmbed{embed}, AllowedMentions: discordAllowedMentions{Parse: []string{}}}
+}
+
+// discordEscape keeps player names from being read as Discord markdown
+func discordEscape(text string) string {
+	var escaped strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune("\\*_~`|>[]<@", r) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
+
+// groupDigits formats n with commas between groups of three digits, such
+// as 42,000
+func groupDigits(n int) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return sign + digits
+}
+
+// post sends a message, retrying after server errors and waiting out rate
+// limits. Failures are logged; announcements are not essential.
+func (n *DiscordNotifier) post(config DiscordConfig, message discordMessage) {
+	message.Username = config.Username
+	body, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Warning: Could not encode Discord message: %v", err)
+		return
+	}
+
+	backoff := n.backoff
+	for attempt := 1; attempt <= n.attempts; attempt++ {
+		wait, err := n.send(config.WebhookURL, body)
+		if err == nil {
+			return
+		}
+
+		log.Printf("Warning: Discord post failed (attempt %d/%d): %v", attempt, n.attempts, err)
+		if wait < 0 {
+			return
+		}
+		if attempt < n.attempts {
+			if wait == 0 {
+				wait = backoff
+				backoff *= 2
+			}
+			time.Sleep(wait)
+		}
+	}
+}
+
+// send makes a single post. On failure it returns how long to wait before
+// retrying: the Retry-After of a rate limit, zero for the usual backoff,
+// or a negative duration if retrying won't help.
+func (n *DiscordNotifier) send(url string, body []byte) (time.Duration, error) {
+	resp, err := n.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests:
+		seconds, _ := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64)
+		return min(time.Duration(seconds*float64(time.Second)), maxDiscordRetryAfter), fmt.Errorf("rate limited")
+	case resp.StatusCode >= 500:
+		return 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return -1, fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
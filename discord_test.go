@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test new leaders are announced in the board's Discord channel, retrying
+// after rate limits
+func TestDiscordLeaderAnnouncements(t *testing.T) {
+	posts := make(chan discordMessage, 10)
+	rateLimited := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rateLimited {
+			rateLimited = true
+			w.Header().Set("Retry-After", "0.01")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		var message discordMessage
+		json.NewDecoder(r.Body).Decode(&message)
+		posts <- message
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{Discord: DiscordConfig{WebhookURL: server.URL, Username: "Leaderboard"}})
+	discord := NewDiscordNotifier()
+	discord.backoff = 0
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithBoardID("speedrun"), WithDiscord(discord))
+	t.Cleanup(store.Close)
+
+	tests := []struct {
+		name     string
+		player   string
+		score    int
+		wantPost string
+		wantWas  string
+	}{
+		{"first score", "Mario", 100, "🏆 **Mario** just took #1 with 100!", ""},
+		{"leader improves", "Mario", 200, "", ""},
+		{"not first", "Luigi", 150, "", ""},
+		{"new leader", "*Kiro*", 42000, "🏆 **\\*Kiro\\*** just took #1 with 42,000!", "Mario with 200"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", strings.NewReader(`{"playerName":"`+tt.player+`","score":`+strconv.Itoa(tt.score)+`}`)))
+			if w.Code != http.StatusCreated {
+				t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+			}
+
+			if tt.wantPost == "" {
+				select {
+				case message := <-posts:
+					t.Errorf("Expected no post, got %+v", message)
+				case <-time.After(100 * time.Millisecond):
+				}
+				return
+			}
+
+			select {
+			case message := <-posts:
+				embed := message.Embeds[0]
+				if message.Username != "Leaderboard" || embed.Title != "New leader on speedrun" || embed.Description != tt.wantPost {
+					t.Errorf("Expected %q on speedrun, got %+v", tt.wantPost, message)
+				}
+				was := ""
+				if len(embed.Fields) > 0 {
+					was = embed.Fields[0].Value
+				}
+				if was != tt.wantWas {
+					t.Errorf("Expected previous leader %q, got %q", tt.wantWas, was)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Expected a post")
+			}
+		})
+	}
+}
+
+// Test a board's announcements are posted one at a time in order, and
+// dropped once its queue is full
+func TestDiscordQueue(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	var posted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+		<-release
+		var message discordMessage
+		json.NewDecoder(r.Body).Decode(&message)
+		mu.Lock()
+		active--
+		posted = append(posted, message.Embeds[0].Description)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	discord := NewDiscordNotifier()
+	config := DiscordConfig{WebhookURL: server.URL}
+	// The first post is taken by the worker and held by the server, so the
+	// queue fills behind it
+	discord.enqueue("speedrun", discordPost{config: config, message: discordMessage{Embeds: []discordEmbed{{Description: "0"}}}})
+	waitFor(t, "the first post to be sent", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return active == 1
+	})
+	for i := 1; i <= discordQueueSize+5; i++ {
+		discord.enqueue("speedrun", discordPost{config: config, message: discordMessage{Embeds: []discordEmbed{{Description: strconv.Itoa(i)}}}})
+	}
+	close(release)
+
+	waitFor(t, "the queued posts to be sent", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(posted) == discordQueueSize+1
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if maxActive != 1 {
+		t.Errorf("Expected one post at a time, got %d at once", maxActive)
+	}
+	for i, content := range posted {
+		if content != strconv.Itoa(i) {
+			t.Fatalf("Expected post %d to be %q, got %q", i, strconv.Itoa(i), content)
+		}
+	}
+}
+
+// Test scores are formatted with digit grouping
+func TestGroupDigits(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{42000, "42,000"},
+		{1234567, "1,234,567"},
+		{-1500, "-1,500"},
+	}
+
+	for _, tt := range tests {
+		if got := groupDigits(tt.n); got != tt.want {
+			t.Errorf("groupDigits(%d): expected %q, got %q", tt.n, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+// Package e2e boots the real server binary against a temporary data
+// directory and runs scenarios across restarts, which the unit tests in the
+// main package cannot exercise.
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	adminToken     = "e2e-admin"
+	moderatorToken = "e2e-mod"
+)
+
+// binary is the server built once for all scenarios
+var binary string
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Short() {
+		fmt.Println("Skipping end-to-end tests in short mode")
+		os.Exit(0)
+	}
+
+	dir, err := os.MkdirTemp("", "super-kiro-world-e2e")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not create build directory: %v\n", err)
+		os.Exit(1)
+	}
+	binary = filepath.Join(dir, "super-kiro-world")
+
+	build := exec.Command("go", "build", "-o", binary, ".")
+	build.Dir = ".."
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not build server: %v\n", err)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// server is a running instance of the binary
+type server struct {
+	t    *testing.T
+	dir  string
+	addr string
+	cmd  *exec.Cmd
+	logs bytes.Buffer
+}
+
+// startServer runs the binary with dir as its data directory and waits
+// until it answers health checks
+func startServer(t *testing.T, dir string) *server {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not find a free port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	s := &server{t: t, dir: dir, addr: addr}
+	s.cmd = exec.Command(binary, "-addr", addr)
+	s.cmd.Dir = dir
+	s.cmd.Env = append(os.Environ(),
+		"ADMIN_TOKENS=admin:"+adminToken,
+		"MODERATOR_TOKENS=mod:"+moderatorToken,
+	)
+	s.cmd.Stdout, s.cmd.Stderr = &s.logs, &s.logs
+	if err := s.cmd.Start(); err != nil {
+		t.Fatalf("Could not start server: %v", err)
+	}
+	t.Cleanup(s.stop)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if resp, err := http.Get("http://" + addr + "/api/health"); err == nil {
+			resp.Body.Close()
+			return s
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("Server did not become ready:\n%s", s.logs.String())
+	return nil
+}
+
+// stop kills the server without warning, as a crash or redeploy would
+func (s *server) stop() {
+	if s.cmd.ProcessState == nil {
+		s.cmd.Process.Kill()
+		s.cmd.Wait()
+	}
+}
+
+// restart stops the server and starts a new one on the same data directory
+func (s *server) restart() *server {
+	s.stop()
+	return startServer(s.t, s.dir)
+}
+
+// do sends a request, with a bearer token if given, and decodes a JSON
+// response into out if it is not nil
+func (s *server) do(method, path, token string, body interface{}, headers map[string]string, out interface{}) int {
+	s.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req, _ := http.NewRequest(method, "http://"+s.addr+path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode
+}
+
+// submit posts a score and returns the created entry
+func (s *server) submit(score int, player string) entry {
+	s.t.Helper()
+	var created entry
+	if code := s.do("POST", "/api/leaderboard", "", map[string]interface{}{"score": score, "playerName": player}, nil, &created); code != http.StatusCreated {
+		s.t.Fatalf("Expected status %d submitting %d for %s, got %d", http.StatusCreated, score, player, code)
+	}
+	return created
+}
+
+// waitForSaved waits until the entries in the data file satisfy done.
+// Changes are saved in the background, so a crash right after one can lose
+// it.
+func (s *server) waitForSaved(description string, done func(saved []entry) bool) {
+	s.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var saved []entry
+		data, err := os.ReadFile(filepath.Join(s.dir, "leaderboard.json"))
+		if err == nil && json.Unmarshal(data, &saved) == nil && done(saved) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	s.t.Fatalf("Expected %s to be saved", description)
+}
+
+// savedCount is a waitForSaved condition for n saved entries
+func savedCount(n int) func([]entry) bool {
+	return func(saved []entry) bool { return len(saved) == n }
+}
+
+// entry is the subset of a leaderboard entry the scenarios check
+type entry struct {
+	ID         string `json:"id"`
+	Score      int    `json:"score"`
+	PlayerName string `json:"playerName"`
+	Rank       int    `json:"rank"`
+	Hidden     bool   `json:"hidden"`
+	Provenance []struct {
+		Source string `json:"source"`
+		Action string `json:"action"`
+	} `json:"provenance"`
+}
+
+// Test submitted scores survive a restart in order, and new IDs don't
+// collide with restored ones
+func TestScoresSurviveRestart(t *testing.T) {
+	s := startServer(t, t.TempDir())
+
+	first := s.submit(300, "Kiro")
+	s.submit(500, "Ghost")
+	s.submit(100, "Byte")
+	s.waitForSaved("3 entries", savedCount(3))
+
+	s = s.restart()
+
+	var board []entry
+	s.do("GET", "/api/leaderboard", "", nil, nil, &board)
+	wantNames := []string{"Ghost", "Kiro", "Byte"}
+	if len(board) != len(wantNames) {
+		t.Fatalf("Expected %d entries after restart, got %d", len(wantNames), len(board))
+	}
+	for i, got := range board {
+		if got.PlayerName != wantNames[i] {
+			t.Errorf("Expected %s at %d, got %s", wantNames[i], i, got.PlayerName)
+		}
+	}
+
+	var restored entry
+	if code := s.do("GET", "/api/leaderboard/"+first.ID, "", nil, nil, &restored); code != http.StatusOK || restored.Rank != 2 {
+		t.Errorf("Expected %s restored at rank 2, got status %d and %+v", first.ID, code, restored)
+	}
+
+	next := s.submit(200, "Kiro")
+	for _, existing := range board {
+		if existing.ID == next.ID {
+			t.Errorf("Expected a new ID after restart, got %s again", next.ID)
+		}
+	}
+}
+
+// Test moderation decisions and their provenance survive a restart
+func TestModerationSurvivesRestart(t *testing.T) {
+	s := startServer(t, t.TempDir())
+
+	cheater := s.submit(99999, "Cheater")
+	s.submit(100, "Honest")
+	if code := s.do("POST", "/api/admin/entries/"+cheater.ID+"/hide", moderatorToken, nil, nil, nil); code != http.StatusOK {
+		t.Fatalf("Expected status %d hiding an entry, got %d", http.StatusOK, code)
+	}
+	s.waitForSaved("the hidden entry", func(saved []entry) bool {
+		return len(saved) == 2 && saved[0].Hidden
+	})
+
+	s = s.restart()
+
+	var board []entry
+	s.do("GET", "/api/leaderboard", "", nil, nil, &board)
+	if len(board) != 1 || board[0].PlayerName != "Honest" {
+		t.Errorf("Expected only the honest entry after restart, got %+v", board)
+	}
+
+	var hidden []entry
+	s.do("GET", "/api/admin/entries?player=Cheater", moderatorToken, nil, nil, &hidden)
+	if len(hidden) != 1 || len(hidden[0].Provenance) != 2 || hidden[0].Provenance[1].Action != "hide" {
+		t.Errorf("Expected the hidden entry with its hide recorded, got %+v", hidden)
+	}
+}
+
+// Test claimed names stay protected after a restart
+func TestNameClaimSurvivesRestart(t *testing.T) {
+	s := startServer(t, t.TempDir())
+
+	var claim struct {
+		Token string `json:"token"`
+	}
+	if code := s.do("POST", "/api/names/claim", "", map[string]string{"playerName": "Kiro"}, nil, &claim); code != http.StatusCreated {
+		t.Fatalf("Expected status %d claiming a name, got %d", http.StatusCreated, code)
+	}
+
+	s = s.restart()
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode int
+	}{
+		{"without token", "", http.StatusConflict},
+		{"wrong token", "guess", http.StatusConflict},
+		{"owner", claim.Token, http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{"X-Player-Token": tt.token}
+			code := s.do("POST", "/api/leaderboard", "", map[string]interface{}{"score": 100, "playerName": "Kiro"}, headers, nil)
+			if code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+// Test a season that ended while the server was down is rolled over on
+// startup, and stays ended with its board archived
+func TestSeasonRolloverAcrossRestart(t *testing.T) {
+	s := startServer(t, t.TempDir())
+
+	endsAt := time.Now().Add(time.Second).UTC()
+	season := map[string]interface{}{"id": "s1", "startsAt": time.Now().Add(-time.Hour).UTC(), "endsAt": endsAt}
+	if code := s.do("POST", "/api/admin/seasons", adminToken, season, nil, nil); code != http.StatusCreated {
+		t.Fatalf("Expected status %d creating a season, got %d", http.StatusCreated, code)
+	}
+	s.submit(500, "Kiro")
+	s.waitForSaved("1 entry", savedCount(1))
+
+	s.stop()
+	time.Sleep(time.Until(endsAt))
+	s = startServer(t, s.dir)
+	s.waitForSaved("the fresh board", savedCount(0))
+
+	for i := 0; i < 2; i++ {
+		var seasons []struct {
+			ID    string `json:"id"`
+			State string `json:"state"`
+		}
+		s.do("GET", "/api/seasons", "", nil, nil, &seasons)
+		if len(seasons) != 1 || seasons[0].State != "ended" {
+			t.Fatalf("Expected s1 to have ended, got %+v", seasons)
+		}
+
+		var board []entry
+		s.do("GET", "/api/leaderboard", "", nil, nil, &board)
+		if len(board) != 0 {
+			t.Errorf("Expected an empty board in the new season, got %+v", board)
+		}
+		s = s.restart()
+	}
+
+	if _, err := os.Stat(filepath.Join(s.dir, "seasons", "s1.json")); err != nil {
+		t.Errorf("Expected the season's board to be archived: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Error kinds returned by the store and other backends. Specific errors
+// wrap one of these so handlers can pick a status code with errors.Is
+// without knowing every error a backend may return.
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrValidation         = errors.New("invalid")
+	ErrConflict           = errors.New("conflict")
+	ErrStorageUnavailable = errors.New("storage unavailable")
+)
+
+// ErrEntryNotFound is returned for operations on an entry ID the store
+// does not have
+var ErrEntryNotFound = newKindError(ErrNotFound, "entry not found")
+
+// kindError is an error with its own message that also matches its kind
+type kindError struct {
+	kind    error
+	message string
+}
+
+// newKindError creates an error with the given message and kind
+func newKindError(kind error, message string) error {
+	return &kindError{kind: kind, message: message}
+}
+
+func (e *kindError) Error() string {
+	return e.message
+}
+
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
+
+// StatusForError maps an error to the HTTP status code for its kind.
+// Errors of no known kind are internal server errors.
+func StatusForError(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrStorageUnavailable):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError responds with the status code for err. Internal errors get a
+// generic message so storage details are not leaked to clients.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := StatusForError(err)
+	if status == http.StatusInternalServerError {
+		httpError(w, r, "Operation failed", status)
+		return
+	}
+	httpError(w, r, err.Error(), status)
+}
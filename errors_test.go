@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test error kinds map to HTTP status codes
+func TestStatusForError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"not found", ErrEntryNotFound, http.StatusNotFound},
+		{"validation", ErrNameTooLong, http.StatusBadRequest},
+		{"conflict", ErrReplayExists, http.StatusConflict},
+		{"storage", fmt.Errorf("%w: disk full", ErrStorageUnavailable), http.StatusServiceUnavailable},
+		{"wrapped", fmt.Errorf("approving: %w", ErrApprovalExpired), http.StatusNotFound},
+		{"unknown", errors.New("boom"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StatusForError(tt.err); got != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+// Test the store returns errors of the documented kinds
+func TestScoreStoreErrorKinds(t *testing.T) {
+	store := NewScoreStore()
+	dir := t.TempDir()
+
+	if _, err := store.SetHidden(context.Background(), "missing", true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing entry, got %v", err)
+	}
+
+	if err := store.SaveToFile(filepath.Join(dir, "missing", "leaderboard.json")); !errors.Is(err, ErrStorageUnavailable) {
+		t.Errorf("Expected ErrStorageUnavailable for a failed save, got %v", err)
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.json")
+	os.WriteFile(corrupt, []byte("{not json"), 0644)
+	if err := store.LoadFromFile(corrupt); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected ErrValidation for a corrupt file, got %v", err)
+	}
+}
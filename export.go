@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportColumns is the header row of CSV exports
+var exportColumns = []string{
+	"id", "playerName", "score", "rawScore", "difficulty", "character", "country",
+	"timestamp", "durationMs", "verification", "hidden", "pending", "metadata",
+}
+
+// exportFlushRows is how many rows board exports write between flushes,
+// so large exports reach the client as they are written rather than
+// piling up in buffers
+const exportFlushRows = 1000
+
+// flushExport pushes rows written so far to the client, if w can
+func flushExport(w io.Writer) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// boardExportColumns is the header row of board CSV exports, which also
+// give each entry's rank
+var boardExportColumns = append([]string{"rank"}, exportColumns...)
+
+// PlayerExport is the JSON document of a player's data export
+type PlayerExport struct {
+	PlayerName string       `json:"playerName"`
+	ExportedAt time.Time    `json:"exportedAt"`
+	Entries    []ScoreEntry `json:"entries"`
+}
+
+// csvCell guards a text cell against being run as a formula when the file
+// is opened in a spreadsheet, by prefixing cells that start like one with
+// a quote
+func csvCell(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// csvRow returns the cells of an entry in exportColumns order
+func csvRow(entry ScoreEntry) []string {
+	return []string{
+		entry.ID,
+		csvCell(entry.PlayerName),
+		strconv.Itoa(entry.Score),
+		strconv.Itoa(entry.RawScore),
+		entry.Difficulty,
+		entry.Character,
+		entry.Country,
+		entry.Timestamp.UTC().Format(time.RFC3339Nano),
+		strconv.FormatInt(entry.DurationMs, 10),
+		entry.Verification,
+		strconv.FormatBool(entry.Hidden),
+		strconv.FormatBool(entry.Pending),
+		csvCell(string(entry.Metadata)),
+	}
+}
+
+// writeCSV writes entries as CSV with a header row
+func writeCSV(w io.Writer, entries []ScoreEntry) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportColumns); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := writer.Write(csvRow(entry)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeBoardCSV streams the public board as CSV with a header row, each
+// entry with its rank
+func writeBoardCSV(ctx context.Context, w io.Writer, store BoardReader) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(boardExportColumns); err != nil {
+		return err
+	}
+	rows := 0
+	err := store.WalkBoard(ctx, func(rank int, entry ScoreEntry) error {
+		if err := writer.Write(append([]string{strconv.Itoa(rank)}, csvRow(entry)...)); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			writer.Flush()
+			flushExport(w)
+		}
+		return writer.Error()
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeBoardNDJSON streams the public board as newline-delimited JSON, one
+// ranked entry per line
+func writeBoardNDJSON(ctx context.Context, w io.Writer, store BoardReader) error {
+	encoder := json.NewEncoder(w)
+	rows := 0
+	return store.WalkBoard(ctx, func(rank int, entry ScoreEntry) error {
+		if err := encoder.Encode(RankedEntry{entry, &Standing{Rank: rank}}); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			flushExport(w)
+		}
+		return nil
+	})
+}
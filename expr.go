@@ -0,0 +1,559 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Expr is a compiled expression in the small rule language used by
+// moderation rules. The language supports number, string and boolean
+// literals, dotted variable lookups (board.p99), arithmetic (+ - * / %),
+// comparisons (== != < <= > >=) and the logical operators &&, || and !.
+type Expr struct {
+	source string
+	root   exprNode
+}
+
+type exprNode interface {
+	eval(vars map[string]interface{}) (interface{}, error)
+	// check returns the type name of the node's value given variables of
+	// the types in vars, or an error if its operands have the wrong types
+	check(vars map[string]interface{}) (string, error)
+}
+
+// CompileExpr parses an expression so it can be evaluated repeatedly
+func CompileExpr(source string) (*Expr, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.peek().text, p.peek().pos)
+	}
+
+	return &Expr{source: source, root: root}, nil
+}
+
+// String returns the source text of the expression
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Eval evaluates the expression against the given variables. Nested maps
+// are used for dotted lookups, so board.p99 reads vars["board"]["p99"].
+func (e *Expr) Eval(vars map[string]interface{}) (interface{}, error) {
+	return e.root.eval(vars)
+}
+
+// EvalBool evaluates the expression and requires a boolean result
+func (e *Expr) EvalBool(vars map[string]interface{}) (bool, error) {
+	v, err := e.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression result is %s, not bool", typeName(v))
+	}
+	return b, nil
+}
+
+// Check type-checks the expression against variables of the types in
+// vars, so mistakes such as comparing a number with a string or reading
+// an unknown variable are caught before the expression is evaluated. The
+// expression must produce a bool.
+func (e *Expr) Check(vars map[string]interface{}) error {
+	t, err := e.root.check(vars)
+	if err != nil {
+		return err
+	}
+	if t != "bool" {
+		return fmt.Errorf("expression result is %s, not bool", t)
+	}
+	return nil
+}
+
+// Variables returns the dotted names of all variables the expression reads
+func (e *Expr) Variables() []string {
+	var names []string
+	var walk func(n exprNode)
+	walk = func(n exprNode) {
+		switch n := n.(type) {
+		case *identNode:
+			names = append(names, strings.Join(n.path, "."))
+		case *unaryNode:
+			walk(n.operand)
+		case *binaryNode:
+			walk(n.left)
+			walk(n.right)
+		}
+	}
+	walk(e.root)
+	return names
+}
+
+// Tokenizer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var twoCharOps = []string{"&&", "||", "==", "!=", "<=", ">="}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case unicode.IsSpace(c):
+			i += size
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, src[start:i], start})
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if r != '_' && r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, token{tokIdent, src[start:i], start})
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			for i < len(src) && rune(src[i]) != c {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			i++
+			tokens = append(tokens, token{tokString, sb.String(), start})
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		default:
+			matched := false
+			for _, op := range twoCharOps {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{tokOp, op, i})
+					i += 2
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+			if strings.ContainsRune("+-*/%<>!", c) {
+				tokens = append(tokens, token{tokOp, string(c), i})
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+		}
+	}
+	tokens = append(tokens, token{tokEOF, "end of expression", len(src)})
+	return tokens, nil
+}
+
+// Parser
+
+// binaryPrecedence lists binary operators from loosest to tightest binding
+var binaryPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) parseBinary(minPrec int) (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		prec, ok := binaryPrecedence[t.text]
+		if t.kind != tokOp || !ok || prec <= minPrec {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseBinary(prec)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	t := p.peek()
+	if t.kind == tokOp && (t.text == "!" || t.text == "-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: t.text, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", t.text, t.pos)
+		}
+		return &literalNode{value: n}, nil
+	case tokString:
+		return &literalNode{value: t.text}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		}
+		path := strings.Split(t.text, ".")
+		for _, part := range path {
+			if part == "" {
+				return nil, fmt.Errorf("invalid identifier %q at offset %d", t.text, t.pos)
+			}
+		}
+		return &identNode{path: path}, nil
+	case tokLParen:
+		inner, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRParen {
+			return nil, fmt.Errorf("missing closing parenthesis for offset %d", t.pos)
+		}
+		return inner, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+}
+
+// Evaluation
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *literalNode) check(map[string]interface{}) (string, error) {
+	return typeName(n.value), nil
+}
+
+type identNode struct {
+	path []string
+}
+
+func (n *identNode) check(vars map[string]interface{}) (string, error) {
+	v, err := n.eval(vars)
+	if err != nil {
+		return "", err
+	}
+	if !isScalar(v) {
+		return "", fmt.Errorf("variable %q is an %s, not a value", strings.Join(n.path, "."), typeName(v))
+	}
+	return typeName(v), nil
+}
+
+func (n *identNode) eval(vars map[string]interface{}) (interface{}, error) {
+	var current interface{} = vars
+	for _, part := range n.path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unknown variable %q", strings.Join(n.path, "."))
+		}
+		if current, ok = m[part]; !ok {
+			return nil, fmt.Errorf("unknown variable %q", strings.Join(n.path, "."))
+		}
+	}
+	return normalizeValue(current), nil
+}
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+}
+
+func (n *unaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	v, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "!":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator ! requires bool, got %s", typeName(v))
+		}
+		return !b, nil
+	default:
+		f, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("operator - requires number, got %s", typeName(v))
+		}
+		return -f, nil
+	}
+}
+
+func (n *unaryNode) check(vars map[string]interface{}) (string, error) {
+	t, err := n.operand.check(vars)
+	if err != nil {
+		return "", err
+	}
+	want := "number"
+	if n.op == "!" {
+		want = "bool"
+	}
+	if t != want {
+		return "", fmt.Errorf("operator %s requires %s, got %s", n.op, want, t)
+	}
+	return t, nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *binaryNode) check(vars map[string]interface{}) (string, error) {
+	left, err := n.left.check(vars)
+	if err != nil {
+		return "", err
+	}
+	right, err := n.right.check(vars)
+	if err != nil {
+		return "", err
+	}
+
+	switch n.op {
+	case "&&", "||":
+		if left != "bool" || right != "bool" {
+			return "", fmt.Errorf("operator %s requires bool, got %s and %s", n.op, left, right)
+		}
+		return "bool", nil
+	case "==", "!=":
+		if left != right {
+			return "", fmt.Errorf("cannot compare %s with %s", left, right)
+		}
+		return "bool", nil
+	case "<", "<=", ">", ">=":
+		if left != right || left != "number" && left != "string" {
+			return "", fmt.Errorf("operator %s requires numbers or strings, got %s and %s", n.op, left, right)
+		}
+		return "bool", nil
+	case "+":
+		if left != right || left != "number" && left != "string" {
+			return "", fmt.Errorf("operator + requires numbers or strings, got %s and %s", left, right)
+		}
+		return left, nil
+	}
+	if left != "number" || right != "number" {
+		return "", fmt.Errorf("operator %s requires numbers, got %s and %s", n.op, left, right)
+	}
+	return "number", nil
+}
+
+func (n *binaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit logical operators
+	if n.op == "&&" || n.op == "||" {
+		lb, ok := left.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator %s requires bool, got %s", n.op, typeName(left))
+		}
+		if (n.op == "&&" && !lb) || (n.op == "||" && lb) {
+			return lb, nil
+		}
+		right, err := n.right.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		rb, ok := right.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator %s requires bool, got %s", n.op, typeName(right))
+		}
+		return rb, nil
+	}
+
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only values are comparable; comparing objects such as board would
+	// panic
+	if n.op == "==" || n.op == "!=" {
+		if !isScalar(left) || !isScalar(right) {
+			return nil, fmt.Errorf("operator %s requires values, got %s and %s", n.op, typeName(left), typeName(right))
+		}
+		return (left == right) == (n.op == "=="), nil
+	}
+
+	if ls, ok := left.(string); ok {
+		rs, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare string with %s", typeName(right))
+		}
+		switch n.op {
+		case "+":
+			return ls + rs, nil
+		case "<":
+			return ls < rs, nil
+		case "<=":
+			return ls <= rs, nil
+		case ">":
+			return ls > rs, nil
+		case ">=":
+			return ls >= rs, nil
+		}
+		return nil, fmt.Errorf("operator %s not supported for strings", n.op)
+	}
+
+	lf, lok := left.(float64)
+	rf, rok := right.(float64)
+	if !lok || !rok {
+		return nil, fmt.Errorf("operator %s requires numbers, got %s and %s", n.op, typeName(left), typeName(right))
+	}
+
+	switch n.op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return lf / rf, nil
+	case "%":
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(lf, rf), nil
+	case "<":
+		return lf < rf, nil
+	case "<=":
+		return lf <= rf, nil
+	case ">":
+		return lf > rf, nil
+	case ">=":
+		return lf >= rf, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", n.op)
+}
+
+// normalizeValue converts Go numeric types to float64 so comparisons
+// between variables and literals behave consistently
+func normalizeValue(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	}
+	return v
+}
+
+// isScalar reports whether v is a value rules can compare, rather than an
+// object of nested variables
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case bool, float64, string, nil:
+		return true
+	}
+	return false
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// maxFriends caps the length of a player's friend list
+const maxFriends = 200
+
+// ErrFriendNotFound is returned when removing a name that is not on the
+// player's friend list
+var ErrFriendNotFound = newKindError(ErrNotFound, "not on the friend list")
+
+// Friends stores the friend list of each player with a claimed name. Lists
+// are one-way: adding a friend shows their runs on your friends board
+// without asking them.
+type Friends struct {
+	lists    map[string][]string
+	filename string
+	mu       sync.RWMutex
+}
+
+// NewFriends creates a Friends saved to filename
+func NewFriends(filename string) *Friends {
+	return &Friends{
+		lists:    make(map[string][]string),
+		filename: filename,
+	}
+}
+
+// Add puts friend on player's friend list. Adding a friend twice has no
+// effect.
+func (f *Friends) Add(player, friend string) error {
+	if friend == player {
+		return newKindError(ErrValidation, "players cannot add themselves as a friend")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	list := f.lists[player]
+	for _, existing := range list {
+		if existing == friend {
+			return nil
+		}
+	}
+	if len(list) >= maxFriends {
+		return newKindError(ErrValidation, fmt.Sprintf("friend lists are limited to %d players", maxFriends))
+	}
+
+	f.lists[player] = append(list, friend)
+	if err := f.save(); err != nil {
+		f.lists[player] = list
+		return fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+	}
+	return nil
+}
+
+// Remove takes friend off player's friend list
+func (f *Friends) Remove(player, friend string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	list := f.lists[player]
+	for i, existing := range list {
+		if existing != friend {
+			continue
+		}
+		kept := append(append([]string{}, list[:i]...), list[i+1:]...)
+		f.lists[player] = kept
+		if len(kept) == 0 {
+			delete(f.lists, player)
+		}
+		if err := f.save(); err != nil {
+			f.lists[player] = list
+			return fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+		}
+		return nil
+	}
+	return ErrFriendNotFound
+}
+
+// List returns player's friends in alphabetical order
+func (f *Friends) List(player string) []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	list := append([]string{}, f.lists[player]...)
+	sort.Strings(list)
+	return list
+}
+
+// Forget drops player's friend list and takes them off everyone else's
+func (f *Friends) Forget(player string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous := f.lists
+	lists := make(map[string][]string, len(previous))
+	for owner, list := range previous {
+		if owner == player {
+			continue
+		}
+		kept := make([]string, 0, len(list))
+		for _, friend := range list {
+			if friend != player {
+				kept = append(kept, friend)
+			}
+		}
+		if len(kept) > 0 {
+			lists[owner] = kept
+		}
+	}
+	f.lists = lists
+	if err := f.save(); err != nil {
+		f.lists = previous
+		return fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+	}
+	return nil
+}
+
+// save writes the friend lists to the friends file. The caller must hold
+// the lock.
+func (f *Friends) save() error {
+	data, err := json.MarshalIndent(f.lists, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.filename, data, 0644)
+}
+
+// LoadFromFile loads friend lists saved by a previous run. A missing file
+// leaves every list empty.
+func (f *Friends) LoadFromFile() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := os.ReadFile(f.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(data, &f.lists)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test friend lists reject bad changes and survive a restart
+func TestFriends(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "friends.json")
+	friends := NewFriends(filename)
+
+	tests := []struct {
+		name    string
+		change  func() error
+		wantErr error
+	}{
+		{"add friend", func() error { return friends.Add("Kiro", "Ghost") }, nil},
+		{"add twice", func() error { return friends.Add("Kiro", "Ghost") }, nil},
+		{"add another", func() error { return friends.Add("Kiro", "Ace") }, nil},
+		{"add self", func() error { return friends.Add("Kiro", "Kiro") }, ErrValidation},
+		{"remove unknown", func() error { return friends.Remove("Kiro", "Nobody") }, ErrNotFound},
+		{"remove friend", func() error { return friends.Remove("Kiro", "Ghost") }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	reloaded := NewFriends(filename)
+	if err := reloaded.LoadFromFile(); err != nil {
+		t.Fatalf("Expected friend lists to load, got %v", err)
+	}
+	if list := reloaded.List("Kiro"); len(list) != 1 || list[0] != "Ace" {
+		t.Errorf("Expected Kiro's friends to be [Ace], got %v", list)
+	}
+	if list := reloaded.List("Ace"); len(list) != 0 {
+		t.Errorf("Expected friend lists to be one-way, got %v", list)
+	}
+}
+
+// Test players manage their friend list with their token and see a board
+// of only their friends' runs
+func TestGetLeaderboardFriends(t *testing.T) {
+	dir := t.TempDir()
+	claims := NewNameClaims(filepath.Join(dir, "claims.json"))
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store, WithNameClaims(claims), WithFriends(NewFriends(filepath.Join(dir, "friends.json"))))
+	token, _ := claims.Claim("Kiro")
+
+	store.AddScore(context.Background(), 900, "Stranger")
+	store.AddScore(context.Background(), 700, "Ghost")
+	store.AddScore(context.Background(), 500, "Kiro")
+	store.AddScore(context.Background(), 300, "Ace")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/friends", handler.Friends)
+	mux.HandleFunc("POST /api/friends", handler.AddFriend)
+	mux.HandleFunc("DELETE /api/friends/{name}", handler.RemoveFriend)
+	mux.HandleFunc("/api/", unrouted(mux))
+
+	friendTests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		token    string
+		wantCode int
+	}{
+		{"add friend", "POST", "/api/friends", `{"playerName": "Ghost"}`, token, http.StatusOK},
+		{"add another", "POST", "/api/friends", `{"playerName": "Ace"}`, token, http.StatusOK},
+		{"remove friend", "DELETE", "/api/friends/Ace", "", token, http.StatusOK},
+		{"remove unknown", "DELETE", "/api/friends/Nobody", "", token, http.StatusNotFound},
+		{"missing name", "POST", "/api/friends", `{}`, token, http.StatusBadRequest},
+		{"no token", "GET", "/api/friends", "", "", http.StatusUnauthorized},
+		{"wrong token", "GET", "/api/friends", "", "nope", http.StatusUnauthorized},
+		{"wrong method", "PUT", "/api/friends", "", token, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range friendTests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(PlayerTokenHeader, tt.token)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+
+	boardTests := []struct {
+		name      string
+		query     string
+		token     string
+		wantCode  int
+		wantNames []string
+	}{
+		{"friends board", "?scope=friends", token, http.StatusOK, []string{"Ghost", "Kiro"}},
+		{"global board", "?scope=global", "", http.StatusOK, []string{"Stranger", "Ghost", "Kiro", "Ace"}},
+		{"friends board without token", "?scope=friends", "", http.StatusUnauthorized, nil},
+		{"unknown scope", "?scope=local", token, http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range boardTests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/leaderboard"+tt.query, nil)
+			req.Header.Set(PlayerTokenHeader, tt.token)
+			w := httptest.NewRecorder()
+			handler.GetLeaderboard(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var scores []ScoreEntry
+			json.NewDecoder(w.Body).Decode(&scores)
+			if len(scores) != len(tt.wantNames) {
+				t.Fatalf("Expected %d scores, got %d", len(tt.wantNames), len(scores))
+			}
+			for i, score := range scores {
+				if score.PlayerName != tt.wantNames[i] {
+					t.Errorf("Expected %s at %d, got %s", tt.wantNames[i], i, score.PlayerName)
+				}
+			}
+		})
+	}
+}
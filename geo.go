@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// countryPattern matches ISO 3166-1 alpha-2 country codes
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// GeoConfig controls how submissions are attributed to countries
+type GeoConfig struct {
+	// CountryHeader names a request header, such as CF-IPCountry, in which
+	// a trusted proxy passes the client's GeoIP country. Submissions that
+	// don't give a country are attributed to it.
+	CountryHeader string `json:"countryHeader,omitempty"`
+}
+
+// normalizeCountry upper-cases a country code. The boolean result is false
+// if it is not a two-letter code.
+func normalizeCountry(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return code, countryPattern.MatchString(code)
+}
+
+// geoCountry returns the country a GeoIP header names, or "" if it names
+// none. Proxies use XX for unknown locations and T1 for Tor.
+func geoCountry(header string) string {
+	code, ok := normalizeCountry(header)
+	if !ok || code == "XX" || code == "T1" {
+		return ""
+	}
+	return code
+}
+
+// CountryStanding summarizes a country's runs on the public board
+type CountryStanding struct {
+	Country string `json:"country"`
+	// Players is the number of players with runs from the country
+	Players int `json:"players"`
+	// BestScore and BestPlayer describe the country's top run
+	BestScore  int    `json:"bestScore"`
+	BestPlayer string `json:"bestPlayer"`
+}
+
+// GetCountries returns every country with runs on the public board, the
+// country with the best run first
+func (s *ScoreStore) GetCountries(ctx context.Context) []CountryStanding {
+	board := s.QueryScores(ctx, ScoreQuery{})
+
+	countries := make([]CountryStanding, 0)
+	index := make(map[string]int)
+	players := make(map[string]map[string]bool)
+	for _, entry := range board {
+		if entry.Country == "" {
+			continue
+		}
+		i, ok := index[entry.Country]
+		if !ok {
+			// The board is sorted, so a country's first run is its best
+			i = len(countries)
+			index[entry.Country] = i
+			players[entry.Country] = make(map[string]bool)
+			countries = append(countries, CountryStanding{
+				Country:    entry.Country,
+				BestScore:  entry.Score,
+				BestPlayer: entry.PlayerName,
+			})
+		}
+		if !players[entry.Country][entry.PlayerName] {
+			players[entry.Country][entry.PlayerName] = true
+			countries[i].Players++
+		}
+	}
+	return countries
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test runs are attributed to the country given or the GeoIP header, and
+// boards can be filtered and summarized by country
+func TestCountryBoards(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithCountryHeader("CF-IPCountry"))
+	t.Cleanup(store.Close)
+
+	tests := []struct {
+		name        string
+		body        string
+		header      string
+		wantCode    int
+		wantCountry string
+	}{
+		{"given country", `{"score": 900, "playerName": "Maple", "country": "ca"}`, "", http.StatusCreated, "CA"},
+		{"given country wins over header", `{"score": 500, "playerName": "Moose", "country": "CA"}`, "US", http.StatusCreated, "CA"},
+		{"country from header", `{"score": 700, "playerName": "Eagle"}`, "US", http.StatusCreated, "US"},
+		{"unknown location", `{"score": 300, "playerName": "Nomad"}`, "XX", http.StatusCreated, ""},
+		{"no country", `{"score": 100, "playerName": "Anon"}`, "", http.StatusCreated, ""},
+		{"invalid country", `{"score": 100, "playerName": "Bad", "country": "Canada"}`, "", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/leaderboard", strings.NewReader(tt.body))
+			if tt.header != "" {
+				req.Header.Set("CF-IPCountry", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.SubmitScore(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusCreated {
+				return
+			}
+
+			var entry ScoreEntry
+			json.NewDecoder(w.Body).Decode(&entry)
+			if entry.Country != tt.wantCountry {
+				t.Errorf("Expected country %q, got %q", tt.wantCountry, entry.Country)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard?country=ca", nil))
+	var scores []ScoreEntry
+	json.NewDecoder(w.Body).Decode(&scores)
+	if len(scores) != 2 || scores[0].PlayerName != "Maple" || scores[1].PlayerName != "Moose" {
+		t.Errorf("Expected the Canadian runs, got %+v", scores)
+	}
+
+	w = httptest.NewRecorder()
+	handler.GetCountries(w, httptest.NewRequest("GET", "/api/leaderboard/countries", nil))
+	var countries []CountryStanding
+	json.NewDecoder(w.Body).Decode(&countries)
+	want := []CountryStanding{
+		{Country: "CA", Players: 2, BestScore: 900, BestPlayer: "Maple"},
+		{Country: "US", Players: 1, BestScore: 700, BestPlayer: "Eagle"},
+	}
+	if len(countries) != len(want) {
+		t.Fatalf("Expected %d countries, got %+v", len(want), countries)
+	}
+	for i := range want {
+		if countries[i] != want[i] {
+			t.Errorf("Expected %+v at %d, got %+v", want[i], i, countries[i])
+		}
+	}
+}
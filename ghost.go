@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// GhostConfig configures storage of positional ghost data
+type GhostConfig struct {
+	// Dir is where ghost tracks are stored, one file per entry
+	Dir string `json:"dir,omitempty"`
+	// MaxPoints is the largest number of samples accepted per level
+	MaxPoints int `json:"maxPoints,omitempty"`
+}
+
+// GhostTrack is the player's position sampled at a fixed interval while
+// playing one level
+type GhostTrack struct {
+	Level      int      `json:"level"`
+	IntervalMs int      `json:"intervalMs"`
+	Points     [][2]int `json:"points"`
+}
+
+// Ghost is a track served to clients along with the run it belongs to
+type Ghost struct {
+	EntryID    string `json:"entryId"`
+	PlayerName string `json:"playerName"`
+	Score      int    `json:"score"`
+	Rank       int    `json:"rank"`
+	GhostTrack
+}
+
+// GhostStore stores ghost tracks alongside entries
+type GhostStore struct {
+	config GhostConfig
+}
+
+// NewGhostStore creates a GhostStore, applying defaults for unset config
+func NewGhostStore(config GhostConfig) *GhostStore {
+	if config.Dir == "" {
+		config.Dir = "ghosts"
+	}
+	if config.MaxPoints <= 0 {
+		config.MaxPoints = 20000
+	}
+	return &GhostStore{config: config}
+}
+
+// Validate checks ghost tracks without storing them
+func (gs *GhostStore) Validate(tracks []GhostTrack) error {
+	seen := make(map[int]bool)
+	for _, track := range tracks {
+		if track.Level <= 0 || track.IntervalMs <= 0 {
+			return errors.New("ghost tracks need a positive level and interval")
+		}
+		if seen[track.Level] {
+			return fmt.Errorf("duplicate ghost track for level %d", track.Level)
+		}
+		seen[track.Level] = true
+		if len(track.Points) > gs.config.MaxPoints {
+			return fmt.Errorf("ghost track for level %d exceeds %d points", track.Level, gs.config.MaxPoints)
+		}
+	}
+	return nil
+}
+
+// path returns the file path of an entry's ghost tracks
+func (gs *GhostStore) path(entryID string) (string, error) {
+	if !entryIDPattern.MatchString(entryID) {
+		return "", errors.New("invalid entry ID")
+	}
+	return filepath.Join(gs.config.Dir, entryID+".json"), nil
+}
+
+// Save stores the ghost tracks of an entry
+func (gs *GhostStore) Save(entryID string, tracks []GhostTrack) error {
+	if err := gs.Validate(tracks); err != nil {
+		return err
+	}
+
+	path, err := gs.path(entryID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(tracks)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(gs.config.Dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Delete removes an entry's ghost tracks, if it has any
+func (gs *GhostStore) Delete(entryID string) error {
+	path, err := gs.path(entryID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Load returns the ghost track of an entry for one level. The second
+// return value is false if the entry has no track for that level.
+func (gs *GhostStore) Load(entryID string, level int) (GhostTrack, bool) {
+	path, err := gs.path(entryID)
+	if err != nil {
+		return GhostTrack{}, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return GhostTrack{}, false
+	}
+
+	var tracks []GhostTrack
+	if err := json.Unmarshal(data, &tracks); err != nil {
+		return GhostTrack{}, false
+	}
+
+	for _, track := range tracks {
+		if track.Level == level {
+			return track, true
+		}
+	}
+	return GhostTrack{}, false
+}
+
+// TopGhosts returns ghost tracks for a level from the highest-ranked
+// visible entries that recorded one, best first
+func (gs *GhostStore) TopGhosts(ctx context.Context, store BoardReader, level, limit int) []Ghost {
+	ghosts := make([]Ghost, 0, limit)
+	for i, entry := range store.GetTopScores(ctx, 0) {
+		if len(ghosts) >= limit {
+			break
+		}
+		if !entry.HasGhost {
+			continue
+		}
+		track, ok := gs.Load(entry.ID, level)
+		if !ok {
+			continue
+		}
+		ghosts = append(ghosts, Ghost{
+			EntryID:    entry.ID,
+			PlayerName: entry.PlayerName,
+			Score:      entry.Score,
+			Rank:       i + 1,
+			GhostTrack: track,
+		})
+	}
+	return ghosts
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// Test ghosts are served for the top runs that recorded the level
+func TestGetGhosts(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithGhosts(NewGhostStore(GhostConfig{Dir: t.TempDir()})))
+	t.Cleanup(store.Close)
+
+	submit := func(score int, name string, ghost []GhostTrack) int {
+		body, _ := json.Marshal(map[string]interface{}{"score": score, "playerName": name, "ghost": ghost})
+		req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.SubmitScore(w, req)
+		return w.Code
+	}
+
+	level1 := []GhostTrack{{Level: 1, IntervalMs: 100, Points: [][2]int{{100, 400}, {110, 390}}}}
+	submit(500, "Second", level1)
+	submit(900, "NoGhost", nil)
+	submit(700, "First", level1)
+	submit(800, "Other", []GhostTrack{{Level: 2, IntervalMs: 100, Points: [][2]int{{0, 0}}}})
+
+	if code := submit(100, "Bad", []GhostTrack{{Level: 1}}); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid ghost, got %d", code)
+	}
+
+	req := httptest.NewRequest("GET", "/api/ghosts?level=1&limit=5", nil)
+	w := httptest.NewRecorder()
+	handler.GetGhosts(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var ghosts []Ghost
+	json.NewDecoder(w.Body).Decode(&ghosts)
+	if len(ghosts) != 2 {
+		t.Fatalf("Expected 2 ghosts, got %d", len(ghosts))
+	}
+	if ghosts[0].PlayerName != "First" || ghosts[0].Rank != 3 || len(ghosts[0].Points) != 2 {
+		t.Errorf("Expected First ranked 3 with 2 points, got %+v", ghosts[0])
+	}
+	if ghosts[1].PlayerName != "Second" {
+		t.Errorf("Expected Second as the next ghost, got %s", ghosts[1].PlayerName)
+	}
+
+	req = httptest.NewRequest("GET", "/api/ghosts", nil)
+	w = httptest.NewRecorder()
+	handler.GetGhosts(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without level, got %d", w.Code)
+	}
+}
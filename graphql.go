@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// graphqlSchema is the schema served at /api/graphql
+const graphqlSchema = `
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+scalar Time
+
+type Query {
+	# The public board, best first. The board defaults to the main board and
+	# the limit to the board's default, capped at its maximum.
+	leaderboard(board: String, limit: Int, offset: Int, difficulty: String, character: String, country: String): [Entry!]!
+	# A player's runs on the public board, null if they have none
+	player(name: String!, board: String): Player
+	# Statistics over the public board, with submissions per day for the
+	# given number of days (default 30)
+	stats(board: String, days: Int): Stats!
+}
+
+type Mutation {
+	# Submits a run with the same checks as POST /api/leaderboard
+	submitScore(input: ScoreInput!): SubmitResult!
+}
+
+type Entry {
+	id: ID!
+	score: Int!
+	playerName: String!
+	timestamp: Time!
+	difficulty: String
+	character: String
+	country: String
+	durationMs: Int
+	replayVerified: Boolean!
+	# Rank on the whole board; tied entries share a rank. Null for entries
+	# not on the public board.
+	rank: Int
+}
+
+type Player {
+	name: String!
+	totalRuns: Int!
+	bestScore: Int!
+	averageScore: Float!
+	firstPlayed: Time!
+	lastPlayed: Time!
+	# Rank of the player's best run, null if it is not on the board
+	rank: Int
+	rankHistory: [RankPoint!]!
+}
+
+type RankPoint {
+	entryId: ID!
+	score: Int!
+	rank: Int!
+	at: Time!
+}
+
+type Stats {
+	count: Int!
+	mean: Float!
+	median: Float!
+	p90: Int!
+	p99: Int!
+	max: Int!
+	submissionsPerDay: [DayCount!]!
+}
+
+type DayCount {
+	date: String!
+	count: Int!
+}
+
+input ScoreInput {
+	board: String
+	playerName: String!
+	score: Int!
+	difficulty: String
+	character: String
+	country: String
+	durationMs: Int
+	runId: String
+}
+
+type SubmitResult {
+	entry: Entry!
+	personalBest: Boolean!
+	previousBest: Int!
+	# Set when the run is held back for review
+	pending: Boolean!
+	# Set when the run was not stored because it did not beat the player's
+	# best on a best-per-player board
+	superseded: Boolean!
+	# Set when the run was already stored moments before; entry is the
+	# stored one
+	duplicate: Boolean!
+}
+`
+
+// maxGraphQLBytes caps the size of a GraphQL request body
+const maxGraphQLBytes = 1 << 20
+
+// maxGraphQLDepth caps how deeply GraphQL queries can nest
+const maxGraphQLDepth = 8
+
+// GraphQLHandler serves /api/graphql, letting frontends fetch exactly the
+// fields they need from boards, players and statistics in one request
+type GraphQLHandler struct {
+	schema *graphql.Schema
+}
+
+// NewGraphQLHandler creates a GraphQLHandler for the boards of a
+// BoardManager
+func NewGraphQLHandler(boards *BoardManager) *GraphQLHandler {
+	return &GraphQLHandler{
+		schema: graphql.MustParseSchema(graphqlSchema, &graphqlResolver{boards: boards}, graphql.MaxDepth(maxGraphQLDepth)),
+	}
+}
+
+// graphqlRequest is the body of a GraphQL request
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphqlHeaderKey is the context key of the request headers, which
+// mutations pass on with the runs they submit
+type graphqlHeaderKey struct{}
+
+// ServeHTTP handles POST /api/graphql with a JSON body holding the query,
+// and optionally the operation name and variables
+func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	var req graphqlRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLBytes)).Decode(&req); err != nil || req.Query == "" {
+		httpError(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), graphqlHeaderKey{}, r.Header)
+	json.NewEncoder(w).Encode(h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+}
+
+// graphqlResolver resolves the root query and mutation fields
+type graphqlResolver struct {
+	boards *BoardManager
+}
+
+// board returns the handler of a board, the main board if id is nil
+func (r *graphqlResolver) board(id *string) (*LeaderboardHandler, error) {
+	handler, ok := r.boards.lookup(optional(id))
+	if !ok {
+		return nil, errors.New("Board not found")
+	}
+	return handler, nil
+}
+
+// optional returns the value of an optional argument, zero if not given
+func optional[T any](value *T) T {
+	if value == nil {
+		var zero T
+		return zero
+	}
+	return *value
+}
+
+func (r *graphqlResolver) Leaderboard(ctx context.Context, args struct {
+	Board      *string
+	Limit      *int32
+	Offset     *int32
+	Difficulty *string
+	Character  *string
+	Country    *string
+}) ([]*entryResolver, error) {
+	handler, err := r.board(args.Board)
+	if err != nil {
+		return nil, err
+	}
+	if optional(args.Offset) < 0 {
+		return nil, errors.New("offset must be non-negative")
+	}
+
+	limit, _ := handler.store.Config().QueryLimit(int(optional(args.Limit)))
+	entries := handler.store.QueryScores(ctx, ScoreQuery{
+		Limit:      limit,
+		Offset:     int(optional(args.Offset)),
+		Difficulty: optional(args.Difficulty),
+		Character:  strings.ToLower(optional(args.Character)),
+		Country:    strings.ToUpper(optional(args.Country)),
+	})
+	resolvers := make([]*entryResolver, len(entries))
+	for i, entry := range entries {
+		resolvers[i] = &entryResolver{entry: entry.public(), store: handler.store}
+	}
+	return resolvers, nil
+}
+
+func (r *graphqlResolver) Player(ctx context.Context, args struct {
+	Name  string
+	Board *string
+}) (*playerResolver, error) {
+	handler, err := r.board(args.Board)
+	if err != nil {
+		return nil, err
+	}
+	profile, ok := handler.store.GetPlayerProfile(ctx, args.Name)
+	if !ok {
+		return nil, nil
+	}
+	return &playerResolver{profile}, nil
+}
+
+func (r *graphqlResolver) Stats(ctx context.Context, args struct {
+	Board *string
+	Days  *int32
+}) (*statsResolver, error) {
+	handler, err := r.board(args.Board)
+	if err != nil {
+		return nil, err
+	}
+	days := 30
+	if args.Days != nil {
+		if *args.Days <= 0 {
+			return nil, errors.New("days must be positive")
+		}
+		days = min(int(*args.Days), maxStatsDays)
+	}
+	return &statsResolver{handler.store.GetStats(ctx, handler.store.Now(), days)}, nil
+}
+
+// scoreInput is the input of the submitScore mutation
+type scoreInput struct {
+	Board      *string
+	PlayerName string
+	Score      int32
+	Difficulty *string
+	Character  *string
+	Country    *string
+	DurationMs *int32
+	RunId      *string
+}
+
+func (r *graphqlResolver) SubmitScore(ctx context.Context, args struct{ Input scoreInput }) (*submitResultResolver, error) {
+	input := args.Input
+	handler, err := r.board(input.Board)
+	if err != nil {
+		return nil, err
+	}
+
+	header, _ := ctx.Value(graphqlHeaderKey{}).(http.Header)
+	result, err := handler.submitRetryable(ctx, ScoreSubmission{
+		Score:      int(input.Score),
+		PlayerName: input.PlayerName,
+		Difficulty: optional(input.Difficulty),
+		Character:  optional(input.Character),
+		Country:    optional(input.Country),
+		DurationMs: int64(optional(input.DurationMs)),
+		RunID:      optional(input.RunId),
+	}, header)
+	var refused *submissionError
+	if errors.As(err, &refused) {
+		return nil, &graphqlSubmissionError{refused}
+	} else if err != nil {
+		return nil, err
+	}
+	return &submitResultResolver{result: result, store: handler.store}, nil
+}
+
+// graphqlSubmissionError reports a refused run with the HTTP status it
+// would be answered with, as the error's "status" extension, any invalid
+// fields as its "violations" extension and a free name for a claimed one
+// as its "suggestion" extension
+type graphqlSubmissionError struct {
+	*submissionError
+}
+
+func (e *graphqlSubmissionError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"status": e.status}
+	if len(e.violations) > 0 {
+		extensions["violations"] = e.violations
+	}
+	if e.suggestion != "" {
+		extensions["suggestion"] = e.suggestion
+	}
+	return extensions
+}
+
+// clampInt32 converts n to a GraphQL Int, which is 32 bits
+func clampInt32[T int | int64](n T) int32 {
+	return int32(max(min(n, math.MaxInt32), math.MinInt32))
+}
+
+// nonEmpty returns nil for an empty string, for optional fields
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// entryResolver resolves the fields of an entry
+type entryResolver struct {
+	entry ScoreEntry
+	store BoardReader
+}
+
+func (r *entryResolver) ID() graphql.ID          { return graphql.ID(r.entry.ID) }
+func (r *entryResolver) Score() int32            { return clampInt32(r.entry.Score) }
+func (r *entryResolver) PlayerName() string      { return r.entry.PlayerName }
+func (r *entryResolver) Timestamp() graphql.Time { return graphql.Time{Time: r.entry.Timestamp} }
+func (r *entryResolver) Difficulty() *string     { return nonEmpty(r.entry.Difficulty) }
+func (r *entryResolver) Character() *string      { return nonEmpty(r.entry.Character) }
+func (r *entryResolver) Country() *string        { return nonEmpty(r.entry.Country) }
+func (r *entryResolver) ReplayVerified() bool    { return r.entry.ReplayVerified }
+
+func (r *entryResolver) DurationMs() *int32 {
+	if r.entry.DurationMs == 0 {
+		return nil
+	}
+	duration := clampInt32(r.entry.DurationMs)
+	return &duration
+}
+
+// Rank is only looked up when it is asked for
+func (r *entryResolver) Rank(ctx context.Context) *int32 {
+	standing, ok := r.store.GetStanding(ctx, r.entry.ID)
+	if !ok {
+		return nil
+	}
+	rank := clampInt32(standing.Rank)
+	return &rank
+}
+
+// playerResolver resolves the fields of a player profile
+type playerResolver struct {
+	profile PlayerProfile
+}
+
+func (r *playerResolver) Name() string              { return r.profile.PlayerName }
+func (r *playerResolver) TotalRuns() int32          { return clampInt32(r.profile.TotalRuns) }
+func (r *playerResolver) BestScore() int32          { return clampInt32(r.profile.BestScore) }
+func (r *playerResolver) AverageScore() float64     { return r.profile.AverageScore }
+func (r *playerResolver) FirstPlayed() graphql.Time { return graphql.Time{Time: r.profile.FirstPlayed} }
+func (r *playerResolver) LastPlayed() graphql.Time  { return graphql.Time{Time: r.profile.LastPlayed} }
+
+func (r *playerResolver) Rank() *int32 {
+	if r.profile.Rank == 0 {
+		return nil
+	}
+	rank := clampInt32(r.profile.Rank)
+	return &rank
+}
+
+func (r *playerResolver) RankHistory() []*rankPointResolver {
+	points := make([]*rankPointResolver, len(r.profile.RankHistory))
+	for i, point := range r.profile.RankHistory {
+		points[i] = &rankPointResolver{point}
+	}
+	return points
+}
+
+// rankPointResolver resolves the fields of a point of a player's rank
+// history
+type rankPointResolver struct {
+	point RankPoint
+}
+
+func (r *rankPointResolver) EntryId() graphql.ID { return graphql.ID(r.point.EntryID) }
+func (r *rankPointResolver) Score() int32        { return clampInt32(r.point.Score) }
+func (r *rankPointResolver) Rank() int32         { return clampInt32(r.point.Rank) }
+func (r *rankPointResolver) At() graphql.Time    { return graphql.Time{Time: r.point.At} }
+
+// statsResolver resolves the fields of board statistics
+type statsResolver struct {
+	stats BoardStats
+}
+
+func (r *statsResolver) Count() int32    { return clampInt32(r.stats.Count) }
+func (r *statsResolver) Mean() float64   { return r.stats.Mean }
+func (r *statsResolver) Median() float64 { return r.stats.Median }
+func (r *statsResolver) P90() int32      { return clampInt32(r.stats.P90) }
+func (r *statsResolver) P99() int32      { return clampInt32(r.stats.P99) }
+func (r *statsResolver) Max() int32      { return clampInt32(r.stats.Max) }
+
+func (r *statsResolver) SubmissionsPerDay() []*dayCountResolver {
+	days := make([]*dayCountResolver, len(r.stats.SubmissionsPerDay))
+	for i, day := range r.stats.SubmissionsPerDay {
+		days[i] = &dayCountResolver{day}
+	}
+	return days
+}
+
+// dayCountResolver resolves the fields of a day's submission count
+type dayCountResolver struct {
+	day DayCount
+}
+
+func (r *dayCountResolver) Date() string { return r.day.Date }
+func (r *dayCountResolver) Count() int32 { return clampInt32(r.day.Count) }
+
+// submitResultResolver resolves the fields of a submission result
+type submitResultResolver struct {
+	result SubmissionResult
+	store  BoardReader
+}
+
+func (r *submitResultResolver) Entry() *entryResolver {
+	return &entryResolver{entry: r.result.ScoreEntry, store: r.store}
+}
+
+func (r *submitResultResolver) PersonalBest() bool  { return r.result.PersonalBest }
+func (r *submitResultResolver) PreviousBest() int32 { return clampInt32(r.result.PreviousBest) }
+func (r *submitResultResolver) Pending() bool       { return r.result.Pending || r.result.HeldUntil != nil }
+func (r *submitResultResolver) Superseded() bool    { return r.result.Superseded }
+func (r *submitResultResolver) Duplicate() bool     { return r.result.Duplicate }
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test queries and mutations over /api/graphql
+func TestGraphQL(t *testing.T) {
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 300, "Mario")
+	store.AddScore(context.Background(), 300, "Luigi")
+	store.AddScore(context.Background(), 100, "Toad")
+	boards := NewBoardManager()
+	boards.Add(MainBoard, NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json"))))
+	t.Cleanup(store.Close)
+	handler := NewGraphQLHandler(boards)
+
+	tests := []struct {
+		name      string
+		query     string
+		variables string
+		want      string
+		wantError string
+	}{
+		{
+			"leaderboard",
+			`{ leaderboard(limit: 3) { rank score } }`, `{}`,
+			`{"leaderboard":[{"rank":1,"score":300},{"rank":1,"score":300},{"rank":3,"score":100}]}`, "",
+		},
+		{
+			"offset",
+			`{ leaderboard(offset: 2) { playerName } }`, `{}`,
+			`{"leaderboard":[{"playerName":"Toad"}]}`, "",
+		},
+		{
+			"player",
+			`query($name: String!) { player(name: $name) { totalRuns bestScore rank } }`, `{"name":"Toad"}`,
+			`{"player":{"bestScore":100,"rank":3,"totalRuns":1}}`, "",
+		},
+		{
+			"unknown player",
+			`{ player(name: "Wario") { totalRuns } }`, `{}`,
+			`{"player":null}`, "",
+		},
+		{
+			"stats",
+			`{ stats(days: 2) { count max submissionsPerDay { count } } }`, `{}`,
+			`{"stats":{"count":3,"max":300,"submissionsPerDay":[{"count":0},{"count":3}]}}`, "",
+		},
+		{
+			"submit",
+			`mutation($input: ScoreInput!) { submitScore(input: $input) { personalBest entry { playerName rank } } }`, `{"input":{"playerName":"Kiro","score":500}}`,
+			`{"submitScore":{"entry":{"playerName":"Kiro","rank":1},"personalBest":true}}`, "",
+		},
+		{
+			"rejected submission",
+			`mutation { submitScore(input: {playerName: "", score: 10}) { pending } }`, `{}`,
+			``, "Player name is required",
+		},
+		{
+			"unknown board",
+			`{ leaderboard(board: "speedrun") { score } }`, `{}`,
+			``, "Board not found",
+		},
+		{
+			"unknown field",
+			`{ leaderboard { secret } }`, `{}`,
+			``, `Cannot query field "secret"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]interface{}{"query": tt.query, "variables": json.RawMessage(tt.variables)})
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("POST", "/api/graphql", strings.NewReader(string(body))))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+			}
+
+			var resp struct {
+				Data   json.RawMessage `json:"data"`
+				Errors []struct {
+					Message string `json:"message"`
+				} `json:"errors"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if tt.wantError != "" {
+				if len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, tt.wantError) {
+					t.Errorf("Expected error %q, got %+v", tt.wantError, resp.Errors)
+				}
+				return
+			}
+			if len(resp.Errors) > 0 {
+				t.Fatalf("Expected no errors, got %+v", resp.Errors)
+			}
+			var got, want interface{}
+			json.Unmarshal(resp.Data, &got)
+			json.Unmarshal([]byte(tt.want), &want)
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("Expected %s, got %s", wantJSON, gotJSON)
+			}
+		})
+	}
+}
+
+// Test requests that aren't GraphQL queries are refused
+func TestGraphQLInvalidRequests(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/graphql", NewGraphQLHandler(NewBoardManager()))
+	mux.HandleFunc("/api/", unrouted(mux))
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{"preflight", "OPTIONS", "", http.StatusOK},
+		{"get", "GET", "", http.StatusMethodNotAllowed},
+		{"invalid JSON", "POST", "{", http.StatusBadRequest},
+		{"missing query", "POST", `{"variables":{}}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, "/api/graphql", strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
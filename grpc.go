@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "super-kiro-world/leaderboardpb"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative leaderboardpb/leaderboard.proto
+
+// GRPCServer serves the leaderboard gRPC API defined in
+// leaderboardpb/leaderboard.proto for the boards of a BoardManager, so game
+// engines with native gRPC support share the boards of the HTTP API
+type GRPCServer struct {
+	pb.UnimplementedLeaderboardServer
+	boards *BoardManager
+}
+
+// NewGRPCServer creates a GRPCServer for boards
+func NewGRPCServer(boards *BoardManager) *GRPCServer {
+	return &GRPCServer{boards: boards}
+}
+
+// Serve accepts gRPC connections on listener until it fails
+func (s *GRPCServer) Serve(listener net.Listener) error {
+	server := grpc.NewServer()
+	pb.RegisterLeaderboardServer(server, s)
+	return server.Serve(listener)
+}
+
+// board returns the handler of a board, the main board if id is empty
+func (s *GRPCServer) board(id string) (*LeaderboardHandler, error) {
+	handler, ok := s.boards.lookup(id)
+	if !ok {
+		return nil, status.Error(codes.NotFound, "Board not found")
+	}
+	return handler, nil
+}
+
+// SubmitScore submits a run to a board with the same checks as runs
+// posted over HTTP
+func (s *GRPCServer) SubmitScore(ctx context.Context, req *pb.SubmitScoreRequest) (*pb.SubmitScoreResponse, error) {
+	handler, err := s.board(req.Board)
+	if err != nil {
+		return nil, err
+	}
+
+	// Headers are passed as metadata of the same name
+	header := make(http.Header)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, name := range forwardedHeaders {
+		if values := md.Get(name); len(values) > 0 {
+			header.Set(name, values[0])
+		}
+	}
+
+	result, err := handler.submitRetryable(ctx, ScoreSubmission{
+		Score:      int(req.Score),
+		PlayerName: req.PlayerName,
+		Difficulty: req.Difficulty,
+		Character:  req.Character,
+		Country:    req.Country,
+		DurationMs: req.DurationMs,
+		RunID:      req.RunId,
+	}, header)
+	var refused *submissionError
+	if errors.As(err, &refused) {
+		return nil, status.Error(grpcCode(refused.status), refused.message)
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	rank := 0
+	if result.Standing != nil {
+		rank = result.Standing.Rank
+	}
+	return &pb.SubmitScoreResponse{
+		Entry:        entryProto(result.ScoreEntry, rank),
+		PersonalBest: result.PersonalBest,
+		PreviousBest: int64(result.PreviousBest),
+		Pending:      result.Pending || result.HeldUntil != nil,
+		Superseded:   result.Superseded,
+		Duplicate:    result.Duplicate,
+	}, nil
+}
+
+// grpcCode maps the HTTP status of a failed request to a gRPC status code
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
+// GetTopScores returns the top of a board, best first
+func (s *GRPCServer) GetTopScores(ctx context.Context, req *pb.GetTopScoresRequest) (*pb.GetTopScoresResponse, error) {
+	handler, err := s.board(req.Board)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetTopScoresResponse{Entries: topEntryProtos(ctx, handler.store, int(req.Limit))}, nil
+}
+
+// WatchLeaderboard sends the top of a board, then sends it again each time
+// it changes until the client cancels the stream
+func (s *GRPCServer) WatchLeaderboard(req *pb.WatchLeaderboardRequest, stream pb.Leaderboard_WatchLeaderboardServer) error {
+	handler, err := s.board(req.Board)
+	if err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	var sent []*pb.ScoreEntry
+	for {
+		// Take the version first, so a change made while reading the top
+		// is sent next time around
+		version := handler.store.Version(handler.store.Now())
+		entries := topEntryProtos(ctx, handler.store, int(req.Limit))
+		if sent == nil || !slices.EqualFunc(entries, sent, func(a, b *pb.ScoreEntry) bool { return proto.Equal(a, b) }) {
+			if err := stream.Send(&pb.LeaderboardUpdate{Entries: entries, Version: version}); err != nil {
+				return err
+			}
+			sent = entries
+		}
+
+		if _, err := handler.store.WaitForChanges(ctx, version, maxChangesWait); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// topEntryProtos returns the top of a board, with limit applied as on
+// GET /api/leaderboard
+func topEntryProtos(ctx context.Context, store BoardReader, limit int) []*pb.ScoreEntry {
+	config := store.Config()
+	limit, _ = config.QueryLimit(limit)
+	entries := store.QueryScores(ctx, ScoreQuery{Limit: limit})
+
+	protos := make([]*pb.ScoreEntry, len(entries))
+	rank := 0
+	for i, entry := range entries {
+		if i == 0 || config.outranks(entries[i-1], entry) {
+			rank = i + 1
+		}
+		protos[i] = entryProto(entry, rank)
+	}
+	return protos
+}
+
+// entryProto converts an entry to its gRPC message
+func entryProto(entry ScoreEntry, rank int) *pb.ScoreEntry {
+	return &pb.ScoreEntry{
+		Id:             entry.ID,
+		Score:          int64(entry.Score),
+		PlayerName:     entry.PlayerName,
+		Timestamp:      timestamppb.New(entry.Timestamp),
+		Difficulty:     entry.Difficulty,
+		Character:      entry.Character,
+		Country:        entry.Country,
+		DurationMs:     entry.DurationMs,
+		ReplayVerified: entry.ReplayVerified,
+		Rank:           int32(rank),
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "super-kiro-world/leaderboardpb"
+)
+
+// newGRPCTestClient serves boards over an in-memory gRPC connection
+func newGRPCTestClient(t *testing.T, boards *BoardManager) pb.LeaderboardClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pb.RegisterLeaderboardServer(server, NewGRPCServer(boards))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewLeaderboardClient(conn)
+}
+
+// Test submitting and reading scores over gRPC, with the same checks as
+// the HTTP API
+func TestGRPCSubmitScore(t *testing.T) {
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 300, "Mario")
+	boards := NewBoardManager()
+	boards.Add(MainBoard, NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithIdempotency(NewIdempotencyCache(IdempotencyConfig{}))))
+	t.Cleanup(store.Close)
+	client := newGRPCTestClient(t, boards)
+
+	tests := []struct {
+		name     string
+		req      *pb.SubmitScoreRequest
+		key      string
+		wantCode codes.Code
+		wantRank int32
+	}{
+		{"valid", &pb.SubmitScoreRequest{PlayerName: "Kiro", Score: 500}, "", codes.OK, 1},
+		{"below the leader", &pb.SubmitScoreRequest{PlayerName: "Luigi", Score: 100}, "run-1", codes.OK, 3},
+		{"retried", &pb.SubmitScoreRequest{PlayerName: "Luigi", Score: 100}, "run-1", codes.OK, 3},
+		{"missing name", &pb.SubmitScoreRequest{Score: 100}, "", codes.InvalidArgument, 0},
+		{"negative score", &pb.SubmitScoreRequest{PlayerName: "Kiro", Score: -1}, "", codes.InvalidArgument, 0},
+		{"unknown board", &pb.SubmitScoreRequest{Board: "speedrun", PlayerName: "Kiro", Score: 100}, "", codes.NotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.key != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, IdempotencyKeyHeader, tt.key)
+			}
+			resp, err := client.SubmitScore(ctx, tt.req)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("Expected code %v, got %v", tt.wantCode, err)
+			}
+			if err == nil && (resp.Entry.PlayerName != tt.req.PlayerName || resp.Entry.Rank != tt.wantRank) {
+				t.Errorf("Expected %s at rank %d, got %+v", tt.req.PlayerName, tt.wantRank, resp.Entry)
+			}
+		})
+	}
+
+	top, err := client.GetTopScores(context.Background(), &pb.GetTopScoresRequest{Limit: 2})
+	if err != nil {
+		t.Fatalf("Expected the top scores, got %v", err)
+	}
+	if len(top.Entries) != 2 || top.Entries[0].PlayerName != "Kiro" || top.Entries[1].PlayerName != "Mario" {
+		t.Errorf("Expected Kiro then Mario, got %v", top.Entries)
+	}
+	if n := len(store.GetAllEntries(context.Background())); n != 3 {
+		t.Errorf("Expected the retry not to add an entry, got %d entries", n)
+	}
+}
+
+// Test watching a board streams its top again when it changes
+func TestGRPCWatchLeaderboard(t *testing.T) {
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 300, "Mario")
+	store.AddScore(context.Background(), 200, "Luigi")
+	boards := NewBoardManager()
+	boards.Add(MainBoard, NewLeaderboardHandler(store))
+	client := newGRPCTestClient(t, boards)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.WatchLeaderboard(ctx, &pb.WatchLeaderboardRequest{Limit: 2})
+	if err != nil {
+		t.Fatalf("Expected to watch the board, got %v", err)
+	}
+
+	update, err := stream.Recv()
+	if err != nil || len(update.Entries) != 2 || update.Entries[0].PlayerName != "Mario" || update.Version == "" {
+		t.Fatalf("Expected the current top, got %v, %v", update, err)
+	}
+
+	// Changes below the watched top are not sent
+	store.AddScore(context.Background(), 50, "Toad")
+	store.AddScore(context.Background(), 900, "Kiro")
+	update, err = stream.Recv()
+	if err != nil || len(update.Entries) != 2 || update.Entries[0].PlayerName != "Kiro" || update.Entries[1].PlayerName != "Mario" {
+		t.Fatalf("Expected Kiro and Mario, got %v, %v", update, err)
+	}
+}
@@ -1,107 +1,1572 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // LeaderboardHandler handles HTTP requests for leaderboard operations
 type LeaderboardHandler struct {
-	store *ScoreStore
+	store      LeaderboardStore
+	rules      *RuleSet
+	antiCheat  *AntiCheatClient
+	validators []ScoreValidator
+	replays    *ReplayStore
+	ghosts     *GhostStore
+	names      *NameFilter
+	claims     *NameClaims
+	friends    *Friends
+	// countryHeader names the GeoIP country header set by a trusted proxy
+	countryHeader string
+	cache         CacheConfig
+	gate          *WriteGate
+	holds         *RecordHoldNotifier
+	idempotency   *IdempotencyCache
+	webhooks      *Webhooks
+	discord       *DiscordNotifier
+	// boards are the boards whose entries decide who may claim a name
+	boards *BoardManager
+	// board is the ID of the board, as named in announcements
+	board string
+	// file is where the board is saved after changes
+	file string
+}
+
+// ScoreSubmission is the request body of POST /api/leaderboard
+type ScoreSubmission struct {
+	Score      int    `json:"score"`
+	PlayerName string `json:"playerName"`
+	Difficulty string `json:"difficulty,omitempty"`
+	// Character is the character or skin the run was played with
+	Character string `json:"character,omitempty"`
+	// Country is an ISO 3166-1 alpha-2 code such as "CA"
+	Country    string `json:"country,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	// Metadata is an optional game-specific JSON object stored with the
+	// entry
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// EndedAt is the client's clock when the run ended
+	EndedAt *time.Time `json:"endedAt,omitempty"`
+	// Telemetry optionally describes the run so the score can be verified
+	Telemetry []LevelTelemetry `json:"telemetry,omitempty"`
+	// Replay is an optional gzip-compressed input replay, base64 encoded
+	Replay []byte `json:"replay,omitempty"`
+	// Ghost optionally records the player's position for ghost playback
+	Ghost []GhostTrack `json:"ghost,omitempty"`
+	// RunID optionally identifies the run, so sending it twice in quick
+	// succession stores it once
+	RunID string `json:"runId,omitempty"`
+}
+
+// SubmissionResult is the response to a score submission: the entry along
+// with where it placed, so the game can show it without another request
+type SubmissionResult struct {
+	ScoreEntry
+	// Standing is omitted for runs not on the public board, such as those
+	// awaiting review
+	*Standing
+	// PersonalBest is set when the run beat the player's previous runs
+	PersonalBest bool `json:"personalBest"`
+	// PreviousBest is the player's best score before this run
+	PreviousBest int `json:"previousBest,omitempty"`
+	// ReplayUploadToken authorizes uploading the run's replay afterwards;
+	// it is only set when the run was stored without one
+	ReplayUploadToken string `json:"replayUploadToken,omitempty"`
+}
+
+// LeaderboardPage is the response envelope of GET /api/leaderboard when
+// ?envelope=true is given
+type LeaderboardPage struct {
+	Entries []ScoreEntry `json:"entries"`
+	// Offset is the number of entries skipped before Entries
+	Offset int `json:"offset"`
+	// Limit is the limit applied to the query
+	Limit int `json:"limit"`
+	// LimitClamped is set when the requested limit was above the board
+	// maximum and Limit was used instead
+	LimitClamped bool `json:"limitClamped,omitempty"`
+	// Total is the number of entries on the board matching the query
+	Total int `json:"total"`
+	// NextCursor is passed as ?cursor= to fetch the next page; it is
+	// empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+	// ResetsAt is when the requested period, such as the daily board,
+	// starts over
+	ResetsAt *time.Time `json:"resetsAt,omitempty"`
+}
+
+// PlayerTokenHeader carries the token of a claimed name on submissions
+const PlayerTokenHeader = "X-Player-Token"
+
+// ReplayTokenHeader carries the upload token a submission returned when
+// its replay is uploaded afterwards
+const ReplayTokenHeader = "X-Replay-Token"
+
+// NameClaimRequest is the request body of POST /api/names/claim
+type NameClaimRequest struct {
+	PlayerName string `json:"playerName"`
+}
+
+// NameClaimResponse returns the token proving ownership of a claimed name
+type NameClaimResponse struct {
+	PlayerName string `json:"playerName"`
+	Token      string `json:"token"`
+}
+
+// FriendRequest is the request body of POST /api/friends
+type FriendRequest struct {
+	PlayerName string `json:"playerName"`
+}
+
+// FriendList is the response of the friends endpoints
+type FriendList struct {
+	PlayerName string   `json:"playerName"`
+	Friends    []string `json:"friends"`
+}
+
+// Leaderboard scopes selectable with ?scope=
+const (
+	ScopeGlobal  = "global"
+	ScopeFriends = "friends"
+)
+
+// HandlerOption configures optional LeaderboardHandler dependencies
+type HandlerOption func(*LeaderboardHandler)
+
+// WithRuleSet enables moderation rule evaluation on every submission
+func WithRuleSet(rules *RuleSet) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.rules = rules
+	}
+}
+
+// WithAntiCheat forwards flagged submissions to an anti-cheat service
+func WithAntiCheat(client *AntiCheatClient) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.antiCheat = client
+	}
+}
+
+// WithValidators adds game-specific validators run before a score is stored
+func WithValidators(validators ...ScoreValidator) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.validators = append(h.validators, validators...)
+	}
+}
+
+// WithReplays enables storing input replays alongside entries
+func WithReplays(replays *ReplayStore) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.replays = replays
+	}
+}
+
+// WithGhosts enables storing and serving positional ghost data
+func WithGhosts(ghosts *GhostStore) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.ghosts = ghosts
+	}
+}
+
+// WithNameFilter rejects or masks offensive player names
+func WithNameFilter(names *NameFilter) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.names = names
+	}
+}
+
+// WithNameClaims lets players claim names so only they can submit under them
+func WithNameClaims(claims *NameClaims) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.claims = claims
+	}
+}
+
+// WithFriends lets players with claimed names keep friend lists and view
+// friends-only boards
+func WithFriends(friends *Friends) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.friends = friends
+	}
+}
+
+// WithCountryHeader attributes submissions without a country to the GeoIP
+// country a trusted proxy passes in header
+func WithCountryHeader(header string) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.countryHeader = header
+	}
+}
+
+// WithCacheControl lets browsers and CDNs cache board responses
+func WithCacheControl(cache CacheConfig) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.cache = cache
+	}
+}
+
+// WithRecordHoldWebhook notifies moderators of record-breaking scores held
+// for review
+func WithRecordHoldWebhook(holds *RecordHoldNotifier) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.holds = holds
+	}
+}
+
+// WithBoardID names the board the handler serves in announcements
+func WithBoardID(board string) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.board = board
+	}
+}
+
+// WithWebhooks announces the board's top scores and records to webhook
+// targets
+func WithWebhooks(webhooks *Webhooks) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.webhooks = webhooks
+	}
+}
+
+// WithDiscord announces new leaders in the Discord channel set in the
+// board configuration
+func WithDiscord(discord *DiscordNotifier) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.discord = discord
+	}
+}
+
+// WithWriteGate rejects submissions while the leaderboard cannot be saved
+func WithWriteGate(gate *WriteGate) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.gate = gate
+	}
+}
+
+// WithIdempotency replays the response to submissions retried with the
+// same Idempotency-Key instead of storing them again
+func WithIdempotency(cache *IdempotencyCache) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.idempotency = cache
+	}
+}
+
+// WithPlayerBoards lets a name be claimed only by whoever submitted the
+// entries under it, or under a name that looks like it, on any of boards
+// rather than only this one
+func WithPlayerBoards(boards *BoardManager) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.boards = boards
+	}
+}
+
+// WithSaveFile saves the board to filename instead of the main leaderboard
+// file
+func WithSaveFile(filename string) HandlerOption {
+	return func(h *LeaderboardHandler) {
+		h.file = filename
+	}
 }
 
 // NewLeaderboardHandler creates a new LeaderboardHandler
-func NewLeaderboardHandler(store *ScoreStore) *LeaderboardHandler {
-	return &LeaderboardHandler{
+func NewLeaderboardHandler(store LeaderboardStore, opts ...HandlerOption) *LeaderboardHandler {
+	h := &LeaderboardHandler{
 		store: store,
+		file:  leaderboardFile,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // SubmitScore handles POST /api/leaderboard
 func (h *LeaderboardHandler) SubmitScore(w http.ResponseWriter, r *http.Request) {
 	// Add CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Expose-Headers", "Idempotent-Replayed")
 	w.Header().Set("Content-Type", "application/json")
 
-	// Handle preflight request
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	// Retries carrying an Idempotency-Key get the original response
+	if key := r.Header.Get(IdempotencyKeyHeader); key != "" && h.idempotency != nil {
+		h.idempotency.idempotent(w, r, key, h.store.Now(), h.submit)
+		return
+	}
+	h.submit(w, r)
+}
+
+// submit decodes a run posted over HTTP and submits it, responding with
+// the entry and where it placed
+func (h *LeaderboardHandler) submit(w http.ResponseWriter, r *http.Request) {
+	// Parse request body, which the game client may send as MessagePack,
+	// and answer in the encoding it prefers
+	var req ScoreSubmission
+	contentType := responseType(r)
+	w.Header().Set("Content-Type", contentType)
+
+	if err := decodeBody(w, r, &req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httpError(w, r, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		httpError(w, r, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Only accept POST requests
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	result, err := h.submitRun(r.Context(), req, r.Header)
+	if err != nil {
+		writeRefusal(w, r, err)
 		return
 	}
+	w.WriteHeader(submissionStatus(result, nil))
+	encodeBody(w, contentType, result)
+}
 
-	// Parse request body
-	var req struct {
-		Score      int    `json:"score"`
-		PlayerName string `json:"playerName"`
+// submitRun validates and stores a run, returning the entry and where it
+// placed. Runs submitted over every API (HTTP, gRPC, GraphQL) go through
+// it, so they all get the same checks. header holds the request's
+// headers, of which the player token and country headers are read. A
+// refused run returns a *submissionError.
+func (h *LeaderboardHandler) submitRun(ctx context.Context, req ScoreSubmission, header http.Header) (SubmissionResult, error) {
+	// Don't accept scores that would be lost on restart
+	if h.gate != nil && !h.gate.Allow() {
+		return SubmissionResult{}, refuse(http.StatusServiceUnavailable, "Leaderboard storage is unavailable")
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
+	// Validate input, reporting every invalid field at once so the game
+	// can highlight each of them
+	var violations []FieldViolation
+	invalid := func(field, code, message string) {
+		violations = append(violations, FieldViolation{Field: field, Code: code, Message: message})
 	}
 
-	// Validate input
+	// Names are shown publicly, so clean them up and filter offensive ones
 	if req.PlayerName == "" {
-		http.Error(w, "Player name is required", http.StatusBadRequest)
-		return
+		invalid("playerName", ViolationRequired, "Player name is required")
+	} else if h.names != nil {
+		name, err := h.names.Check(req.PlayerName)
+		if err != nil {
+			invalid("playerName", ViolationInvalid, "Invalid player name: "+err.Error())
+		} else {
+			req.PlayerName = name
+		}
 	}
 
 	if req.Score < 0 {
-		http.Error(w, "Score must be non-negative", http.StatusBadRequest)
-		return
+		invalid("score", ViolationNegative, "Score must be non-negative")
+	}
+
+	if req.DurationMs < 0 {
+		invalid("durationMs", ViolationNegative, "Duration must be non-negative")
+	}
+
+	// Characters are matched case-insensitively by ?character=
+	req.Character = strings.ToLower(req.Character)
+	if req.Character != "" && !boardNamePattern.MatchString(req.Character) {
+		invalid("character", ViolationFormat, "Character may only contain letters, digits, - and _")
+	}
+
+	if req.RunID != "" && !boardNamePattern.MatchString(req.RunID) {
+		invalid("runId", ViolationFormat, "Run ID may only contain letters, digits, - and _, up to 64 characters")
+	}
+
+	// Attribute the run to the country given, or else to the client's
+	// GeoIP country
+	if req.Country != "" {
+		country, ok := normalizeCountry(req.Country)
+		if !ok {
+			invalid("country", ViolationFormat, "Country must be a two-letter ISO 3166-1 code")
+		}
+		req.Country = country
+	} else if h.countryHeader != "" {
+		req.Country = geoCountry(header.Get(h.countryHeader))
+	}
+
+	// Normalize the score for the difficulty it was played on
+	score, difficulty, ok := h.store.Config().NormalizeScore(req.Score, req.Difficulty)
+	if !ok {
+		invalid("difficulty", ViolationUnknown, "Unknown difficulty")
+	}
+
+	metadata, err := h.store.Config().CheckMetadata(req.Metadata)
+	if errors.Is(err, ErrMetadataTooLarge) {
+		return SubmissionResult{}, invalidRun(http.StatusRequestEntityTooLarge, FieldViolation{Field: "metadata", Code: ViolationTooLarge, Message: "Metadata too large"})
+	} else if err != nil {
+		invalid("metadata", ViolationInvalid, "Metadata must be a JSON object")
+	}
+
+	if len(violations) > 0 {
+		return SubmissionResult{}, invalidRun(http.StatusBadRequest, violations...)
+	}
+
+	// Claimed names can only be used by their owner
+	if h.claims != nil {
+		if err := h.claims.Authorize(req.PlayerName, header.Get(PlayerTokenHeader)); err != nil {
+			return SubmissionResult{}, h.nameConflict(req.PlayerName)
+		}
+	}
+
+	// Names that look like another player's, such as "Кіго" written in
+	// Cyrillic for "Kiro", would pass as theirs on the board
+	if other, ok := h.store.FindLookalike(ctx, req.PlayerName); ok {
+		return SubmissionResult{}, refuse(http.StatusConflict, "Player name looks too much like "+other)
+	}
+
+	// Reject scores that are impossible under the board's caps
+	if reason := h.store.Config().CheckScoreCap(req.Score, req.DurationMs); reason != "" {
+		return SubmissionResult{}, refuse(http.StatusUnprocessableEntity, reason)
+	}
+
+	// Check an inline replay before accepting the score
+	if len(req.Replay) > 0 {
+		if h.replays == nil {
+			return SubmissionResult{}, refuse(http.StatusBadRequest, "Replays are not supported")
+		}
+		if err := h.replays.Validate(req.Replay); err != nil {
+			return SubmissionResult{}, invalidRun(http.StatusBadRequest, FieldViolation{Field: "replay", Code: ViolationInvalid, Message: "Invalid replay: " + err.Error()})
+		}
+	}
+
+	// Check ghost data before accepting the score
+	if len(req.Ghost) > 0 && h.ghosts != nil {
+		if err := h.ghosts.Validate(req.Ghost); err != nil {
+			return SubmissionResult{}, invalidRun(http.StatusBadRequest, FieldViolation{Field: "ghost", Code: ViolationInvalid, Message: "Invalid ghost data: " + err.Error()})
+		}
+	}
+
+	// Run pluggable game-specific validation
+	for _, validator := range h.validators {
+		if err := validator.ValidateScore(req); err != nil {
+			return SubmissionResult{}, refuse(http.StatusUnprocessableEntity, err.Error())
+		}
+	}
+
+	entry := ScoreEntry{
+		Score:      score,
+		PlayerName: req.PlayerName,
+		Character:  req.Character,
+		Country:    req.Country,
+		DurationMs: req.DurationMs,
+		Metadata:   metadata,
+		Telemetry:  req.Telemetry,
+		RunID:      req.RunID,
+		Provenance: []ProvenanceStep{{
+			Source:     SourceAPI,
+			Credential: playerCredential(header.Get(PlayerTokenHeader)),
+		}},
+	}
+	if difficulty != "" {
+		entry.RawScore = req.Score
+		entry.Difficulty = difficulty
+	}
+
+	// Keep the client's run-end time only if its clock is close to ours
+	if req.EndedAt != nil && h.store.Config().AcceptClientTime(*req.EndedAt, h.store.Now()) {
+		entry.ClientTimestamp = req.EndedAt
+	}
+
+	// Hold back implausibly good scores for moderator approval
+	if threshold := h.store.Config().ReviewThreshold; threshold > 0 && h.store.Config().beats(entry.Score, threshold) {
+		entry.Pending = true
+	}
+
+	// Mark or quarantine statistical outliers
+	if anomaly := h.store.Config().Anomaly; anomaly.Enabled() {
+		window := anomaly.Window
+		if window <= 0 {
+			window = 200
+		}
+		if reason := DetectAnomaly(h.store.GetRecentScores(ctx, window), entry.Score, anomaly); reason != "" {
+			log.Printf("Anomalous submission from %q: %s", req.PlayerName, reason)
+			entry.Suspect = true
+			if anomaly.Action == AnomalyActionQuarantine {
+				entry.Pending = true
+			}
+		}
+	}
+
+	// Briefly hold back scores that would take first place
+	previousRecord := 0
+	if hold := h.store.Config().RecordHold; hold.Enabled() && !entry.Pending {
+		if top := h.store.GetTopScores(ctx, 1); len(top) > 0 && h.store.Config().outranks(entry, top[0]) {
+			previousRecord = top[0].Score
+			releasesAt := h.store.Now().Add(time.Duration(hold.Seconds) * time.Second)
+			entry.HeldUntil = &releasesAt
+		}
+	}
+
+	// Flag the submission if it matches any moderation rule
+	if h.rules != nil && h.rules.Len() > 0 {
+		entry.Flags = h.rules.Evaluate(buildRuleVars(ctx, h.store, entry.Score, req.PlayerName, h.store.Now()))
+	}
+
+	// Flagged and suspect entries await an anti-cheat verdict
+	if h.antiCheat != nil && (len(entry.Flags) > 0 || entry.Suspect) {
+		entry.Verification = VerificationPending
+	}
+
+	// Note the player's best before this run for the response, and the
+	// board leader for record announcements
+	previous, played := h.store.GetPlayerHistory(ctx, entry.PlayerName)
+	var leader *ScoreEntry
+	if h.webhooks != nil || (h.discord != nil && h.store.Config().Discord.WebhookURL != "") {
+		if top := h.store.GetTopScores(ctx, 1); len(top) > 0 {
+			leader = &top[0]
+		}
 	}
 
 	// Add score to store
-	entry := h.store.AddScore(req.Score, req.PlayerName)
+	entry.HasReplay = len(req.Replay) > 0
+	entry.HasGhost = len(req.Ghost) > 0 && h.ghosts != nil
+	added := h.store.AddEntry(ctx, entry)
+	entry = added
 
-	// Save to file (async to not block response)
-	go h.store.SaveToFile("leaderboard.json")
+	// A run sent again moments after it was stored, such as by a
+	// double-tapped submit button, gets the stored entry back
+	if entry.Duplicate {
+		result := SubmissionResult{ScoreEntry: entry.public(), ReplayUploadToken: h.replayUploadToken(entry)}
+		if standing, ok := h.store.GetStanding(ctx, entry.ID); ok {
+			result.Standing = &standing
+		}
+		return result, nil
+	}
 
-	// Return the created entry
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(entry)
+	// Runs that don't beat the player's best aren't kept on best-per-player
+	// boards; tell the client without storing anything for them
+	if entry.Superseded {
+		entry.HasReplay, entry.HasGhost = false, false
+		return SubmissionResult{ScoreEntry: entry.public(), PreviousBest: previous.BestScore}, nil
+	}
+
+	if entry.HasGhost {
+		if err := h.ghosts.Save(entry.ID, req.Ghost); err != nil {
+			log.Printf("Warning: Could not save ghost for %s: %v", entry.ID, err)
+			entry, _ = h.store.SetHasGhost(ctx, entry.ID, false)
+		}
+	}
+
+	if entry.HasReplay {
+		if err := h.replays.Save(entry.ID, req.Replay); err != nil {
+			log.Printf("Warning: Could not save replay for %s: %v", entry.ID, err)
+			entry, _ = h.store.SetHasReplay(ctx, entry.ID, false)
+		} else {
+			entry = h.verifyReplay(ctx, entry)
+		}
+	}
+
+	// Save to file, in the background unless the durability setting
+	// requires the entry to be saved before it is accepted
+	if err := h.store.Persist(h.file); err != nil {
+		// The entry was never acknowledged, so take it back rather than
+		// keep a score the client will submit again
+		h.withdraw(ctx, added)
+		return SubmissionResult{}, refuse(http.StatusServiceUnavailable, "Leaderboard storage is unavailable")
+	}
+
+	if entry.Verification == VerificationPending {
+		h.antiCheat.Submit(entry)
+	}
+
+	if entry.HeldUntil != nil && h.holds != nil {
+		h.holds.Notify(RecordHold{Entry: entry, PreviousRecord: previousRecord, ReleasesAt: *entry.HeldUntil})
+	}
+
+	if h.webhooks != nil {
+		h.webhooks.ScoreAdded(h.board, h.store, entry, leader)
+	}
+	if h.discord != nil {
+		h.discord.ScoreAdded(h.board, h.store, entry, leader)
+	}
+
+	// Return the created entry and where it placed
+	result := SubmissionResult{
+		ScoreEntry:   entry.public(),
+		PersonalBest: !played || h.store.Config().beats(entry.Score, previous.BestScore),
+		PreviousBest: previous.BestScore,
+		// Only the submitter learns the token to upload the replay with
+		ReplayUploadToken: h.replayUploadToken(entry),
+	}
+	if standing, ok := h.store.GetStanding(ctx, entry.ID); ok {
+		result.Standing = &standing
+	}
+	return result, nil
+}
+
+// withdraw takes back an entry that couldn't be saved, putting back the
+// runs it replaced and removing its replay and ghost
+func (h *LeaderboardHandler) withdraw(ctx context.Context, entry ScoreEntry) {
+	if err := h.store.WithdrawEntry(ctx, entry); err != nil {
+		log.Printf("Warning: Could not withdraw unsaved entry %s: %v", entry.ID, err)
+	}
+	if entry.HasReplay {
+		if err := h.replays.Delete(entry.ID); err != nil {
+			log.Printf("Warning: Could not remove replay of unsaved entry %s: %v", entry.ID, err)
+		}
+	}
+	if entry.HasGhost {
+		if err := h.ghosts.Delete(entry.ID); err != nil {
+			log.Printf("Warning: Could not remove ghost of unsaved entry %s: %v", entry.ID, err)
+		}
+	}
+}
+
+// submissionError is a run submitRun refused, with the HTTP status it is
+// answered with, which other APIs map to their own codes
+type submissionError struct {
+	status     int
+	message    string
+	violations []FieldViolation
+	// suggestion is a free name offered when the name is claimed
+	suggestion string
+}
+
+func (e *submissionError) Error() string {
+	return e.message
+}
+
+// refuse returns a submissionError with status and message
+func refuse(status int, message string) error {
+	return &submissionError{status: status, message: message}
+}
+
+// invalidRun returns a submissionError listing the invalid fields of a
+// run, whose message is the first violation's
+func invalidRun(status int, violations ...FieldViolation) error {
+	return &submissionError{status: status, message: violations[0].Message, violations: violations}
+}
+
+// submissionStatus is the HTTP status a submission is answered with: 201
+// Created for a new entry, 200 OK for a run that wasn't stored again, or
+// the status of the error it was refused with
+func submissionStatus(result SubmissionResult, err error) int {
+	var refused *submissionError
+	switch {
+	case errors.As(err, &refused):
+		return refused.status
+	case err != nil:
+		return StatusForError(err)
+	case result.Duplicate || result.Superseded:
+		return http.StatusOK
+	}
+	return http.StatusCreated
+}
+
+// writeRefusal responds with the problem a run was refused for. Runs
+// refused because storage is unavailable are told when to retry.
+func writeRefusal(w http.ResponseWriter, r *http.Request, err error) {
+	var refused *submissionError
+	if !errors.As(err, &refused) {
+		writeError(w, r, err)
+		return
+	}
+	if refused.status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "30")
+	}
+	writeProblem(w, Problem{
+		Type:       problemType(refused.status),
+		Title:      http.StatusText(refused.status),
+		Status:     refused.status,
+		Detail:     refused.message,
+		Instance:   r.URL.Path,
+		RequestID:  requestIDFrom(r.Context()),
+		Violations: refused.violations,
+		Suggestion: refused.suggestion,
+	})
+}
+
+// forwardedHeaders are the request headers other APIs pass on with the
+// runs submitted through them
+var forwardedHeaders = []string{PlayerTokenHeader, IdempotencyKeyHeader}
+
+// submitRetryable submits a run for another API, such as gRPC. header
+// holds the forwardedHeaders of the request; as over HTTP, a run retried
+// with the same Idempotency-Key gets the first result back.
+func (h *LeaderboardHandler) submitRetryable(ctx context.Context, submission ScoreSubmission, header http.Header) (SubmissionResult, error) {
+	key := header.Get(IdempotencyKeyHeader)
+	if key == "" || h.idempotency == nil {
+		return h.submitRun(ctx, submission, header)
+	}
+	// Board IDs can't start with /, so these keys are never confused with
+	// those of submissions over HTTP, which are scoped to the path
+	scope := h.board + "\x00" + header.Get(PlayerTokenHeader)
+	return h.idempotency.run(scope, key, submission, h.store.Now(), func() (SubmissionResult, error) {
+		return h.submitRun(ctx, submission, header)
+	})
 }
 
 // GetLeaderboard handles GET /api/leaderboard
 func (h *LeaderboardHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
 	// Add CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count, X-Next-Cursor, ETag, X-Board-Version")
 	w.Header().Set("Content-Type", "application/json")
 
-	// Handle preflight request
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	// Parse limit query parameter, falling back to the board default and
+	// clamping it to the board maximum
+	requested, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	limit, clamped := h.store.Config().QueryLimit(requested)
+
+	// Parse offset query parameter for browsing past the top entries
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		parsedOffset, err := strconv.Atoi(offsetStr)
+		if err != nil || parsedOffset < 0 {
+			httpError(w, r, "Offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = parsedOffset
+	}
+
+	// Parse minScore query parameter, for integrations that only want
+	// qualifying runs
+	minScore := 0
+	if minScoreStr := r.URL.Query().Get("minScore"); minScoreStr != "" {
+		parsedMinScore, err := strconv.Atoi(minScoreStr)
+		if err != nil {
+			httpError(w, r, "minScore must be an integer", http.StatusBadRequest)
+			return
+		}
+		minScore = parsedMinScore
+	}
+
+	// Parse the date range, e.g. for "today's scores"
+	since, ok := parseTimeParam(r, "since")
+	if !ok {
+		httpError(w, r, "Invalid since: use an RFC3339 time", http.StatusBadRequest)
+		return
+	}
+	until, ok := parseTimeParam(r, "until")
+	if !ok {
+		httpError(w, r, "Invalid until: use an RFC3339 time", http.StatusBadRequest)
+		return
+	}
+	if !since.IsZero() && !until.IsZero() && !since.Before(until) {
+		httpError(w, r, "since must be before until", http.StatusBadRequest)
+		return
+	}
+
+	// Parse the period, e.g. the daily board, which narrows the date range
+	periodStart, periodEnd, ok := h.store.Config().PeriodBounds(r.URL.Query().Get("period"), h.store.Now())
+	if !ok {
+		httpError(w, r, "period must be alltime, daily, weekly or monthly", http.StatusBadRequest)
 		return
 	}
+	if periodStart.After(since) {
+		since = periodStart
+	}
 
-	// Only accept GET requests
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// Parse the ordering, e.g. most recent runs first
+	query := ScoreQuery{
+		Sort:  r.URL.Query().Get("sort"),
+		Order: r.URL.Query().Get("order"),
+	}
+	if !query.validSort() {
+		httpError(w, r, "sort must be score, timestamp, name or decayed and order must be asc or desc", http.StatusBadRequest)
 		return
 	}
+	config := h.store.Config()
+	if query.Sort == "" && config.Decay.Default {
+		query.Sort = SortDecayed
+	}
+	if query.Sort == SortDecayed && config.Decay.HalfLifeDays <= 0 {
+		httpError(w, r, "Decayed ranking is not enabled on this board", http.StatusBadRequest)
+		return
+	}
+
+	// Parse cursor query parameter, which continues from a previous page
+	if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+		if offset > 0 {
+			httpError(w, r, "Use either offset or cursor, not both", http.StatusBadRequest)
+			return
+		}
+		if !query.boardOrder(config.Direction) {
+			httpError(w, r, "Cursors are only supported in board order, sorting by score", http.StatusBadRequest)
+			return
+		}
+		cursor, err := DecodeCursor(cursorStr)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		query.After = &cursor
+	}
+
+	// Get top scores, optionally for a single difficulty, character or
+	// country, or only runs whose replay reproduced the score. One extra
+	// entry is fetched to tell whether there is a next page.
+	query.Limit = limit + 1
+	query.Offset = offset
+	query.Since, query.Until = since, until
+	query.MinScore = minScore
+	query.Difficulty = r.URL.Query().Get("difficulty")
+	query.Character = strings.ToLower(r.URL.Query().Get("character"))
+	query.Country = strings.ToUpper(r.URL.Query().Get("country"))
+	switch r.URL.Query().Get("scope") {
+	case "", ScopeGlobal:
+	case ScopeFriends:
+		// Friends boards list the requester's friends and the requester
+		player, ok := h.authenticatedPlayer(r)
+		if !ok || h.friends == nil {
+			httpError(w, r, "A player token is required for the friends board", http.StatusUnauthorized)
+			return
+		}
+		query.Players = map[string]bool{player: true}
+		for _, friend := range h.friends.List(player) {
+			query.Players[friend] = true
+		}
+	default:
+		httpError(w, r, "Scope must be global or friends", http.StatusBadRequest)
+		return
+	}
+	query.VerifiedOnly = r.URL.Query().Get("verified") == "true"
+	envelope := r.URL.Query().Get("envelope") == "true"
+
+	// Bandwidth-sensitive clients can ask for protobuf or MessagePack
+	// instead of JSON. The
+	// response varies with Accept, so caches keep the encodings apart.
+	contentType := negotiateContentType(r.Header.Get("Accept"), "application/json", ProtobufContentType, MsgpackContentType)
+	w.Header().Add("Vary", "Accept")
+
+	// Polling clients whose copy is current get 304 Not Modified. Decayed
+	// scores and rolling periods change continuously, so they are never
+	// cached.
+	h.setCacheControl(w, query.Players != nil)
+	version := h.store.Version(h.store.Now())
+	rolling := !periodStart.IsZero() && periodEnd.IsZero()
+	if query.Sort != SortDecayed && !rolling {
+		etag := leaderboardETag(version, query, envelope, clamped, contentType)
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
 
-	// Parse limit query parameter (default to 10)
-	limit := 10
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
+	// The version lets clients poll GET /api/leaderboard/changes for what
+	// is added after this response
+	w.Header().Set("X-Board-Version", version)
+	scores, total := h.store.QueryPage(r.Context(), query)
+	nextCursor := ""
+	if len(scores) > limit {
+		scores = scores[:limit]
+		if query.boardOrder(config.Direction) {
+			nextCursor = CursorAt(scores[limit-1]).Encode()
+			w.Header().Set("X-Next-Cursor", nextCursor)
 		}
 	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	// Return scores, wrapped with the limit applied if asked for. Protobuf
+	// has no bare lists, so protobuf responses are always wrapped.
+	var resetsAt *time.Time
+	if !periodEnd.IsZero() {
+		resetsAt = &periodEnd
+	}
+	page := LeaderboardPage{
+		Entries:      publicEntries(scores),
+		Offset:       offset,
+		Limit:        limit,
+		LimitClamped: clamped,
+		Total:        total,
+		NextCursor:   nextCursor,
+		ResetsAt:     resetsAt,
+	}
+	if contentType == ProtobufContentType {
+		writeProto(w, r, pageProto(page))
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	if envelope {
+		encodeBody(w, contentType, page)
+		return
+	}
+	encodeBody(w, contentType, page.Entries)
+}
+
+// replayEntry returns the entry whose replay the request is for, or
+// responds 404 if there is none
+func (h *LeaderboardHandler) replayEntry(w http.ResponseWriter, r *http.Request) (ScoreEntry, bool) {
+	if h.replays == nil {
+		httpError(w, r, "Replays are not supported", http.StatusNotFound)
+		return ScoreEntry{}, false
+	}
 
-	// Get top scores
-	scores := h.store.GetTopScores(limit)
+	entry, ok := h.store.GetEntry(r.Context(), r.PathValue("id"))
+	if !ok {
+		httpError(w, r, "Entry not found", http.StatusNotFound)
+		return ScoreEntry{}, false
+	}
+	return entry, true
+}
+
+// Replay handles GET /api/leaderboard/{id}/replay, returning an entry's
+// compressed input replay
+func (h *LeaderboardHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	entry, ok := h.replayEntry(w, r)
+	if !ok {
+		return
+	}
+
+	// Runs moderators removed are not served to anyone else, even with a
+	// link signed before they were removed. Links to held and pending runs
+	// are only given to moderators and the anti-cheat service.
+	if (entry.Hidden || entry.Verification == VerificationRejected) && !moderating(r) {
+		httpError(w, r, "Entry not found", http.StatusNotFound)
+		return
+	}
+
+	// Replays are much larger than scores, so they are only served
+	// through short-lived signed links and at a limited rate
+	query := r.URL.Query()
+	if !h.replays.CheckSignature(entry.ID, query.Get("expires"), query.Get("sig"), h.store.Now()) {
+		httpError(w, r, "Invalid or expired replay link", http.StatusForbidden)
+		return
+	}
+	path, err := h.replays.Open(entry.ID)
+	if err != nil {
+		httpError(w, r, "Replay not found", http.StatusNotFound)
+		return
+	}
+	if !h.replays.acquireDownload() {
+		w.Header().Set("Retry-After", "5")
+		httpError(w, r, "Too many replay downloads", http.StatusTooManyRequests)
+		return
+	}
+	defer h.replays.releaseDownload()
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+entry.ID+`.replay.gz"`)
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeFile(newThrottledWriter(w, h.replays.config.BytesPerSecond), r, path)
+}
 
-	// Return scores
-	json.NewEncoder(w).Encode(scores)
+// replayUploadToken returns the token for uploading an entry's replay
+// afterwards, or an empty string if replays aren't supported or the entry
+// already has one
+func (h *LeaderboardHandler) replayUploadToken(entry ScoreEntry) string {
+	if h.replays == nil || entry.HasReplay {
+		return ""
+	}
+	return h.replays.UploadToken(entry.ID)
+}
+
+// UploadReplay handles PUT /api/leaderboard/{id}/replay, which attaches a
+// replay shortly after the score was submitted. The X-Replay-Token header
+// must hold the upload token the submission returned.
+func (h *LeaderboardHandler) UploadReplay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	entry, ok := h.replayEntry(w, r)
+	if !ok {
+		return
+	}
+
+	if !h.replays.CheckUploadToken(entry.ID, r.Header.Get(ReplayTokenHeader)) {
+		httpError(w, r, "Invalid replay upload token", http.StatusForbidden)
+		return
+	}
+
+	window := time.Duration(h.replays.config.UploadWindowMinutes) * time.Minute
+	if h.store.Now().Sub(entry.Timestamp) > window {
+		httpError(w, r, "Replay upload window has closed", http.StatusForbidden)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, int64(h.replays.config.MaxBytes)+1))
+	if err != nil {
+		httpError(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	switch err := h.replays.Save(entry.ID, data); {
+	case errors.Is(err, ErrReplayTooLarge):
+		httpError(w, r, "Replay too large", http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		log.Printf("Could not store replay for %s: %v", entry.ID, err)
+		writeError(w, r, err)
+		return
+	}
+
+	entry, _ = h.store.SetHasReplay(r.Context(), entry.ID, true)
+	entry = h.verifyReplay(r.Context(), entry)
+
+	// The upload counts once it is saved as the durability setting
+	// requires; otherwise take it back so the client can send it again
+	if err := h.store.Persist(h.file); err != nil {
+		log.Printf("Error: Could not save replay upload for %s: %v", entry.ID, err)
+		h.store.SetReplayVerified(r.Context(), entry.ID, false)
+		h.store.SetHasReplay(r.Context(), entry.ID, false)
+		h.replays.Delete(entry.ID)
+		writeRefusal(w, r, refuse(http.StatusServiceUnavailable, "Leaderboard storage is unavailable"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+}
+
+// RankedEntry is an entry with its current standing on the board
+type RankedEntry struct {
+	ScoreEntry
+	// Standing is omitted for entries not on the public board, such as
+	// those awaiting review
+	*Standing
+}
+
+// GetEntry handles GET /api/leaderboard/{id}, returning a single entry and
+// its current rank so share links can point at a specific run. Only entries
+// on the public board are found; hidden, rejected, pending and held ones
+// are not.
+func (h *LeaderboardHandler) GetEntry(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	entry, ok := h.store.GetEntry(r.Context(), r.PathValue("id"))
+	if !ok || !entry.listed(h.store.Now()) {
+		httpError(w, r, "Entry not found", http.StatusNotFound)
+		return
+	}
+
+	result := RankedEntry{ScoreEntry: entry.public()}
+	if standing, ok := h.store.GetStanding(r.Context(), entry.ID); ok {
+		result.Standing = &standing
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// parseTimeParam parses an optional RFC3339 query parameter, returning the
+// zero time if it is absent. The boolean result is false if it is invalid.
+func parseTimeParam(r *http.Request, name string) (time.Time, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, true
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	return parsed, err == nil
+}
+
+// GetPercentile handles GET /api/percentile?score=1234, returning the
+// percentage of the board a score beats, so the game can show "better than
+// 83% of players"
+func (h *LeaderboardHandler) GetPercentile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	score, err := strconv.Atoi(r.URL.Query().Get("score"))
+	if err != nil || score < 0 {
+		httpError(w, r, "Score must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(h.store.GetPercentile(r.Context(), score))
+}
+
+// maxStatsDays caps how many days of submission counts GetStats returns
+const maxStatsDays = 366
+
+// GetStats handles GET /api/leaderboard/stats?days=30, returning score
+// statistics and daily submission counts for a stats page
+func (h *LeaderboardHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	// Parse days query parameter (default to 30)
+	days := 30
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		if parsedDays, err := strconv.Atoi(daysStr); err == nil && parsedDays > 0 {
+			days = min(parsedDays, maxStatsDays)
+		}
+	}
+
+	h.setCacheControl(w, false)
+	json.NewEncoder(w).Encode(h.store.GetStats(r.Context(), h.store.Now(), days))
+}
+
+// GetCountries handles GET /api/leaderboard/countries, listing the
+// countries with runs on the board, the one with the best run first
+func (h *LeaderboardHandler) GetCountries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	h.setCacheControl(w, false)
+	json.NewEncoder(w).Encode(h.store.GetCountries(r.Context()))
+}
+
+// ExportBoard handles GET /api/leaderboard/export, streaming the whole
+// public board, best first, as a download with each entry's rank. The
+// format is csv (the default) or ndjson.
+func (h *LeaderboardHandler) ExportBoard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	var contentType, filename string
+	var write func(context.Context, io.Writer, BoardReader) error
+	switch r.URL.Query().Get("format") {
+	case "", "csv":
+		contentType, filename, write = "text/csv; charset=utf-8", "leaderboard.csv", writeBoardCSV
+	case "ndjson":
+		contentType, filename, write = "application/x-ndjson", "leaderboard.ndjson", writeBoardNDJSON
+	default:
+		httpError(w, r, "Format must be csv or ndjson", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	if err := write(r.Context(), w, h.store); err != nil {
+		log.Printf("Warning: Could not write board export: %v", err)
+	}
+}
+
+// GetChanges handles GET /api/leaderboard/changes?since=VERSION, returning
+// only the entries added to the public board since the version, taken from
+// the X-Board-Version header of GET /api/leaderboard or an earlier poll.
+// With ?wait=SECONDS the request is held open until there are changes or
+// the wait is over, so polling clients get new entries promptly without
+// downloading the board again.
+func (h *LeaderboardHandler) GetChanges(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	since := r.URL.Query().Get("since")
+	if since == "" {
+		httpError(w, r, "since is required", http.StatusBadRequest)
+		return
+	}
+
+	wait := time.Duration(0)
+	if waitStr := r.URL.Query().Get("wait"); waitStr != "" {
+		seconds, err := strconv.Atoi(waitStr)
+		if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > maxChangesWait {
+			httpError(w, r, "wait must be between 0 and "+strconv.Itoa(int(maxChangesWait/time.Second))+" seconds", http.StatusBadRequest)
+			return
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	changes, err := h.store.WaitForChanges(r.Context(), since, wait)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(changes)
+}
+
+// maxSearchLength caps the length of a player search query
+const maxSearchLength = 50
+
+// SearchPlayers handles GET /api/leaderboard/search?q=kir, returning board
+// entries whose player name contains the query, ignoring case, so players
+// can find their friends on big boards. Results are paginated with offset
+// and limit like GET /api/leaderboard.
+func (h *LeaderboardHandler) SearchPlayers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count")
+	w.Header().Set("Content-Type", "application/json")
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" || len(query) > maxSearchLength {
+		httpError(w, r, "Search query must be 1 to "+strconv.Itoa(maxSearchLength)+" characters", http.StatusBadRequest)
+		return
+	}
+
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		parsedOffset, err := strconv.Atoi(offsetStr)
+		if err != nil || parsedOffset < 0 {
+			httpError(w, r, "Offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = parsedOffset
+	}
+	requested, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	limit, _ := h.store.Config().QueryLimit(requested)
+
+	entries, total := h.store.SearchPlayers(r.Context(), query, offset, limit)
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	h.setCacheControl(w, false)
+	json.NewEncoder(w).Encode(entries)
+}
+
+// maxAroundWindow caps how many places either side of a player GetAround
+// returns
+const maxAroundWindow = 50
+
+// GetAround handles GET /api/leaderboard/around?player=NAME&window=5,
+// returning the entries just above and below the player's best, so mid-pack
+// players see the competition they can actually catch
+func (h *LeaderboardHandler) GetAround(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	player := r.URL.Query().Get("player")
+	if player == "" {
+		httpError(w, r, "Player is required", http.StatusBadRequest)
+		return
+	}
+
+	// Parse window query parameter (default to 5)
+	window := 5
+	if windowStr := r.URL.Query().Get("window"); windowStr != "" {
+		if parsedWindow, err := strconv.Atoi(windowStr); err == nil && parsedWindow >= 0 {
+			window = min(parsedWindow, maxAroundWindow)
+		}
+	}
+
+	entries, ok := h.store.GetAround(r.Context(), player, window)
+	if !ok {
+		httpError(w, r, "Player not found", http.StatusNotFound)
+		return
+	}
+	h.setCacheControl(w, false)
+	json.NewEncoder(w).Encode(entries)
+}
+
+// GetPlayerProfile handles GET /api/players/{name}, returning a summary of
+// the player's runs on the public board
+func (h *LeaderboardHandler) GetPlayerProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	name := r.PathValue("name")
+	profile, ok := h.store.GetPlayerProfile(r.Context(), name)
+	if !ok {
+		httpError(w, r, "Player not found", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(profile)
+}
+
+// ExportPlayer handles GET /api/players/{name}/export, returning every entry
+// of a claimed name, including hidden and pending ones, as a download for
+// data portability requests. The X-Player-Token header must prove the
+// claim. ?format=csv returns CSV instead of JSON.
+func (h *LeaderboardHandler) ExportPlayer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	name := r.PathValue("name")
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		httpError(w, r, "Format must be json or csv", http.StatusBadRequest)
+		return
+	}
+
+	// Only the player may export their data, which needs a claimed name
+	player, ok := h.authenticatedPlayer(r)
+	if !ok || NameSkeleton(player) != NameSkeleton(name) {
+		httpError(w, r, "A valid player token for the name is required", http.StatusUnauthorized)
+		return
+	}
+
+	entries := publicEntries(h.store.GetPlayerEntries(r.Context(), name))
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + "-export.csv"}))
+		if err := writeCSV(w, entries); err != nil {
+			log.Printf("Warning: Could not write export of %s: %v", name, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + "-export.json"}))
+	json.NewEncoder(w).Encode(PlayerExport{PlayerName: name, ExportedAt: h.store.Now().UTC(), Entries: entries})
+}
+
+// ReplayLink is a signed, short-lived link for downloading a replay
+type ReplayLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ReplayURL handles GET /api/leaderboard/{id}/replay/url, returning a
+// signed link to download the entry's replay. Runs off the public board
+// are only linked for moderators.
+func (h *LeaderboardHandler) ReplayURL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.replays == nil {
+		httpError(w, r, "Replays are not supported", http.StatusNotFound)
+		return
+	}
+
+	// Only moderators get links to runs off the public board, such as
+	// those removed or held for review
+	entry, ok := h.store.GetEntry(r.Context(), r.PathValue("id"))
+	if !ok || !entry.HasReplay || !entry.listed(h.store.Now()) && !moderating(r) {
+		httpError(w, r, "Replay not found", http.StatusNotFound)
+		return
+	}
+
+	url, expiresAt := h.replays.SignedURL(entry.ID, h.store.Now())
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(ReplayLink{URL: url, ExpiresAt: expiresAt})
+}
+
+// moderating reports whether the request was made by a moderator or
+// admin, as identified by Authenticator.Identify
+func moderating(r *http.Request) bool {
+	return PrincipalFrom(r.Context()).Role >= RoleModerator
+}
+
+// verifyReplay re-simulates an entry's stored replay and marks the entry
+// replay-verified if it reproduces the submitted score
+func (h *LeaderboardHandler) verifyReplay(ctx context.Context, entry ScoreEntry) ScoreEntry {
+	result, err := h.replays.Verify(entry.ID)
+	if err != nil {
+		log.Printf("Replay for %s could not be verified: %v", entry.ID, err)
+		return entry
+	}
+
+	claimed := entry.Score
+	if entry.Difficulty != "" {
+		claimed = entry.RawScore
+	}
+	if result.Score != claimed {
+		log.Printf("Replay for %s scores %d, but %d was submitted", entry.ID, result.Score, claimed)
+		return entry
+	}
+
+	if updated, err := h.store.SetReplayVerified(ctx, entry.ID, true); err == nil {
+		return updated
+	}
+	return entry
+}
+
+// GetGhosts handles GET /api/ghosts?level=1&limit=1, returning ghost tracks
+// of the top-ranked runs for a level
+func (h *LeaderboardHandler) GetGhosts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	level, err := strconv.Atoi(r.URL.Query().Get("level"))
+	if err != nil || level <= 0 {
+		httpError(w, r, "A positive level is required", http.StatusBadRequest)
+		return
+	}
+
+	// Default to the record holder only, capped at 10 ghosts
+	limit := 1
+	if parsedLimit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && parsedLimit > 0 {
+		limit = parsedLimit
+	}
+	if limit > 10 {
+		limit = 10
+	}
+
+	if h.ghosts == nil {
+		json.NewEncoder(w).Encode([]Ghost{})
+		return
+	}
+	json.NewEncoder(w).Encode(h.ghosts.TopGhosts(r.Context(), h.store, level, limit))
+}
+
+// nameConflict returns the 409 Conflict for a name claimed by someone
+// else, suggesting a free one
+func (h *LeaderboardHandler) nameConflict(name string) error {
+	return &submissionError{
+		status:     http.StatusConflict,
+		message:    "Player name is claimed by another player",
+		suggestion: h.claims.Suggest(name, h.maxNameLength()),
+	}
+}
+
+// maxNameLength returns the longest player name allowed, 0 for no limit
+func (h *LeaderboardHandler) maxNameLength() int {
+	if h.names == nil {
+		return 0
+	}
+	return h.names.maxLength
+}
+
+// ClaimName handles POST /api/names/claim, reserving a player name and
+// returning the token to send in the X-Player-Token header with scores
+// submitted under it
+func (h *LeaderboardHandler) ClaimName(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.claims == nil {
+		httpError(w, r, "Name claims are not supported", http.StatusNotFound)
+		return
+	}
+
+	var req NameClaimRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.PlayerName == "" {
+		httpError(w, r, "Player name is required", http.StatusBadRequest)
+		return
+	}
+
+	// Claim the name as it would be stored on the board
+	if h.names != nil {
+		name, err := h.names.Check(req.PlayerName)
+		if err != nil {
+			httpError(w, r, "Invalid player name: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.PlayerName = name
+	}
+
+	if !h.claims.Allow(clientAddress(r), h.store.Now()) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(claimWindow.Seconds())))
+		httpError(w, r, "Too many name claims; try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	// A name with scores, or one looking like it, can only be claimed by
+	// whoever submitted them, proven by the X-Player-Token they sent with
+	// every one
+	if !h.ownsEntries(r.Context(), req.PlayerName, r.Header.Get(PlayerTokenHeader)) {
+		writeRefusal(w, r, &submissionError{
+			status:     http.StatusConflict,
+			message:    "Player name already has scores submitted by another player",
+			suggestion: h.claims.Suggest(req.PlayerName, h.maxNameLength()),
+		})
+		return
+	}
+
+	token, err := h.claims.Claim(req.PlayerName)
+	if errors.Is(err, ErrNameClaimed) {
+		writeRefusal(w, r, h.nameConflict(req.PlayerName))
+		return
+	}
+	if err != nil {
+		log.Printf("Error: Could not save name claim: %v", err)
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(NameClaimResponse{PlayerName: req.PlayerName, Token: token})
+}
+
+// ownsEntries reports whether every entry under name, or under a name
+// looking like it as decided by NameSkeleton, was submitted with token on
+// every board, which is true of names with no such entries. Claims are
+// kept by skeleton, so a lookalike claim would take the name over too.
+func (h *LeaderboardHandler) ownsEntries(ctx context.Context, name, token string) bool {
+	stores := []LeaderboardStore{h.store}
+	if h.boards != nil {
+		stores = stores[:0]
+		for _, id := range h.boards.IDs() {
+			if board, ok := h.boards.Board(id); ok {
+				stores = append(stores, board.store)
+			}
+		}
+	}
+
+	credential := playerCredential(token)
+	for _, store := range stores {
+		for _, entry := range store.GetLookalikeEntries(ctx, name) {
+			if token == "" || len(entry.Provenance) == 0 || entry.Provenance[0].Credential != credential {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// authenticatedPlayer returns the claimed name whose token the request
+// carries in the X-Player-Token header
+func (h *LeaderboardHandler) authenticatedPlayer(r *http.Request) (string, bool) {
+	if h.claims == nil {
+		return "", false
+	}
+	return h.claims.Identify(r.Header.Get(PlayerTokenHeader))
+}
+
+// friendsOf returns the player whose claimed name the X-Player-Token header
+// proves, or responds with an error if friends are unsupported or there
+// is no valid token
+func (h *LeaderboardHandler) friendsOf(w http.ResponseWriter, r *http.Request) (string, bool) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	if h.friends == nil {
+		httpError(w, r, "Friends are not supported", http.StatusNotFound)
+		return "", false
+	}
+
+	player, ok := h.authenticatedPlayer(r)
+	if !ok {
+		httpError(w, r, "A player token is required", http.StatusUnauthorized)
+		return "", false
+	}
+	return player, true
+}
+
+// Friends handles GET /api/friends, listing the friends of the player
+// whose claimed name the X-Player-Token header proves
+func (h *LeaderboardHandler) Friends(w http.ResponseWriter, r *http.Request) {
+	player, ok := h.friendsOf(w, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(FriendList{PlayerName: player, Friends: h.friends.List(player)})
+}
+
+// AddFriend handles POST /api/friends, adding a player to the friend list
+func (h *LeaderboardHandler) AddFriend(w http.ResponseWriter, r *http.Request) {
+	player, ok := h.friendsOf(w, r)
+	if !ok {
+		return
+	}
+
+	var req FriendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.PlayerName == "" {
+		httpError(w, r, "Player name is required", http.StatusBadRequest)
+		return
+	}
+	// Friends are matched by the name their runs are stored under
+	if h.names != nil {
+		name, err := h.names.Check(req.PlayerName)
+		if err != nil {
+			httpError(w, r, "Invalid player name: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.PlayerName = name
+	}
+	if err := h.friends.Add(player, req.PlayerName); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(FriendList{PlayerName: player, Friends: h.friends.List(player)})
+}
+
+// RemoveFriend handles DELETE /api/friends/{name}, removing a player from
+// the friend list
+func (h *LeaderboardHandler) RemoveFriend(w http.ResponseWriter, r *http.Request) {
+	player, ok := h.friendsOf(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.friends.Remove(player, r.PathValue("name")); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	json.NewEncoder(w).Encode(FriendList{PlayerName: player, Friends: h.friends.List(player)})
 }
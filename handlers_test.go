@@ -2,18 +2,26 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 // Test POST endpoint with valid data
 func TestSubmitScoreValid(t *testing.T) {
 	store := NewScoreStore()
-	handler := NewLeaderboardHandler(store)
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+	t.Cleanup(store.Close)
 
 	reqBody := map[string]interface{}{
 		"score":      1000,
@@ -98,10 +106,10 @@ func TestGetLeaderboardSorted(t *testing.T) {
 	handler := NewLeaderboardHandler(store)
 
 	// Add scores in random order
-	store.AddScore(500, "Player1")
-	store.AddScore(1000, "Player2")
-	store.AddScore(250, "Player3")
-	store.AddScore(750, "Player4")
+	store.AddScore(context.Background(), 500, "Player1")
+	store.AddScore(context.Background(), 1000, "Player2")
+	store.AddScore(context.Background(), 250, "Player3")
+	store.AddScore(context.Background(), 750, "Player4")
 
 	req := httptest.NewRequest("GET", "/api/leaderboard", nil)
 	w := httptest.NewRecorder()
@@ -140,7 +148,7 @@ func TestGetLeaderboardLimit(t *testing.T) {
 
 	// Add 15 scores
 	for i := 0; i < 15; i++ {
-		store.AddScore(i*100, "Player"+string(rune('A'+i)))
+		store.AddScore(context.Background(), i*100, "Player"+string(rune('A'+i)))
 	}
 
 	tests := []struct {
@@ -178,6 +186,530 @@ func TestGetLeaderboardLimit(t *testing.T) {
 	}
 }
 
+// Test the board's default and maximum limits, with clamping reported in
+// the envelope
+func TestGetLeaderboardConfiguredLimits(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{DefaultLimit: 3, MaxLimit: 8})
+	handler := NewLeaderboardHandler(store)
+
+	for i := 0; i < 15; i++ {
+		store.AddScore(context.Background(), i*100, "Player"+string(rune('A'+i)))
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		wantCount   int
+		wantClamped bool
+	}{
+		{"default limit", "?envelope=true", 3, false},
+		{"within maximum", "?envelope=true&limit=8", 8, false},
+		{"above maximum", "?envelope=true&limit=1000000", 8, true},
+		{"invalid limit", "?envelope=true&limit=abc", 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard"+tt.query, nil))
+
+			var page LeaderboardPage
+			if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(page.Entries) != tt.wantCount || page.Limit != tt.wantCount {
+				t.Errorf("Expected %d scores with limit %d, got %d with limit %d", tt.wantCount, tt.wantCount, len(page.Entries), page.Limit)
+			}
+			if page.LimitClamped != tt.wantClamped {
+				t.Errorf("Expected limitClamped %v, got %v", tt.wantClamped, page.LimitClamped)
+			}
+		})
+	}
+}
+
+// Test offset pagination with the total count
+func TestGetLeaderboardOffset(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store)
+
+	for i := 0; i < 15; i++ {
+		store.AddScore(context.Background(), 1500-i*100, "Player"+string(rune('A'+i)))
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantFirst string
+		wantCount int
+	}{
+		{"first page", "?limit=5", http.StatusOK, "PlayerA", 5},
+		{"second page", "?limit=5&offset=5", http.StatusOK, "PlayerF", 5},
+		{"last partial page", "?limit=10&offset=10", http.StatusOK, "PlayerK", 5},
+		{"past the end", "?offset=100", http.StatusOK, "", 0},
+		{"negative offset", "?offset=-1", http.StatusBadRequest, "", 0},
+		{"invalid offset", "?offset=abc", http.StatusBadRequest, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			if total := w.Header().Get("X-Total-Count"); total != "15" {
+				t.Errorf("Expected X-Total-Count 15, got %q", total)
+			}
+
+			var scores []ScoreEntry
+			json.NewDecoder(w.Body).Decode(&scores)
+			if len(scores) != tt.wantCount {
+				t.Fatalf("Expected %d scores, got %d", tt.wantCount, len(scores))
+			}
+			if tt.wantCount > 0 && scores[0].PlayerName != tt.wantFirst {
+				t.Errorf("Expected page to start with %s, got %s", tt.wantFirst, scores[0].PlayerName)
+			}
+		})
+	}
+}
+
+// Test polling clients get 304 Not Modified until the board changes
+func TestGetLeaderboardETag(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store)
+	store.AddScore(context.Background(), 500, "Kiro")
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		handler.GetLeaderboard(w, req)
+		return w
+	}
+
+	etag := get("/api/leaderboard", "").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag")
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		ifNoneMatch string
+		wantCode    int
+	}{
+		{"unchanged", "/api/leaderboard", etag, http.StatusNotModified},
+		{"weak validator", "/api/leaderboard", "W/" + etag, http.StatusNotModified},
+		{"one of several", "/api/leaderboard", `"other", ` + etag, http.StatusNotModified},
+		{"different query", "/api/leaderboard?limit=5", etag, http.StatusOK},
+		{"stale tag", "/api/leaderboard", `"other"`, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.path, tt.ifNoneMatch)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("Expected no body with 304, got %q", w.Body.String())
+			}
+		})
+	}
+
+	// New scores change the tag
+	store.AddScore(context.Background(), 300, "Ghost")
+	w := get("/api/leaderboard", etag)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("Expected a new ETag after a submission, got status %d", w.Code)
+	}
+
+	// So does a held record appearing on the board
+	releasesAt := time.Now().Add(50 * time.Millisecond)
+	store.AddEntry(context.Background(), ScoreEntry{Score: 900, PlayerName: "Record", HeldUntil: &releasesAt})
+	etag = get("/api/leaderboard", "").Header().Get("ETag")
+	time.Sleep(time.Until(releasesAt))
+	if w := get("/api/leaderboard", etag); w.Code != http.StatusOK {
+		t.Errorf("Expected the released record to change the ETag, got status %d", w.Code)
+	}
+}
+
+// Test board responses carry the configured Cache-Control, private for
+// boards of a single player and absent on errors
+func TestGetLeaderboardCacheControl(t *testing.T) {
+	dir := t.TempDir()
+	claims := NewNameClaims(filepath.Join(dir, "claims.json"))
+	token, _ := claims.Claim("Kiro")
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 500, "Kiro")
+
+	cache := CacheConfig{MaxAgeSeconds: 5, StaleWhileRevalidateSeconds: 30}
+	tests := []struct {
+		name    string
+		cache   CacheConfig
+		path    string
+		want    string
+		handler func(*LeaderboardHandler, http.ResponseWriter, *http.Request)
+	}{
+		{"board", cache, "/api/leaderboard", "public, max-age=5, stale-while-revalidate=30", (*LeaderboardHandler).GetLeaderboard},
+		{"max age only", CacheConfig{MaxAgeSeconds: 5}, "/api/leaderboard", "public, max-age=5", (*LeaderboardHandler).GetLeaderboard},
+		{"caching off", CacheConfig{}, "/api/leaderboard", "", (*LeaderboardHandler).GetLeaderboard},
+		{"friends board", cache, "/api/leaderboard?scope=friends", "private, max-age=5, stale-while-revalidate=30", (*LeaderboardHandler).GetLeaderboard},
+		{"invalid query", cache, "/api/leaderboard?offset=-1", "", (*LeaderboardHandler).GetLeaderboard},
+		{"stats", cache, "/api/leaderboard/stats", "public, max-age=5, stale-while-revalidate=30", (*LeaderboardHandler).GetStats},
+		{"unknown player", cache, "/api/leaderboard/around?player=Nobody", "", (*LeaderboardHandler).GetAround},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewLeaderboardHandler(store, WithCacheControl(tt.cache), WithNameClaims(claims), WithFriends(NewFriends(filepath.Join(dir, "friends.json"))))
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set(PlayerTokenHeader, token)
+			w := httptest.NewRecorder()
+			tt.handler(handler, w, req)
+			if got := w.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("Expected Cache-Control %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// Test cursor pages stay consistent while scores are inserted between
+// requests
+func TestGetLeaderboardCursor(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store)
+
+	original := make(map[string]bool)
+	for i := 0; i < 12; i++ {
+		original[store.AddScore(context.Background(), 1200-i*100, "Player").ID] = true
+	}
+
+	get := func(query string) (LeaderboardPage, int) {
+		w := httptest.NewRecorder()
+		handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard?envelope=true&limit=5"+query, nil))
+		var page LeaderboardPage
+		json.NewDecoder(w.Body).Decode(&page)
+		return page, w.Code
+	}
+
+	seen := make(map[string]bool)
+	previous := 0
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		query := ""
+		if cursor != "" {
+			query = "&cursor=" + cursor
+		}
+		page, code := get(query)
+		if code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, code)
+		}
+		for _, entry := range page.Entries {
+			if seen[entry.ID] {
+				t.Errorf("Expected each entry once, got %s again", entry.ID)
+			}
+			if previous > 0 && entry.Score > previous {
+				t.Errorf("Expected descending scores across pages, got %d after %d", entry.Score, previous)
+			}
+			seen[entry.ID] = true
+			previous = entry.Score
+		}
+
+		// New scores above and below the cursor don't shift later pages
+		store.AddScore(context.Background(), 5000, "Newcomer")
+		store.AddScore(context.Background(), 50, "Newcomer")
+
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	for id := range original {
+		if !seen[id] {
+			t.Errorf("Expected %s to appear on a page", id)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"invalid cursor", "&cursor=not-a-cursor"},
+		{"cursor and offset", "&offset=5&cursor=" + CursorAt(ScoreEntry{Score: 100, ID: "x"}).Encode()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, code := get(tt.query); code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, code)
+			}
+		})
+	}
+}
+
+// Test date-range filtering by the time runs are attributed to
+func TestGetLeaderboardDateRange(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store)
+
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"Yesterday", "Today", "Tomorrow"} {
+		store.restoreEntry(ScoreEntry{ID: name, Score: 100 * (i + 1), PlayerName: name, Timestamp: day.AddDate(0, 0, i-1).Add(12 * time.Hour)})
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantNames []string
+	}{
+		{"today", "?since=2024-06-01T00:00:00Z&until=2024-06-02T00:00:00Z", http.StatusOK, []string{"Today"}},
+		{"since only", "?since=2024-06-01T00:00:00Z", http.StatusOK, []string{"Tomorrow", "Today"}},
+		{"until only", "?until=2024-06-01T12:00:00Z", http.StatusOK, []string{"Yesterday"}},
+		{"time zone offset", "?since=2024-06-01T13:00:00%2B02:00&until=2024-06-01T15:00:00%2B02:00", http.StatusOK, []string{"Today"}},
+		{"invalid since", "?since=yesterday", http.StatusBadRequest, nil},
+		{"empty range", "?since=2024-06-02T00:00:00Z&until=2024-06-01T00:00:00Z", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var scores []ScoreEntry
+			json.NewDecoder(w.Body).Decode(&scores)
+			if len(scores) != len(tt.wantNames) {
+				t.Fatalf("Expected %d scores, got %d", len(tt.wantNames), len(scores))
+			}
+			for i, score := range scores {
+				if score.PlayerName != tt.wantNames[i] {
+					t.Errorf("Expected %s at %d, got %s", tt.wantNames[i], i, score.PlayerName)
+				}
+			}
+		})
+	}
+}
+
+// Test the daily board only lists runs since the last reset
+func TestGetLeaderboardDaily(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store)
+	now := time.Now()
+	store.restoreEntry(ScoreEntry{ID: "old", Score: 900, PlayerName: "Yesterday", Timestamp: now.Add(-25 * time.Hour)})
+	store.restoreEntry(ScoreEntry{ID: "new", Score: 100, PlayerName: "Today", Timestamp: now})
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantNames []string
+	}{
+		{"daily", "?period=daily", http.StatusOK, []string{"Today"}},
+		{"all time", "?period=alltime", http.StatusOK, []string{"Yesterday", "Today"}},
+		{"unknown period", "?period=hourly", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var scores []ScoreEntry
+			json.NewDecoder(w.Body).Decode(&scores)
+			if len(scores) != len(tt.wantNames) {
+				t.Fatalf("Expected %d scores, got %d", len(tt.wantNames), len(scores))
+			}
+			for i, score := range scores {
+				if score.PlayerName != tt.wantNames[i] {
+					t.Errorf("Expected %s at %d, got %s", tt.wantNames[i], i, score.PlayerName)
+				}
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard?period=daily&envelope=true", nil))
+	var page LeaderboardPage
+	json.NewDecoder(w.Body).Decode(&page)
+	if page.ResetsAt == nil || !page.ResetsAt.After(now) || page.ResetsAt.After(now.Add(24*time.Hour)) {
+		t.Errorf("Expected the daily board to reset within a day, got %v", page.ResetsAt)
+	}
+}
+
+// Test decayed ranking favours recent runs without changing stored scores
+func TestGetLeaderboardDecayed(t *testing.T) {
+	now := time.Now()
+	newStore := func(decay DecayConfig) *ScoreStore {
+		store := NewScoreStore()
+		store.SetConfig(BoardConfig{BestPerPlayer: true, Decay: decay})
+		store.restoreEntry(ScoreEntry{ID: "veteran", Score: 1000, PlayerName: "Veteran", Timestamp: now.AddDate(0, 0, -30)})
+		store.restoreEntry(ScoreEntry{ID: "active-old", Score: 900, PlayerName: "Active", Timestamp: now.AddDate(0, 0, -30)})
+		store.restoreEntry(ScoreEntry{ID: "active-new", Score: 400, PlayerName: "Active", Timestamp: now})
+		return store
+	}
+
+	tests := []struct {
+		name     string
+		decay    DecayConfig
+		query    string
+		wantCode int
+		wantIDs  []string
+	}{
+		{"raw scores", DecayConfig{HalfLifeDays: 10}, "", http.StatusOK, []string{"veteran", "active-old"}},
+		{"decayed", DecayConfig{HalfLifeDays: 10}, "?sort=decayed", http.StatusOK, []string{"active-new", "veteran"}},
+		{"decayed ascending", DecayConfig{HalfLifeDays: 10}, "?sort=decayed&order=asc", http.StatusOK, []string{"veteran", "active-new"}},
+		{"decayed by default", DecayConfig{HalfLifeDays: 10, Default: true}, "", http.StatusOK, []string{"active-new", "veteran"}},
+		{"raw when asked", DecayConfig{HalfLifeDays: 10, Default: true}, "?sort=score", http.StatusOK, []string{"veteran", "active-old"}},
+		{"not enabled", DecayConfig{}, "?sort=decayed", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newStore(tt.decay)
+			w := httptest.NewRecorder()
+			NewLeaderboardHandler(store).GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var scores []ScoreEntry
+			json.NewDecoder(w.Body).Decode(&scores)
+			if len(scores) != len(tt.wantIDs) {
+				t.Fatalf("Expected %d scores, got %d", len(tt.wantIDs), len(scores))
+			}
+			for i, score := range scores {
+				if score.ID != tt.wantIDs[i] {
+					t.Errorf("Expected %s at %d, got %s", tt.wantIDs[i], i, score.ID)
+				}
+			}
+			if entry, _ := store.GetEntry(context.Background(), "veteran"); entry.Score != 1000 || entry.DecayedScore != 0 {
+				t.Errorf("Expected the stored score to be unchanged, got %+v", entry)
+			}
+		})
+	}
+}
+
+// Test the minimum score filter
+func TestGetLeaderboardMinScore(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store)
+	for _, score := range []int{5000, 10000, 15000, 20000} {
+		store.AddScore(context.Background(), score, "Player")
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantCount int
+	}{
+		{"no filter", "", http.StatusOK, 4},
+		{"qualifying runs", "?minScore=10000", http.StatusOK, 3},
+		{"above every run", "?minScore=25000", http.StatusOK, 0},
+		{"invalid", "?minScore=lots", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var scores []ScoreEntry
+			json.NewDecoder(w.Body).Decode(&scores)
+			if len(scores) != tt.wantCount {
+				t.Errorf("Expected %d scores, got %d", tt.wantCount, len(scores))
+			}
+			if total := w.Header().Get("X-Total-Count"); total != strconv.Itoa(tt.wantCount) {
+				t.Errorf("Expected X-Total-Count %d, got %s", tt.wantCount, total)
+			}
+		})
+	}
+}
+
+// Test sorting by score, timestamp and name in either order
+func TestGetLeaderboardSort(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store)
+
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i, run := range []struct {
+		name  string
+		score int
+	}{{"bob", 300}, {"Alice", 100}, {"carol", 200}} {
+		store.restoreEntry(ScoreEntry{ID: run.name, Score: run.score, PlayerName: run.name, Timestamp: start.Add(time.Duration(i) * time.Hour)})
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantNames []string
+	}{
+		{"default", "", http.StatusOK, []string{"bob", "carol", "Alice"}},
+		{"score ascending", "?sort=score&order=asc", http.StatusOK, []string{"Alice", "carol", "bob"}},
+		{"most recent first", "?sort=timestamp", http.StatusOK, []string{"carol", "Alice", "bob"}},
+		{"oldest first", "?sort=timestamp&order=asc", http.StatusOK, []string{"bob", "Alice", "carol"}},
+		{"name ignoring case", "?sort=name", http.StatusOK, []string{"Alice", "bob", "carol"}},
+		{"name descending", "?sort=name&order=desc", http.StatusOK, []string{"carol", "bob", "Alice"}},
+		{"limited", "?sort=timestamp&limit=2", http.StatusOK, []string{"carol", "Alice"}},
+		{"unknown sort", "?sort=level", http.StatusBadRequest, nil},
+		{"unknown order", "?order=up", http.StatusBadRequest, nil},
+		{"cursor with other sort", "?sort=name&cursor=" + CursorAt(ScoreEntry{Score: 1, ID: "x"}).Encode(), http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var scores []ScoreEntry
+			json.NewDecoder(w.Body).Decode(&scores)
+			if len(scores) != len(tt.wantNames) {
+				t.Fatalf("Expected %d scores, got %d", len(tt.wantNames), len(scores))
+			}
+			for i, score := range scores {
+				if score.PlayerName != tt.wantNames[i] {
+					t.Errorf("Expected %s at %d, got %s", tt.wantNames[i], i, score.PlayerName)
+				}
+			}
+		})
+	}
+}
+
 // Test concurrent score submissions
 func TestConcurrentSubmissions(t *testing.T) {
 	store := NewScoreStore()
@@ -189,13 +721,13 @@ func TestConcurrentSubmissions(t *testing.T) {
 		wg.Add(1)
 		go func(score int) {
 			defer wg.Done()
-			store.AddScore(score, "Player")
+			store.AddScore(context.Background(), score, "Player")
 		}(i)
 	}
 
 	wg.Wait()
 
-	scores := store.GetTopScores(0)
+	scores := store.GetTopScores(context.Background(), 0)
 	if len(scores) != numGoroutines {
 		t.Errorf("Expected %d scores, got %d", numGoroutines, len(scores))
 	}
@@ -208,9 +740,9 @@ func TestFilePersistence(t *testing.T) {
 
 	// Create store and add scores
 	store1 := NewScoreStore()
-	store1.AddScore(1000, "Player1")
-	store1.AddScore(500, "Player2")
-	store1.AddScore(750, "Player3")
+	store1.AddScore(context.Background(), 1000, "Player1")
+	store1.AddScore(context.Background(), 500, "Player2")
+	store1.AddScore(context.Background(), 750, "Player3")
 
 	// Save to file
 	if err := store1.SaveToFile(filename); err != nil {
@@ -224,8 +756,8 @@ func TestFilePersistence(t *testing.T) {
 	}
 
 	// Verify scores match
-	scores1 := store1.GetTopScores(0)
-	scores2 := store2.GetTopScores(0)
+	scores1 := store1.GetTopScores(context.Background(), 0)
+	scores2 := store2.GetTopScores(context.Background(), 0)
 
 	if len(scores1) != len(scores2) {
 		t.Errorf("Expected %d scores, got %d", len(scores1), len(scores2))
@@ -240,3 +772,790 @@ func TestFilePersistence(t *testing.T) {
 		}
 	}
 }
+
+// Test difficulty multipliers normalize submitted scores
+func TestSubmitScoreDifficultyNormalization(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{
+		DifficultyMultipliers: map[string]float64{"easy": 0.5, "normal": 1, "hard": 1.5},
+		DefaultDifficulty:     "normal",
+	})
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+	t.Cleanup(store.Close)
+
+	tests := []struct {
+		name           string
+		reqBody        map[string]interface{}
+		wantCode       int
+		wantScore      int
+		wantDifficulty string
+	}{
+		{"hard run", map[string]interface{}{"score": 1000, "playerName": "A", "difficulty": "hard"}, http.StatusCreated, 1500, "hard"},
+		{"easy run", map[string]interface{}{"score": 1000, "playerName": "B", "difficulty": "easy"}, http.StatusCreated, 500, "easy"},
+		{"default difficulty", map[string]interface{}{"score": 1000, "playerName": "C"}, http.StatusCreated, 1000, "normal"},
+		{"unknown difficulty", map[string]interface{}{"score": 1000, "playerName": "D", "difficulty": "nightmare"}, http.StatusBadRequest, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.reqBody)
+			req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.SubmitScore(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if tt.wantCode != http.StatusCreated {
+				return
+			}
+
+			var entry ScoreEntry
+			if err := json.NewDecoder(w.Body).Decode(&entry); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if entry.Score != tt.wantScore || entry.RawScore != 1000 || entry.Difficulty != tt.wantDifficulty {
+				t.Errorf("Expected score %d raw 1000 difficulty %s, got %d raw %d difficulty %s",
+					tt.wantScore, tt.wantDifficulty, entry.Score, entry.RawScore, entry.Difficulty)
+			}
+		})
+	}
+
+	// Filter by difficulty
+	req := httptest.NewRequest("GET", "/api/leaderboard?difficulty=hard", nil)
+	w := httptest.NewRecorder()
+	handler.GetLeaderboard(w, req)
+
+	var scores []ScoreEntry
+	if err := json.NewDecoder(w.Body).Decode(&scores); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(scores) != 1 || scores[0].Difficulty != "hard" {
+		t.Errorf("Expected only the hard run, got %v", scores)
+	}
+}
+
+// Test runs record their character and each character has its own board,
+// even when only each player's best is kept
+func TestGetLeaderboardCharacter(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{BestPerPlayer: true})
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+	t.Cleanup(store.Close)
+
+	submissions := []struct {
+		body     string
+		wantCode int
+	}{
+		{`{"score": 500, "playerName": "A", "character": "Kiro"}`, http.StatusCreated},
+		{`{"score": 900, "playerName": "A", "character": "ghost"}`, http.StatusCreated},
+		{`{"score": 700, "playerName": "B", "character": "kiro"}`, http.StatusCreated},
+		{`{"score": 300, "playerName": "C"}`, http.StatusCreated},
+		{`{"score": 100, "playerName": "D", "character": "big kiro"}`, http.StatusBadRequest},
+	}
+	for _, s := range submissions {
+		w := httptest.NewRecorder()
+		handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", strings.NewReader(s.body)))
+		if w.Code != s.wantCode {
+			t.Fatalf("Expected status %d for %s, got %d", s.wantCode, s.body, w.Code)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantScores []int
+	}{
+		{"all characters", "", []int{900, 700, 300}},
+		{"one character", "?character=kiro", []int{700, 500}},
+		{"case-insensitive", "?character=GHOST", []int{900}},
+		{"unplayed character", "?character=other", []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard"+tt.query, nil))
+
+			var scores []ScoreEntry
+			json.NewDecoder(w.Body).Decode(&scores)
+			if len(scores) != len(tt.wantScores) {
+				t.Fatalf("Expected %d scores, got %d", len(tt.wantScores), len(scores))
+			}
+			for i, score := range scores {
+				if score.Score != tt.wantScores[i] {
+					t.Errorf("Expected %d at %d, got %d", tt.wantScores[i], i, score.Score)
+				}
+			}
+		})
+	}
+}
+
+// Test impossible scores are rejected with 422
+func TestSubmitScoreCap(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{MaxScore: 100000, MaxScorePerSecond: 500})
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+	t.Cleanup(store.Close)
+
+	tests := []struct {
+		name     string
+		reqBody  map[string]interface{}
+		wantCode int
+	}{
+		{"within caps", map[string]interface{}{"score": 20000, "playerName": "A", "durationMs": 60000}, http.StatusCreated},
+		{"no duration", map[string]interface{}{"score": 20000, "playerName": "A"}, http.StatusUnprocessableEntity},
+		{"above max score", map[string]interface{}{"score": 999999999, "playerName": "A", "durationMs": 60000}, http.StatusUnprocessableEntity},
+		{"too fast", map[string]interface{}{"score": 50000, "playerName": "A", "durationMs": 3000}, http.StatusUnprocessableEntity},
+		{"negative duration", map[string]interface{}{"score": 100, "playerName": "A", "durationMs": -1}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.reqBody)
+			req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.SubmitScore(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+}
+
+// Test run durations are checked against the board minimum and listed
+// with the entries
+func TestSubmitScoreDuration(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{MinDurationMs: 30000})
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+	t.Cleanup(store.Close)
+
+	tests := []struct {
+		name     string
+		reqBody  map[string]interface{}
+		wantCode int
+	}{
+		{"plausible duration", map[string]interface{}{"score": 50000, "playerName": "A", "durationMs": 95000}, http.StatusCreated},
+		{"at the minimum", map[string]interface{}{"score": 100, "playerName": "B", "durationMs": 30000}, http.StatusCreated},
+		{"too short", map[string]interface{}{"score": 50000, "playerName": "C", "durationMs": 3000}, http.StatusUnprocessableEntity},
+		{"missing duration", map[string]interface{}{"score": 100, "playerName": "D"}, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.reqBody)
+			w := httptest.NewRecorder()
+			handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body)))
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard", nil))
+	var scores []ScoreEntry
+	json.NewDecoder(w.Body).Decode(&scores)
+	if len(scores) != 2 || scores[0].DurationMs != 95000 || scores[1].DurationMs != 30000 {
+		t.Errorf("Expected both accepted runs with their durations, got %+v", scores)
+	}
+}
+
+// Test submissions can carry a bounded metadata object that is stored and
+// returned with the entry
+func TestSubmitScoreMetadata(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{MaxMetadataBytes: 64})
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+	t.Cleanup(store.Close)
+
+	tests := []struct {
+		name         string
+		body         string
+		wantCode     int
+		wantMetadata string
+	}{
+		{"object", `{"score": 100, "playerName": "A", "metadata": {"character": "kiro", "deaths": 3}}`, http.StatusCreated, `{"character":"kiro","deaths":3}`},
+		{"no metadata", `{"score": 100, "playerName": "A"}`, http.StatusCreated, ""},
+		{"null", `{"score": 100, "playerName": "A", "metadata": null}`, http.StatusCreated, ""},
+		{"not an object", `{"score": 100, "playerName": "A", "metadata": [1, 2]}`, http.StatusBadRequest, ""},
+		{"too large", `{"score": 100, "playerName": "A", "metadata": {"notes": "` + strings.Repeat("x", 64) + `"}}`, http.StatusRequestEntityTooLarge, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", strings.NewReader(tt.body)))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusCreated {
+				return
+			}
+
+			var result ScoreEntry
+			json.NewDecoder(w.Body).Decode(&result)
+			if string(result.Metadata) != tt.wantMetadata {
+				t.Errorf("Expected metadata %s, got %s", tt.wantMetadata, result.Metadata)
+			}
+			if entry, _ := store.GetEntry(context.Background(), result.ID); string(entry.Metadata) != tt.wantMetadata {
+				t.Errorf("Expected stored metadata %s, got %s", tt.wantMetadata, entry.Metadata)
+			}
+		})
+	}
+}
+
+// Test pluggable validators can reject submissions
+func TestSubmitScoreValidators(t *testing.T) {
+	store := NewScoreStore()
+	var seen ScoreSubmission
+	t.Cleanup(store.Close)
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithValidators(
+		ScoreValidatorFunc(func(s ScoreSubmission) error {
+			seen = s
+			return nil
+		}),
+		ScoreValidatorFunc(func(s ScoreSubmission) error {
+			if s.Score%10 != 0 {
+				return errors.New("Scores are always multiples of 10")
+			}
+			return nil
+		}),
+	))
+
+	tests := []struct {
+		score    int
+		wantCode int
+	}{
+		{1230, http.StatusCreated},
+		{1234, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		body, _ := json.Marshal(map[string]interface{}{"score": tt.score, "playerName": "Player"})
+		req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+
+		handler.SubmitScore(w, req)
+
+		if w.Code != tt.wantCode {
+			t.Errorf("Expected status %d for score %d, got %d", tt.wantCode, tt.score, w.Code)
+		}
+		if seen.Score != tt.score || seen.PlayerName != "Player" {
+			t.Errorf("Expected validator to see the submission, got %+v", seen)
+		}
+	}
+
+	if len(store.GetTopScores(context.Background(), 0)) != 1 {
+		t.Errorf("Expected only the valid score to be stored")
+	}
+}
+
+// Test claimed run-end times are kept only within the allowed skew
+func TestSubmitScoreClientTimestamp(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{Timestamps: TimestampConfig{Policy: TimestampPolicyClient, MaxSkewSeconds: 60}})
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+	t.Cleanup(store.Close)
+
+	tests := []struct {
+		name     string
+		endedAt  time.Time
+		wantKept bool
+	}{
+		{"just before receipt", time.Now().Add(-2 * time.Second), true},
+		{"slightly ahead", time.Now().Add(10 * time.Second), true},
+		{"an hour ago", time.Now().Add(-time.Hour), false},
+		{"tomorrow", time.Now().Add(24 * time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]interface{}{"score": 100, "playerName": "Clock", "endedAt": tt.endedAt})
+			req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			handler.SubmitScore(w, req)
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+			}
+
+			var entry ScoreEntry
+			json.NewDecoder(w.Body).Decode(&entry)
+			if kept := entry.ClientTimestamp != nil; kept != tt.wantKept {
+				t.Fatalf("Expected client timestamp kept=%v, got %v", tt.wantKept, kept)
+			}
+
+			want := entry.Timestamp
+			if tt.wantKept {
+				want = *entry.ClientTimestamp
+			}
+			if got := store.Config().EffectiveTime(entry); !got.Equal(want) {
+				t.Errorf("Expected effective time %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+// Test runs that don't beat the player's best are not stored
+func TestSubmitScoreBestPerPlayer(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{BestPerPlayer: true})
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+	t.Cleanup(store.Close)
+
+	submit := func(score int) (int, ScoreEntry) {
+		body, _ := json.Marshal(map[string]interface{}{"score": score, "playerName": "Kiro"})
+		w := httptest.NewRecorder()
+		handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body)))
+		var entry ScoreEntry
+		json.NewDecoder(w.Body).Decode(&entry)
+		return w.Code, entry
+	}
+
+	if code, _ := submit(1000); code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d", http.StatusCreated, code)
+	}
+	code, entry := submit(800)
+	if code != http.StatusOK || !entry.Superseded {
+		t.Errorf("Expected status %d with a superseded run, got %d %+v", http.StatusOK, code, entry)
+	}
+	if n := len(store.GetAllEntries(context.Background())); n != 1 {
+		t.Errorf("Expected 1 stored entry, got %d", n)
+	}
+}
+
+// Test speedrun boards list the fastest times first and page in that order
+func TestGetLeaderboardSpeedrun(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{Direction: DirectionAscending})
+	for _, ms := range []int{65000, 60000, 70000} {
+		store.AddScore(context.Background(), ms, "Runner")
+	}
+	handler := NewLeaderboardHandler(store)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantScores []int
+		wantCursor bool
+	}{
+		{"fastest first", "?limit=2", []int{60000, 65000}, true},
+		{"explicit board order", "?sort=score&order=asc&limit=2", []int{60000, 65000}, true},
+		{"slowest first", "?order=desc&limit=2", []int{70000, 65000}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard"+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+			}
+			if cursor := w.Header().Get("X-Next-Cursor"); (cursor != "") != tt.wantCursor {
+				t.Errorf("Expected next cursor %v, got %q", tt.wantCursor, cursor)
+			}
+
+			var scores []ScoreEntry
+			json.NewDecoder(w.Body).Decode(&scores)
+			if len(scores) != len(tt.wantScores) {
+				t.Fatalf("Expected %d scores, got %d", len(tt.wantScores), len(scores))
+			}
+			for i, score := range scores {
+				if score.Score != tt.wantScores[i] {
+					t.Errorf("Expected %d at %d, got %d", tt.wantScores[i], i, score.Score)
+				}
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	cursor := CursorAt(store.GetTopScores(context.Background(), 2)[1]).Encode()
+	handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard?cursor="+cursor, nil))
+	var rest []ScoreEntry
+	json.NewDecoder(w.Body).Decode(&rest)
+	if len(rest) != 1 || rest[0].Score != 70000 {
+		t.Errorf("Expected the slowest time after the cursor, got %+v", rest)
+	}
+}
+
+// Test the submission response reports placement and personal bests
+func TestSubmitScoreStanding(t *testing.T) {
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 1000, "Leader")
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+	t.Cleanup(store.Close)
+
+	submit := func(score int) map[string]interface{} {
+		body, _ := json.Marshal(map[string]interface{}{"score": score, "playerName": "Climber"})
+		w := httptest.NewRecorder()
+		handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body)))
+		var result map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&result)
+		return result
+	}
+
+	tests := []struct {
+		name       string
+		score      int
+		wantRank   float64
+		wantToNext float64
+		wantPB     bool
+	}{
+		{"first run", 600, 2, 401, true},
+		{"worse run", 500, 3, 101, false},
+		{"new best", 1200, 1, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := submit(tt.score)
+			if result["id"] == nil || result["score"] != float64(tt.score) {
+				t.Errorf("Expected the entry fields in the response, got %v", result)
+			}
+			if result["rank"] != tt.wantRank {
+				t.Errorf("Expected rank %v, got %v", tt.wantRank, result["rank"])
+			}
+			toNext, _ := result["pointsToNextRank"].(float64)
+			if toNext != tt.wantToNext {
+				t.Errorf("Expected %v points to next rank, got %v", tt.wantToNext, toNext)
+			}
+			if result["personalBest"] != tt.wantPB {
+				t.Errorf("Expected personalBest %v, got %v", tt.wantPB, result["personalBest"])
+			}
+		})
+	}
+}
+
+// Test single entries can be looked up by ID with their rank
+func TestGetEntry(t *testing.T) {
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 1000, "Leader")
+	runnerUp := store.AddScore(context.Background(), 700, "RunnerUp")
+	pending := store.AddEntry(context.Background(), ScoreEntry{Score: 5000, PlayerName: "Pending", Pending: true})
+	hidden := store.AddEntry(context.Background(), ScoreEntry{Score: 300, PlayerName: "Hidden", Hidden: true})
+	releasesAt := time.Now().Add(time.Hour)
+	held := store.AddEntry(context.Background(), ScoreEntry{Score: 9000, PlayerName: "Held", HeldUntil: &releasesAt})
+	handler := NewLeaderboardHandler(store)
+
+	tests := []struct {
+		name     string
+		id       string
+		wantCode int
+		wantRank float64
+	}{
+		{"ranked", runnerUp.ID, http.StatusOK, 2},
+		{"pending", pending.ID, http.StatusNotFound, 0},
+		{"held", held.ID, http.StatusNotFound, 0},
+		{"hidden", hidden.ID, http.StatusNotFound, 0},
+		{"missing", "missing", http.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetEntry(w, routedRequest(t, "GET /api/leaderboard/{id}", "/api/leaderboard/"+tt.id, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var result map[string]interface{}
+			json.NewDecoder(w.Body).Decode(&result)
+			if result["id"] != tt.id {
+				t.Errorf("Expected entry %s, got %v", tt.id, result["id"])
+			}
+			rank, _ := result["rank"].(float64)
+			if rank != tt.wantRank {
+				t.Errorf("Expected rank %v, got %v", tt.wantRank, rank)
+			}
+		})
+	}
+}
+
+// Test the around view returns the entries either side of a player's best
+func TestGetAround(t *testing.T) {
+	store := NewScoreStore()
+	for i, name := range []string{"A", "B", "C", "D", "E", "F", "G"} {
+		store.AddScore(context.Background(), 1000-i*100, name)
+	}
+	store.AddScore(context.Background(), 100, "D")
+	handler := NewLeaderboardHandler(store)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantNames []string
+	}{
+		{"mid-pack", "?player=D&window=2", http.StatusOK, []string{"B", "C", "D", "E", "F"}},
+		{"near the top", "?player=A&window=2", http.StatusOK, []string{"A", "B", "C"}},
+		{"default window", "?player=G", http.StatusOK, []string{"B", "C", "D", "E", "F", "G", "D"}},
+		{"zero window", "?player=C&window=0", http.StatusOK, []string{"C"}},
+		{"unknown player", "?player=Nobody", http.StatusNotFound, nil},
+		{"missing player", "", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetAround(w, httptest.NewRequest("GET", "/api/leaderboard/around"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var entries []RankedEntry
+			json.NewDecoder(w.Body).Decode(&entries)
+			if len(entries) != len(tt.wantNames) {
+				t.Fatalf("Expected %d entries, got %d", len(tt.wantNames), len(entries))
+			}
+			for i, entry := range entries {
+				if entry.PlayerName != tt.wantNames[i] {
+					t.Errorf("Expected %s at %d, got %s", tt.wantNames[i], i, entry.PlayerName)
+				}
+				if entry.Standing == nil || entry.Rank == 0 {
+					t.Errorf("Expected a rank for %s", entry.PlayerName)
+				}
+			}
+		})
+	}
+}
+
+// Test percentiles count the share of the board scoring strictly less
+func TestGetPercentile(t *testing.T) {
+	store := NewScoreStore()
+	for _, score := range []int{100, 200, 200, 300, 400, 500, 600, 700, 800, 900} {
+		store.AddScore(context.Background(), score, "Player")
+	}
+	store.AddEntry(context.Background(), ScoreEntry{Score: 1, PlayerName: "Hidden", Hidden: true})
+	handler := NewLeaderboardHandler(store)
+
+	tests := []struct {
+		name           string
+		query          string
+		wantCode       int
+		wantPercentile float64
+	}{
+		{"top score", "?score=1000", http.StatusOK, 100},
+		{"mid board", "?score=550", http.StatusOK, 60},
+		{"ties not beaten", "?score=200", http.StatusOK, 10},
+		{"lowest", "?score=0", http.StatusOK, 0},
+		{"missing score", "", http.StatusBadRequest, 0},
+		{"negative score", "?score=-5", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetPercentile(w, httptest.NewRequest("GET", "/api/percentile"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var result Percentile
+			json.NewDecoder(w.Body).Decode(&result)
+			if result.Percentile != tt.wantPercentile || result.Total != 10 {
+				t.Errorf("Expected percentile %v of 10, got %+v", tt.wantPercentile, result)
+			}
+		})
+	}
+
+	if empty := NewScoreStore().GetPercentile(context.Background(), 500); empty.Percentile != 0 || empty.Total != 0 {
+		t.Errorf("Expected percentile 0 on an empty board, got %+v", empty)
+	}
+}
+
+// Test exporting the whole board as CSV
+func TestExportBoard(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store)
+	store.AddScore(context.Background(), 500, "Kiro")
+	store.AddScore(context.Background(), 900, "=SUM(A1)")
+	store.AddScore(context.Background(), 500, "Mario")
+	store.AddEntry(context.Background(), ScoreEntry{Score: 1000, PlayerName: "Hidden", Hidden: true})
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+	}{
+		{"default format", "/api/leaderboard/export", http.StatusOK},
+		{"csv", "/api/leaderboard/export?format=csv", http.StatusOK},
+		{"unknown format", "/api/leaderboard/export?format=xml", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+			handler.ExportBoard(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+				t.Errorf("Expected CSV content type, got %q", got)
+			}
+			if got := w.Header().Get("Content-Disposition"); got != "attachment; filename=leaderboard.csv" {
+				t.Errorf("Expected a download, got %q", got)
+			}
+
+			rows, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("Expected valid CSV, got %v", err)
+			}
+			if len(rows) != 4 || rows[0][0] != "rank" || rows[0][1] != "id" {
+				t.Fatalf("Expected a header and 3 public entries, got %v", rows)
+			}
+			want := [][2]string{{"1", "'=SUM(A1)"}, {"2", "Kiro"}, {"2", "Mario"}}
+			for i, w := range want {
+				if rank, name := rows[i+1][0], rows[i+1][2]; rank != w[0] || name != w[1] {
+					t.Errorf("Row %d: expected rank %s for %q, got rank %s for %q", i+1, w[0], w[1], rank, name)
+				}
+			}
+		})
+	}
+}
+
+// Test streaming the board as newline-delimited JSON
+func TestExportBoardNDJSON(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store)
+	total := exportFlushRows + 1
+	for i := 0; i < total; i++ {
+		store.AddScore(context.Background(), i/2, "Player"+strconv.Itoa(i))
+	}
+
+	req := httptest.NewRequest("GET", "/api/leaderboard/export?format=ndjson", nil)
+	w := httptest.NewRecorder()
+	handler.ExportBoard(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Expected NDJSON content type, got %q", got)
+	}
+	if !w.Flushed {
+		t.Error("Expected the export to be flushed as it was written")
+	}
+
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(lines) != total {
+		t.Fatalf("Expected %d lines, got %d", total, len(lines))
+	}
+	var first, second, third RankedEntry
+	for i, entry := range []*RankedEntry{&first, &second, &third} {
+		if err := json.Unmarshal([]byte(lines[i]), entry); err != nil {
+			t.Fatalf("Expected line %d to be JSON, got %v", i+1, err)
+		}
+	}
+	if first.Score != total/2 || first.Standing == nil || first.Rank != 1 {
+		t.Errorf("Expected the best score first at rank 1, got %+v", first)
+	}
+	if second.Rank != 2 || third.Rank != 2 {
+		t.Errorf("Expected tied scores to share rank 2, got %d and %d", second.Rank, third.Rank)
+	}
+}
+
+// Test a run sent twice with the same run ID is stored once
+func TestSubmitScoreDuplicateRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		window      int
+		first       string
+		second      string
+		wantCode    int
+		wantEntries int
+	}{
+		{"double tap", 0, `{"playerName":"Kiro","score":500,"runId":"run-1"}`, `{"playerName":"Kiro","score":500,"runId":"run-1"}`, http.StatusOK, 1},
+		{"different score", 0, `{"playerName":"Kiro","score":500,"runId":"run-1"}`, `{"playerName":"Kiro","score":600,"runId":"run-1"}`, http.StatusCreated, 2},
+		{"different player", 0, `{"playerName":"Kiro","score":500,"runId":"run-1"}`, `{"playerName":"Mario","score":500,"runId":"run-1"}`, http.StatusCreated, 2},
+		{"no run ID", 0, `{"playerName":"Kiro","score":500}`, `{"playerName":"Kiro","score":500}`, http.StatusCreated, 2},
+		{"deduplication disabled", -1, `{"playerName":"Kiro","score":500,"runId":"run-1"}`, `{"playerName":"Kiro","score":500,"runId":"run-1"}`, http.StatusCreated, 2},
+		{"invalid run ID", 0, `{"playerName":"Kiro","score":500,"runId":"run-1"}`, `{"playerName":"Kiro","score":500,"runId":"run 1!"}`, http.StatusBadRequest, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewScoreStore()
+			store.SetConfig(BoardConfig{DedupeWindowSeconds: tt.window})
+			handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+			t.Cleanup(store.Close)
+
+			submit := func(body string) (*httptest.ResponseRecorder, SubmissionResult) {
+				w := httptest.NewRecorder()
+				handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", strings.NewReader(body)))
+				var result SubmissionResult
+				json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&result)
+				return w, result
+			}
+
+			_, first := submit(tt.first)
+			w, second := submit(tt.second)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if n := len(store.GetAllEntries(context.Background())); n != tt.wantEntries {
+				t.Errorf("Expected %d entries, got %d", tt.wantEntries, n)
+			}
+			if tt.wantCode == http.StatusOK && (second.ID != first.ID || !second.Duplicate || second.Standing == nil) {
+				t.Errorf("Expected the first entry back marked duplicate, got %+v", second)
+			}
+		})
+	}
+}
+
+// Test refused runs return the status and details every API answers with,
+// without going through HTTP
+func TestSubmitRunRefusals(t *testing.T) {
+	claims := NewNameClaims(filepath.Join(t.TempDir(), "claims.json"))
+	if _, err := claims.Claim("Kiro"); err != nil {
+		t.Fatalf("Failed to claim name: %v", err)
+	}
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithNameClaims(claims))
+	t.Cleanup(store.Close)
+
+	tests := []struct {
+		name           string
+		submission     ScoreSubmission
+		wantStatus     int
+		wantViolations int
+		wantSuggestion string
+	}{
+		{"valid", ScoreSubmission{PlayerName: "Mario", Score: 100}, http.StatusCreated, 0, ""},
+		{"invalid fields", ScoreSubmission{Score: -1}, http.StatusBadRequest, 2, ""},
+		{"claimed name", ScoreSubmission{PlayerName: "kiro", Score: 100}, http.StatusConflict, 0, "kiro2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := handler.submitRun(context.Background(), tt.submission, http.Header{})
+			if status := submissionStatus(result, err); status != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d (%v)", tt.wantStatus, status, err)
+			}
+			if err == nil {
+				return
+			}
+			var refused *submissionError
+			if !errors.As(err, &refused) {
+				t.Fatalf("Expected a submissionError, got %T", err)
+			}
+			if len(refused.violations) != tt.wantViolations || refused.suggestion != tt.wantSuggestion {
+				t.Errorf("Expected %d violations and suggestion %q, got %+v", tt.wantViolations, tt.wantSuggestion, refused)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PersistenceConfig controls how failing saves gate new submissions
+type PersistenceConfig struct {
+	// FailureThreshold is how many consecutive saves must fail before the
+	// store is unhealthy. Defaults to 3.
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+	// AcceptWhenUnhealthy keeps accepting submissions while saves fail,
+	// instead of rejecting them with 503 Service Unavailable
+	AcceptWhenUnhealthy bool `json:"acceptWhenUnhealthy,omitempty"`
+	// RetrySeconds is how often a rejected submission retries saving to
+	// detect recovery. Defaults to 10.
+	RetrySeconds int `json:"retrySeconds,omitempty"`
+	// SaveAttempts is how many times a background save is tried before
+	// it is abandoned until the next change. Defaults to 5.
+	SaveAttempts int `json:"saveAttempts,omitempty"`
+	// SaveBackoffMs is the delay before retrying a failed background
+	// save, doubling with each retry. Defaults to 500.
+	SaveBackoffMs int `json:"saveBackoffMs,omitempty"`
+	// Durability is when a submission counts as accepted, one of the
+	// Durability constants. Defaults to async.
+	Durability string `json:"durability,omitempty"`
+}
+
+// Durability levels of accepted submissions
+const (
+	// DurabilityAsync responds before the board is saved, which is
+	// fastest but loses the latest scores if the server crashes first
+	DurabilityAsync = "async"
+	// DurabilitySync saves the board before responding, refusing the
+	// submission if the save fails
+	DurabilitySync = "sync"
+	// DurabilityFsync is sync, but also waits for the operating system to
+	// flush the file to disk, so scores survive a power failure
+	DurabilityFsync = "fsync"
+)
+
+// PersistenceStatus reports the outcome of recent saves
+type PersistenceStatus struct {
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	TotalFailures       int       `json:"totalFailures"`
+	TotalSaves          int       `json:"totalSaves"`
+	LastError           string    `json:"lastError,omitempty"`
+	LastSuccess         time.Time `json:"lastSuccess,omitempty"`
+	LastFailure         time.Time `json:"lastFailure,omitempty"`
+	// TotalRetries counts background saves tried again after failing,
+	// and AbandonedSaves those given up on after their last attempt
+	TotalRetries   int `json:"totalRetries"`
+	AbandonedSaves int `json:"abandonedSaves"`
+}
+
+// persistenceTracker records save outcomes for a ScoreStore. It has its
+// own lock so saves can report without holding the store's write lock.
+type persistenceTracker struct {
+	status PersistenceStatus
+	mu     sync.Mutex
+}
+
+// record notes the outcome of a save
+func (p *persistenceTracker) record(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.status.TotalSaves++
+	if err == nil {
+		if p.status.ConsecutiveFailures > 0 {
+			log.Printf("Leaderboard saves recovered after %d failures", p.status.ConsecutiveFailures)
+		}
+		p.status.ConsecutiveFailures = 0
+		p.status.LastSuccess = time.Now()
+		return
+	}
+
+	p.status.ConsecutiveFailures++
+	p.status.TotalFailures++
+	p.status.LastError = err.Error()
+	p.status.LastFailure = time.Now()
+	log.Printf("Error: Could not save leaderboard (%d consecutive failures): %v", p.status.ConsecutiveFailures, err)
+}
+
+// retry notes that a failed background save will be tried again
+func (p *persistenceTracker) retry() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.TotalRetries++
+}
+
+// abandon notes that a background save was given up on
+func (p *persistenceTracker) abandon() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.AbandonedSaves++
+}
+
+// get returns a copy of the current status
+func (p *persistenceTracker) get() PersistenceStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// WriteGate rejects submissions while the leaderboard cannot be saved, so
+// scores are not accepted only to be lost on restart
+type WriteGate struct {
+	store     Persister
+	filename  string
+	config    PersistenceConfig
+	lastProbe time.Time
+	mu        sync.Mutex
+}
+
+// NewWriteGate creates a WriteGate for a store saved to filename
+func NewWriteGate(store Persister, filename string, config PersistenceConfig) *WriteGate {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 3
+	}
+	if config.RetrySeconds <= 0 {
+		config.RetrySeconds = 10
+	}
+	return &WriteGate{
+		store:    store,
+		filename: filename,
+		config:   config,
+	}
+}
+
+// Healthy reports whether recent saves have succeeded
+func (g *WriteGate) Healthy() bool {
+	return g.store.PersistenceStatus().ConsecutiveFailures < g.config.FailureThreshold
+}
+
+// Allow reports whether a new submission should be accepted. While
+// unhealthy it retries a save at most once per retry interval, so the
+// gate reopens once storage recovers even without successful writes.
+func (g *WriteGate) Allow() bool {
+	if g.Healthy() || g.config.AcceptWhenUnhealthy {
+		return true
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if time.Since(g.lastProbe) < time.Duration(g.config.RetrySeconds)*time.Second {
+		return false
+	}
+	g.lastProbe = time.Now()
+	return g.store.SaveToFile(g.filename) == nil
+}
+
+// HealthStatus is the body of the health endpoint
+type HealthStatus struct {
+	Status string `json:"status"`
+	// Persistence is the main board's status, kept for clients that
+	// predate Boards
+	Persistence PersistenceStatus `json:"persistence"`
+	// Boards holds the status of every board by ID, including the main
+	// board
+	Boards map[string]BoardHealth `json:"boards"`
+}
+
+// BoardHealth is the health of one board
+type BoardHealth struct {
+	Status      string            `json:"status"`
+	Persistence PersistenceStatus `json:"persistence"`
+}
+
+// MetricsWriter writes additional metrics in the Prometheus text format
+type MetricsWriter interface {
+	WriteMetrics(w io.Writer)
+}
+
+// HealthHandler serves the health check and metrics of every board
+type HealthHandler struct {
+	boards  map[string]healthBoard
+	metrics []MetricsWriter
+}
+
+// healthBoard is a board whose saves the HealthHandler reports on
+type healthBoard struct {
+	store *ScoreStore
+	gate  *WriteGate
+}
+
+// NewHealthHandler creates a HealthHandler reporting on store as the main
+// board. Metrics from the optional writers are appended to the persistence
+// metrics.
+func NewHealthHandler(store *ScoreStore, gate *WriteGate, metrics ...MetricsWriter) *HealthHandler {
+	h := &HealthHandler{boards: make(map[string]healthBoard), metrics: metrics}
+	h.AddBoard(MainBoard, store, gate)
+	return h
+}
+
+// AddBoard reports on a named board as well
+func (h *HealthHandler) AddBoard(id string, store *ScoreStore, gate *WriteGate) {
+	h.boards[id] = healthBoard{store: store, gate: gate}
+}
+
+// ids returns the IDs of the boards in order
+func (h *HealthHandler) ids() []string {
+	ids := make([]string, 0, len(h.boards))
+	for id := range h.boards {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ServeHealth handles GET /api/health, responding 503 while any board's
+// saves are failing so load balancers and uptime checks can alert on it
+func (h *HealthHandler) ServeHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	status := HealthStatus{Status: "ok", Boards: make(map[string]BoardHealth, len(h.boards))}
+	for id, b := range h.boards {
+		board := BoardHealth{Status: "ok", Persistence: b.store.PersistenceStatus()}
+		if !b.gate.Healthy() {
+			board.Status = "degraded"
+			status.Status = "degraded"
+		}
+		status.Boards[id] = board
+	}
+	status.Persistence = status.Boards[MainBoard].Persistence
+	if status.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// persistenceMetrics are the per-board persistence metrics
+var persistenceMetrics = []struct {
+	name, help, kind string
+	value            func(status PersistenceStatus, healthy bool) int
+}{
+	{"leaderboard_persistence_healthy", "Whether recent leaderboard saves succeeded.", "gauge", func(_ PersistenceStatus, healthy bool) int {
+		if healthy {
+			return 1
+		}
+		return 0
+	}},
+	{"leaderboard_persistence_consecutive_failures", "Saves failed since the last success.", "gauge", func(s PersistenceStatus, _ bool) int { return s.ConsecutiveFailures }},
+	{"leaderboard_persistence_failures_total", "Failed leaderboard saves.", "counter", func(s PersistenceStatus, _ bool) int { return s.TotalFailures }},
+	{"leaderboard_persistence_saves_total", "Attempted leaderboard saves.", "counter", func(s PersistenceStatus, _ bool) int { return s.TotalSaves }},
+	{"leaderboard_persistence_retries_total", "Failed background saves tried again.", "counter", func(s PersistenceStatus, _ bool) int { return s.TotalRetries }},
+	{"leaderboard_persistence_abandoned_total", "Background saves given up on after their last attempt.", "counter", func(s PersistenceStatus, _ bool) int { return s.AbandonedSaves }},
+}
+
+// ServeMetrics handles GET /metrics in the Prometheus text format, with
+// the persistence metrics labelled by board
+func (h *HealthHandler) ServeMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	ids := h.ids()
+	statuses := make([]PersistenceStatus, len(ids))
+	healthy := make([]bool, len(ids))
+	for i, id := range ids {
+		statuses[i] = h.boards[id].store.PersistenceStatus()
+		healthy[i] = h.boards[id].gate.Healthy()
+	}
+
+	for _, metric := range persistenceMetrics {
+		fmt.Fprintf(w, "# HELP %s %s\n", metric.name, metric.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", metric.name, metric.kind)
+		for i, id := range ids {
+			fmt.Fprintf(w, "%s{board=%q} %d\n", metric.name, id, metric.value(statuses[i], healthy[i]))
+		}
+	}
+
+	for _, m := range h.metrics {
+		m.WriteMetrics(w)
+	}
+}
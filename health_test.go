@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test submissions are rejected while saves fail and accepted again once
+// storage recovers
+func TestWriteGate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	filename := filepath.Join(dir, "leaderboard.json")
+	store := NewScoreStore()
+	gate := NewWriteGate(store, filename, PersistenceConfig{FailureThreshold: 2})
+	handler := NewLeaderboardHandler(store, WithSaveFile(filename), WithWriteGate(gate))
+	t.Cleanup(store.Close)
+	health := NewHealthHandler(store, gate)
+
+	submit := func() int {
+		req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewBufferString(`{"score":100,"playerName":"Saver"}`))
+		w := httptest.NewRecorder()
+		handler.SubmitScore(w, req)
+		return w.Code
+	}
+	checkHealth := func() int {
+		w := httptest.NewRecorder()
+		health.ServeHealth(w, httptest.NewRequest("GET", "/api/health", nil))
+		return w.Code
+	}
+
+	// The data directory does not exist, so saves fail
+	store.SaveToFile(filename)
+	if !gate.Allow() {
+		t.Errorf("Expected writes to be allowed below the failure threshold")
+	}
+	store.SaveToFile(filename)
+
+	// The rejected submission also probes storage, failing a third time
+	if code := submit(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d once unhealthy, got %d", http.StatusServiceUnavailable, code)
+	}
+	if code := checkHealth(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected health status %d, got %d", http.StatusServiceUnavailable, code)
+	}
+
+	// Storage recovers; the next probe succeeds and reopens the gate
+	os.MkdirAll(dir, 0755)
+	gate.lastProbe = time.Time{}
+	if code := submit(); code != http.StatusCreated {
+		t.Errorf("Expected status %d after recovery, got %d", http.StatusCreated, code)
+	}
+	if code := checkHealth(); code != http.StatusOK {
+		t.Errorf("Expected health status %d, got %d", http.StatusOK, code)
+	}
+
+	w := httptest.NewRecorder()
+	health.ServeMetrics(w, httptest.NewRequest("GET", "/metrics", nil))
+	if body := w.Body.String(); !strings.Contains(body, `leaderboard_persistence_healthy{board="main"} 1`) || !strings.Contains(body, `leaderboard_persistence_failures_total{board="main"} 3`) {
+		t.Errorf("Unexpected metrics:\n%s", body)
+	}
+}
+
+// Test the gate can be configured to keep accepting submissions
+func TestWriteGateAcceptWhenUnhealthy(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "missing", "leaderboard.json")
+	store := NewScoreStore()
+	gate := NewWriteGate(store, filename, PersistenceConfig{FailureThreshold: 1, AcceptWhenUnhealthy: true})
+
+	store.SaveToFile(filename)
+	if gate.Healthy() || !gate.Allow() {
+		t.Errorf("Expected unhealthy gate to still allow writes")
+	}
+}
+
+// Test health and metrics cover every board, so a failing named board is
+// noticed
+func TestHealthEveryBoard(t *testing.T) {
+	dir := t.TempDir()
+	main, weekly := NewScoreStore(), NewScoreStore()
+	mainFile, weeklyFile := filepath.Join(dir, "leaderboard.json"), filepath.Join(dir, "missing", "leaderboard-weekly.json")
+	health := NewHealthHandler(main, NewWriteGate(main, mainFile, PersistenceConfig{FailureThreshold: 1}))
+	health.AddBoard("weekly", weekly, NewWriteGate(weekly, weeklyFile, PersistenceConfig{FailureThreshold: 1}))
+
+	main.SaveToFile(mainFile)
+	weekly.SaveToFile(weeklyFile)
+
+	w := httptest.NewRecorder()
+	health.ServeHealth(w, httptest.NewRequest("GET", "/api/health", nil))
+	var status HealthStatus
+	json.NewDecoder(w.Body).Decode(&status)
+	if w.Code != http.StatusServiceUnavailable || status.Status != "degraded" {
+		t.Errorf("Expected a degraded status, got %d %s", w.Code, status.Status)
+	}
+	if status.Boards[MainBoard].Status != "ok" || status.Boards["weekly"].Status != "degraded" || status.Boards["weekly"].Persistence.TotalFailures != 1 {
+		t.Errorf("Expected only the weekly board to be degraded, got %+v", status.Boards)
+	}
+
+	w = httptest.NewRecorder()
+	health.ServeMetrics(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`leaderboard_persistence_healthy{board="main"} 1`,
+		`leaderboard_persistence_healthy{board="weekly"} 0`,
+		`leaderboard_persistence_failures_total{board="weekly"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %s in the metrics:\n%s", want, body)
+		}
+	}
+	if n := strings.Count(body, "# TYPE leaderboard_persistence_healthy "); n != 1 {
+		t.Errorf("Expected one TYPE line per metric, got %d", n)
+	}
+}
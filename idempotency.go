@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader lets clients retry a submission without creating a
+// second entry: requests repeating a key get the first response back
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength caps the length of an Idempotency-Key
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyConfig controls how long submission responses are kept for
+// replaying to clients that retry with the same Idempotency-Key
+type IdempotencyConfig struct {
+	// TTLSeconds is how long a response is replayed. Defaults to 86400 (a
+	// day); negative disables Idempotency-Key support.
+	TTLSeconds int `json:"ttlSeconds,omitempty"`
+	// MaxKeys caps how many responses are kept, dropping the oldest first.
+	// Defaults to 10000.
+	MaxKeys int `json:"maxKeys,omitempty"`
+}
+
+// ttl returns how long responses are replayed
+func (c IdempotencyConfig) ttl() time.Duration {
+	if c.TTLSeconds == 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(c.TTLSeconds) * time.Second
+}
+
+// maxKeys returns how many responses are kept
+func (c IdempotencyConfig) maxKeys() int {
+	if c.MaxKeys <= 0 {
+		return 10000
+	}
+	return c.MaxKeys
+}
+
+// idempotentResponse is a response kept for a key, or a placeholder while
+// the first request with the key is being handled
+type idempotentResponse struct {
+	key string
+	// fingerprint is a hash of the request body, so a key reused for a
+	// different request can be refused
+	fingerprint [sha256.Size]byte
+	stored      time.Time
+	done        bool
+	status      int
+	contentType string
+	body        []byte
+}
+
+// IdempotencyCache keeps the responses to submissions made with an
+// Idempotency-Key for a while, so retries of the same request are answered
+// with the original response instead of creating a duplicate entry
+type IdempotencyCache struct {
+	config IdempotencyConfig
+	// responses holds the elements of order by key
+	responses map[string]*list.Element
+	// order holds the *idempotentResponse values oldest first, so expired
+	// and evicted responses are dropped from the front without a scan
+	order *list.List
+	mu    sync.Mutex
+}
+
+// NewIdempotencyCache creates an empty IdempotencyCache
+func NewIdempotencyCache(config IdempotencyConfig) *IdempotencyCache {
+	return &IdempotencyCache{
+		config:    config,
+		responses: make(map[string]*list.Element),
+		order:     list.New(),
+	}
+}
+
+// Outcomes of IdempotencyCache.begin
+const (
+	// idempotencyNew means the caller should handle the request and then
+	// call finish
+	idempotencyNew = iota
+	// idempotencyReplay means the returned response should be sent again
+	idempotencyReplay
+	// idempotencyInFlight means the first request with the key is still
+	// being handled
+	idempotencyInFlight
+	// idempotencyMismatch means the key was used for a different request
+	idempotencyMismatch
+)
+
+// begin looks up a key, reserving it for the caller if it is new
+func (c *IdempotencyCache) begin(key string, fingerprint [sha256.Size]byte, now time.Time) (int, idempotentResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prune(now)
+	if element, ok := c.responses[key]; ok {
+		response := element.Value.(*idempotentResponse)
+		switch {
+		case response.fingerprint != fingerprint:
+			return idempotencyMismatch, idempotentResponse{}
+		case !response.done:
+			return idempotencyInFlight, idempotentResponse{}
+		default:
+			return idempotencyReplay, *response
+		}
+	}
+
+	if len(c.responses) >= c.config.maxKeys() {
+		c.remove(c.order.Front())
+	}
+	c.responses[key] = c.order.PushBack(&idempotentResponse{key: key, fingerprint: fingerprint, stored: now})
+	return idempotencyNew, idempotentResponse{}
+}
+
+// finish keeps the response to a request begun with key. Only successful
+// responses are kept; after a failure, such as storage being unavailable,
+// the key is released so a retry can succeed.
+func (c *IdempotencyCache) finish(key string, status int, contentType string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.responses[key]
+	if !ok {
+		return
+	}
+	if status < 200 || status >= 300 {
+		c.remove(element)
+		return
+	}
+	response := element.Value.(*idempotentResponse)
+	response.done = true
+	response.status = status
+	response.contentType = contentType
+	response.body = body
+}
+
+// prune drops responses older than the TTL. Responses are stored in
+// order, so the expired ones are at the front.
+func (c *IdempotencyCache) prune(now time.Time) {
+	for element := c.order.Front(); element != nil; element = c.order.Front() {
+		if now.Sub(element.Value.(*idempotentResponse).stored) <= c.config.ttl() {
+			return
+		}
+		c.remove(element)
+	}
+}
+
+// remove drops a response
+func (c *IdempotencyCache) remove(element *list.Element) {
+	delete(c.responses, element.Value.(*idempotentResponse).key)
+	c.order.Remove(element)
+}
+
+// run calls submit for a run submitted with an Idempotency-Key through
+// another API, such as gRPC, unless the key was seen before: a repeat of
+// a completed run gets the first result back, and a repeat of one still
+// in progress, or a different run with the same key, is refused. Keys are
+// scoped to scope, such as the board and player token. Keys expire by
+// now, the board's time.
+func (c *IdempotencyCache) run(scope, key string, submission ScoreSubmission, now time.Time, submit func() (SubmissionResult, error)) (SubmissionResult, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		return SubmissionResult{}, refuse(http.StatusBadRequest, "Idempotency-Key must be at most "+strconv.Itoa(maxIdempotencyKeyLength)+" characters")
+	}
+
+	body, err := json.Marshal(submission)
+	if err != nil {
+		return SubmissionResult{}, err
+	}
+	scoped := scope + "\x00" + key
+	outcome, response := c.begin(scoped, sha256.Sum256(body), now)
+	switch outcome {
+	case idempotencyReplay:
+		var result SubmissionResult
+		err := json.Unmarshal(response.body, &result)
+		return result, err
+	case idempotencyInFlight:
+		return SubmissionResult{}, refuse(http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+	case idempotencyMismatch:
+		return SubmissionResult{}, refuse(http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+	}
+
+	result, err := submit()
+	status := submissionStatus(result, err)
+	if err != nil {
+		c.finish(scoped, status, "", nil)
+		return result, err
+	}
+	body, err = json.Marshal(result)
+	if err != nil {
+		status = http.StatusInternalServerError
+	}
+	c.finish(scoped, status, "application/json", body)
+	return result, nil
+}
+
+// recordingWriter passes a response through while keeping a copy of it
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// idempotent handles a request carrying an Idempotency-Key with handle,
+// unless the key was seen before: a repeat of a completed request gets the
+// original response with an Idempotent-Replayed header, and a repeat of
+// one still in progress, or a different request with the same key, is
+// refused. Keys are scoped to the path and player token, so clients
+// cannot replay each other's responses. Keys expire by now, the board's
+// time.
+func (c *IdempotencyCache) idempotent(w http.ResponseWriter, r *http.Request, key string, now time.Time, handle http.HandlerFunc) {
+	if len(key) > maxIdempotencyKeyLength {
+		httpError(w, r, "Idempotency-Key must be at most "+strconv.Itoa(maxIdempotencyKeyLength)+" characters", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httpError(w, r, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		httpError(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	scoped := r.URL.Path + "\x00" + r.Header.Get(PlayerTokenHeader) + "\x00" + key
+	outcome, response := c.begin(scoped, sha256.Sum256(body), now)
+	switch outcome {
+	case idempotencyReplay:
+		w.Header().Set("Content-Type", response.contentType)
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(response.status)
+		w.Write(response.body)
+		return
+	case idempotencyInFlight:
+		httpError(w, r, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+		return
+	case idempotencyMismatch:
+		httpError(w, r, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+		return
+	}
+
+	recorder := &recordingWriter{ResponseWriter: w}
+	defer func() {
+		c.finish(scoped, recorder.status, w.Header().Get("Content-Type"), recorder.body.Bytes())
+	}()
+	handle(recorder, r)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test submissions retried with an Idempotency-Key get the original
+// response instead of creating another entry
+func TestSubmitScoreIdempotencyKey(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store, WithSaveFile(t.TempDir()+"/leaderboard.json"), WithIdempotency(NewIdempotencyCache(IdempotencyConfig{})))
+	t.Cleanup(store.Close)
+
+	tests := []struct {
+		name         string
+		key          string
+		token        string
+		body         string
+		wantCode     int
+		wantReplayed bool
+		wantEntries  int
+	}{
+		{"first attempt", "run-1", "", `{"playerName":"Kiro","score":500}`, http.StatusCreated, false, 1},
+		{"retry", "run-1", "", `{"playerName":"Kiro","score":500}`, http.StatusCreated, true, 1},
+		{"key reused for another run", "run-1", "", `{"playerName":"Kiro","score":900}`, http.StatusUnprocessableEntity, false, 1},
+		{"another player's key", "run-1", "other-token", `{"playerName":"Mario","score":500}`, http.StatusCreated, false, 2},
+		{"no key", "", "", `{"playerName":"Kiro","score":500}`, http.StatusCreated, false, 3},
+		{"invalid submission", "run-2", "", `{"playerName":"","score":500}`, http.StatusBadRequest, false, 3},
+		{"key released after failure", "run-2", "", `{"playerName":"Luigi","score":500}`, http.StatusCreated, false, 4},
+		{"key too long", strings.Repeat("k", 256), "", `{"playerName":"Kiro","score":500}`, http.StatusBadRequest, false, 4},
+		{"body too large", "run-3", "", `{"playerName":"Kiro","score":500,"metadata":"` + strings.Repeat("x", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, false, 4},
+		{"body too large without key", "", "", `{"playerName":"Kiro","score":500,"metadata":"` + strings.Repeat("x", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, false, 4},
+	}
+
+	var firstID string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/leaderboard", strings.NewReader(tt.body))
+			if tt.key != "" {
+				req.Header.Set(IdempotencyKeyHeader, tt.key)
+			}
+			if tt.token != "" {
+				req.Header.Set(PlayerTokenHeader, tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.SubmitScore(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != tt.wantReplayed {
+				t.Errorf("Expected replayed %v, got %v", tt.wantReplayed, replayed)
+			}
+			if n := len(store.GetAllEntries(context.Background())); n != tt.wantEntries {
+				t.Errorf("Expected %d entries, got %d", tt.wantEntries, n)
+			}
+
+			var result SubmissionResult
+			json.NewDecoder(w.Body).Decode(&result)
+			switch tt.name {
+			case "first attempt":
+				firstID = result.ID
+			case "retry":
+				if result.ID != firstID {
+					t.Errorf("Expected the original entry %s, got %s", firstID, result.ID)
+				}
+			}
+		})
+	}
+}
+
+// Test keys expire by the board's clock rather than the wall clock
+func TestSubmitScoreIdempotencyKeyExpiry(t *testing.T) {
+	now := time.Now()
+	store := NewScoreStore(WithClock(ClockFunc(func() time.Time { return now })))
+	handler := NewLeaderboardHandler(store, WithSaveFile(t.TempDir()+"/leaderboard.json"), WithIdempotency(NewIdempotencyCache(IdempotencyConfig{TTLSeconds: 60})))
+	t.Cleanup(store.Close)
+
+	submit := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/leaderboard", strings.NewReader(`{"playerName":"Kiro","score":500}`))
+		req.Header.Set(IdempotencyKeyHeader, "run-1")
+		w := httptest.NewRecorder()
+		handler.SubmitScore(w, req)
+		return w
+	}
+
+	submit()
+	if w := submit(); w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected a retry within the TTL to be replayed, got %d", w.Code)
+	}
+	now = now.Add(2 * time.Minute)
+	if w := submit(); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") == "true" {
+		t.Errorf("Expected the key to have expired, got %d replayed=%s", w.Code, w.Header().Get("Idempotent-Replayed"))
+	}
+	if n := len(store.GetAllEntries(context.Background())); n != 2 {
+		t.Errorf("Expected 2 entries, got %d", n)
+	}
+}
+
+// Test keys are refused while their first request is in progress and
+// forgotten after the TTL
+func TestIdempotencyCache(t *testing.T) {
+	cache := NewIdempotencyCache(IdempotencyConfig{TTLSeconds: 60, MaxKeys: 2})
+	fingerprint := sha256.Sum256([]byte("body"))
+	now := time.Now()
+
+	if outcome, _ := cache.begin("a", fingerprint, now); outcome != idempotencyNew {
+		t.Fatalf("Expected a new key, got %d", outcome)
+	}
+	if outcome, _ := cache.begin("a", fingerprint, now); outcome != idempotencyInFlight {
+		t.Errorf("Expected the key to be in flight, got %d", outcome)
+	}
+
+	cache.finish("a", http.StatusCreated, "application/json", []byte(`{"id":"1"}`))
+	outcome, response := cache.begin("a", fingerprint, now.Add(time.Minute))
+	if outcome != idempotencyReplay || response.status != http.StatusCreated || string(response.body) != `{"id":"1"}` {
+		t.Errorf("Expected the response to be replayed, got %d %+v", outcome, response)
+	}
+	if outcome, _ := cache.begin("a", fingerprint, now.Add(2*time.Minute)); outcome != idempotencyNew {
+		t.Errorf("Expected the key to expire after the TTL, got %d", outcome)
+	}
+
+	// The oldest key makes room once the cache is full
+	later := now.Add(2*time.Minute + 30*time.Second)
+	cache.begin("b", fingerprint, later)
+	cache.begin("c", fingerprint, later.Add(time.Second))
+	if _, ok := cache.responses["a"]; ok || len(cache.responses) != 2 || cache.order.Len() != 2 {
+		t.Errorf("Expected the oldest key to be evicted, got %d keys", len(cache.responses))
+	}
+
+	// Expired keys are dropped from the front, leaving later ones
+	cache.begin("d", fingerprint, later.Add(time.Minute+time.Millisecond))
+	if _, ok := cache.responses["c"]; !ok || len(cache.responses) != 2 {
+		t.Errorf("Expected only the expired key to be dropped, got %d keys", len(cache.responses))
+	}
+}
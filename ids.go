@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ID generator names for IDConfig.Generator
+const (
+	IDGeneratorUUID      = "uuid"
+	IDGeneratorUUIDv7    = "uuidv7"
+	IDGeneratorULID      = "ulid"
+	IDGeneratorSnowflake = "snowflake"
+)
+
+// IDConfig selects how entry IDs are generated
+type IDConfig struct {
+	// Generator is "uuid" (random UUIDv4, the default), or one of the
+	// time-ordered "uuidv7", "ulid" or "snowflake"
+	Generator string `json:"generator,omitempty"`
+	// NodeID distinguishes server instances generating snowflake IDs, 0-1023
+	NodeID int `json:"nodeId,omitempty"`
+}
+
+// IDGenerator creates unique entry IDs
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc adapts a function to the IDGenerator interface
+type IDGeneratorFunc func() string
+
+// NewID calls f()
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+// NewIDGenerator creates the generator selected by config
+func NewIDGenerator(config IDConfig) (IDGenerator, error) {
+	switch config.Generator {
+	case "", IDGeneratorUUID:
+		return IDGeneratorFunc(newUUID), nil
+	case IDGeneratorUUIDv7:
+		return IDGeneratorFunc(newUUIDv7), nil
+	case IDGeneratorULID:
+		return &ulidGenerator{}, nil
+	case IDGeneratorSnowflake:
+		if config.NodeID < 0 || config.NodeID > snowflakeMaxNode {
+			return nil, fmt.Errorf("snowflake node ID must be between 0 and %d", snowflakeMaxNode)
+		}
+		return &snowflakeGenerator{node: int64(config.NodeID)}, nil
+	}
+	return nil, fmt.Errorf("unknown ID generator %q", config.Generator)
+}
+
+// newUUID returns a random UUIDv4
+func newUUID() string {
+	return uuid.New().String()
+}
+
+// newUUIDv7 returns a time-ordered UUIDv7
+func newUUIDv7() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return newUUID()
+	}
+	return id.String()
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidGenerator creates ULIDs: a 48-bit millisecond timestamp followed by
+// 80 random bits, as 26 Crockford base32 characters. IDs created in the
+// same millisecond increment the random part so they stay ordered.
+type ulidGenerator struct {
+	lastMs int64
+	last   [10]byte
+	mu     sync.Mutex
+}
+
+// NewID returns the next ULID
+func (g *ulidGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := time.Now().UnixMilli()
+	if ms <= g.lastMs {
+		// Same millisecond (or clock went back): increment the random part
+		ms = g.lastMs
+		for i := len(g.last) - 1; i >= 0; i-- {
+			g.last[i]++
+			if g.last[i] != 0 {
+				break
+			}
+		}
+	} else {
+		g.lastMs = ms
+		rand.Read(g.last[:])
+	}
+
+	var id [16]byte
+	for i := 0; i < 6; i++ {
+		id[i] = byte(ms >> (40 - 8*i))
+	}
+	copy(id[6:], g.last[:])
+	return encodeULID(id)
+}
+
+// encodeULID encodes 128 bits as 26 base32 characters, most significant first
+func encodeULID(id [16]byte) string {
+	var out [26]byte
+	// 26 characters hold 130 bits; the first character carries the top 3
+	bitPos := -2
+	for i := range out {
+		var v byte
+		for b := 0; b < 5; b++ {
+			v <<= 1
+			if pos := bitPos + b; pos >= 0 && id[pos/8]&(0x80>>(pos%8)) != 0 {
+				v |= 1
+			}
+		}
+		out[i] = crockford[v]
+		bitPos += 5
+	}
+	return string(out[:])
+}
+
+// Snowflake layout: 41 bits of milliseconds since snowflakeEpoch, 10 bits
+// of node ID and 12 bits of per-millisecond sequence
+const (
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	snowflakeMaxNode  = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSeq   = 1<<snowflakeSeqBits - 1
+)
+
+// snowflakeEpoch is the start of snowflake timestamps
+var snowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+// snowflakeGenerator creates 63-bit snowflake IDs, formatted as 19 digit
+// zero-padded decimals so string order matches time order
+type snowflakeGenerator struct {
+	node   int64
+	lastMs int64
+	seq    int64
+	mu     sync.Mutex
+}
+
+// NewID returns the next snowflake ID
+func (g *snowflakeGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := time.Now().UnixMilli() - snowflakeEpoch
+	if ms < g.lastMs {
+		ms = g.lastMs
+	}
+	if ms == g.lastMs {
+		g.seq++
+		if g.seq > snowflakeMaxSeq {
+			// Sequence exhausted for this millisecond; borrow the next one
+			ms++
+			g.seq = 0
+		}
+	} else {
+		g.seq = 0
+	}
+	g.lastMs = ms
+
+	id := ms<<(snowflakeNodeBits+snowflakeSeqBits) | g.node<<snowflakeSeqBits | g.seq
+	return fmt.Sprintf("%019d", id)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// Test each generator creates unique IDs safe for use in file names, and
+// time-ordered generators create IDs that sort in creation order
+func TestIDGenerators(t *testing.T) {
+	tests := []struct {
+		generator string
+		length    int
+		ordered   bool
+	}{
+		{IDGeneratorUUID, 36, false},
+		{IDGeneratorUUIDv7, 36, true},
+		{IDGeneratorULID, 26, true},
+		{IDGeneratorSnowflake, 19, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.generator, func(t *testing.T) {
+			gen, err := NewIDGenerator(IDConfig{Generator: tt.generator, NodeID: 7})
+			if err != nil {
+				t.Fatalf("Failed to create generator: %v", err)
+			}
+
+			seen := make(map[string]bool)
+			prev := ""
+			for i := 0; i < 10000; i++ {
+				id := gen.NewID()
+				if len(id) != tt.length || !entryIDPattern.MatchString(id) {
+					t.Fatalf("Unexpected ID format %q", id)
+				}
+				if seen[id] {
+					t.Fatalf("Duplicate ID %q after %d IDs", id, i)
+				}
+				seen[id] = true
+				if tt.ordered && id <= prev {
+					t.Fatalf("Expected %q to sort after %q", id, prev)
+				}
+				prev = id
+			}
+		})
+	}
+}
+
+// Test ULIDs encode their timestamp in the standard layout
+func TestEncodeULID(t *testing.T) {
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xff
+	}
+
+	if got := encodeULID([16]byte{}); got != "00000000000000000000000000" {
+		t.Errorf("Expected zero ULID, got %s", got)
+	}
+	if got := encodeULID(max); got != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Errorf("Expected max ULID, got %s", got)
+	}
+}
+
+// Test invalid generator settings are rejected
+func TestNewIDGeneratorInvalid(t *testing.T) {
+	for _, config := range []IDConfig{
+		{Generator: "sequential"},
+		{Generator: IDGeneratorSnowflake, NodeID: 1024},
+		{Generator: IDGeneratorSnowflake, NodeID: -1},
+	} {
+		if _, err := NewIDGenerator(config); err == nil {
+			t.Errorf("Expected error for %+v", config)
+		}
+	}
+}
+
+// Test the store uses the configured generator for new entries
+func TestScoreStoreIDGenerator(t *testing.T) {
+	store := NewScoreStore()
+	store.SetIDGenerator(IDGeneratorFunc(func() string { return "fixed-id" }))
+
+	if entry := store.AddScore(context.Background(), 100, "Player"); entry.ID != "fixed-id" {
+		t.Errorf("Expected ID fixed-id, got %s", entry.ID)
+	}
+
+	// Generators given at creation make IDs predictable in tests
+	next := 0
+	store = NewScoreStore(WithIDGenerator(IDGeneratorFunc(func() string {
+		next++
+		return fmt.Sprintf("run-%d", next)
+	})))
+	for i, want := range []string{"run-1", "run-2"} {
+		if entry := store.AddScore(context.Background(), 100*(i+1), "Player"); entry.ID != want {
+			t.Errorf("Expected ID %s, got %s", want, entry.ID)
+		}
+	}
+}
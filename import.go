@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxImportBytes caps the size of an uploaded import file
+const maxImportBytes = 32 << 20
+
+// ImportError reports why an entry of an import file was rejected. Row
+// counts entries from 1, not counting a CSV header.
+type ImportError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// ImportResult reports the outcome of a bulk import. Duplicates lists the
+// rows skipped because the entry is already on the board, and Superseded
+// those skipped on best-per-player boards because the player has a better
+// run.
+type ImportResult struct {
+	Imported   int           `json:"imported"`
+	Duplicates []int         `json:"duplicates,omitempty"`
+	Superseded []int         `json:"superseded,omitempty"`
+	Errors     []ImportError `json:"errors,omitempty"`
+	// EntryIDs are the IDs given to the imported entries
+	EntryIDs []string `json:"entryIds,omitempty"`
+}
+
+// parseImportJSON reads entries from a JSON array of entries, such as a
+// leaderboard.json file, or from a player export document
+func parseImportJSON(r io.Reader) ([]ScoreEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+
+	var entries []ScoreEntry
+	if len(data) > 0 && data[0] == '{' {
+		var export PlayerExport
+		err = json.Unmarshal(data, &export)
+		entries = export.Entries
+	} else {
+		err = json.Unmarshal(data, &entries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	return entries, nil
+}
+
+// importRequiredColumns must be in the header row of CSV imports
+var importRequiredColumns = []string{"playerName", "score", "timestamp"}
+
+// parseImportCSV reads entries from CSV with a header row naming
+// exportColumns, in any order, so player and board exports can be imported.
+// Other columns, such as rank, are ignored. Cells that fail to parse are
+// reported against their row.
+func parseImportCSV(r io.Reader) ([]ScoreEntry, []ImportError, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range importRequiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("CSV header is missing the %s column", name)
+		}
+	}
+
+	var entries []ScoreEntry
+	var rowErrors []ImportError
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return entries, rowErrors, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		entry, err := importCSVRow(columns, record)
+		if err != nil {
+			rowErrors = append(rowErrors, ImportError{Row: row, Message: err.Error()})
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// importCSVRow reads an entry from a CSV record
+func importCSVRow(columns map[string]int, record []string) (ScoreEntry, error) {
+	cell := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	var entry ScoreEntry
+	var err error
+	entry.ID = cell("id")
+	entry.PlayerName = csvText(cell("playerName"))
+	entry.Difficulty = cell("difficulty")
+	entry.Character = cell("character")
+	entry.Country = cell("country")
+	entry.Verification = cell("verification")
+	if metadata := csvText(cell("metadata")); metadata != "" {
+		entry.Metadata = json.RawMessage(metadata)
+	}
+	if entry.Score, err = strconv.Atoi(cell("score")); err != nil {
+		return entry, errors.New("score must be an integer")
+	}
+	if raw := cell("rawScore"); raw != "" {
+		if entry.RawScore, err = strconv.Atoi(raw); err != nil {
+			return entry, errors.New("rawScore must be an integer")
+		}
+	}
+	if entry.Timestamp, err = time.Parse(time.RFC3339Nano, cell("timestamp")); err != nil {
+		return entry, errors.New("timestamp must be an RFC 3339 time")
+	}
+	if duration := cell("durationMs"); duration != "" {
+		if entry.DurationMs, err = strconv.ParseInt(duration, 10, 64); err != nil {
+			return entry, errors.New("durationMs must be an integer")
+		}
+	}
+	if hidden := cell("hidden"); hidden != "" {
+		if entry.Hidden, err = strconv.ParseBool(hidden); err != nil {
+			return entry, errors.New("hidden must be true or false")
+		}
+	}
+	if pending := cell("pending"); pending != "" {
+		if entry.Pending, err = strconv.ParseBool(pending); err != nil {
+			return entry, errors.New("pending must be true or false")
+		}
+	}
+	return entry, nil
+}
+
+// csvText undoes csvCell, removing the quote that kept a cell from being
+// run as a formula
+func csvText(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+// prepareImport checks an entry being imported against the same rules as
+// submissions, including the name filter if names is not nil, and returns
+// it ready to store: normalized, with its provenance replaced by an import
+// step, and without flags for replays and ghosts, whose files are not
+// imported. Review and verification state from the file is not trusted.
+func prepareImport(entry ScoreEntry, config BoardConfig, names *NameFilter, credential string, now time.Time) (ScoreEntry, error) {
+	if entry.PlayerName == "" {
+		return entry, errors.New("player name is required")
+	}
+	if names != nil {
+		name, err := names.Check(entry.PlayerName)
+		if err != nil {
+			return entry, fmt.Errorf("invalid player name: %w", err)
+		}
+		entry.PlayerName = name
+	}
+	if entry.Score < 0 {
+		return entry, errors.New("score must be non-negative")
+	}
+	if entry.DurationMs < 0 {
+		return entry, errors.New("duration must be non-negative")
+	}
+	if entry.Timestamp.IsZero() {
+		return entry, errors.New("timestamp is required")
+	}
+	if entry.Timestamp.After(now) {
+		return entry, errors.New("timestamp is in the future")
+	}
+
+	entry.Character = strings.ToLower(entry.Character)
+	if entry.Character != "" && !boardNamePattern.MatchString(entry.Character) {
+		return entry, errors.New("character may only contain letters, digits, - and _")
+	}
+	if entry.Country != "" {
+		country, ok := normalizeCountry(entry.Country)
+		if !ok {
+			return entry, errors.New("country must be a two-letter ISO 3166-1 code")
+		}
+		entry.Country = country
+	}
+	metadata, err := config.CheckMetadata(entry.Metadata)
+	if err != nil {
+		return entry, err
+	}
+	entry.Metadata = metadata
+
+	raw := entry.Score
+	if entry.Difficulty != "" {
+		raw = entry.RawScore
+	}
+	if reason := config.CheckScoreCap(raw, entry.DurationMs); reason != "" {
+		return entry, errors.New(reason)
+	}
+
+	// Flags, verdicts and telemetry were never checked here, so they are
+	// dropped, and scores this board would hold for review are held even
+	// if they were approved elsewhere
+	entry.Flags = nil
+	entry.Verification = ""
+	entry.Telemetry = nil
+	if threshold := config.ReviewThreshold; threshold > 0 && config.beats(entry.Score, threshold) {
+		entry.Pending = true
+	}
+
+	entry.Superseded = false
+	entry.Duplicate = false
+	entry.HeldUntil = nil
+	entry.HasReplay = false
+	entry.ReplayVerified = false
+	entry.HasGhost = false
+	entry.Provenance = []ProvenanceStep{{Source: SourceImport, Credential: credential, At: now}}
+	return entry, nil
+}
+
+// importKey identifies an entry by player, score and time, which survive
+// a move between deployments when IDs may not
+func importKey(entry ScoreEntry) string {
+	return entry.PlayerName + "\x00" + strconv.Itoa(entry.Score) + "\x00" + strconv.FormatInt(entry.Timestamp.UnixNano(), 10)
+}
+
+// ImportEntries adds entries brought over from another deployment, keeping
+// their timestamps but giving each a new ID. An entry is a duplicate, and
+// is skipped, if it has the ID of a stored entry or the same player, score
+// and time as a stored or earlier imported one. On best-per-player boards
+// the imported and stored runs are pruned like AddEntry prunes them, so
+// only each player's current bests are kept. With dryRun nothing is
+// stored. It returns the entries added, or that would be, and the
+// positions in entries of the duplicates and of the runs pruned.
+func (s *ScoreStore) ImportEntries(ctx context.Context, entries []ScoreEntry, dryRun bool) ([]ScoreEntry, []int, []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.load()
+	ids := make(map[string]bool, len(st.entries))
+	keys := make(map[string]bool, len(st.entries)+len(entries))
+	for _, entry := range st.entries {
+		ids[entry.ID] = true
+		keys[importKey(entry)] = true
+	}
+
+	var added []ScoreEntry
+	var positions, duplicates []int
+	for i, entry := range entries {
+		key := importKey(entry)
+		if (entry.ID != "" && ids[entry.ID]) || keys[key] {
+			duplicates = append(duplicates, i)
+			continue
+		}
+		keys[key] = true
+		// Dry runs don't use up IDs, but pruning needs one per entry
+		if dryRun {
+			entry.ID = "import-" + strconv.Itoa(i)
+		} else {
+			entry.ID = s.ids.NewID()
+		}
+		added = append(added, entry)
+		positions = append(positions, i)
+	}
+
+	kept := st.entries
+	var superseded []int
+	if st.config.BestPerPlayer {
+		var pruned map[string]bool
+		kept, pruned = st.config.pruneImport(st.entries, added, s.clock.Now())
+		imported := added[:0]
+		for i, entry := range added {
+			if pruned[entry.ID] {
+				superseded = append(superseded, positions[i])
+			} else {
+				imported = append(imported, entry)
+			}
+		}
+		added = imported
+	}
+
+	if dryRun {
+		for i := range added {
+			added[i].ID = ""
+		}
+	} else if len(added) > 0 || len(kept) != len(st.entries) {
+		s.publish(st.withEntries(append(slices.Clone(kept), added...)))
+	}
+	return added, duplicates, superseded
+}
+
+// pruneImport keeps only the current bests among the settled runs of each
+// player and character that entries are imported for, counting both stored
+// and imported runs. It returns the stored entries to keep and the IDs of
+// the imported entries to drop.
+func (c BoardConfig) pruneImport(stored, imported []ScoreEntry, now time.Time) ([]ScoreEntry, map[string]bool) {
+	type runKey struct{ player, character string }
+	runs := make(map[runKey][]ScoreEntry)
+	for _, entry := range imported {
+		if entry.settled(now) {
+			key := runKey{entry.PlayerName, entry.Character}
+			runs[key] = append(runs[key], entry)
+		}
+	}
+	for _, entry := range stored {
+		key := runKey{entry.PlayerName, entry.Character}
+		if _, ok := runs[key]; ok && entry.settled(now) {
+			runs[key] = append(runs[key], entry)
+		}
+	}
+
+	bests := make(map[string]bool)
+	for _, playerRuns := range runs {
+		maps.Copy(bests, c.currentBests(playerRuns, now))
+	}
+	beaten := func(entry ScoreEntry) bool {
+		_, ok := runs[runKey{entry.PlayerName, entry.Character}]
+		return ok && entry.settled(now) && !bests[entry.ID]
+	}
+
+	kept := slices.DeleteFunc(slices.Clone(stored), beaten)
+	pruned := make(map[string]bool)
+	for _, entry := range imported {
+		if beaten(entry) {
+			pruned[entry.ID] = true
+		}
+	}
+	return kept, pruned
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test importing entries as JSON and CSV, skipping duplicates and
+// rejecting files with invalid entries
+func TestImport(t *testing.T) {
+	useTempSaveFiles(t)
+	old := NewScoreStore()
+	old.AddScore(context.Background(), 900, "=Champion")
+	old.AddEntry(context.Background(), ScoreEntry{Score: 500, PlayerName: "Kiro", Country: "de", Metadata: json.RawMessage(`{"level":3}`)})
+	var exported bytes.Buffer
+	if err := writeBoardCSV(context.Background(), &exported, old); err != nil {
+		t.Fatalf("Expected the old board to export, got %v", err)
+	}
+	oldJSON, _ := json.Marshal(old.GetAllEntries(context.Background()))
+	earlier := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	store := NewScoreStore()
+	t.Cleanup(store.Close)
+	store.AddScore(context.Background(), 700, "Mario")
+	handler := NewAdminHandler(store, NewRuleSet(), "", WithAuditLog(NewAuditLog(filepath.Join(t.TempDir(), "audit.log"))))
+
+	// Dry runs report the entries that would be imported
+	req := httptest.NewRequest("POST", "/api/admin/import?dryRun=true", strings.NewReader(exported.String()))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	handler.Import(w, req)
+	var dryRun DryRunResult
+	json.NewDecoder(w.Body).Decode(&dryRun)
+	if w.Code != http.StatusOK || !dryRun.DryRun || dryRun.Affected != 2 {
+		t.Errorf("Expected a dry run of 2 entries, got %d: %+v", w.Code, dryRun)
+	}
+	if got := len(store.GetAllEntries(context.Background())); got != 1 {
+		t.Errorf("Expected the dry run to import nothing, got %d entries", got)
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		contentType    string
+		body           string
+		wantCode       int
+		wantImported   int
+		wantDuplicates int
+		wantErrors     int
+		wantEntries    int
+	}{
+		{"csv export", "", "text/csv", exported.String(), http.StatusOK, 2, 0, 0, 3},
+		{"same entries as json", "", "application/json", string(oldJSON), http.StatusOK, 0, 2, 0, 3},
+		{"invalid rows", "?format=csv", "", "playerName,score,timestamp\nLuigi,100," + earlier + "\n,100," + earlier + "\nPeach,lots," + earlier + "\n", http.StatusBadRequest, 0, 0, 2, 3},
+		{"missing column", "?format=csv", "", "playerName,score\nLuigi,100\n", http.StatusBadRequest, 0, 0, 0, 3},
+		{"unknown format", "?format=xml", "", "", http.StatusBadRequest, 0, 0, 0, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/admin/import"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			handler.Import(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+
+			var result ImportResult
+			json.NewDecoder(w.Body).Decode(&result)
+			if result.Imported != tt.wantImported || len(result.Duplicates) != tt.wantDuplicates || len(result.Errors) != tt.wantErrors {
+				t.Errorf("Expected %d imported, %d duplicates and %d errors, got %+v", tt.wantImported, tt.wantDuplicates, tt.wantErrors, result)
+			}
+			if got := len(store.GetAllEntries(context.Background())); got != tt.wantEntries {
+				t.Errorf("Expected %d entries, got %d", tt.wantEntries, got)
+			}
+		})
+	}
+
+	board := store.QueryScores(context.Background(), ScoreQuery{})
+	champion := board[0]
+	if champion.PlayerName != "=Champion" || champion.Score != 900 || champion.Origin().Source != SourceImport {
+		t.Errorf("Expected the imported champion first with import provenance, got %+v", champion)
+	}
+	if kiro := board[2]; kiro.Country != "DE" || string(kiro.Metadata) != `{"level":3}` {
+		t.Errorf("Expected Kiro's country and metadata to be imported, got %+v", kiro)
+	}
+}
+
+// Test imported entries get the name filter and score caps of submissions,
+// and that review state in the file is not trusted
+func TestImportChecksLikeSubmissions(t *testing.T) {
+	useTempSaveFiles(t)
+	earlier := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	names, _ := NewNameFilter(NameConfig{})
+
+	tests := []struct {
+		name      string
+		body      string
+		wantError string
+	}{
+		{"profane name", `[{"playerName": "shitlord", "score": 100, "timestamp": "` + earlier + `"}]`, "invalid player name"},
+		{"control characters", `[{"playerName": "Ki\u0007ro", "score": 100, "timestamp": "` + earlier + `"}]`, ""},
+		{"over the cap", `[{"playerName": "Kiro", "score": 5000, "timestamp": "` + earlier + `"}]`, "Score exceeds maximum of 1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewScoreStore()
+			t.Cleanup(store.Close)
+			store.SetConfig(BoardConfig{MaxScore: 1000})
+			handler := NewAdminHandler(store, NewRuleSet(), "", WithAdminNameFilter(names))
+
+			w := httptest.NewRecorder()
+			handler.Import(w, httptest.NewRequest("POST", "/api/admin/import", strings.NewReader(tt.body)))
+			var result ImportResult
+			json.NewDecoder(w.Body).Decode(&result)
+			if tt.wantError == "" {
+				if w.Code != http.StatusOK || result.Imported != 1 {
+					t.Fatalf("Expected the entry to be imported, got %d: %+v", w.Code, result)
+				}
+				if name := store.GetAllEntries(context.Background())[0].PlayerName; name != "Kiro" {
+					t.Errorf("Expected the name to be cleaned to Kiro, got %q", name)
+				}
+				return
+			}
+			if w.Code != http.StatusBadRequest || len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, tt.wantError) {
+				t.Errorf("Expected an error containing %q, got %d: %+v", tt.wantError, w.Code, result)
+			}
+		})
+	}
+
+	store := NewScoreStore()
+	t.Cleanup(store.Close)
+	store.SetConfig(BoardConfig{ReviewThreshold: 1000})
+	handler := NewAdminHandler(store, NewRuleSet(), "")
+	body := `[{"playerName": "Kiro", "score": 5000, "timestamp": "` + earlier + `", "verification": "verified", "flags": ["fast"]}]`
+	w := httptest.NewRecorder()
+	handler.Import(w, httptest.NewRequest("POST", "/api/admin/import", strings.NewReader(body)))
+	entries := store.GetAllEntries(context.Background())
+	if w.Code != http.StatusOK || len(entries) != 1 {
+		t.Fatalf("Expected the entry to be imported, got %d: %s", w.Code, w.Body.String())
+	}
+	if entry := entries[0]; !entry.Pending || entry.Verification != "" || entry.Flags != nil {
+		t.Errorf("Expected a pending entry without verdict or flags, got %+v", entry)
+	}
+}
+
+// Test imports onto best-per-player boards keep only each player's best
+func TestImportBestPerPlayer(t *testing.T) {
+	useTempSaveFiles(t)
+	store := NewScoreStore()
+	t.Cleanup(store.Close)
+	store.SetConfig(BoardConfig{BestPerPlayer: true})
+	store.AddScore(context.Background(), 300, "Kiro")
+	handler := NewAdminHandler(store, NewRuleSet(), "")
+
+	lastYear := time.Now().AddDate(-1, 0, 0).UTC().Format(time.RFC3339)
+	body := `[
+		{"playerName": "Kiro", "score": 500, "timestamp": "` + lastYear + `"},
+		{"playerName": "Kiro", "score": 200, "timestamp": "` + lastYear + `"},
+		{"playerName": "Luigi", "score": 100, "timestamp": "` + lastYear + `"}
+	]`
+	w := httptest.NewRecorder()
+	handler.Import(w, httptest.NewRequest("POST", "/api/admin/import", strings.NewReader(body)))
+	var result ImportResult
+	json.NewDecoder(w.Body).Decode(&result)
+	if w.Code != http.StatusOK || result.Imported != 2 || len(result.Superseded) != 1 || result.Superseded[0] != 2 {
+		t.Fatalf("Expected 2 imported and row 2 superseded, got %d: %+v", w.Code, result)
+	}
+
+	// Last year's 500 is Kiro's all-time best and today's 300 the best of
+	// the current periods, so both stay
+	var scores []int
+	for _, entry := range store.GetAllEntries(context.Background()) {
+		scores = append(scores, entry.Score)
+	}
+	slices.Sort(scores)
+	if !slices.Equal(scores, []int{100, 300, 500}) {
+		t.Errorf("Expected scores [100 300 500], got %v", scores)
+	}
+}
@@ -1,85 +1,1387 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"log"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/google/uuid"
 )
 
-// ScoreEntry represents a single leaderboard entry
+// ScoreEntry represents a single leaderboard entry. Score is the value used
+// for ranking; for boards with difficulty multipliers it is the normalized
+// score and RawScore holds the score as submitted.
 type ScoreEntry struct {
-	ID         string    `json:"id"`
-	Score      int       `json:"score"`
+	ID         string `json:"id"`
+	Score      int    `json:"score"`
+	RawScore   int    `json:"rawScore,omitempty"`
+	Difficulty string `json:"difficulty,omitempty"`
+	// Character is the character or skin the run was played with
+	Character string `json:"character,omitempty"`
+	// Country is the ISO 3166-1 alpha-2 code of the player's country
+	Country    string    `json:"country,omitempty"`
 	PlayerName string    `json:"playerName"`
 	Timestamp  time.Time `json:"timestamp"`
+	// DurationMs is the run's completion time reported by the client, zero
+	// if it was not given
+	DurationMs int64 `json:"durationMs,omitempty"`
+	// ClientTimestamp is the run-end time claimed by the client, kept only
+	// when it is within the board's allowed clock skew of Timestamp
+	ClientTimestamp *time.Time `json:"clientTimestamp,omitempty"`
+	Flags           []string   `json:"flags,omitempty"`
+	Hidden          bool       `json:"hidden,omitempty"`
+	// Pending entries exceeded the review threshold and are excluded from
+	// results until a moderator approves them
+	Pending bool `json:"pending,omitempty"`
+	// HeldUntil is when a record-breaking entry held for review appears
+	// on the board, unless a moderator approves or rejects it first
+	HeldUntil *time.Time `json:"heldUntil,omitempty"`
+	// Suspect entries are statistical outliers versus recent submissions
+	Suspect bool `json:"suspect,omitempty"`
+	// Verification is the anti-cheat review state of flagged entries
+	Verification string `json:"verification,omitempty"`
+	// Metadata is a game-specific JSON object submitted with the score,
+	// such as the character used, stored and returned as is
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// Telemetry is the per-level run data submitted with the score
+	Telemetry []LevelTelemetry `json:"telemetry,omitempty"`
+	// HasReplay is set when an input replay is stored for the entry
+	HasReplay bool `json:"hasReplay,omitempty"`
+	// ReplayVerified is set when re-simulating the replay reproduced the
+	// submitted score
+	ReplayVerified bool `json:"replayVerified,omitempty"`
+	// HasGhost is set when positional ghost data is stored for the entry
+	HasGhost bool `json:"hasGhost,omitempty"`
+	// Superseded is set on a run returned by AddEntry that was not stored
+	// because it did not beat the player's best on a best-per-player board
+	Superseded bool `json:"superseded,omitempty"`
+	// RunID is an optional ID the client gives each run, so a run sent
+	// twice, such as by double-tapping submit, is stored once
+	RunID string `json:"runId,omitempty"`
+	// Duplicate is set on the entry AddEntry returns in place of a run that
+	// was already stored moments before; the entry is the stored one
+	Duplicate bool `json:"duplicate,omitempty"`
+	// Provenance records how the entry was created and later edited. It is
+	// shown only to moderators.
+	Provenance []ProvenanceStep `json:"provenance,omitempty"`
+	// DecayedScore is the score weighted by age, set on results ranked by
+	// decayed score. It is not stored.
+	DecayedScore float64 `json:"decayedScore,omitempty"`
+	// version is the board version that appended the entry, zero for
+	// entries loaded from storage
+	version uint64
+	// replaced holds the runs AddEntry took off a best-per-player board
+	// for this one, so WithdrawEntry can put them back. It is only set on
+	// the entry AddEntry returns.
+	replaced []ScoreEntry
+}
+
+// held reports whether an entry is held back as a possible fake record
+func (e ScoreEntry) held(now time.Time) bool {
+	return e.HeldUntil != nil && now.Before(*e.HeldUntil)
+}
+
+// listed reports whether an entry is shown on the public board at now
+func (e ScoreEntry) listed(now time.Time) bool {
+	return !e.Hidden && !e.Pending && e.Verification != VerificationRejected && !e.held(now)
+}
+
+// settled reports whether an entry is on the board with no review or
+// anti-cheat verdict outstanding
+func (e ScoreEntry) settled(now time.Time) bool {
+	return !e.Hidden && !e.Pending && !e.held(now) &&
+		e.Verification != VerificationPending && e.Verification != VerificationRejected
 }
 
-// ScoreStore manages leaderboard entries with thread-safe operations
+// leaderboardFile is where the board is saved. QA mode points it at a
+// separate test board so simulated traffic never mixes with real scores.
+var leaderboardFile = "leaderboard.json"
+
+// ScoreStore manages leaderboard entries with thread-safe operations.
+// Readers work on the current boardState without locking; writers take
+// turns building the next state and publish it when it is complete, so
+// reads never wait for writes.
+//
+// Methods that read or change entries take the context of the request
+// they serve, so stores backed by a database can stop work for requests
+// that were cancelled or ran out of time. In memory there is little work
+// to stop, and only walks over the whole board give up early.
 type ScoreStore struct {
+	state       atomic.Pointer[boardState]
+	ids         IDGenerator
+	clock       Clock
+	persistence persistenceTracker
+	// savers write the store in the background, one per file, configured
+	// by persistConfig, until the store is closed
+	savers        map[string]*saver
+	persistConfig PersistenceConfig
+	closed        bool
+	saversMu      sync.Mutex
+	// fileMu serializes writes of the save file
+	fileMu sync.Mutex
+	// epoch tells apart the versions of states from different runs
+	epoch int64
+	// snapshot caches the sorted public board between changes, and top
+	// the start of it between submissions
+	snapshot   *boardSnapshot
+	top        *topBoard
+	snapshotMu sync.Mutex
+	// mu serializes writers
+	mu sync.Mutex
+}
+
+// boardState is one version of the store's entries and configuration. A
+// published state is never modified.
+type boardState struct {
 	entries []ScoreEntry
-	mu      sync.RWMutex
+	// byPlayer indexes entries by player name
+	byPlayer *playerIndex
+	// byID indexes entries by ID
+	byID   *idIndex
+	config BoardConfig
+	// version is bumped on every change
+	version uint64
+	// appendedSince is the earliest version the state differs from only
+	// by appended entries, so the changes since it are those entries
+	appendedSince uint64
+	// changed is closed when the next state is published
+	changed chan struct{}
+}
+
+// playerIndex indexes entries by player name, as their positions in
+// entries in increasing order. States that differ only by appended entries
+// share an index: appending adds positions past the end of what older
+// states can see, and playerPositions leaves those out, so an append costs
+// one player's positions rather than a copy of the whole index.
+type playerIndex struct {
+	// positions maps player names to []int
+	positions sync.Map
+	// skeletons maps name skeletons to []string, the player names with
+	// that skeleton in the order they were first seen
+	skeletons sync.Map
+}
+
+// add records that entries[i] is one of player's. Callers hold the
+// store's write lock.
+func (idx *playerIndex) add(player string, i int) {
+	value, _ := idx.positions.Load(player)
+	positions, _ := value.([]int)
+	if len(positions) == 0 {
+		skeleton := NameSkeleton(player)
+		names, _ := idx.skeletons.Load(skeleton)
+		known, _ := names.([]string)
+		idx.skeletons.Store(skeleton, append(known, player))
+	}
+	idx.positions.Store(player, append(positions, i))
+}
+
+// playerPositions returns the positions in entries of a player's entries
+func (st *boardState) playerPositions(player string) []int {
+	value, ok := st.byPlayer.positions.Load(player)
+	if !ok {
+		return nil
+	}
+	positions := value.([]int)
+	return positions[:sort.SearchInts(positions, len(st.entries))]
+}
+
+// lookalikes returns the names of the players with entries in the state
+// whose names look the same as playerName, as decided by NameSkeleton,
+// playerName included, in the order they were first seen
+func (st *boardState) lookalikes(playerName string) []string {
+	value, ok := st.byPlayer.skeletons.Load(NameSkeleton(playerName))
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, name := range value.([]string) {
+		if len(st.playerPositions(name)) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// idIndex indexes entries by ID, as their positions in entries. Like a
+// playerIndex it is shared by states that differ only by appended entries,
+// and position leaves out entries past the end of the state.
+type idIndex struct {
+	// positions maps IDs to int
+	positions sync.Map
+}
+
+// add records that entries[i] has id, keeping the first position of an ID.
+// Callers hold the store's write lock.
+func (idx *idIndex) add(id string, i int) {
+	idx.positions.LoadOrStore(id, i)
+}
+
+// position returns the position in entries of the entry with id. The
+// boolean result is false if the state has no such entry.
+func (st *boardState) position(id string) (int, bool) {
+	value, ok := st.byID.positions.Load(id)
+	if !ok || value.(int) >= len(st.entries) {
+		return 0, false
+	}
+	return value.(int), true
+}
+
+// boardSnapshot is the public board in board order, shared by reads until
+// the board changes or a held entry is released. Its slices are never
+// modified.
+type boardSnapshot struct {
+	version uint64
+	// visible holds every entry on the public board, best first
+	visible []ScoreEntry
+	// board is visible with only each player's best on best-per-player
+	// boards, and visible otherwise
+	board []ScoreEntry
+	// expires is when the next held entry is released, zero if none are
+	expires time.Time
+	// held is the number of entries held for review until expires
+	held int
+}
+
+// ScoreQuery filters and limits leaderboard results
+type ScoreQuery struct {
+	// Limit caps the number of results; zero or negative means no limit
+	Limit int
+	// Offset skips this many results before the limit is applied
+	Offset int
+	// After skips results up to and including the cursor position
+	After *Cursor
+	// Difficulty restricts results to runs played on one difficulty
+	Difficulty string
+	// Character restricts results to runs played with one character
+	Character string
+	// Country restricts results to runs from one country
+	Country string
+	// Players restricts results to runs by these players; nil includes
+	// everyone
+	Players map[string]bool
+	// VerifiedOnly restricts results to runs whose replay was verified
+	VerifiedOnly bool
+	// MinScore restricts results to runs scoring at least this much
+	MinScore int
+	// Sort orders results by SortScore (the default), SortTimestamp,
+	// SortName or SortDecayed
+	Sort string
+	// Order is OrderAsc or OrderDesc; empty uses the default for Sort,
+	// which is ascending for names and descending otherwise
+	Order string
+	// Since and Until restrict results to runs attributed to times in
+	// [Since, Until) under the board's timestamp policy; zero values leave
+	// that end open
+	Since, Until time.Time
+}
+
+// Sort fields and orders for ScoreQuery
+const (
+	SortScore     = "score"
+	SortTimestamp = "timestamp"
+	SortName      = "name"
+	SortDecayed   = "decayed"
+	OrderAsc      = "asc"
+	OrderDesc     = "desc"
+)
+
+// validSort reports whether the query names a supported ordering
+func (q ScoreQuery) validSort() bool {
+	switch q.Sort {
+	case "", SortScore, SortTimestamp, SortName, SortDecayed:
+	default:
+		return false
+	}
+	return q.Order == "" || q.Order == OrderAsc || q.Order == OrderDesc
+}
+
+// unfiltered reports whether the query lists the whole public board, so it
+// can be paged straight from the snapshot
+func (q ScoreQuery) unfiltered() bool {
+	return q.Difficulty == "" && q.Character == "" && q.Country == "" && q.Players == nil &&
+		!q.VerifiedOnly && q.MinScore == 0 && q.Since.IsZero() && q.Until.IsZero()
+}
+
+// wholeBoard reports whether the query pages through the whole public
+// board in board order, so pages can be cut from a sorted snapshot
+func (q ScoreQuery) wholeBoard(c BoardConfig) bool {
+	return q.unfiltered() && q.Sort != SortDecayed && q.boardOrder(c.Direction)
+}
+
+// boardOrder reports whether results are in board order, best score first
+// for a board ranked in direction, which is the only order cursors can
+// point into
+func (q ScoreQuery) boardOrder(direction string) bool {
+	if direction == "" {
+		direction = DirectionDescending
+	}
+	return (q.Sort == "" || q.Sort == SortScore) && (q.Order == "" || q.Order == direction)
+}
+
+// sortBy stably reorders entries sorted by score
+func (c BoardConfig) sortBy(entries []ScoreEntry, sortBy, order string) {
+	descending := order == OrderDesc || order == "" && sortBy != SortName
+
+	var less func(a, b ScoreEntry) bool
+	switch sortBy {
+	case SortTimestamp:
+		less = func(a, b ScoreEntry) bool {
+			return c.EffectiveTime(a).Before(c.EffectiveTime(b))
+		}
+	case SortName:
+		less = func(a, b ScoreEntry) bool {
+			return strings.ToLower(a.PlayerName) < strings.ToLower(b.PlayerName)
+		}
+	case SortDecayed:
+		less = func(a, b ScoreEntry) bool { return a.DecayedScore < b.DecayedScore }
+	default:
+		less = func(a, b ScoreEntry) bool { return a.Score < b.Score }
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if descending {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+}
+
+// inPeriod reports whether a run attributed to time at falls within the
+// query's date range
+func (q ScoreQuery) inPeriod(at time.Time) bool {
+	if !q.Since.IsZero() && at.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !at.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// matches reports whether an entry passes the query filters at now
+func (q ScoreQuery) matches(entry ScoreEntry, now time.Time) bool {
+	if !entry.listed(now) {
+		return false
+	}
+	if q.Difficulty != "" && entry.Difficulty != q.Difficulty {
+		return false
+	}
+	if q.Character != "" && entry.Character != q.Character {
+		return false
+	}
+	if q.Country != "" && entry.Country != q.Country {
+		return false
+	}
+	if q.Players != nil && !q.Players[entry.PlayerName] {
+		return false
+	}
+	if q.VerifiedOnly && !entry.ReplayVerified {
+		return false
+	}
+	if entry.Score < q.MinScore {
+		return false
+	}
+	return true
+}
+
+// StoreOption configures a ScoreStore
+type StoreOption func(*ScoreStore)
+
+// WithClock makes the store take the time from clock instead of the
+// system clock
+func WithClock(clock Clock) StoreOption {
+	return func(s *ScoreStore) {
+		s.clock = clock
+	}
+}
+
+// WithIDGenerator makes the store generate the IDs of new entries with ids
+// instead of as random UUIDs, for time-ordered or, in tests, predictable
+// IDs
+func WithIDGenerator(ids IDGenerator) StoreOption {
+	return func(s *ScoreStore) {
+		s.ids = ids
+	}
+}
+
+// WithPersistence sets how the store is saved: how background saves are
+// retried and how durable saves are
+func WithPersistence(config PersistenceConfig) StoreOption {
+	return func(s *ScoreStore) {
+		s.persistConfig = config
+	}
 }
 
 // NewScoreStore creates a new ScoreStore instance
-func NewScoreStore() *ScoreStore {
-	return &ScoreStore{
-		entries: make([]ScoreEntry, 0),
+func NewScoreStore(opts ...StoreOption) *ScoreStore {
+	s := &ScoreStore{
+		ids:   IDGeneratorFunc(newUUID),
+		clock: SystemClock,
+		epoch: time.Now().UnixNano(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	initial := (&boardState{}).withEntries(make([]ScoreEntry, 0))
+	initial.changed = make(chan struct{})
+	s.state.Store(initial)
+	return s
 }
 
-// AddScore adds a new score entry to the store
-func (s *ScoreStore) AddScore(score int, playerName string) ScoreEntry {
+// Now returns the current time by the store's clock, which callers pass
+// to the store's time-dependent queries
+func (s *ScoreStore) Now() time.Time {
+	return s.clock.Now()
+}
+
+// load returns the current state
+func (s *ScoreStore) load() *boardState {
+	return s.state.Load()
+}
+
+// publish makes next the current state, one version on from the last,
+// and wakes anyone waiting for a change. Callers hold the write lock.
+func (s *ScoreStore) publish(next *boardState) {
+	prev := s.load()
+	next.version = prev.version + 1
+	if next.appendedSince == 0 {
+		next.appendedSince = next.version
+	}
+	next.changed = make(chan struct{})
+	s.state.Store(next)
+	close(prev.changed)
+}
+
+// withEntries returns a copy of the state holding entries instead,
+// indexed afresh
+func (st *boardState) withEntries(entries []ScoreEntry) *boardState {
+	next := &boardState{entries: entries, byPlayer: &playerIndex{}, byID: &idIndex{}, config: st.config}
+	for i, entry := range entries {
+		next.byPlayer.add(entry.PlayerName, i)
+		next.byID.add(entry.ID, i)
+	}
+	return next
+}
+
+// withAppended returns a copy of the state with entry added at the end.
+// The copy shares the state's storage and indexes, which is safe
+// because appending only writes past the end of what the state can see,
+// and writers only ever append to the current state and publish the
+// result.
+func (st *boardState) withAppended(entry ScoreEntry) *boardState {
+	next := &boardState{entries: append(st.entries, entry), byPlayer: st.byPlayer, byID: st.byID, config: st.config, appendedSince: st.appendedSince}
+	next.byPlayer.add(entry.PlayerName, len(next.entries)-1)
+	next.byID.add(entry.ID, len(next.entries)-1)
+	return next
+}
+
+// SetIDGenerator replaces how IDs are generated for new entries
+func (s *ScoreStore) SetIDGenerator(ids IDGenerator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids = ids
+}
+
+// SetConfig replaces the board configuration
+func (s *ScoreStore) SetConfig(config BoardConfig) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	next := *s.load()
+	next.config = config
+	next.appendedSince = 0
+	s.publish(&next)
+}
 
-	entry := ScoreEntry{
-		ID:         uuid.New().String(),
+// Config returns the board configuration
+func (s *ScoreStore) Config() BoardConfig {
+	return s.load().config
+}
+
+// AddScore adds a new score entry to the store
+func (s *ScoreStore) AddScore(ctx context.Context, score int, playerName string) ScoreEntry {
+	return s.AddEntry(ctx, ScoreEntry{
 		Score:      score,
 		PlayerName: playerName,
-		Timestamp:  time.Now(),
+	})
+}
+
+// AddEntry adds a prepared entry to the store, assigning its ID and
+// timestamp. On best-per-player boards each player keeps only the settled
+// runs that are their best of some current period, such as their all-time
+// best and their best of the day, so every period's board still lists
+// them. Runs with different characters are kept apart, so per-character
+// boards list each player's best with that character. A run beaten by one
+// of the player's settled runs from the same day is not stored and is
+// returned marked Superseded. Runs still under review are kept alongside
+// the old bests until they settle. A run repeating one just stored, see
+// duplicateOf, is not stored again; the stored run is returned marked
+// Duplicate instead.
+func (s *ScoreStore) AddEntry(ctx context.Context, entry ScoreEntry) ScoreEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.Timestamp = s.clock.Now()
+	st := s.load()
+	if original, ok := st.duplicateOf(entry); ok {
+		original.Duplicate = true
+		return original
 	}
 
-	s.entries = append(s.entries, entry)
+	entry.ID = s.ids.NewID()
+	entry.version = st.version + 1
+	for i := range entry.Provenance {
+		if entry.Provenance[i].At.IsZero() {
+			entry.Provenance[i].At = entry.Timestamp
+		}
+	}
+
+	if !st.config.BestPerPlayer {
+		s.publishEntry(st.withAppended(entry), entry, nil)
+		return entry
+	}
+
+	at := st.config.EffectiveTime(entry)
+	dayStart, _, _ := st.config.PeriodBounds(PeriodDaily, at)
+	var runs []ScoreEntry
+	for _, i := range st.playerPositions(entry.PlayerName) {
+		existing := st.entries[i]
+		if existing.Character != entry.Character || !existing.settled(entry.Timestamp) {
+			continue
+		}
+		if !st.config.outranks(entry, existing) && !st.config.EffectiveTime(existing).Before(dayStart) {
+			entry.Superseded = true
+			return entry
+		}
+		runs = append(runs, existing)
+	}
+
+	var removed []ScoreEntry
+	if entry.settled(entry.Timestamp) {
+		bests := st.config.currentBests(append(runs, entry), at)
+		kept := make([]ScoreEntry, 0, len(st.entries)+1)
+		for _, existing := range st.entries {
+			if existing.PlayerName != entry.PlayerName || existing.Character != entry.Character || !existing.settled(entry.Timestamp) || bests[existing.ID] {
+				kept = append(kept, existing)
+			} else {
+				removed = append(removed, existing)
+			}
+		}
+		if len(removed) > 0 {
+			st = st.withEntries(kept)
+		}
+	}
+	s.publishEntry(st.withAppended(entry), entry, removed)
+	entry.replaced = removed
 	return entry
 }
 
-// GetTopScores returns the top N scores sorted by score descending
-func (s *ScoreStore) GetTopScores(limit int) []ScoreEntry {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// duplicateOf returns the stored run that entry repeats: one by the same
+// player with the same score and client run ID, stored within the board's
+// dedupe window before it. Runs without a run ID are never duplicates.
+func (st *boardState) duplicateOf(entry ScoreEntry) (ScoreEntry, bool) {
+	window := st.config.dedupeWindow()
+	if entry.RunID == "" || window <= 0 {
+		return ScoreEntry{}, false
+	}
+	for _, i := range st.playerPositions(entry.PlayerName) {
+		existing := st.entries[i]
+		if existing.RunID == entry.RunID && existing.Score == entry.Score && entry.Timestamp.Sub(existing.Timestamp) < window {
+			return existing, true
+		}
+	}
+	return ScoreEntry{}, false
+}
+
+// publishEntry publishes the state AddEntry built for entry, keeping the
+// top of the board in step with it. Callers hold the write lock.
+func (s *ScoreStore) publishEntry(next *boardState, entry ScoreEntry, removed []ScoreEntry) {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+	s.publish(next)
+	s.addToTop(next, entry, removed, entry.Timestamp)
+}
+
+// GetFlaggedEntries returns all entries flagged by moderation rules or left
+// for review by the anti-cheat service, newest first
+func (s *ScoreStore) GetFlaggedEntries(ctx context.Context) []ScoreEntry {
+	st := s.load()
+
+	flagged := make([]ScoreEntry, 0)
+	for _, entry := range st.entries {
+		if len(entry.Flags) > 0 || entry.Verification == VerificationReview {
+			flagged = append(flagged, entry)
+		}
+	}
+
+	sort.Slice(flagged, func(i, j int) bool {
+		return flagged[i].Timestamp.After(flagged[j].Timestamp)
+	})
+
+	return flagged
+}
+
+// PlayerHistory summarizes a player's previous submissions
+type PlayerHistory struct {
+	Runs      int
+	BestScore int
+	FirstSeen time.Time
+}
+
+// GetPlayerHistory returns the submission history for a player name.
+// The second return value is false if the player has never submitted.
+func (s *ScoreStore) GetPlayerHistory(ctx context.Context, playerName string) (PlayerHistory, bool) {
+	st := s.load()
+
+	var history PlayerHistory
+	for _, i := range st.playerPositions(playerName) {
+		entry := st.entries[i]
+		if history.Runs == 0 || entry.Timestamp.Before(history.FirstSeen) {
+			history.FirstSeen = entry.Timestamp
+		}
+		if history.Runs == 0 || st.config.beats(entry.Score, history.BestScore) {
+			history.BestScore = entry.Score
+		}
+		history.Runs++
+	}
+
+	return history, history.Runs > 0
+}
+
+// GetRecentScores returns the scores of the n most recently added entries
+func (s *ScoreStore) GetRecentScores(ctx context.Context, n int) []int {
+	st := s.load()
+
+	start := 0
+	if n > 0 && n < len(st.entries) {
+		start = len(st.entries) - n
+	}
+
+	scores := make([]int, 0, len(st.entries)-start)
+	for _, entry := range st.entries[start:] {
+		scores = append(scores, entry.Score)
+	}
+	return scores
+}
+
+// ScoreDistribution summarizes the scores on the public board. The
+// percentiles are nearest-rank percentiles of the scores in ascending
+// order, whichever direction the board is ranked in.
+type ScoreDistribution struct {
+	Count int
+	Max   int
+	P50   int
+	P90   int
+	P99   int
+}
+
+// GetScoreDistribution summarizes the scores of the entries on the public
+// board. It reads them from the sorted snapshot, so it costs no more than
+// a listing.
+func (s *ScoreStore) GetScoreDistribution(ctx context.Context) ScoreDistribution {
+	st, snap := s.sortedBoard(s.clock.Now())
+	visible := snap.visible
+	n := len(visible)
+	if n == 0 {
+		return ScoreDistribution{}
+	}
+
+	// The snapshot is sorted best first, which is ascending only on boards
+	// where lower scores win
+	at := func(p float64) int {
+		i := percentileRank(n, p) - 1
+		if st.config.Direction != DirectionAscending {
+			i = n - 1 - i
+		}
+		return visible[i].Score
+	}
+	return ScoreDistribution{Count: n, Max: at(100), P50: at(50), P90: at(90), P99: at(99)}
+}
+
+// percentileOf returns the nearest-rank percentile p (0-100) of an
+// ascending slice, or 0 for an empty slice
+func percentileOf(sorted []int, p float64) int {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[percentileRank(len(sorted), p)-1]
+}
+
+// percentileRank returns the 1-based nearest rank of percentile p (0-100)
+// among n values
+func percentileRank(n int, p float64) int {
+	rank := int(math.Ceil(p / 100 * float64(n)))
+	return max(1, min(rank, n))
+}
+
+// GetEntry returns the entry with the given ID, including hidden and
+// pending entries. The second return value is false if it does not exist.
+func (s *ScoreStore) GetEntry(ctx context.Context, id string) (ScoreEntry, bool) {
+	st := s.load()
+
+	i, ok := st.position(id)
+	if !ok {
+		return ScoreEntry{}, false
+	}
+	return st.entries[i], true
+}
+
+// FindLookalike returns the name of another player on the board whose name
+// looks the same as playerName, as decided by NameSkeleton. The boolean
+// result is false if playerName already has entries or no other player's
+// name looks like it.
+func (s *ScoreStore) FindLookalike(ctx context.Context, playerName string) (string, bool) {
+	st := s.load()
+	if len(st.playerPositions(playerName)) > 0 {
+		return "", false
+	}
+
+	others := st.lookalikes(playerName)
+	if len(others) == 0 {
+		return "", false
+	}
+	return others[0], true
+}
+
+// Version identifies the state of the board at now. It changes whenever an
+// entry or the configuration changes and when held entries are released,
+// so queries made at the same version return the same results.
+func (s *ScoreStore) Version(now time.Time) string {
+	_, snap := s.sortedBoard(now)
+	return s.versionOf(snap)
+}
+
+// versionOf returns the version of the board snap was taken of. Releases
+// only ever lower the number of held entries between changes, so the
+// count tells releases apart.
+func (s *ScoreStore) versionOf(snap *boardSnapshot) string {
+	return fmt.Sprintf("%x.%d.%d", s.epoch, snap.version, snap.held)
+}
+
+// GetAllEntries returns a copy of every entry, including hidden and pending
+// ones, in insertion order
+func (s *ScoreStore) GetAllEntries(ctx context.Context) []ScoreEntry {
+	st := s.load()
+
+	entries := make([]ScoreEntry, len(st.entries))
+	copy(entries, st.entries)
+	return entries
+}
+
+// restoreEntry appends an entry exactly as given, keeping its ID and
+// timestamp. It is used when rebuilding a store from existing data.
+func (s *ScoreStore) restoreEntry(entry ScoreEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.load()
+	entry.version = st.version + 1
+	s.publish(st.withAppended(entry))
+}
+
+// ApplyBackfill copies the fields a backfill recomputes (Score, RawScore,
+// Difficulty and Flags) from updated onto the entries with the same IDs,
+// and returns the number updated. It works on the entries as they are when
+// it takes the write lock, so changes made while the backfill ran, such as
+// entries hidden or approved by moderators, are kept.
+func (s *ScoreStore) ApplyBackfill(ctx context.Context, updated map[string]ScoreEntry) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.load()
+	entries := slices.Clone(st.entries)
+	applied := 0
+	for i := range entries {
+		if entry, ok := updated[entries[i].ID]; ok {
+			entries[i].Score = entry.Score
+			entries[i].RawScore = entry.RawScore
+			entries[i].Difficulty = entry.Difficulty
+			entries[i].Flags = entry.Flags
+			applied++
+		}
+	}
+	if applied > 0 {
+		s.publish(st.withEntries(entries))
+	}
+	return applied
+}
+
+// updateEntry applies fn to the entry with the given ID under the write
+// lock. It returns ErrEntryNotFound if no entry has the given ID.
+func (s *ScoreStore) updateEntry(id string, fn func(*ScoreEntry)) (ScoreEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.load()
+	for i := range st.entries {
+		if st.entries[i].ID == id {
+			entries := slices.Clone(st.entries)
+			fn(&entries[i])
+			s.publish(st.withEntries(entries))
+			return entries[i], nil
+		}
+	}
+	return ScoreEntry{}, ErrEntryNotFound
+}
+
+// DeleteEntry permanently removes an entry by ID and returns it
+func (s *ScoreStore) DeleteEntry(ctx context.Context, id string) (ScoreEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.load()
+	for i, entry := range st.entries {
+		if entry.ID == id {
+			s.publish(st.withEntries(slices.Delete(slices.Clone(st.entries), i, i+1)))
+			return entry, nil
+		}
+	}
+	return ScoreEntry{}, ErrEntryNotFound
+}
+
+// WithdrawEntry removes an entry AddEntry returned and puts back the runs
+// it replaced on a best-per-player board, for a submission that was never
+// accepted because it couldn't be saved
+func (s *ScoreStore) WithdrawEntry(ctx context.Context, entry ScoreEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.load()
+	i := slices.IndexFunc(st.entries, func(existing ScoreEntry) bool { return existing.ID == entry.ID })
+	if i < 0 {
+		return ErrEntryNotFound
+	}
+	entries := slices.Delete(slices.Clone(st.entries), i, i+1)
+	s.publish(st.withEntries(append(entries, entry.replaced...)))
+	return nil
+}
+
+// removeEntries removes the entries whose IDs pick returns for the current
+// state, and returns them in the order pick gave them
+func (s *ScoreStore) removeEntries(pick func(st *boardState) []string) []ScoreEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.load()
+	ids := pick(st)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	picked := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		picked[id] = true
+	}
+	kept := make([]ScoreEntry, 0, len(st.entries))
+	byID := make(map[string]ScoreEntry, len(ids))
+	for _, entry := range st.entries {
+		if picked[entry.ID] {
+			byID[entry.ID] = entry
+		} else {
+			kept = append(kept, entry)
+		}
+	}
+	if len(byID) == 0 {
+		return nil
+	}
+
+	removed := make([]ScoreEntry, 0, len(byID))
+	for _, id := range ids {
+		if entry, ok := byID[id]; ok {
+			removed = append(removed, entry)
+			delete(byID, id)
+		}
+	}
+
+	s.publish(st.withEntries(kept))
+	return removed
+}
+
+// TakeEntries removes and returns every entry, leaving the board empty
+func (s *ScoreStore) TakeEntries(ctx context.Context) []ScoreEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.load()
+	s.publish(st.withEntries(make([]ScoreEntry, 0)))
+	return slices.Clone(st.entries)
+}
+
+// AppendProvenance records a later step in an entry's history
+func (s *ScoreStore) AppendProvenance(ctx context.Context, id string, step ProvenanceStep) (ScoreEntry, error) {
+	if step.At.IsZero() {
+		step.At = s.clock.Now()
+	}
+	return s.updateEntry(id, func(e *ScoreEntry) {
+		if len(e.Provenance) == 0 {
+			e.Provenance = []ProvenanceStep{e.Origin()}
+		}
+		e.Provenance = append(e.Provenance, step)
+	})
+}
+
+// SetHidden hides or reveals an entry by ID. Hidden entries are kept in the
+// store but excluded from leaderboard results.
+func (s *ScoreStore) SetHidden(ctx context.Context, id string, hidden bool) (ScoreEntry, error) {
+	return s.updateEntry(id, func(e *ScoreEntry) {
+		e.Hidden = hidden
+	})
+}
+
+// SetVerification updates the anti-cheat verification state of an entry
+func (s *ScoreStore) SetVerification(ctx context.Context, id, state string) (ScoreEntry, error) {
+	return s.updateEntry(id, func(e *ScoreEntry) {
+		e.Verification = state
+	})
+}
+
+// SetHasReplay records whether an input replay is stored for an entry
+func (s *ScoreStore) SetHasReplay(ctx context.Context, id string, hasReplay bool) (ScoreEntry, error) {
+	return s.updateEntry(id, func(e *ScoreEntry) {
+		e.HasReplay = hasReplay
+	})
+}
+
+// SetReplayVerified records whether an entry's replay reproduced its score
+func (s *ScoreStore) SetReplayVerified(ctx context.Context, id string, verified bool) (ScoreEntry, error) {
+	return s.updateEntry(id, func(e *ScoreEntry) {
+		e.ReplayVerified = verified
+	})
+}
+
+// SetHasGhost records whether ghost data is stored for an entry
+func (s *ScoreStore) SetHasGhost(ctx context.Context, id string, hasGhost bool) (ScoreEntry, error) {
+	return s.updateEntry(id, func(e *ScoreEntry) {
+		e.HasGhost = hasGhost
+	})
+}
+
+// ApproveEntry releases a pending or held entry onto the leaderboard
+func (s *ScoreStore) ApproveEntry(ctx context.Context, id string) (ScoreEntry, error) {
+	return s.updateEntry(id, func(e *ScoreEntry) {
+		e.Pending = false
+		e.HeldUntil = nil
+	})
+}
+
+// RejectEntry removes a pending or held entry from the queue and hides it
+func (s *ScoreStore) RejectEntry(ctx context.Context, id string) (ScoreEntry, error) {
+	return s.updateEntry(id, func(e *ScoreEntry) {
+		e.Pending = false
+		e.HeldUntil = nil
+		e.Hidden = true
+	})
+}
+
+// GetHiddenEntries returns every hidden entry, most recently changed first
+func (s *ScoreStore) GetHiddenEntries(ctx context.Context) []ScoreEntry {
+	st := s.load()
+
+	hidden := make([]ScoreEntry, 0)
+	for _, entry := range st.entries {
+		if entry.Hidden {
+			hidden = append(hidden, entry)
+		}
+	}
+
+	sort.SliceStable(hidden, func(i, j int) bool {
+		return hidden[i].lastChanged().After(hidden[j].lastChanged())
+	})
+
+	return hidden
+}
+
+// GetPendingEntries returns entries awaiting moderator approval, including
+// records still held for review, oldest first
+func (s *ScoreStore) GetPendingEntries(ctx context.Context) []ScoreEntry {
+	st := s.load()
+
+	now := s.clock.Now()
+	pending := make([]ScoreEntry, 0)
+	for _, entry := range st.entries {
+		if entry.Pending || (entry.held(now) && !entry.Hidden) {
+			pending = append(pending, entry)
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Timestamp.Before(pending[j].Timestamp)
+	})
+
+	return pending
+}
+
+// GetTopScores returns the top N visible scores sorted by score descending
+func (s *ScoreStore) GetTopScores(ctx context.Context, limit int) []ScoreEntry {
+	return s.QueryScores(ctx, ScoreQuery{Limit: limit})
+}
+
+// QueryScores returns visible entries matching the query sorted by score
+// descending
+func (s *ScoreStore) QueryScores(ctx context.Context, query ScoreQuery) []ScoreEntry {
+	entries, _ := s.QueryPage(ctx, query)
+	return entries
+}
+
+// sortedBoard returns the current state and the snapshot of its public
+// board, sorting it again only if the board changed or a held entry was
+// released since the last call
+func (s *ScoreStore) sortedBoard(now time.Time) (*boardState, *boardSnapshot) {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+
+	st := s.load()
+	if snap := s.snapshot; snap != nil && snap.version == st.version && (snap.expires.IsZero() || now.Before(snap.expires)) {
+		return st, snap
+	}
+
+	snap := &boardSnapshot{version: st.version, visible: make([]ScoreEntry, 0, len(st.entries))}
+	for _, entry := range st.entries {
+		if entry.held(now) {
+			snap.held++
+			if snap.expires.IsZero() || entry.HeldUntil.Before(snap.expires) {
+				snap.expires = *entry.HeldUntil
+			}
+		}
+		if entry.listed(now) {
+			snap.visible = append(snap.visible, entry)
+		}
+	}
+
+	// Sort best score first, breaking ties by the earlier submission and
+	// then by ID so the order is total and cursors can point into it
+	sort.Slice(snap.visible, func(i, j int) bool {
+		return st.config.before(snap.visible[i], snap.visible[j])
+	})
+
+	snap.board = snap.visible
+	if st.config.BestPerPlayer {
+		snap.board = bestPerPlayer(snap.visible)
+	}
+	s.snapshot = snap
+	s.top = newTopBoard(snap, st.config.topCacheSize(), st.config.BestPerPlayer)
+	return st, snap
+}
+
+// bestPerPlayer returns the first entry of each player from entries in
+// board order, without modifying entries
+func bestPerPlayer(entries []ScoreEntry) []ScoreEntry {
+	seen := make(map[string]bool)
+	best := make([]ScoreEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !seen[entry.PlayerName] {
+			seen[entry.PlayerName] = true
+			best = append(best, entry)
+		}
+	}
+	return best
+}
+
+// QueryPage is QueryScores that also returns the number of entries matching
+// the query before the offset and limit were applied
+func (s *ScoreStore) QueryPage(ctx context.Context, query ScoreQuery) ([]ScoreEntry, int) {
+	now := s.clock.Now()
+	if page, total, ok := s.topPage(query, now); ok {
+		return page, total
+	}
+
+	// Pages of the whole board are cut straight from the snapshot
+	st, snap := s.sortedBoard(now)
+	config := st.config
+	if query.wholeBoard(config) {
+		page := config.page(snap.board, query)
+		return append([]ScoreEntry(nil), page...), len(snap.board)
+	}
+
+	// Filter a copy of the snapshot, which is already in board order
+	entriesCopy := make([]ScoreEntry, 0, len(snap.visible))
+	for _, entry := range snap.visible {
+		if query.matches(entry, now) && query.inPeriod(config.EffectiveTime(entry)) {
+			entriesCopy = append(entriesCopy, entry)
+		}
+	}
+
+	// Weigh scores by age before picking each player's best, since a
+	// recent run may outrank an older higher score once decayed
+	if query.Sort == SortDecayed {
+		for i := range entriesCopy {
+			entriesCopy[i].DecayedScore = config.DecayedScore(entriesCopy[i], now)
+		}
+		config.sortBy(entriesCopy, SortDecayed, OrderDesc)
+	}
+
+	// Runs approved after a player's later best may leave several
+	// entries; only the highest counts on best-per-player boards
+	if config.BestPerPlayer {
+		seen := make(map[string]bool)
+		best := entriesCopy[:0]
+		for _, entry := range entriesCopy {
+			if !seen[entry.PlayerName] {
+				seen[entry.PlayerName] = true
+				best = append(best, entry)
+			}
+		}
+		entriesCopy = best
+	}
+
+	// Order by another field if asked; ties keep their score order
+	if !query.boardOrder(config.Direction) {
+		config.sortBy(entriesCopy, query.Sort, query.Order)
+	}
+
+	return config.page(entriesCopy, query), len(entriesCopy)
+}
+
+// page applies the query's cursor, offset and limit to sorted results
+func (c BoardConfig) page(entries []ScoreEntry, query ScoreQuery) []ScoreEntry {
+	if query.After != nil {
+		entries = entries[sort.Search(len(entries), func(i int) bool {
+			return c.before(query.After.entry(), entries[i])
+		}):]
+	}
+	if query.Offset > 0 {
+		entries = entries[min(query.Offset, len(entries)):]
+	}
+	if query.Limit > 0 && query.Limit < len(entries) {
+		entries = entries[:query.Limit]
+	}
+	return entries
+}
+
+// WalkBoard calls fn with each entry of the public board, best first, and
+// its rank, stopping at the first error fn returns or once ctx is done,
+// such as when the client downloading an export goes away. It walks a
+// snapshot of the board without copying it, so exports of large boards
+// don't hold a second copy in memory.
+func (s *ScoreStore) WalkBoard(ctx context.Context, fn func(rank int, entry ScoreEntry) error) error {
+	st, snap := s.sortedBoard(s.clock.Now())
+	rank := 0
+	for i, entry := range snap.board {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if i == 0 || st.config.outranks(snap.board[i-1], entry) {
+			rank = i + 1
+		}
+		if err := fn(rank, entry.public()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Standing is an entry's position on the public board
+type Standing struct {
+	// Rank is 1 for first place; tied entries share a rank
+	Rank int `json:"rank"`
+	// PointsToNextRank is how many more points the entry needed to
+	// overtake the one ranked above it; zero for first place
+	PointsToNextRank int `json:"pointsToNextRank,omitempty"`
+}
+
+// GetStanding returns the current standing of an entry. The second return
+// value is false if the entry is not on the public board.
+func (s *ScoreStore) GetStanding(ctx context.Context, id string) (Standing, bool) {
+	now := s.clock.Now()
+	st, snap := s.sortedBoard(now)
+	i, ok := st.position(id)
+	if !ok || !st.entries[i].listed(now) {
+		return Standing{}, false
+	}
+
+	// The board is in board order, so the entry is found by its place
+	entry := st.entries[i]
+	index := sort.Search(len(snap.board), func(i int) bool {
+		return !st.config.before(snap.board[i], entry)
+	})
+	if index == len(snap.board) || snap.board[index].ID != id {
+		return Standing{}, false
+	}
+	return st.config.standingAt(snap.board, index), true
+}
+
+// standingAt returns the standing of board[index]. The board is sorted, so
+// entries above the first one it ties with outrank it.
+func (c BoardConfig) standingAt(board []ScoreEntry, index int) Standing {
+	score := board[index].Score
+	rank := index
+	for rank > 0 && !c.outranks(board[rank-1], board[index]) {
+		rank--
+	}
+
+	standing := Standing{Rank: rank + 1}
+	if rank > 0 {
+		// The gap is a time to cut on boards where lower is better
+		gap := board[rank-1].Score - score
+		standing.PointsToNextRank = max(gap, -gap) + 1
+	}
+	return standing
+}
+
+// Percentile describes how a score compares with the public board
+type Percentile struct {
+	Score int `json:"score"`
+	// Percentile is the percentage of board entries the score beats, zero
+	// on an empty board
+	Percentile float64 `json:"percentile"`
+	// Total is the number of entries on the board
+	Total int `json:"total"`
+}
+
+// GetPercentile returns the share of the public board a score beats
+func (s *ScoreStore) GetPercentile(ctx context.Context, score int) Percentile {
+	board := s.QueryScores(ctx, ScoreQuery{})
 
-	// Create a copy to avoid modifying the original slice
-	entriesCopy := make([]ScoreEntry, len(s.entries))
-	copy(entriesCopy, s.entries)
+	result := Percentile{Score: score, Total: len(board)}
+	if len(board) == 0 {
+		return result
+	}
 
-	// Sort by score descending
-	sort.Slice(entriesCopy, func(i, j int) bool {
-		return entriesCopy[i].Score > entriesCopy[j].Score
+	// The board is sorted best first, so the scores beaten form its tail
+	config := s.Config()
+	below := len(board) - sort.Search(len(board), func(i int) bool {
+		return config.beats(score, board[i].Score)
 	})
+	result.Percentile = math.Round(float64(below)/float64(len(board))*1000) / 10
+	return result
+}
+
+// GetAround returns the visible entries up to window places above and below
+// the player's best entry, with their standings, best first. The second
+// return value is false if the player has no entry on the board.
+func (s *ScoreStore) GetAround(ctx context.Context, playerName string, window int) ([]RankedEntry, bool) {
+	now := s.clock.Now()
+	st, snap := s.sortedBoard(now)
+	board := snap.board
+	index := st.boardIndex(board, playerName, now)
+	if index < 0 {
+		return nil, false
+	}
 
-	// Limit the results
-	if limit > 0 && limit < len(entriesCopy) {
-		entriesCopy = entriesCopy[:limit]
+	start, end := index-window, index+window+1
+	if start < 0 {
+		start = 0
+	}
+	if end > len(board) {
+		end = len(board)
 	}
 
-	return entriesCopy
+	around := make([]RankedEntry, 0, end-start)
+	for i := start; i < end; i++ {
+		standing := st.config.standingAt(board, i)
+		around = append(around, RankedEntry{ScoreEntry: board[i].public(), Standing: &standing})
+	}
+	return around, true
 }
 
-// SaveToFile persists the leaderboard to a JSON file
+// SaveToFile persists the leaderboard to a JSON file. The outcome is
+// recorded in the store's persistence status.
 func (s *ScoreStore) SaveToFile(filename string) error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	err := s.writeFile(filename)
+	s.persistence.record(err)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+	}
+	return nil
+}
+
+// writeFile writes the entries to a JSON file. Writes take turns, and
+// each writes the entries as they are when its turn comes, so the file
+// is never interleaved or older than the last write. The entries are
+// written to a temporary file that is renamed over filename, so a crash
+// mid-write leaves the previous board rather than a truncated one; with
+// fsync durability the directory is flushed too, so the rename itself
+// survives a power failure.
+func (s *ScoreStore) writeFile(filename string) error {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+
+	data, err := json.MarshalIndent(s.load().entries, "", "  ")
+	if err != nil {
+		return err
+	}
 
-	data, err := json.MarshalIndent(s.entries, "", "  ")
+	dir := filepath.Dir(filename)
+	tmp, err := writeTempFile(dir, "."+filepath.Base(filename)+"-*.tmp", data)
 	if err != nil {
 		return err
 	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return err
+	}
 
-	return os.WriteFile(filename, data, 0644)
+	if s.persistConfig.Durability == DurabilityFsync {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+// Persist saves the leaderboard to filename as the durability setting
+// requires: in the background for async, and before returning for sync
+// and fsync, so callers can refuse changes that could not be saved
+func (s *ScoreStore) Persist(filename string) error {
+	switch s.persistConfig.Durability {
+	case DurabilitySync, DurabilityFsync:
+		return s.SaveToFile(filename)
+	}
+	s.SaveInBackground(filename)
+	return nil
+}
+
+// SaveInBackground saves the leaderboard to filename without waiting for
+// it. Failed saves are retried and recorded in the persistence status
+// rather than returned. Once the store is closed it saves before
+// returning instead.
+func (s *ScoreStore) SaveInBackground(filename string) {
+	s.saversMu.Lock()
+	if s.closed {
+		s.saversMu.Unlock()
+		if err := s.SaveToFile(filename); err != nil {
+			log.Printf("Error: Could not save %s: %v", filename, err)
+		}
+		return
+	}
+	sv, ok := s.savers[filename]
+	if !ok {
+		if s.savers == nil {
+			s.savers = make(map[string]*saver)
+		}
+		sv = newSaver(s, filename, s.persistConfig)
+		s.savers[filename] = sv
+	}
+	// Requested under the lock, so Close can't stop the saver in between
+	sv.request()
+	s.saversMu.Unlock()
+}
+
+// Close stops the background savers, waiting for the saves already
+// requested to be written
+func (s *ScoreStore) Close() {
+	s.saversMu.Lock()
+	savers := s.savers
+	s.savers = nil
+	s.closed = true
+	s.saversMu.Unlock()
+
+	for _, sv := range savers {
+		sv.stop()
+	}
+}
+
+// PersistenceStatus reports the outcome of recent saves
+func (s *ScoreStore) PersistenceStatus() PersistenceStatus {
+	return s.persistence.get()
 }
 
 // LoadFromFile loads the leaderboard from a JSON file
@@ -91,11 +1393,39 @@ func (s *ScoreStore) LoadFromFile(filename string) error {
 	if err != nil {
 		if os.IsNotExist(err) {
 			// File doesn't exist yet, start with empty entries
-			s.entries = make([]ScoreEntry, 0)
+			s.publish(s.load().withEntries(make([]ScoreEntry, 0)))
 			return nil
 		}
 		return err
 	}
 
-	return json.Unmarshal(data, &s.entries)
+	var entries []ScoreEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrValidation, filename, err)
+	}
+
+	entries, dropped := dedupeEntries(entries)
+	if len(dropped) > 0 {
+		log.Printf("Warning: %s has duplicate entry IDs; kept the first of each and dropped %d: %v", filename, len(dropped), dropped)
+	}
+	s.publish(s.load().withEntries(entries))
+	return nil
+}
+
+// dedupeEntries keeps the first entry with each ID, in order, and returns
+// the IDs of the entries it dropped
+func dedupeEntries(entries []ScoreEntry) ([]ScoreEntry, []string) {
+	seen := make(map[string]bool, len(entries))
+	kept := make([]ScoreEntry, 0, len(entries))
+	var dropped []string
+
+	for _, entry := range entries {
+		if seen[entry.ID] {
+			dropped = append(dropped, entry.ID)
+			continue
+		}
+		seen[entry.ID] = true
+		kept = append(kept, entry)
+	}
+	return kept, dropped
 }
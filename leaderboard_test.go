@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
 	"testing/quick"
+	"time"
 )
 
 // **Feature: game-enhancements, Property 6: Leaderboard ordering**
@@ -29,11 +35,11 @@ func TestLeaderboardOrdering(t *testing.T) {
 				score = -score
 			}
 			playerName := "Player" + string(rune('A'+i%26))
-			store.AddScore(score, playerName)
+			store.AddScore(context.Background(), score, playerName)
 		}
 
 		// Get top scores (no limit to check full ordering)
-		topScores := store.GetTopScores(0)
+		topScores := store.GetTopScores(context.Background(), 0)
 
 		// Verify descending order
 		for i := 1; i < len(topScores); i++ {
@@ -91,11 +97,11 @@ func TestLeaderboardLimitEnforcement(t *testing.T) {
 				score = -score
 			}
 			playerName := "Player" + string(rune('A'+i%26))
-			store.AddScore(score, playerName)
+			store.AddScore(context.Background(), score, playerName)
 		}
 
 		// Get top scores with limit
-		topScores := store.GetTopScores(limitInt)
+		topScores := store.GetTopScores(context.Background(), limitInt)
 
 		// Verify the returned count doesn't exceed the limit
 		if len(topScores) > limitInt {
@@ -115,3 +121,509 @@ func TestLeaderboardLimitEnforcement(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// Test equal scores are listed earliest submission first, then by ID, on
+// every request
+func TestLeaderboardTieBreaking(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := NewScoreStore()
+	store.restoreEntry(ScoreEntry{ID: "a", Score: 500, PlayerName: "Late", Timestamp: start.Add(time.Hour)})
+	store.restoreEntry(ScoreEntry{ID: "d", Score: 500, PlayerName: "SameTimeD", Timestamp: start})
+	store.restoreEntry(ScoreEntry{ID: "b", Score: 900, PlayerName: "Leader", Timestamp: start.Add(2 * time.Hour)})
+	store.restoreEntry(ScoreEntry{ID: "c", Score: 500, PlayerName: "SameTimeC", Timestamp: start})
+
+	want := []string{"b", "c", "d", "a"}
+	for attempt := 0; attempt < 10; attempt++ {
+		board := store.GetTopScores(context.Background(), 0)
+		if len(board) != len(want) {
+			t.Fatalf("Expected %d entries, got %d", len(want), len(board))
+		}
+		for i, entry := range board {
+			if entry.ID != want[i] {
+				t.Fatalf("Expected %s at %d, got %s", want[i], i, entry.ID)
+			}
+		}
+	}
+}
+
+// Test duplicate IDs in a saved leaderboard are dropped on load, keeping
+// the first entry with each ID
+func TestLoadFromFileDuplicateIDs(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "leaderboard.json")
+	data := `[
+		{"id": "a", "score": 100, "playerName": "First"},
+		{"id": "b", "score": 200, "playerName": "Other"},
+		{"id": "a", "score": 900, "playerName": "Copy"},
+		{"id": "a", "score": 100, "playerName": "First"}
+	]`
+	if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	store := NewScoreStore()
+	if err := store.LoadFromFile(filename); err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+
+	entries := store.GetAllEntries(context.Background())
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+	if entry, _ := store.GetEntry(context.Background(), "a"); entry.PlayerName != "First" {
+		t.Errorf("Expected first entry with ID a to be kept, got %s", entry.PlayerName)
+	}
+}
+
+// Test best-per-player boards keep only each player's highest run
+func TestBestPerPlayer(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{BestPerPlayer: true})
+
+	store.AddScore(context.Background(), 500, "Kiro")
+	store.AddScore(context.Background(), 300, "Mario")
+	if entry := store.AddScore(context.Background(), 400, "Kiro"); !entry.Superseded {
+		t.Error("Expected a lower run to be superseded")
+	}
+	if entry := store.AddScore(context.Background(), 500, "Kiro"); !entry.Superseded {
+		t.Error("Expected a tying run to be superseded")
+	}
+	best := store.AddScore(context.Background(), 900, "Kiro")
+	if best.Superseded {
+		t.Error("Expected a new best to be kept")
+	}
+
+	if n := len(store.GetAllEntries(context.Background())); n != 2 {
+		t.Errorf("Expected 2 stored entries, got %d", n)
+	}
+
+	// A higher run awaiting review doesn't replace the settled best yet
+	store.AddEntry(context.Background(), ScoreEntry{Score: 5000, PlayerName: "Mario", Pending: true})
+	if n := len(store.GetAllEntries(context.Background())); n != 3 {
+		t.Errorf("Expected pending run to be kept alongside the best, got %d entries", n)
+	}
+
+	// Once approved, only the higher run is listed
+	pending := store.GetPendingEntries(context.Background())[0]
+	store.ApproveEntry(context.Background(), pending.ID)
+	top := store.GetTopScores(context.Background(), 0)
+	if len(top) != 2 || top[0].PlayerName != "Mario" || top[0].Score != 5000 || top[1].ID != best.ID {
+		t.Errorf("Expected one run per player with Mario's approved best first, got %+v", top)
+	}
+}
+
+// Test best-per-player boards also keep each player's best run of every
+// current period for the daily, weekly and monthly boards
+func TestBestPerPlayerKeepsPeriodBests(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{BestPerPlayer: true, PeriodWindows: PeriodWindowsRolling})
+	now := time.Now()
+	store.restoreEntry(ScoreEntry{ID: "old", Score: 900, PlayerName: "Kiro", Timestamp: now.AddDate(0, 0, -60)})
+	store.restoreEntry(ScoreEntry{ID: "week", Score: 600, PlayerName: "Kiro", Timestamp: now.AddDate(0, 0, -2)})
+
+	today := store.AddScore(context.Background(), 400, "Kiro")
+	if today.Superseded {
+		t.Fatal("Expected the day's first run to be kept")
+	}
+	if entry := store.AddScore(context.Background(), 300, "Kiro"); !entry.Superseded {
+		t.Error("Expected a run below the day's best to be superseded")
+	}
+
+	config := store.Config()
+	for _, tt := range []struct {
+		period string
+		wantID string
+	}{
+		{PeriodAllTime, "old"},
+		{PeriodWeekly, "week"},
+		{PeriodDaily, today.ID},
+	} {
+		start, _, _ := config.PeriodBounds(tt.period, time.Now())
+		board := store.QueryScores(context.Background(), ScoreQuery{Since: start})
+		if len(board) != 1 || board[0].ID != tt.wantID {
+			t.Errorf("Expected %s on the %s board, got %+v", tt.wantID, tt.period, board)
+		}
+	}
+
+	// A run beating the week's best replaces it and the day's best
+	best := store.AddScore(context.Background(), 700, "Kiro")
+	entries := store.GetAllEntries(context.Background())
+	if len(entries) != 2 || entries[0].ID != "old" || entries[1].ID != best.ID {
+		t.Errorf("Expected only the all-time best and the new run to be stored, got %+v", entries)
+	}
+}
+
+// Test the store takes the time from its clock, so runs land on chosen
+// days and record holds lift without waiting
+func TestScoreStoreClock(t *testing.T) {
+	now := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	store := NewScoreStore(WithClock(ClockFunc(func() time.Time { return now })))
+	store.SetConfig(BoardConfig{BestPerPlayer: true})
+
+	if first := store.AddScore(context.Background(), 500, "Kiro"); !first.Timestamp.Equal(now) {
+		t.Errorf("Expected the run timestamped %v, got %v", now, first.Timestamp)
+	}
+	if entry := store.AddScore(context.Background(), 300, "Kiro"); !entry.Superseded {
+		t.Error("Expected a lower run on the same day to be superseded")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if entry := store.AddScore(context.Background(), 300, "Kiro"); entry.Superseded {
+		t.Error("Expected the next day's first run to be kept")
+	}
+
+	until := now.Add(time.Minute)
+	held := store.AddEntry(context.Background(), ScoreEntry{Score: 900, PlayerName: "Ace", HeldUntil: &until})
+	if board := store.QueryScores(context.Background(), ScoreQuery{}); len(board) != 1 || board[0].PlayerName != "Kiro" {
+		t.Errorf("Expected the held run off the board, got %+v", board)
+	}
+	now = now.Add(2 * time.Minute)
+	if board := store.QueryScores(context.Background(), ScoreQuery{}); len(board) != 2 || board[0].ID != held.ID {
+		t.Errorf("Expected the released run on top, got %+v", board)
+	}
+}
+
+// Test boards where lower is better rank the fastest times first
+func TestSpeedrunBoard(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{Direction: DirectionAscending, BestPerPlayer: true})
+	store.AddScore(context.Background(), 65000, "Steady")
+	fastest := store.AddScore(context.Background(), 60000, "Rapid")
+	store.AddScore(context.Background(), 70000, "Casual")
+	if entry := store.AddScore(context.Background(), 61000, "Rapid"); !entry.Superseded {
+		t.Error("Expected a slower run to be superseded")
+	}
+
+	board := store.GetTopScores(context.Background(), 0)
+	want := []int{60000, 65000, 70000}
+	if len(board) != len(want) {
+		t.Fatalf("Expected %d entries, got %d", len(want), len(board))
+	}
+	for i, entry := range board {
+		if entry.Score != want[i] {
+			t.Errorf("Expected %d at %d, got %d", want[i], i, entry.Score)
+		}
+	}
+
+	standing, _ := store.GetStanding(context.Background(), board[1].ID)
+	if standing.Rank != 2 || standing.PointsToNextRank != 5001 {
+		t.Errorf("Expected rank 2 needing 5001ms off, got %+v", standing)
+	}
+	if percentile := store.GetPercentile(context.Background(), 62000); percentile.Percentile != 66.7 {
+		t.Errorf("Expected 62000ms to beat 66.7%% of the board, got %v", percentile.Percentile)
+	}
+
+	// A faster run replaces the player's best
+	store.AddScore(context.Background(), 55000, "Rapid")
+	if _, ok := store.GetEntry(context.Background(), fastest.ID); ok {
+		t.Error("Expected the faster run to replace the old best")
+	}
+}
+
+// Test boards breaking ties by time rank equal scores fastest first, with
+// untimed runs after timed ones
+func TestCompositeRanking(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{TieBreak: TieBreakTime, BestPerPlayer: true})
+	store.AddEntry(context.Background(), ScoreEntry{Score: 500, PlayerName: "Untimed"})
+	store.AddEntry(context.Background(), ScoreEntry{Score: 500, PlayerName: "Slow", DurationMs: 90000})
+	store.AddEntry(context.Background(), ScoreEntry{Score: 400, PlayerName: "Quickest", DurationMs: 30000})
+	store.AddEntry(context.Background(), ScoreEntry{Score: 500, PlayerName: "Fast", DurationMs: 60000})
+	store.AddEntry(context.Background(), ScoreEntry{Score: 500, PlayerName: "AlsoFast", DurationMs: 60000})
+
+	board := store.GetTopScores(context.Background(), 0)
+	want := []string{"Fast", "AlsoFast", "Slow", "Untimed", "Quickest"}
+	if len(board) != len(want) {
+		t.Fatalf("Expected %d entries, got %d", len(want), len(board))
+	}
+	for i, entry := range board {
+		if entry.PlayerName != want[i] {
+			t.Errorf("Expected %s at %d, got %s", want[i], i, entry.PlayerName)
+		}
+	}
+
+	wantRanks := []int{1, 1, 3, 4, 5}
+	for i, entry := range board {
+		if standing, _ := store.GetStanding(context.Background(), entry.ID); standing.Rank != wantRanks[i] {
+			t.Errorf("Expected %s at rank %d, got %d", entry.PlayerName, wantRanks[i], standing.Rank)
+		}
+	}
+
+	// Matching a best score in a faster time replaces it
+	slow := board[2]
+	if entry := store.AddEntry(context.Background(), ScoreEntry{Score: 500, PlayerName: "Slow", DurationMs: 95000}); !entry.Superseded {
+		t.Error("Expected a slower run with the same score to be superseded")
+	}
+	store.AddEntry(context.Background(), ScoreEntry{Score: 500, PlayerName: "Slow", DurationMs: 45000})
+	if _, ok := store.GetEntry(context.Background(), slow.ID); ok {
+		t.Error("Expected the faster run to replace the old best")
+	}
+	if top := store.GetTopScores(context.Background(), 1); top[0].PlayerName != "Slow" {
+		t.Errorf("Expected the faster run to lead, got %s", top[0].PlayerName)
+	}
+}
+
+// Test standings rank tied scores together and measure the gap upward
+func TestGetStanding(t *testing.T) {
+	store := NewScoreStore()
+	first := store.AddScore(context.Background(), 1000, "First")
+	tiedA := store.AddScore(context.Background(), 800, "TiedA")
+	tiedB := store.AddScore(context.Background(), 800, "TiedB")
+	last := store.AddScore(context.Background(), 500, "Last")
+	hidden := store.AddEntry(context.Background(), ScoreEntry{Score: 9000, PlayerName: "Hidden", Hidden: true})
+
+	tests := []struct {
+		name string
+		id   string
+		want Standing
+	}{
+		{"first", first.ID, Standing{Rank: 1}},
+		{"tied", tiedA.ID, Standing{Rank: 2, PointsToNextRank: 201}},
+		{"tied other", tiedB.ID, Standing{Rank: 2, PointsToNextRank: 201}},
+		{"last", last.ID, Standing{Rank: 4, PointsToNextRank: 301}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := store.GetStanding(context.Background(), tt.id)
+			if !ok || got != tt.want {
+				t.Errorf("Expected %+v, got %+v (ok=%v)", tt.want, got, ok)
+			}
+		})
+	}
+
+	if _, ok := store.GetStanding(context.Background(), hidden.ID); ok {
+		t.Error("Expected hidden entries to have no standing")
+	}
+
+	// Only each player's best is on a best-per-player board
+	worse := store.AddScore(context.Background(), 600, "First")
+	store.SetConfig(BoardConfig{BestPerPlayer: true})
+	if _, ok := store.GetStanding(context.Background(), worse.ID); ok {
+		t.Error("Expected a player's worse run to have no standing")
+	}
+	if got, ok := store.GetStanding(context.Background(), last.ID); !ok || got.Rank != 4 {
+		t.Errorf("Expected Last to stay 4th, got %+v (ok=%v)", got, ok)
+	}
+
+	// Erased entries are no longer found by ID
+	store.DeletePlayer(context.Background(), "Last")
+	if _, ok := store.GetEntry(context.Background(), last.ID); ok {
+		t.Error("Expected the erased entry to be gone")
+	}
+	if _, ok := store.GetStanding(context.Background(), last.ID); ok {
+		t.Error("Expected the erased entry to have no standing")
+	}
+	if entry, ok := store.GetEntry(context.Background(), worse.ID); !ok || entry.Score != 600 {
+		t.Errorf("Expected the other entries to be found, got %+v (ok=%v)", entry, ok)
+	}
+}
+
+// Test reads served from the cached sorted board follow writes and hold
+// releases, and agree with the filtered path
+func TestSortedBoardSnapshot(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{BestPerPlayer: true})
+	store.AddScore(context.Background(), 500, "Kiro")
+	store.AddScore(context.Background(), 300, "Mario")
+
+	// Callers may modify what they're given without touching the cache
+	top := store.GetTopScores(context.Background(), 0)
+	top[0].Score = 1
+	if top := store.GetTopScores(context.Background(), 1); top[0].Score != 500 {
+		t.Errorf("Expected the cached board to be unchanged, got %+v", top)
+	}
+
+	store.AddScore(context.Background(), 700, "Mario")
+	releasesAt := time.Now().Add(time.Hour)
+	store.AddEntry(context.Background(), ScoreEntry{Score: 900, PlayerName: "Record", HeldUntil: &releasesAt})
+
+	tests := []struct {
+		name      string
+		query     ScoreQuery
+		wantNames []string
+		wantTotal int
+	}{
+		{"whole board", ScoreQuery{}, []string{"Mario", "Kiro"}, 2},
+		{"filtered board", ScoreQuery{MinScore: 1}, []string{"Mario", "Kiro"}, 2},
+		{"page", ScoreQuery{Offset: 1, Limit: 1}, []string{"Kiro"}, 2},
+		{"filtered page", ScoreQuery{MinScore: 1, Offset: 1, Limit: 1}, []string{"Kiro"}, 2},
+		{"score order", ScoreQuery{Sort: SortScore, Order: OrderAsc}, []string{"Kiro", "Mario"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores, total := store.QueryPage(context.Background(), tt.query)
+			if total != tt.wantTotal {
+				t.Errorf("Expected total %d, got %d", tt.wantTotal, total)
+			}
+			if len(scores) != len(tt.wantNames) {
+				t.Fatalf("Expected %d scores, got %+v", len(tt.wantNames), scores)
+			}
+			for i, score := range scores {
+				if score.PlayerName != tt.wantNames[i] {
+					t.Errorf("Expected %s at %d, got %s", tt.wantNames[i], i, score.PlayerName)
+				}
+			}
+		})
+	}
+
+	// The held record joins the board once released
+	_, snap := store.sortedBoard(releasesAt.Add(time.Second))
+	if len(snap.board) != 3 || snap.board[0].PlayerName != "Record" {
+		t.Errorf("Expected the released record first, got %+v", snap.board)
+	}
+}
+
+// Test per-player lookups stay correct as entries are removed, replaced
+// and reloaded
+func TestPlayerIndex(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "leaderboard.json")
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{BestPerPlayer: true})
+
+	first := store.AddScore(context.Background(), 300, "Kiro")
+	store.AddScore(context.Background(), 500, "Mario")
+	store.AddScore(context.Background(), 700, "Kiro")
+	luigi := store.AddScore(context.Background(), 100, "Luigi")
+
+	tests := []struct {
+		name      string
+		change    func()
+		player    string
+		wantRuns  int
+		wantBest  int
+		wantIndex int
+	}{
+		{"best replaced", func() {}, "Kiro", 1, 700, 0},
+		{"after others", func() {}, "Luigi", 1, 100, 2},
+		{"entry before removed", func() { store.DeleteEntry(context.Background(), first.ID) }, "Mario", 1, 500, 1},
+		{"entry deleted", func() { store.DeleteEntry(context.Background(), luigi.ID) }, "Luigi", 0, 0, -1},
+		{"reloaded", func() {
+			store.SaveToFile(filename)
+			store.TakeEntries(context.Background())
+			store.LoadFromFile(filename)
+		}, "Mario", 1, 500, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			history, _ := store.GetPlayerHistory(context.Background(), tt.player)
+			if history.Runs != tt.wantRuns || history.BestScore != tt.wantBest {
+				t.Errorf("Expected %d runs with best %d, got %+v", tt.wantRuns, tt.wantBest, history)
+			}
+
+			around, ok := store.GetAround(context.Background(), tt.player, 0)
+			if tt.wantIndex < 0 {
+				if ok {
+					t.Errorf("Expected %s to be off the board, got %+v", tt.player, around)
+				}
+				return
+			}
+			if !ok || len(around) != 1 || around[0].PlayerName != tt.player || around[0].Standing.Rank != tt.wantIndex+1 {
+				t.Errorf("Expected %s at rank %d, got %+v", tt.player, tt.wantIndex+1, around)
+			}
+		})
+	}
+}
+
+// Test appends share the player index without older states seeing the
+// appended entries
+func TestPlayerIndexSharedByAppends(t *testing.T) {
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 300, "Kiro")
+	before := store.load()
+	store.AddScore(context.Background(), 500, "Kiro")
+	store.AddScore(context.Background(), 700, "Mario")
+	after := store.load()
+
+	if before.byPlayer != after.byPlayer {
+		t.Error("Expected appends to share the player index")
+	}
+	if positions := before.playerPositions("Kiro"); len(positions) != 1 {
+		t.Errorf("Expected the older state to see 1 of Kiro's entries, got %v", positions)
+	}
+	if positions := before.playerPositions("Mario"); len(positions) != 0 {
+		t.Errorf("Expected the older state to see none of Mario's entries, got %v", positions)
+	}
+	if positions := after.playerPositions("Kiro"); len(positions) != 2 {
+		t.Errorf("Expected 2 of Kiro's entries, got %v", positions)
+	}
+}
+
+// Test reads are served from the published state while a write is in
+// progress, and concurrent reads and writes see consistent boards
+func TestReadsDontWaitForWrites(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{BestPerPlayer: true})
+	store.AddScore(context.Background(), 500, "Kiro")
+
+	// Hold the write lock as a slow writer would
+	store.mu.Lock()
+	done := make(chan []ScoreEntry)
+	go func() { done <- store.GetTopScores(context.Background(), 10) }()
+	select {
+	case top := <-done:
+		if len(top) != 1 || top[0].Score != 500 {
+			t.Errorf("Expected the published board, got %+v", top)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected reads not to wait for the writer")
+	}
+	store.mu.Unlock()
+
+	finished := make(chan bool)
+	for w := 0; w < 4; w++ {
+		go func(w int) {
+			for i := 0; i < 50; i++ {
+				store.AddScore(context.Background(), i*10+w, fmt.Sprintf("Player%d", i%7))
+			}
+			finished <- true
+		}(w)
+	}
+	for r := 0; r < 4; r++ {
+		go func() {
+			for i := 0; i < 50; i++ {
+				top := store.GetTopScores(context.Background(), 5)
+				for j := 1; j < len(top); j++ {
+					if top[j].Score > top[j-1].Score {
+						t.Errorf("Expected the board in order, got %+v", top)
+					}
+				}
+				store.GetAround(context.Background(), "Kiro", 2)
+			}
+			finished <- true
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		<-finished
+	}
+
+	if _, ok := store.GetAround(context.Background(), "Kiro", 0); !ok {
+		t.Error("Expected Kiro to stay on the board")
+	}
+}
+
+// Test walks over the board stop once their context is done
+func TestWalkBoardCancelled(t *testing.T) {
+	store := NewScoreStore()
+	for i := 1; i <= 5; i++ {
+		store.AddScore(context.Background(), i*100, fmt.Sprintf("Player%d", i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	walked := 0
+	err := store.WalkBoard(ctx, func(rank int, entry ScoreEntry) error {
+		walked++
+		if walked == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+	if walked != 2 {
+		t.Errorf("Expected the walk to stop after 2 entries, got %d", walked)
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// LiveConfig configures the live-update and lobby WebSockets
+type LiveConfig struct {
+	// MessagesPerSecond is how many messages a connection may send each
+	// second on average, with bursts of up to Burst (defaults 5 and 10).
+	// Connections sending faster are closed.
+	MessagesPerSecond float64 `json:"messagesPerSecond,omitempty"`
+	Burst             int     `json:"burst,omitempty"`
+	// MaxSubscriptions is how many boards one live-update connection can
+	// watch at once (default 5)
+	MaxSubscriptions int `json:"maxSubscriptions,omitempty"`
+	// MaxMessageBytes caps the size of a message sent by a client
+	// (default 4096)
+	MaxMessageBytes int `json:"maxMessageBytes,omitempty"`
+	// AllowedOrigins lists the origins, such as "https://kiro.example",
+	// whose pages may open WebSockets besides the server's own, so a
+	// third-party site can't open sockets from a player's browser
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+}
+
+// Live message types
+const (
+	LiveSubscribe   = "subscribe"
+	LiveUnsubscribe = "unsubscribe"
+	LiveUpdate      = "update"
+	LiveChat        = "chat"
+	LiveJoined      = "joined"
+	LiveLeft        = "left"
+	LiveError       = "error"
+)
+
+// maxChatLength caps the length of a lobby chat message
+const maxChatLength = 500
+
+// liveWriteTimeout bounds how long a message to a slow client may take
+const liveWriteTimeout = 10 * time.Second
+
+// LiveMessage is a message sent either way over the live-update and lobby
+// WebSockets. Type says which of the other fields are set.
+type LiveMessage struct {
+	Type    string       `json:"type"`
+	Board   string       `json:"board,omitempty"`
+	Version string       `json:"version,omitempty"`
+	Entries []ScoreEntry `json:"entries,omitempty"`
+	Player  string       `json:"player,omitempty"`
+	Text    string       `json:"text,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// LiveHub serves the live-update and lobby WebSockets. Connections must
+// present the token of a claimed player name, may only send messages at a
+// limited rate and may only watch a few boards at once. The hub counts
+// connections per board and lobby for the metrics endpoint.
+type LiveHub struct {
+	boards *BoardManager
+	claims *NameClaims
+	config LiveConfig
+	// watchers counts live-update connections subscribed to each board
+	watchers map[string]int
+	// lobbies holds the connections in each lobby
+	lobbies     map[string]map[*liveConn]bool
+	rateLimited int
+	mu          sync.Mutex
+}
+
+// NewLiveHub creates a LiveHub for the boards of a BoardManager,
+// authenticating players with their claimed names
+func NewLiveHub(boards *BoardManager, claims *NameClaims, config LiveConfig) *LiveHub {
+	if config.MessagesPerSecond <= 0 {
+		config.MessagesPerSecond = 5
+	}
+	if config.Burst <= 0 {
+		config.Burst = 10
+	}
+	if config.MaxSubscriptions <= 0 {
+		config.MaxSubscriptions = 5
+	}
+	if config.MaxMessageBytes <= 0 {
+		config.MaxMessageBytes = 4096
+	}
+
+	return &LiveHub{
+		boards:   boards,
+		claims:   claims,
+		config:   config,
+		watchers: make(map[string]int),
+		lobbies:  make(map[string]map[*liveConn]bool),
+	}
+}
+
+// liveConn is one WebSocket connection of an authenticated player
+type liveConn struct {
+	ws     *websocket.Conn
+	player string
+	// tokens and refilled implement the connection's message rate limit
+	// as a token bucket
+	tokens   float64
+	refilled time.Time
+	sendMu   sync.Mutex
+}
+
+// send writes a message to the client. It is safe to call from several
+// goroutines.
+func (c *liveConn) send(msg LiveMessage) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+	return websocket.JSON.Send(c.ws, msg)
+}
+
+// allow takes a token for a message received at now, reporting false if
+// the connection has run out
+func (c *liveConn) allow(now time.Time, config LiveConfig) bool {
+	elapsed := now.Sub(c.refilled).Seconds()
+	c.tokens = min(c.tokens+elapsed*config.MessagesPerSecond, float64(config.Burst))
+	c.refilled = now
+	if c.tokens < 1 {
+		return false
+	}
+	c.tokens--
+	return true
+}
+
+// authenticate returns the claimed player name whose token the request
+// carries, in the X-Player-Token header or, for browsers, which can't set
+// headers on WebSockets, the token query parameter
+func (h *LiveHub) authenticate(r *http.Request) (string, bool) {
+	token := r.Header.Get(PlayerTokenHeader)
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	return h.claims.Identify(token)
+}
+
+// accept authenticates a request and upgrades it to a WebSocket served by
+// serve. Unauthenticated requests are answered with 401 and requests from
+// pages on disallowed origins with 403, and neither is upgraded.
+func (h *LiveHub) accept(w http.ResponseWriter, r *http.Request, serve func(*liveConn)) {
+	player, ok := h.authenticate(r)
+	if !ok {
+		httpError(w, r, "A claimed player token is required", http.StatusUnauthorized)
+		return
+	}
+
+	websocket.Server{Handshake: h.checkOrigin, Handler: func(ws *websocket.Conn) {
+		ws.MaxPayloadBytes = h.config.MaxMessageBytes
+		serve(&liveConn{ws: ws, player: player, tokens: float64(h.config.Burst), refilled: time.Now()})
+	}}.ServeHTTP(w, r)
+}
+
+// checkOrigin refuses the upgrade of requests made by pages on other
+// origins than the server's own and AllowedOrigins. Requests without an
+// Origin header don't come from browsers and are accepted.
+func (h *LiveHub) checkOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	if slices.Contains(h.config.AllowedOrigins, origin) {
+		return nil
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return nil
+	}
+	return fmt.Errorf("origin %q is not allowed", origin)
+}
+
+// receive reads the client's next message, enforcing the rate limit. It
+// returns false once the connection should be closed.
+func (h *LiveHub) receive(conn *liveConn, msg *LiveMessage) bool {
+	*msg = LiveMessage{}
+	if err := websocket.JSON.Receive(conn.ws, msg); err != nil {
+		if err == websocket.ErrFrameTooLarge {
+			conn.send(LiveMessage{Type: LiveError, Error: fmt.Sprintf("Messages are limited to %d bytes", h.config.MaxMessageBytes)})
+		} else if err != io.EOF {
+			conn.send(LiveMessage{Type: LiveError, Error: "Invalid message"})
+		}
+		return false
+	}
+
+	if !conn.allow(time.Now(), h.config) {
+		h.mu.Lock()
+		h.rateLimited++
+		h.mu.Unlock()
+		conn.send(LiveMessage{Type: LiveError, Error: "Rate limit exceeded"})
+		return false
+	}
+	return true
+}
+
+// ServeLive handles GET /api/live, a WebSocket on which clients subscribe
+// to boards with {"type": "subscribe", "board": "main"} and receive the top
+// of each board whenever it changes
+func (h *LiveHub) ServeLive(w http.ResponseWriter, r *http.Request) {
+	h.accept(w, r, h.live)
+}
+
+func (h *LiveHub) live(conn *liveConn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subscriptions := make(map[string]context.CancelFunc)
+	defer func() {
+		for board, stop := range subscriptions {
+			stop()
+			h.unwatch(board)
+		}
+	}()
+
+	var msg LiveMessage
+	for h.receive(conn, &msg) {
+		board := msg.Board
+		if board == "" {
+			board = MainBoard
+		}
+
+		switch msg.Type {
+		case LiveSubscribe:
+			if _, ok := subscriptions[board]; ok {
+				continue
+			}
+			if len(subscriptions) >= h.config.MaxSubscriptions {
+				conn.send(LiveMessage{Type: LiveError, Board: board, Error: fmt.Sprintf("At most %d boards can be watched at once", h.config.MaxSubscriptions)})
+				continue
+			}
+			handler, ok := h.boards.Board(board)
+			if !ok {
+				conn.send(LiveMessage{Type: LiveError, Board: board, Error: "Board not found"})
+				continue
+			}
+
+			watchCtx, stop := context.WithCancel(ctx)
+			subscriptions[board] = stop
+			h.watch(board)
+			go h.push(watchCtx, conn, board, handler.store)
+
+		case LiveUnsubscribe:
+			if stop, ok := subscriptions[board]; ok {
+				stop()
+				delete(subscriptions, board)
+				h.unwatch(board)
+			}
+
+		default:
+			conn.send(LiveMessage{Type: LiveError, Error: "Unknown message type"})
+		}
+	}
+}
+
+// push sends the top of a board, then sends it again each time it changes
+// until ctx is cancelled
+func (h *LiveHub) push(ctx context.Context, conn *liveConn, board string, store BoardReader) {
+	var sent []ScoreEntry
+	for {
+		// Take the version first, so a change made while reading the top
+		// is sent next time around
+		version := store.Version(store.Now())
+		limit, _ := store.Config().QueryLimit(0)
+		entries := publicEntries(store.QueryScores(ctx, ScoreQuery{Limit: limit}))
+		if sent == nil || !reflect.DeepEqual(entries, sent) {
+			if err := conn.send(LiveMessage{Type: LiveUpdate, Board: board, Version: version, Entries: entries}); err != nil {
+				return
+			}
+			sent = entries
+		}
+
+		if _, err := store.WaitForChanges(ctx, version, maxChangesWait); err != nil || ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (h *LiveHub) watch(board string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.watchers[board]++
+}
+
+func (h *LiveHub) unwatch(board string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.watchers[board]--; h.watchers[board] <= 0 {
+		delete(h.watchers, board)
+	}
+}
+
+// Watching returns the number of live-update connections subscribed to a
+// board
+func (h *LiveHub) Watching(board string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.watchers[board]
+}
+
+// ServeLobby handles GET /api/lobbies/{lobby}, a WebSocket on which players
+// in the same lobby chat with {"type": "chat", "text": "..."} and are told
+// when others join or leave
+func (h *LiveHub) ServeLobby(w http.ResponseWriter, r *http.Request) {
+	lobby := r.PathValue("lobby")
+	if !boardNamePattern.MatchString(lobby) {
+		httpError(w, r, "Invalid lobby name", http.StatusBadRequest)
+		return
+	}
+	h.accept(w, r, func(conn *liveConn) { h.lobby(lobby, conn) })
+}
+
+func (h *LiveHub) lobby(lobby string, conn *liveConn) {
+	h.mu.Lock()
+	if h.lobbies[lobby] == nil {
+		h.lobbies[lobby] = make(map[*liveConn]bool)
+	}
+	h.lobbies[lobby][conn] = true
+	h.mu.Unlock()
+	h.broadcast(lobby, LiveMessage{Type: LiveJoined, Player: conn.player})
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.lobbies[lobby], conn)
+		if len(h.lobbies[lobby]) == 0 {
+			delete(h.lobbies, lobby)
+		}
+		h.mu.Unlock()
+		h.broadcast(lobby, LiveMessage{Type: LiveLeft, Player: conn.player})
+	}()
+
+	var msg LiveMessage
+	for h.receive(conn, &msg) {
+		switch {
+		case msg.Type != LiveChat:
+			conn.send(LiveMessage{Type: LiveError, Error: "Unknown message type"})
+		case msg.Text == "" || len(msg.Text) > maxChatLength:
+			conn.send(LiveMessage{Type: LiveError, Error: fmt.Sprintf("Chat messages must be 1 to %d bytes", maxChatLength)})
+		default:
+			h.broadcast(lobby, LiveMessage{Type: LiveChat, Player: conn.player, Text: msg.Text})
+		}
+	}
+}
+
+// LobbySize returns the number of connections in a lobby
+func (h *LiveHub) LobbySize(lobby string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.lobbies[lobby])
+}
+
+// broadcast sends a message to every connection in a lobby
+func (h *LiveHub) broadcast(lobby string, msg LiveMessage) {
+	h.mu.Lock()
+	conns := make([]*liveConn, 0, len(h.lobbies[lobby]))
+	for conn := range h.lobbies[lobby] {
+		conns = append(conns, conn)
+	}
+	h.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.send(msg)
+	}
+}
+
+// WriteMetrics writes connection counts per board and lobby in the
+// Prometheus text format
+func (h *LiveHub) WriteMetrics(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP leaderboard_live_connections Live-update connections watching each board.")
+	fmt.Fprintln(w, "# TYPE leaderboard_live_connections gauge")
+	for _, board := range h.boards.IDs() {
+		fmt.Fprintf(w, "leaderboard_live_connections{board=%q} %d\n", board, h.watchers[board])
+	}
+
+	lobbies := make([]string, 0, len(h.lobbies))
+	for lobby := range h.lobbies {
+		lobbies = append(lobbies, lobby)
+	}
+	sort.Strings(lobbies)
+	fmt.Fprintln(w, "# HELP leaderboard_lobby_connections Connections in each lobby.")
+	fmt.Fprintln(w, "# TYPE leaderboard_lobby_connections gauge")
+	for _, lobby := range lobbies {
+		fmt.Fprintf(w, "leaderboard_lobby_connections{lobby=%q} %d\n", lobby, len(h.lobbies[lobby]))
+	}
+
+	fmt.Fprintln(w, "# HELP leaderboard_live_rate_limited_total Connections closed for sending messages too fast.")
+	fmt.Fprintln(w, "# TYPE leaderboard_live_rate_limited_total counter")
+	fmt.Fprintf(w, "leaderboard_live_rate_limited_total %d\n", h.rateLimited)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// newLiveServer serves a LiveHub over a main board, returning the server,
+// the board's store and a token for the player "Kiro"
+func newLiveServer(t *testing.T, config LiveConfig) (*httptest.Server, *LiveHub, *ScoreStore, string) {
+	t.Helper()
+	claims := NewNameClaims(filepath.Join(t.TempDir(), "claims.json"))
+	token, err := claims.Claim("Kiro")
+	if err != nil {
+		t.Fatalf("Failed to claim name: %v", err)
+	}
+
+	store := NewScoreStore()
+	boards := NewBoardManager()
+	boards.Add(MainBoard, NewLeaderboardHandler(store))
+	hub := NewLiveHub(boards, claims, config)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/live", hub.ServeLive)
+	mux.HandleFunc("GET /api/lobbies/{lobby}", hub.ServeLobby)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, hub, store, token
+}
+
+// dialLive opens a WebSocket to path on server
+func dialLive(t *testing.T, server *httptest.Server, path string) *websocket.Conn {
+	t.Helper()
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+path, "", server.URL)
+	if err != nil {
+		t.Fatalf("Failed to dial %s: %v", path, err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// receiveLive reads the next message, failing the test if none arrives
+func receiveLive(t *testing.T, ws *websocket.Conn) LiveMessage {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg LiveMessage
+	if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Fatalf("Failed to receive message: %v", err)
+	}
+	return msg
+}
+
+// Test connections without a claimed player token are refused before the
+// upgrade
+func TestLiveRequiresToken(t *testing.T) {
+	server, _, _, _ := newLiveServer(t, LiveConfig{})
+
+	for _, path := range []string{"/api/live", "/api/live?token=wrong", "/api/lobbies/speedrun"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusUnauthorized, path, resp.StatusCode)
+		}
+	}
+}
+
+// Test pages on other origins can only connect if their origin is allowed
+func TestLiveChecksOrigin(t *testing.T) {
+	url := func(server *httptest.Server, token string) string {
+		return "ws" + strings.TrimPrefix(server.URL, "http") + "/api/live?token=" + token
+	}
+
+	server, _, _, token := newLiveServer(t, LiveConfig{})
+	if ws, err := websocket.Dial(url(server, token), "", "https://evil.example"); err == nil {
+		ws.Close()
+		t.Errorf("Expected a connection from another origin to be refused")
+	}
+
+	server, _, _, token = newLiveServer(t, LiveConfig{AllowedOrigins: []string{"https://kiro.example"}})
+	ws, err := websocket.Dial(url(server, token), "", "https://kiro.example")
+	if err != nil {
+		t.Fatalf("Expected a connection from an allowed origin, got %v", err)
+	}
+	ws.Close()
+}
+
+// Test subscribers get the board's top and again after each change, and
+// are counted in the metrics
+func TestLiveUpdates(t *testing.T) {
+	server, hub, store, token := newLiveServer(t, LiveConfig{})
+	store.AddScore(context.Background(), 100, "Kiro")
+	ws := dialLive(t, server, "/api/live?token="+token)
+
+	websocket.JSON.Send(ws, LiveMessage{Type: LiveSubscribe, Board: MainBoard})
+	if msg := receiveLive(t, ws); msg.Type != LiveUpdate || len(msg.Entries) != 1 {
+		t.Fatalf("Expected an update with 1 entry, got %+v", msg)
+	}
+
+	var metrics strings.Builder
+	hub.WriteMetrics(&metrics)
+	if !strings.Contains(metrics.String(), `leaderboard_live_connections{board="main"} 1`) {
+		t.Errorf("Expected one watcher of the main board, got:\n%s", metrics.String())
+	}
+
+	store.AddScore(context.Background(), 200, "Luigi")
+	if msg := receiveLive(t, ws); msg.Type != LiveUpdate || len(msg.Entries) != 2 || msg.Entries[0].PlayerName != "Luigi" {
+		t.Errorf("Expected an update led by Luigi, got %+v", msg)
+	}
+}
+
+// Test connections can only watch a few boards, and only boards that exist
+func TestLiveSubscriptionLimit(t *testing.T) {
+	server, _, _, token := newLiveServer(t, LiveConfig{MaxSubscriptions: 1})
+	ws := dialLive(t, server, "/api/live?token="+token)
+
+	websocket.JSON.Send(ws, LiveMessage{Type: LiveSubscribe, Board: "missing"})
+	if msg := receiveLive(t, ws); msg.Type != LiveError || msg.Error != "Board not found" {
+		t.Errorf("Expected a board not found error, got %+v", msg)
+	}
+
+	websocket.JSON.Send(ws, LiveMessage{Type: LiveSubscribe})
+	if msg := receiveLive(t, ws); msg.Type != LiveUpdate {
+		t.Fatalf("Expected an update, got %+v", msg)
+	}
+	websocket.JSON.Send(ws, LiveMessage{Type: LiveSubscribe, Board: "weekly"})
+	if msg := receiveLive(t, ws); msg.Type != LiveError || !strings.Contains(msg.Error, "At most 1") {
+		t.Errorf("Expected a subscription limit error, got %+v", msg)
+	}
+}
+
+// Test connections sending too fast are told so and closed
+func TestLiveRateLimit(t *testing.T) {
+	server, hub, _, token := newLiveServer(t, LiveConfig{MessagesPerSecond: 0.001, Burst: 2})
+	ws := dialLive(t, server, "/api/lobbies/speedrun?token="+token)
+	if msg := receiveLive(t, ws); msg.Type != LiveJoined || msg.Player != "Kiro" {
+		t.Fatalf("Expected Kiro to join, got %+v", msg)
+	}
+
+	for i := 0; i < 3; i++ {
+		websocket.JSON.Send(ws, LiveMessage{Type: LiveChat, Text: "hi"})
+	}
+	for i := 0; i < 2; i++ {
+		if msg := receiveLive(t, ws); msg.Type != LiveChat || msg.Text != "hi" {
+			t.Fatalf("Expected chat message %d, got %+v", i+1, msg)
+		}
+	}
+	if msg := receiveLive(t, ws); msg.Type != LiveError || msg.Error != "Rate limit exceeded" {
+		t.Fatalf("Expected a rate limit error, got %+v", msg)
+	}
+
+	var msg LiveMessage
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := websocket.JSON.Receive(ws, &msg); err == nil {
+		t.Errorf("Expected the connection to be closed, got %+v", msg)
+	}
+
+	var metrics strings.Builder
+	hub.WriteMetrics(&metrics)
+	if !strings.Contains(metrics.String(), "leaderboard_live_rate_limited_total 1") {
+		t.Errorf("Expected one rate-limited connection, got:\n%s", metrics.String())
+	}
+}
+
+// Test lobby chat reaches everyone in the lobby, and oversized messages
+// are refused
+func TestLobbyChat(t *testing.T) {
+	server, _, _, token := newLiveServer(t, LiveConfig{MaxMessageBytes: 256})
+	first := dialLive(t, server, "/api/lobbies/speedrun?token="+token)
+	receiveLive(t, first)
+	second := dialLive(t, server, "/api/lobbies/speedrun?token="+token)
+	receiveLive(t, second)
+	if msg := receiveLive(t, first); msg.Type != LiveJoined {
+		t.Fatalf("Expected a join, got %+v", msg)
+	}
+
+	websocket.JSON.Send(second, LiveMessage{Type: LiveChat, Text: "gg"})
+	if msg := receiveLive(t, first); msg.Type != LiveChat || msg.Player != "Kiro" || msg.Text != "gg" {
+		t.Errorf("Expected the chat message, got %+v", msg)
+	}
+	receiveLive(t, second)
+
+	websocket.JSON.Send(second, LiveMessage{Type: LiveChat, Text: strings.Repeat("a", 300)})
+	if msg := receiveLive(t, second); msg.Type != LiveError || !strings.Contains(msg.Error, "256 bytes") {
+		t.Errorf("Expected a message size error, got %+v", msg)
+	}
+	if msg := receiveLive(t, first); msg.Type != LiveLeft {
+		t.Errorf("Expected the oversized sender to leave, got %+v", msg)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ModerationRule flags submissions whose expression evaluates to true
+type ModerationRule struct {
+	Name       string    `json:"name"`
+	Expression string    `json:"expression"`
+	CreatedAt  time.Time `json:"createdAt"`
+	compiled   *Expr
+}
+
+// RuleSet holds the moderation rules evaluated on every submission
+type RuleSet struct {
+	rules []ModerationRule
+	mu    sync.RWMutex
+}
+
+// NewRuleSet creates an empty RuleSet
+func NewRuleSet() *RuleSet {
+	return &RuleSet{
+		rules: make([]ModerationRule, 0),
+	}
+}
+
+// AddRule compiles and stores a rule, replacing any rule with the same name
+func (rs *RuleSet) AddRule(name, expression string) (ModerationRule, error) {
+	compiled, err := CompileExpr(expression)
+	if err != nil {
+		return ModerationRule{}, err
+	}
+
+	// Reject rules that reference unknown variables or mix types up front
+	if err := compiled.Check(sampleRuleVars()); err != nil {
+		return ModerationRule{}, err
+	}
+
+	rule := ModerationRule{
+		Name:       name,
+		Expression: expression,
+		CreatedAt:  time.Now(),
+		compiled:   compiled,
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for i, existing := range rs.rules {
+		if existing.Name == name {
+			rs.rules[i] = rule
+			return rule, nil
+		}
+	}
+	rs.rules = append(rs.rules, rule)
+	return rule, nil
+}
+
+// RemoveRule deletes the named rule, returning false if it did not exist
+func (rs *RuleSet) RemoveRule(name string) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for i, rule := range rs.rules {
+		if rule.Name == name {
+			rs.rules = append(rs.rules[:i], rs.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of rules
+func (rs *RuleSet) Len() int {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return len(rs.rules)
+}
+
+// ListRules returns a copy of all rules in the order they were added
+func (rs *RuleSet) ListRules() []ModerationRule {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	rules := make([]ModerationRule, len(rs.rules))
+	copy(rules, rs.rules)
+	return rules
+}
+
+// Evaluate returns the names of all rules matching the given variables.
+// Rules that fail to evaluate are logged and treated as not matching.
+func (rs *RuleSet) Evaluate(vars map[string]interface{}) []string {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	var matched []string
+	for _, rule := range rs.rules {
+		ok, err := rule.compiled.EvalBool(vars)
+		if err != nil {
+			log.Printf("Warning: moderation rule %q failed: %v", rule.Name, err)
+			continue
+		}
+		if ok {
+			matched = append(matched, rule.Name)
+		}
+	}
+	return matched
+}
+
+// SaveToFile persists the rules to a JSON file
+func (rs *RuleSet) SaveToFile(filename string) error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	data, err := json.MarshalIndent(rs.rules, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, data, 0644)
+}
+
+// LoadFromFile loads, compiles and type-checks rules from a JSON file.
+// A file with any rule that fails to compile or check is rejected whole.
+func (rs *RuleSet) LoadFromFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var rules []ModerationRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return err
+	}
+
+	// Rules are checked like those added through the API, so a rule that
+	// no longer fits the variables is caught at startup rather than when
+	// a submission is evaluated
+	vars := sampleRuleVars()
+	for i := range rules {
+		compiled, err := CompileExpr(rules[i].Expression)
+		if err != nil {
+			return fmt.Errorf("rule %q: %w", rules[i].Name, err)
+		}
+		if err := compiled.Check(vars); err != nil {
+			return fmt.Errorf("rule %q: %w", rules[i].Name, err)
+		}
+		rules[i].compiled = compiled
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.rules = rules
+	return nil
+}
+
+// buildRuleVars assembles the variables visible to moderation rules for a
+// submission made at now. Board statistics describe the public board
+// before the new score.
+func buildRuleVars(ctx context.Context, store BoardReader, score int, playerName string, now time.Time) map[string]interface{} {
+	history, _ := store.GetPlayerHistory(ctx, playerName)
+	return ruleVars(boardRuleVars(store.GetScoreDistribution(ctx)), score, playerName, history, now)
+}
+
+// boardRuleVars returns the board variables for rules from the board's
+// score distribution
+func boardRuleVars(stats ScoreDistribution) map[string]interface{} {
+	return map[string]interface{}{
+		"count": stats.Count,
+		"max":   stats.Max,
+		"p50":   stats.P50,
+		"p90":   stats.P90,
+		"p99":   stats.P99,
+	}
+}
+
+// ruleVars assembles the variables for a submission from the board
+// variables and the player's history before it. A zero history is a player
+// who has not submitted before.
+func ruleVars(board map[string]interface{}, score int, playerName string, history PlayerHistory, now time.Time) map[string]interface{} {
+	player := map[string]interface{}{
+		"name":     playerName,
+		"runs":     0,
+		"best":     0,
+		"age_days": 0,
+	}
+	if history.Runs > 0 {
+		player["runs"] = history.Runs
+		player["best"] = history.BestScore
+		player["age_days"] = now.Sub(history.FirstSeen).Hours() / 24
+	}
+
+	return map[string]interface{}{
+		"score":  score,
+		"board":  board,
+		"player": player,
+	}
+}
+
+// sampleRuleVars returns a variable set with every name rules may use,
+// for validating expressions when they are defined
+func sampleRuleVars() map[string]interface{} {
+	return buildRuleVars(context.Background(), NewScoreStore(), 1, "sample", time.Now())
+}
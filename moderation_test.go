@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test expression evaluation against nested variables
+func TestExprEvaluation(t *testing.T) {
+	vars := map[string]interface{}{
+		"score": 5000,
+		"größe": 2,
+		"board": map[string]interface{}{"p99": 2000},
+		"player": map[string]interface{}{
+			"name":     "Cheater",
+			"age_days": 0.5,
+		},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"score > 2*board.p99 && player.age_days < 1", true},
+		{"score > 2*board.p99 && player.age_days > 1", false},
+		{"!(score <= 4999)", true},
+		{"player.name == 'Cheater' || false", true},
+		{"(score - board.p99) % 2000 == 1000", true},
+		{"-score < 0", true},
+		{"player.name != 'Jalapeño' && größe > 1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := CompileExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("Failed to compile: %v", err)
+			}
+
+			got, err := expr.EvalBool(vars)
+			if err != nil {
+				t.Fatalf("Failed to evaluate: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// Test comparing objects fails instead of panicking
+func TestExprCompareObjects(t *testing.T) {
+	vars := map[string]interface{}{
+		"board":  map[string]interface{}{"p99": 2000},
+		"player": map[string]interface{}{"best": 100},
+	}
+
+	expr, err := CompileExpr("board == player")
+	if err != nil {
+		t.Fatalf("Failed to compile: %v", err)
+	}
+	if _, err := expr.EvalBool(vars); err == nil {
+		t.Error("Expected an error comparing objects")
+	}
+}
+
+// Test board statistics describe only the public board
+func TestBuildRuleVarsListedOnly(t *testing.T) {
+	store := NewScoreStore()
+	for _, score := range []int{100, 200, 300} {
+		store.AddScore(context.Background(), score, "Kiro")
+	}
+	hidden := store.AddScore(context.Background(), 5000, "Rude")
+	store.SetHidden(context.Background(), hidden.ID, true)
+
+	board := buildRuleVars(context.Background(), store, 1, "Kiro", time.Now())["board"].(map[string]interface{})
+	if board["count"] != 3 || board["max"] != 300 || board["p50"] != 200 {
+		t.Errorf("Expected count 3, max 300 and p50 200, got %v", board)
+	}
+}
+
+// Test invalid expressions are rejected when rules are added
+func TestAddRuleInvalid(t *testing.T) {
+	rules := NewRuleSet()
+
+	invalid := []string{
+		"score >",
+		"(score > 1",
+		"score > 1 @ 2",
+		"unknown.var > 1",
+		"'unterminated",
+		"board == player",
+		"score == 'high'",
+		"player.name > 3",
+		"score + 1",
+	}
+
+	for _, expression := range invalid {
+		if _, err := rules.AddRule("bad", expression); err == nil {
+			t.Errorf("Expected error for %q", expression)
+		}
+	}
+
+	if len(rules.ListRules()) != 0 {
+		t.Errorf("Expected no rules to be stored, got %d", len(rules.ListRules()))
+	}
+}
+
+// Test rule files are checked like rules added through the API, and a
+// file with an ill-typed rule is rejected without replacing the rules
+func TestLoadRulesChecksTypes(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr bool
+	}{
+		{"valid", `[{"name":"big","expression":"score > 1000"}]`, false},
+		{"type error", `[{"name":"big","expression":"score > 1000"},{"name":"odd","expression":"score == 'high'"}]`, true},
+		{"unknown variable", `[{"name":"gone","expression":"unknown.var > 1"}]`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(dir, tt.name+".json")
+			os.WriteFile(filename, []byte(tt.rules), 0644)
+
+			rules := NewRuleSet()
+			rules.AddRule("existing", "score > 1")
+			err := rules.LoadFromFile(filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr && (len(rules.ListRules()) != 1 || rules.ListRules()[0].Name != "existing") {
+				t.Errorf("Expected the existing rules to be kept, got %+v", rules.ListRules())
+			}
+		})
+	}
+}
+
+// Test submissions matching a rule are flagged
+func TestSubmitScoreFlaggedByRule(t *testing.T) {
+	store := NewScoreStore()
+	rules := NewRuleSet()
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithRuleSet(rules))
+	t.Cleanup(store.Close)
+
+	for i := 0; i < 100; i++ {
+		store.AddScore(context.Background(), 1000, "Regular")
+	}
+
+	if _, err := rules.AddRule("too-good", "score > 2*board.p99 && player.age_days < 1"); err != nil {
+		t.Fatalf("Failed to add rule: %v", err)
+	}
+
+	submit := func(score int, name string) ScoreEntry {
+		body, _ := json.Marshal(map[string]interface{}{"score": score, "playerName": name})
+		req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.SubmitScore(w, req)
+
+		var entry ScoreEntry
+		json.NewDecoder(w.Body).Decode(&entry)
+		return entry
+	}
+
+	if entry := submit(1500, "Newbie"); len(entry.Flags) != 0 {
+		t.Errorf("Expected no flags, got %v", entry.Flags)
+	}
+
+	entry := submit(5000, "Suspicious")
+	if len(entry.Flags) != 1 || entry.Flags[0] != "too-good" {
+		t.Errorf("Expected flag 'too-good', got %v", entry.Flags)
+	}
+
+	flagged := store.GetFlaggedEntries(context.Background())
+	if len(flagged) != 1 || flagged[0].ID != entry.ID {
+		t.Errorf("Expected flagged entry %s, got %v", entry.ID, flagged)
+	}
+}
+
+// Test admin rule endpoints create and delete rules
+func TestAdminRulesEndpoints(t *testing.T) {
+	store := NewScoreStore()
+	rules := NewRuleSet()
+	handler := NewAdminHandler(store, rules, t.TempDir()+"/rules.json")
+
+	body := []byte(`{"name":"big","expression":"score > 100000"}`)
+	req := httptest.NewRequest("POST", "/api/admin/rules", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.AddRule(w, req)
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d", w.Code)
+	}
+
+	body = []byte(`{"name":"broken","expression":"score >"}`)
+	req = httptest.NewRequest("POST", "/api/admin/rules", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	handler.AddRule(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	req = routedRequest(t, "DELETE /api/admin/rules/{name}", "/api/admin/rules/big", nil)
+	w = httptest.NewRecorder()
+	handler.DeleteRule(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+
+	if len(rules.ListRules()) != 0 {
+		t.Errorf("Expected rule to be removed")
+	}
+}
+
+// Test scores above the review threshold wait in the moderation queue
+func TestModerationQueue(t *testing.T) {
+	useTempSaveFiles(t)
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{ReviewThreshold: 50000})
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+	t.Cleanup(store.Close)
+	admin := NewAdminHandler(store, NewRuleSet(), "")
+
+	for _, score := range []int{1000, 999999, 888888} {
+		body, _ := json.Marshal(map[string]interface{}{"score": score, "playerName": "Player"})
+		req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.SubmitScore(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d", w.Code)
+		}
+	}
+
+	if scores := store.GetTopScores(context.Background(), 0); len(scores) != 1 {
+		t.Fatalf("Expected pending scores to be excluded, got %d entries", len(scores))
+	}
+
+	pending := store.GetPendingEntries(context.Background())
+	if len(pending) != 2 {
+		t.Fatalf("Expected 2 pending entries, got %d", len(pending))
+	}
+
+	for action, entry := range map[string]ScoreEntry{"approve": pending[0], "reject": pending[1]} {
+		req := routedRequest(t, "POST /api/admin/entries/{id}/{action}", "/api/admin/entries/"+entry.ID+"/"+action, nil)
+		w := httptest.NewRecorder()
+		admin.EntryAction(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200 for %s, got %d", action, w.Code)
+		}
+	}
+
+	scores := store.GetTopScores(context.Background(), 0)
+	if len(scores) != 2 || scores[0].Score != 999999 {
+		t.Errorf("Expected approved score to be listed, got %v", scores)
+	}
+	if len(store.GetPendingEntries(context.Background())) != 0 {
+		t.Errorf("Expected empty queue")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Test runs can be submitted and answered in MessagePack, with the same
+// keys as JSON
+func TestSubmitScoreMsgpack(t *testing.T) {
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 900, "Mario")
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+	t.Cleanup(store.Close)
+
+	body, _ := msgpack.Marshal(map[string]interface{}{
+		"playerName": "Kiro",
+		"score":      500,
+		"metadata":   map[string]interface{}{"level": "1-2"},
+	})
+	req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+	req.Header.Set("Content-Type", MsgpackContentType)
+	req.Header.Set("Accept", MsgpackContentType)
+	w := httptest.NewRecorder()
+	handler.SubmitScore(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != MsgpackContentType {
+		t.Errorf("Expected Content-Type %s, got %s", MsgpackContentType, ct)
+	}
+
+	var result map[string]interface{}
+	if err := msgpack.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result["playerName"] != "Kiro" || result["rank"] != int8(2) || result["personalBest"] != true {
+		t.Errorf("Expected Kiro's first run at rank 2, got %v", result)
+	}
+	if metadata, _ := result["metadata"].(map[string]interface{}); metadata["level"] != "1-2" {
+		t.Errorf("Expected the metadata as a map, got %#v", result["metadata"])
+	}
+
+	entries := store.GetTopScores(context.Background(), 2)
+	if len(entries) != 2 || entries[1].PlayerName != "Kiro" || string(entries[1].Metadata) != `{"level":"1-2"}` {
+		t.Errorf("Expected Kiro stored with JSON metadata, got %+v", entries)
+	}
+}
+
+// Test the request and response encodings are independent
+func TestMsgpackEncodings(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		accept      string
+		wantStatus  int
+		wantType    string
+	}{
+		{"JSON", "application/json", "", http.StatusCreated, "application/json"},
+		{"MessagePack in, JSON out", MsgpackContentType, "application/json", http.StatusCreated, "application/json"},
+		{"JSON in, MessagePack out", "application/json", MsgpackContentType, http.StatusCreated, MsgpackContentType},
+		{"legacy MessagePack type", "application/x-msgpack", "", http.StatusCreated, "application/json"},
+		{"mislabelled body", MsgpackContentType, "", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewScoreStore()
+			handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+			t.Cleanup(store.Close)
+			body := []byte(`{"playerName":"Kiro","score":100}`)
+			if isMsgpack(tt.contentType) && tt.wantStatus != http.StatusBadRequest {
+				body, _ = msgpack.Marshal(map[string]interface{}{"playerName": "Kiro", "score": 100})
+			}
+			req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			handler.SubmitScore(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); tt.wantType != "" && ct != tt.wantType {
+				t.Errorf("Expected Content-Type %s, got %s", tt.wantType, ct)
+			}
+		})
+	}
+}
+
+// Test GET /api/leaderboard lists entries in MessagePack when asked to
+func TestGetLeaderboardMsgpack(t *testing.T) {
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 300, "Mario")
+	store.AddScore(context.Background(), 200, "Luigi")
+	handler := NewLeaderboardHandler(store)
+
+	req := httptest.NewRequest("GET", "/api/leaderboard", nil)
+	req.Header.Set("Accept", MsgpackContentType)
+	w := httptest.NewRecorder()
+	handler.GetLeaderboard(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != MsgpackContentType {
+		t.Errorf("Expected Content-Type %s, got %s", MsgpackContentType, ct)
+	}
+	var entries []ScoreEntry
+	dec := msgpack.NewDecoder(w.Body)
+	dec.SetCustomStructTag("json")
+	if err := dec.Decode(&entries); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(entries) != 2 || entries[0].PlayerName != "Mario" || entries[0].Timestamp.IsZero() {
+		t.Errorf("Expected Mario then Luigi with timestamps, got %+v", entries)
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Errors returned for invalid player names
+var (
+	ErrNameEmpty     = newKindError(ErrValidation, "name is empty")
+	ErrNameTooLong   = newKindError(ErrValidation, "name is too long")
+	ErrNameCharset   = newKindError(ErrValidation, "name contains characters that are not allowed")
+	ErrNameOffensive = newKindError(ErrValidation, "name is not allowed")
+)
+
+// defaultNameCharset allows letters, combining marks, digits, spaces and
+// a little punctuation
+const defaultNameCharset = `\p{L}\p{M}\p{N} _.'-`
+
+// Profanity actions for offensive player names
+const (
+	ProfanityReject = "reject"
+	ProfanityMask   = "mask"
+)
+
+// NameConfig holds rules for player names shown on the public board
+type NameConfig struct {
+	// MaxLength is the longest name allowed, in characters. Defaults to 24.
+	MaxLength int `json:"maxLength,omitempty"`
+	// Charset is the body of a regular expression character class that
+	// every character of a name must match. Defaults to letters, digits,
+	// spaces and _.'-
+	Charset string `json:"charset,omitempty"`
+	// BlockedWords replaces the built-in list of offensive words
+	BlockedWords []string `json:"blockedWords,omitempty"`
+	// BlockedWordsFile adds words from a file, one per line
+	BlockedWordsFile string `json:"blockedWordsFile,omitempty"`
+	// AllowedWords replaces the built-in list of innocent words that
+	// contain a blocked word, such as "therapist"
+	AllowedWords []string `json:"allowedWords,omitempty"`
+	// ProfanityAction is "reject" (the default) to refuse offensive names
+	// or "mask" to replace the offending letters with asterisks
+	ProfanityAction string `json:"profanityAction,omitempty"`
+}
+
+// defaultBlockedWords is used when the config does not list its own.
+// Words match anywhere inside a word of a name, so "BigFuck" is caught,
+// but only whole words count when spelled across separators, so
+// "Cass Holer" is allowed.
+var defaultBlockedWords = []string{
+	"fuck", "shit", "cunt", "bitch", "whore", "slut", "bastard",
+	"asshole", "nigger", "nigga", "faggot", "retard", "rapist",
+}
+
+// defaultAllowedWords is used when the config does not list its own.
+// A blocked word inside one of these is not counted.
+var defaultAllowedWords = []string{
+	"shiitake", "scunthorpe", "therapist", "snigger", "niggard",
+	"retardant",
+}
+
+// leetLetters maps digits and symbols commonly substituted for letters
+var leetLetters = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't',
+	'8': 'b', '9': 'g', '@': 'a', '$': 's', '!': 'i', '|': 'i', '+': 't',
+}
+
+// confusables maps Cyrillic and Greek letters to the Latin letters they
+// are indistinguishable from on screen
+var confusables = map[rune]rune{
+	// Cyrillic
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O',
+	'Р': 'P', 'С': 'C', 'Т': 'T', 'У': 'Y', 'Х': 'X', 'Ѕ': 'S', 'І': 'I',
+	'Ј': 'J', 'Ԛ': 'Q', 'Ԝ': 'W', 'Ү': 'Y',
+	'а': 'a', 'е': 'e', 'о': 'o', 'р': 'p', 'с': 'c', 'у': 'y', 'х': 'x',
+	'ѕ': 's', 'і': 'i', 'ј': 'j', 'ԛ': 'q', 'ԝ': 'w', 'һ': 'h', 'ԁ': 'd',
+	'ү': 'y', 'ӏ': 'l', 'г': 'r',
+	// Greek
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K',
+	'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+	'ο': 'o', 'ν': 'v', 'ι': 'i', 'κ': 'k', 'ρ': 'p', 'υ': 'u', 'α': 'a',
+}
+
+// NameFilter cleans up player names, enforces length and charset limits,
+// and detects offensive words, seeing through leetspeak, separators and
+// repeated letters
+type NameFilter struct {
+	maxLength int
+	charset   *regexp.Regexp
+	words     [][]rune
+	allowed   [][]rune
+	action    string
+}
+
+// compileCharset compiles a name charset into a pattern matching whole names
+func compileCharset(charset string) (*regexp.Regexp, error) {
+	if charset == "" {
+		charset = defaultNameCharset
+	}
+	pattern, err := regexp.Compile("^[" + charset + "]*$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid name charset: %w", err)
+	}
+	return pattern, nil
+}
+
+// NewNameFilter creates a NameFilter from config, reading the optional
+// wordlist file
+func NewNameFilter(config NameConfig) (*NameFilter, error) {
+	words := config.BlockedWords
+	if len(words) == 0 {
+		words = defaultBlockedWords
+	}
+
+	if config.BlockedWordsFile != "" {
+		file, err := os.Open(config.BlockedWordsFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if word := strings.TrimSpace(scanner.Text()); word != "" && !strings.HasPrefix(word, "#") {
+				words = append(words, word)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	charset, err := compileCharset(config.Charset)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &NameFilter{
+		maxLength: config.MaxLength,
+		charset:   charset,
+		action:    config.ProfanityAction,
+	}
+	if f.maxLength <= 0 {
+		f.maxLength = 24
+	}
+	if f.action == "" {
+		f.action = ProfanityReject
+	}
+	for _, word := range words {
+		if letters, _ := normalizeForMatch(word); len(letters) > 0 {
+			f.words = append(f.words, letters)
+		}
+	}
+
+	allowed := config.AllowedWords
+	if len(allowed) == 0 {
+		allowed = defaultAllowedWords
+	}
+	for _, word := range allowed {
+		if letters, _ := normalizeForMatch(word); len(letters) > 0 {
+			f.allowed = append(f.allowed, letters)
+		}
+	}
+	return f, nil
+}
+
+// foldRune lowercases a rune and undoes homoglyphs and leetspeak. 'l' is folded into
+// 'i' since '1' and '|' stand in for either.
+func foldRune(r rune) rune {
+	if latin, ok := confusables[r]; ok {
+		r = latin
+	}
+	r = unicode.ToLower(r)
+	if leet, ok := leetLetters[r]; ok {
+		r = leet
+	}
+	if r == 'l' {
+		r = 'i'
+	}
+	return r
+}
+
+// normalizeForMatch reduces a name to folded letters for matching,
+// dropping separators and collapsing repeated letters. The second return
+// value maps each letter back to its rune in the name.
+func normalizeForMatch(name string) ([]rune, []int) {
+	var letters []rune
+	var index []int
+
+	for i, r := range []rune(name) {
+		r = foldRune(r)
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if len(letters) > 0 && letters[len(letters)-1] == r {
+			continue
+		}
+		letters = append(letters, r)
+		index = append(index, i)
+	}
+	return letters, index
+}
+
+// cleanName strips control and formatting characters and collapses runs
+// of whitespace into single spaces
+func cleanName(name string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range name {
+		switch {
+		case unicode.IsControl(r) && !unicode.IsSpace(r), unicode.Is(unicode.Cf, r):
+			continue
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// collapseHomoglyphs replaces Cyrillic and Greek lookalikes with Latin
+// letters in names that also contain Latin letters, so "Рlayer1" spelled
+// with a Cyrillic Р is stored as "Player1". Names written wholly in
+// another script are left alone.
+func collapseHomoglyphs(name string) string {
+	hasLatin, hasConfusable := false, false
+	for _, r := range name {
+		if _, ok := confusables[r]; ok {
+			hasConfusable = true
+		} else if unicode.Is(unicode.Latin, r) {
+			hasLatin = true
+		}
+	}
+	if !hasLatin || !hasConfusable {
+		return name
+	}
+	return strings.Map(func(r rune) rune {
+		if latin, ok := confusables[r]; ok {
+			return latin
+		}
+		return r
+	}, name)
+}
+
+// skeletonLookalikes maps lowercase Latin characters that are easily
+// mistaken for one another to a single representative. Case is folded
+// first, so i joins them to cover the capital I.
+var skeletonLookalikes = map[rune]rune{
+	'i': 'l', '1': 'l', '|': 'l', '0': 'o',
+}
+
+// NameSkeleton returns the form of a name used to decide whether two names
+// look the same: compatibility-normalized, with homoglyphs from any script
+// and lookalike characters such as I, l and 1 folded together, and case
+// ignored. "PIayer1" and "Player1" have the same skeleton.
+func NameSkeleton(name string) string {
+	name = norm.NFKC.String(cleanName(name))
+	name = strings.Map(func(r rune) rune {
+		if latin, ok := confusables[r]; ok {
+			r = latin
+		}
+		r = unicode.ToLower(r)
+		if lookalike, ok := skeletonLookalikes[r]; ok {
+			r = lookalike
+		}
+		return r
+	}, name)
+	return strings.ReplaceAll(name, "rn", "m")
+}
+
+// Check returns the name to store for a submitted player name, or an error
+// explaining why it is not allowed. Names are cleaned of control
+// characters and excess whitespace, normalized to NFC with homoglyphs
+// collapsed, then checked against the length and
+// charset limits. Offensive names are masked or rejected, depending on the
+// configured action.
+func (f *NameFilter) Check(name string) (string, error) {
+	name = collapseHomoglyphs(norm.NFC.String(cleanName(name)))
+	if name == "" {
+		return "", ErrNameEmpty
+	}
+	if utf8.RuneCountInString(name) > f.maxLength {
+		return "", ErrNameTooLong
+	}
+	if !f.charset.MatchString(name) {
+		return "", ErrNameCharset
+	}
+
+	letters, index := normalizeForMatch(name)
+	runes := []rune(name)
+	masked := make([]bool, len(runes))
+	found := false
+
+	for _, word := range f.words {
+		for start := 0; start+len(word) <= len(letters); start++ {
+			if !runesEqual(letters[start:start+len(word)], word) {
+				continue
+			}
+			// The whole span, including separators and repeated letters
+			end := index[start+len(word)-1]
+			for end+1 < len(runes) && foldRune(runes[end+1]) == word[len(word)-1] {
+				end++
+			}
+			// Spelled across separators only whole words count, or
+			// innocent names such as "Miss Hitomi" would be caught.
+			// Inside a word they count wherever they are, since run
+			// together is how they are most often slipped in, unless an
+			// allowed word contains them.
+			if spansSeparator(runes, index[start], end) {
+				if !wordBoundary(runes, index[start]-1) || !wordBoundary(runes, end+1) {
+					continue
+				}
+			} else if f.allows(letters, start, len(word)) {
+				continue
+			}
+			found = true
+			for i := index[start]; i <= end; i++ {
+				masked[i] = true
+			}
+		}
+	}
+
+	if !found {
+		return name, nil
+	}
+	if f.action != ProfanityMask {
+		return "", ErrNameOffensive
+	}
+
+	for i := range runes {
+		if masked[i] && !unicode.IsSpace(runes[i]) {
+			runes[i] = '*'
+		}
+	}
+	return string(runes), nil
+}
+
+// wordBoundary reports whether runes[i] separates words: it is out of
+// range, or neither a letter nor something standing in for one
+func wordBoundary(runes []rune, i int) bool {
+	return i < 0 || i >= len(runes) || !unicode.IsLetter(foldRune(runes[i]))
+}
+
+// spansSeparator reports whether runes[start:end+1] has anything but
+// letters and things standing in for them
+func spansSeparator(runes []rune, start, end int) bool {
+	for i := start; i <= end; i++ {
+		if wordBoundary(runes, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether letters[start:start+n] lies inside one of the
+// allowed words
+func (f *NameFilter) allows(letters []rune, start, n int) bool {
+	for _, word := range f.allowed {
+		for p := max(0, start+n-len(word)); p <= start && p+len(word) <= len(letters); p++ {
+			if runesEqual(letters[p:p+len(word)], word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runesEqual reports whether two rune slices are equal
+func runesEqual(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test offensive names are caught through leetspeak and separators
+func TestNameFilterCheck(t *testing.T) {
+	// Allow any character so symbols used as letters reach the word filter
+	reject, _ := NewNameFilter(NameConfig{Charset: `\S `})
+	mask, _ := NewNameFilter(NameConfig{Charset: `\S `, ProfanityAction: ProfanityMask})
+
+	tests := []struct {
+		name       string
+		input      string
+		wantOK     bool
+		wantMasked string
+	}{
+		{"clean", "Kiro Fan", true, "Kiro Fan"},
+		{"clean with digits", "Player123", true, "Player123"},
+		{"plain", "shit", false, "****"},
+		{"mixed case", "xXShitXx", false, "xX****Xx"},
+		{"leetspeak", "sh1t_happens", false, "****_happens"},
+		{"separators", "f.u.c.k", false, "*******"},
+		{"repeated letters", "fuuuuck you", false, "******* you"},
+		{"bang for i", "b!tch", false, "*****"},
+		{"symbols", "@$$hole", false, "*******"},
+		{"cyrillic lookalikes", "сunt", false, "****"},
+		{"run together", "FuckYou", false, "****You"},
+		{"end of a word", "BigFuck", false, "Big****"},
+		{"across words", "Cass Holer", true, "Cass Holer"},
+		{"across words at the start", "Miss Hitomi", true, "Miss Hitomi"},
+		{"allowed word", "Shiitake", true, "Shiitake"},
+		{"inside an allowed word", "xXTherapistXx", true, "xXTherapistXx"},
+		{"outside an allowed word", "Rapist", false, "******"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := reject.Check(tt.input); (err == nil) != tt.wantOK {
+				t.Errorf("Expected ok=%v for %q, got %v", tt.wantOK, tt.input, err)
+			}
+			if masked, err := mask.Check(tt.input); err != nil || masked != tt.wantMasked {
+				t.Errorf("Expected %q to be masked as %q, got %q", tt.input, tt.wantMasked, masked)
+			}
+		})
+	}
+}
+
+// Test names are cleaned up and held to length and charset limits
+func TestNameFilterLimits(t *testing.T) {
+	filter, _ := NewNameFilter(NameConfig{MaxLength: 10})
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{"plain", "Kiro", "Kiro", nil},
+		{"long accented name", "Zoë Ångström", "", ErrNameTooLong},
+		{"short accents", "Zoë", "Zoë", nil},
+		{"whitespace collapsed", "  Mario \t\n Bros  ", "Mario Bros", nil},
+		{"control characters stripped", "Ki\x00ro\u200b", "Kiro", nil},
+		{"only whitespace", " \n\t ", "", ErrNameEmpty},
+		{"too long", strings.Repeat("a", 11), "", ErrNameTooLong},
+		{"newline flood", strings.Repeat("\n", 1<<20) + "x", "x", nil},
+		{"markup", "<script>", "", ErrNameCharset},
+		{"emoji", "Kiro 🚀", "", ErrNameCharset},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filter.Check(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := NewNameFilter(NameConfig{Charset: "a-"}); err != nil {
+		t.Errorf("Expected charset ending in a dash to compile, got %v", err)
+	}
+	if _, err := NewNameFilter(NameConfig{Charset: `\p{Nope}`}); err == nil {
+		t.Error("Expected error for invalid charset")
+	}
+}
+
+// Test names are normalized to NFC and lookalike letters from other
+// scripts are collapsed so they cannot impersonate Latin names
+func TestNameFilterHomoglyphs(t *testing.T) {
+	filter, _ := NewNameFilter(NameConfig{})
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"latin", "Player1", "Player1"},
+		{"cyrillic P", "\u0420layer1", "Player1"},
+		{"cyrillic vowels", "Pl\u0430y\u0435r1", "Player1"},
+		{"greek capitals", "\u039a\u0399RO fan", "KIRO fan"},
+		{"decomposed accent", "Zoe\u0308", "Zo\u00eb"},
+		{"all cyrillic kept", "\u0421\u0435\u0440\u0433\u0435\u0439", "\u0421\u0435\u0440\u0433\u0435\u0439"},
+		{"all greek kept", "\u039d\u03af\u03ba\u03b7", "\u039d\u03af\u03ba\u03b7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filter.Check(tt.input)
+			if err != nil {
+				t.Fatalf("Expected %q to be allowed, got %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// Test the wordlist can be replaced and extended from a file
+func TestNameFilterWordlist(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "words.txt")
+	os.WriteFile(filename, []byte("# extra words\nbadger\n\n"), 0644)
+
+	filter, err := NewNameFilter(NameConfig{BlockedWords: []string{"goomba"}, BlockedWordsFile: filename})
+	if err != nil {
+		t.Fatalf("Failed to create filter: %v", err)
+	}
+
+	for name, wantOK := range map[string]bool{"G00mba": false, "Badg3r": false, "shit": true} {
+		if _, err := filter.Check(name); (err == nil) != wantOK {
+			t.Errorf("Expected ok=%v for %q, got %v", wantOK, name, err)
+		}
+	}
+
+	if _, err := NewNameFilter(NameConfig{BlockedWordsFile: filepath.Join(t.TempDir(), "missing.txt")}); err == nil {
+		t.Error("Expected error for missing wordlist file")
+	}
+}
+
+// Test SubmitScore rejects offensive names
+func TestSubmitScoreNameFilter(t *testing.T) {
+	filter, _ := NewNameFilter(NameConfig{})
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithNameFilter(filter))
+	t.Cleanup(store.Close)
+
+	body, _ := json.Marshal(map[string]interface{}{"score": 100, "playerName": "5h1t"})
+	req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.SubmitScore(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// Test SubmitScore refuses names that look like another player's,
+// whatever script they are written in
+func TestSubmitScoreLookalikeName(t *testing.T) {
+	filter, _ := NewNameFilter(NameConfig{})
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 100, "Kiro")
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithNameFilter(filter))
+	t.Cleanup(store.Close)
+
+	tests := []struct {
+		name       string
+		playerName string
+		wantStatus int
+	}{
+		{"same name", "Kiro", http.StatusCreated},
+		{"all cyrillic", "\u041a\u0456\u0433\u043e", http.StatusConflict},
+		{"capital I", "KIro", http.StatusConflict},
+		{"different name", "Mario", http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]interface{}{"score": 100, "playerName": tt.playerName})
+			req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			handler.SubmitScore(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d for %q, got %d: %s", tt.wantStatus, tt.playerName, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// Test names that look alike share a skeleton
+func TestNameSkeleton(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Player1", "player1", true},
+		{"Player1", "PIayer1", true},
+		{"Player1", "Playerl", true},
+		{"Player1", "Рlayer1", true},
+		{"Player1", "Ｐlayer1", true},
+		{"Kiro", "Кіго", true},
+		{"Bob0", "BobO", true},
+		{"modern", "modem", true},
+		{"Player1", "Player2", false},
+		{"Kiro", "Mario", false},
+	}
+
+	for _, tt := range tests {
+		if got := NameSkeleton(tt.a) == NameSkeleton(tt.b); got != tt.same {
+			t.Errorf("Expected same skeleton for %q and %q to be %v", tt.a, tt.b, tt.same)
+		}
+	}
+}
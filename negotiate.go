@@ -0,0 +1,95 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "super-kiro-world/leaderboardpb"
+)
+
+// ProtobufContentType is the media type of protobuf-encoded responses,
+// whose messages are defined in leaderboardpb/leaderboard.proto
+const ProtobufContentType = "application/x-protobuf"
+
+// negotiateContentType picks the offered media type the Accept header
+// prefers, falling back to the first offer when the header is missing or
+// accepts none of them. Ties go to the earlier offer.
+func negotiateContentType(accept string, offers ...string) string {
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality an Accept header gives a media type,
+// from its most specific matching range, or zero if none matches
+func acceptQuality(accept, mediaType string) float64 {
+	quality, specificity := 0.0, -1
+	kind, _, _ := strings.Cut(mediaType, "/")
+	for _, part := range strings.Split(accept, ",") {
+		accepted, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		matched := -1
+		switch accepted {
+		case mediaType:
+			matched = 2
+		case kind + "/*":
+			matched = 1
+		case "*/*":
+			matched = 0
+		}
+		if matched <= specificity {
+			continue
+		}
+		q := 1.0
+		if qStr, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qStr, 64); err != nil {
+				continue
+			}
+		}
+		quality, specificity = q, matched
+	}
+	return quality
+}
+
+// writeProto encodes message as the response body
+func writeProto(w http.ResponseWriter, r *http.Request, message proto.Message) {
+	body, err := proto.Marshal(message)
+	if err != nil {
+		httpError(w, r, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", ProtobufContentType)
+	w.Write(body)
+}
+
+// pageProto converts a page of the board to its protobuf message
+func pageProto(page LeaderboardPage) *pb.LeaderboardPage {
+	entries := make([]*pb.ScoreEntry, len(page.Entries))
+	for i, entry := range page.Entries {
+		entries[i] = entryProto(entry, 0)
+	}
+	var resetsAt *timestamppb.Timestamp
+	if page.ResetsAt != nil {
+		resetsAt = timestamppb.New(*page.ResetsAt)
+	}
+	return &pb.LeaderboardPage{
+		Entries:      entries,
+		Offset:       int32(page.Offset),
+		Limit:        int32(page.Limit),
+		LimitClamped: page.LimitClamped,
+		Total:        int32(page.Total),
+		NextCursor:   page.NextCursor,
+		ResetsAt:     resetsAt,
+	}
+}
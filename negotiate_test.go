@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "super-kiro-world/leaderboardpb"
+)
+
+// Test the response encoding follows the Accept header, defaulting to JSON
+func TestNegotiateContentType(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"text/html", "application/json"},
+		{"application/json", "application/json"},
+		{"application/x-protobuf", ProtobufContentType},
+		{"application/x-protobuf, application/json;q=0.5", ProtobufContentType},
+		{"application/json, application/x-protobuf", "application/json"},
+		{"application/json;q=0.1, application/*", ProtobufContentType},
+		{"application/x-protobuf;q=0, */*", "application/json"},
+		{"application/x-protobuf;q=bad", "application/json"},
+	}
+
+	for _, tt := range tests {
+		if got := negotiateContentType(tt.accept, "application/json", ProtobufContentType); got != tt.want {
+			t.Errorf("Accept %q: expected %s, got %s", tt.accept, tt.want, got)
+		}
+	}
+}
+
+// Test GET /api/leaderboard encodes the page as protobuf when asked to
+func TestGetLeaderboardProtobuf(t *testing.T) {
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 300, "Mario")
+	store.AddScore(context.Background(), 200, "Luigi")
+	store.AddScore(context.Background(), 100, "Toad")
+	handler := NewLeaderboardHandler(store)
+
+	req := httptest.NewRequest("GET", "/api/leaderboard?limit=2", nil)
+	req.Header.Set("Accept", ProtobufContentType)
+	w := httptest.NewRecorder()
+	handler.GetLeaderboard(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ProtobufContentType {
+		t.Errorf("Expected Content-Type %s, got %s", ProtobufContentType, ct)
+	}
+	if vary := w.Header().Get("Vary"); vary != "Accept" {
+		t.Errorf("Expected Vary: Accept, got %q", vary)
+	}
+
+	var page pb.LeaderboardPage
+	if err := proto.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(page.Entries) != 2 || page.Entries[0].PlayerName != "Mario" || page.Entries[1].Score != 200 {
+		t.Errorf("Expected Mario then Luigi, got %v", page.Entries)
+	}
+	if page.Total != 3 || page.Limit != 2 || page.NextCursor == "" {
+		t.Errorf("Expected total 3, limit 2 and a next cursor, got %v", &page)
+	}
+
+	// The JSON and protobuf responses are cached apart
+	protoETag := w.Header().Get("ETag")
+	w = httptest.NewRecorder()
+	handler.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard?limit=2", nil))
+	var entries []ScoreEntry
+	if err := json.NewDecoder(w.Body).Decode(&entries); err != nil || len(entries) != 2 {
+		t.Fatalf("Expected a JSON list by default, got %v, %v", entries, err)
+	}
+	if w.Header().Get("ETag") == protoETag {
+		t.Errorf("Expected the JSON response to have a different ETag, both got %s", protoETag)
+	}
+}
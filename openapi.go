@@ -0,0 +1,573 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiParam is a query, path or header parameter of an API operation
+type apiParam struct {
+	Name        string
+	In          string
+	Type        string
+	Description string
+	Required    bool
+}
+
+// Parameters shared by several operations
+var (
+	limitParam       = apiParam{Name: "limit", In: "query", Type: "integer", Description: "Number of entries; defaults to the board's default limit and is capped at its maximum"}
+	offsetParam      = apiParam{Name: "offset", In: "query", Type: "integer", Description: "Number of entries to skip"}
+	playerTokenParam = apiParam{Name: PlayerTokenHeader, In: "header", Type: "string", Description: "Token of the player's claimed name"}
+	liveTokenParam   = apiParam{Name: "token", In: "query", Type: "string", Description: "Token of the player's claimed name, for browsers, which can't set WebSocket headers"}
+)
+
+// apiOneOf is a response that is one of several types, depending on the
+// request
+type apiOneOf []interface{}
+
+// apiRoute is an operation of the public HTTP API. The OpenAPI document is
+// generated from these, with schemas derived from the Go types the
+// handlers decode and encode, so a field added to a type shows up in the
+// document without further changes.
+type apiRoute struct {
+	Method  string
+	Path    string
+	Tag     string
+	Summary string
+	Params  []apiParam
+	// Request is a value of the type of the JSON request body, nil if the
+	// operation takes none
+	Request interface{}
+	// RequestType is the content type of a non-JSON request body
+	RequestType string
+	// Response is a value of the type of the JSON response body
+	Response interface{}
+	// ResponseType is the content type of a non-JSON response body
+	ResponseType string
+	// Status is the status of a successful response, 200 if unset
+	Status int
+	// PerBoard is set for operations that named boards also serve under
+	// /api/boards/{board}
+	PerBoard bool
+}
+
+// apiRoutes lists the operations of the public HTTP API
+var apiRoutes = []apiRoute{
+	{
+		Method: "GET", Path: "/api/leaderboard", Tag: "Leaderboard", PerBoard: true,
+		Summary: "List the board, best first. Send Accept: application/x-protobuf or application/msgpack for a more compact encoding.",
+		Params: []apiParam{
+			limitParam, offsetParam,
+			{Name: "cursor", In: "query", Type: "string", Description: "nextCursor of the previous page, for stable paging"},
+			{Name: "minScore", In: "query", Type: "integer", Description: "Only runs scoring at least this"},
+			{Name: "since", In: "query", Type: "string", Description: "Only runs submitted at or after this RFC3339 time"},
+			{Name: "until", In: "query", Type: "string", Description: "Only runs submitted before this RFC3339 time"},
+			{Name: "period", In: "query", Type: "string", Description: "alltime, daily, weekly or monthly"},
+			{Name: "sort", In: "query", Type: "string", Description: "score, timestamp, name or decayed"},
+			{Name: "order", In: "query", Type: "string", Description: "asc or desc"},
+			{Name: "difficulty", In: "query", Type: "string"},
+			{Name: "character", In: "query", Type: "string"},
+			{Name: "country", In: "query", Type: "string", Description: "ISO 3166-1 alpha-2 code"},
+			{Name: "scope", In: "query", Type: "string", Description: "global, or friends for the player's friends board"},
+			{Name: "verified", In: "query", Type: "boolean", Description: "Only runs whose replay reproduced the score"},
+			{Name: "envelope", In: "query", Type: "boolean", Description: "Wrap the entries in a LeaderboardPage"},
+			playerTokenParam,
+		},
+		Response: apiOneOf{[]ScoreEntry{}, LeaderboardPage{}},
+	},
+	{
+		Method: "POST", Path: "/api/leaderboard", Tag: "Leaderboard", PerBoard: true,
+		Summary: "Submit a run. The body can also be sent as application/msgpack.",
+		Params: []apiParam{
+			playerTokenParam,
+			{Name: IdempotencyKeyHeader, In: "header", Type: "string", Description: "Retries with the same key get the original response"},
+		},
+		Request: ScoreSubmission{}, Response: SubmissionResult{}, Status: http.StatusCreated,
+	},
+	{
+		Method: "GET", Path: "/api/leaderboard/{id}", Tag: "Leaderboard",
+		Summary:  "Get an entry and its current rank",
+		Response: RankedEntry{},
+	},
+	{
+		Method: "GET", Path: "/api/leaderboard/around", Tag: "Leaderboard", PerBoard: true,
+		Summary: "List the entries around a player's best",
+		Params: []apiParam{
+			{Name: "player", In: "query", Type: "string", Required: true},
+			{Name: "window", In: "query", Type: "integer", Description: "Entries on each side; default 5"},
+		},
+		Response: []RankedEntry{},
+	},
+	{
+		Method: "GET", Path: "/api/leaderboard/stats", Tag: "Leaderboard", PerBoard: true,
+		Summary:  "Get score statistics and daily submission counts",
+		Params:   []apiParam{{Name: "days", In: "query", Type: "integer", Description: "Days of submission counts; default 30"}},
+		Response: BoardStats{},
+	},
+	{
+		Method: "GET", Path: "/api/leaderboard/search", Tag: "Leaderboard", PerBoard: true,
+		Summary:  "Search the board by player name",
+		Params:   []apiParam{{Name: "q", In: "query", Type: "string", Required: true}, limitParam, offsetParam},
+		Response: []RankedEntry{},
+	},
+	{
+		Method: "GET", Path: "/api/leaderboard/countries", Tag: "Leaderboard", PerBoard: true,
+		Summary:  "List the countries on the board with their best runs",
+		Response: []CountryStanding{},
+	},
+	{
+		Method: "GET", Path: "/api/leaderboard/export", Tag: "Leaderboard", PerBoard: true,
+		Summary:      "Download the whole board",
+		Params:       []apiParam{{Name: "format", In: "query", Type: "string", Description: "csv (default) or ndjson"}},
+		ResponseType: "text/csv",
+	},
+	{
+		Method: "GET", Path: "/api/leaderboard/changes", Tag: "Leaderboard", PerBoard: true,
+		Summary: "List the entries added since a board version, waiting for some if asked",
+		Params: []apiParam{
+			{Name: "since", In: "query", Type: "string", Required: true, Description: "X-Board-Version of a previous response"},
+			{Name: "wait", In: "query", Type: "integer", Description: "Seconds to wait for changes, up to 30"},
+		},
+		Response: BoardChanges{},
+	},
+	{
+		Method: "GET", Path: "/api/leaderboard/{id}/replay/url", Tag: "Replays",
+		Summary:  "Get a short-lived link to an entry's replay",
+		Response: ReplayLink{},
+	},
+	{
+		Method: "GET", Path: "/api/leaderboard/{id}/replay", Tag: "Replays",
+		Summary: "Download an entry's compressed input replay",
+		Params: []apiParam{
+			{Name: "expires", In: "query", Type: "string", Required: true},
+			{Name: "sig", In: "query", Type: "string", Required: true},
+		},
+		ResponseType: "application/gzip",
+	},
+	{
+		Method: "PUT", Path: "/api/leaderboard/{id}/replay", Tag: "Replays",
+		Summary:     "Attach a replay shortly after the run was submitted",
+		Params:      []apiParam{{Name: ReplayTokenHeader, In: "header", Type: "string", Required: true, Description: "replayUploadToken returned by the submission"}},
+		RequestType: "application/gzip", Response: ScoreEntry{},
+	},
+	{
+		Method: "GET", Path: "/api/leaderboard/{board}/presence", Tag: "Leaderboard",
+		Summary:  "Get the number of clients watching a board",
+		Response: Presence{},
+	},
+	{
+		Method: "POST", Path: "/api/leaderboard/{board}/presence", Tag: "Leaderboard",
+		Summary: "Record that a client is watching a board",
+		Request: PresenceHeartbeat{}, Response: Presence{},
+	},
+	{
+		Method: "GET", Path: "/api/live", Tag: "Live",
+		Summary: "Open a WebSocket receiving the top of subscribed boards whenever they change",
+		Params:  []apiParam{playerTokenParam, liveTokenParam},
+		Status:  http.StatusSwitchingProtocols,
+	},
+	{
+		Method: "GET", Path: "/api/lobbies/{lobby}", Tag: "Live",
+		Summary: "Open a WebSocket relaying chat between the players in a lobby",
+		Params:  []apiParam{playerTokenParam, liveTokenParam},
+		Status:  http.StatusSwitchingProtocols,
+	},
+	{
+		Method: "GET", Path: "/api/lobbies/{lobby}/presence", Tag: "Live",
+		Summary:  "Get the number of players in a lobby",
+		Response: Presence{},
+	},
+	{
+		Method: "GET", Path: "/api/percentile", Tag: "Leaderboard",
+		Summary:  "Get the share of the board a score beats",
+		Params:   []apiParam{{Name: "score", In: "query", Type: "integer", Required: true}},
+		Response: Percentile{},
+	},
+	{
+		Method: "GET", Path: "/api/players/{name}", Tag: "Players",
+		Summary:  "Get a player's profile",
+		Response: PlayerProfile{},
+	},
+	{
+		Method: "GET", Path: "/api/players/{name}/export", Tag: "Players",
+		Summary:  "Download every entry of a player",
+		Params:   []apiParam{{Name: "format", In: "query", Type: "string", Description: "json (default) or csv"}},
+		Response: PlayerExport{},
+	},
+	{
+		Method: "POST", Path: "/api/names/claim", Tag: "Players",
+		Summary: "Claim a player name, returning the token that proves ownership",
+		Params: []apiParam{
+			{Name: PlayerTokenHeader, In: "header", Type: "string", Description: "Secret the name's existing scores were submitted with"},
+		},
+		Request: NameClaimRequest{}, Response: NameClaimResponse{}, Status: http.StatusCreated,
+	},
+	{
+		Method: "GET", Path: "/api/friends", Tag: "Players",
+		Summary:  "List the player's friends",
+		Params:   []apiParam{withRequired(playerTokenParam)},
+		Response: FriendList{},
+	},
+	{
+		Method: "POST", Path: "/api/friends", Tag: "Players",
+		Summary: "Add a friend",
+		Params:  []apiParam{withRequired(playerTokenParam)},
+		Request: FriendRequest{}, Response: FriendList{},
+	},
+	{
+		Method: "DELETE", Path: "/api/friends/{name}", Tag: "Players",
+		Summary:  "Remove a friend",
+		Params:   []apiParam{withRequired(playerTokenParam)},
+		Response: FriendList{},
+	},
+	{
+		Method: "GET", Path: "/api/ghosts", Tag: "Ghosts",
+		Summary: "List the ghost tracks of the top runs of a level",
+		Params: []apiParam{
+			{Name: "level", In: "query", Type: "integer", Required: true},
+			{Name: "limit", In: "query", Type: "integer"},
+		},
+		Response: []Ghost{},
+	},
+	{
+		Method: "GET", Path: "/api/boards", Tag: "Boards",
+		Summary:  "List the IDs of the named boards",
+		Response: []string{},
+	},
+	{
+		Method: "GET", Path: "/api/seasons", Tag: "Seasons",
+		Summary:  "List the seasons, newest first",
+		Response: []SeasonListing{},
+	},
+	{
+		Method: "GET", Path: "/api/seasons/{id}/leaderboard", Tag: "Seasons",
+		Summary:  "List a season's board, with the same options as GET /api/leaderboard",
+		Params:   []apiParam{limitParam, offsetParam},
+		Response: []ScoreEntry{},
+	},
+	{
+		Method: "POST", Path: "/api/graphql", Tag: "GraphQL",
+		Summary: "Run a GraphQL query or mutation over the boards",
+		Request: graphqlRequest{}, Response: map[string]interface{}{},
+	},
+	{
+		Method: "GET", Path: "/api/health", Tag: "Health",
+		Summary:  "Check the server can save scores on every board",
+		Response: HealthStatus{},
+	},
+	{
+		Method: "GET", Path: "/metrics", Tag: "Health",
+		Summary:      "Get the server's metrics in the Prometheus text format",
+		ResponseType: "text/plain",
+	},
+	{
+		Method: "GET", Path: "/archives/{board}", Tag: "Archives",
+		Summary:  "List the published snapshots of a board, newest first",
+		Response: []ArchiveListing{},
+	},
+	{
+		Method: "GET", Path: "/archives/{board}/{file}", Tag: "Archives",
+		Summary:  "Get a published snapshot, named {date}.json",
+		Response: Snapshot{},
+	},
+}
+
+// withRequired returns a copy of param that is required
+func withRequired(param apiParam) apiParam {
+	param.Required = true
+	return param
+}
+
+// openAPIDocument builds the OpenAPI 3 document of routes
+func openAPIDocument(routes []apiRoute) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]map[string]interface{}{}
+
+	for _, route := range routes {
+		paths[route.Path] = addOperation(paths[route.Path], route, nil, schemas)
+		if route.PerBoard {
+			path := "/api/boards/{board}" + strings.TrimPrefix(route.Path, "/api")
+			board := apiParam{Name: "board", In: "path", Type: "string", Required: true, Description: "ID of a named board"}
+			paths[path] = addOperation(paths[path], route, &board, schemas)
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Super Kiro World Leaderboard API",
+			"version":     "1.0.0",
+			"description": "Scores, players and boards of Super Kiro World. Errors are RFC 7807 problem details.",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+// addOperation adds the operation of route to the operations of its path,
+// with an extra path parameter if given
+func addOperation(operations map[string]interface{}, route apiRoute, extra *apiParam, schemas map[string]interface{}) map[string]interface{} {
+	if operations == nil {
+		operations = map[string]interface{}{}
+	}
+
+	params := []interface{}{}
+	if extra != nil {
+		params = append(params, paramObject(*extra))
+	}
+	for _, name := range pathParams(route.Path) {
+		params = append(params, paramObject(apiParam{Name: name, In: "path", Type: "string", Required: true}))
+	}
+	for _, param := range route.Params {
+		params = append(params, paramObject(param))
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := map[string]interface{}{"description": http.StatusText(status)}
+	switch {
+	case route.Response != nil:
+		response["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schemaOf(route.Response, schemas)},
+		}
+	case route.ResponseType != "":
+		response["content"] = map[string]interface{}{
+			route.ResponseType: map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}},
+		}
+	}
+
+	operation := map[string]interface{}{
+		"summary":    route.Summary,
+		"tags":       []string{route.Tag},
+		"parameters": params,
+		"responses": map[string]interface{}{
+			strconv.Itoa(status): response,
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content": map[string]interface{}{
+					ProblemContentType: map[string]interface{}{"schema": schemaOf(Problem{}, schemas)},
+				},
+			},
+		},
+	}
+	switch {
+	case route.Request != nil:
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemaOf(route.Request, schemas)},
+			},
+		}
+	case route.RequestType != "":
+		operation["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				route.RequestType: map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}},
+			},
+		}
+	}
+
+	operations[strings.ToLower(route.Method)] = operation
+	return operations
+}
+
+// pathParams returns the names of the {parameters} of a path
+func pathParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			names = append(names, strings.Trim(segment, "{}"))
+		}
+	}
+	return names
+}
+
+// paramObject is the OpenAPI parameter object of param
+func paramObject(param apiParam) map[string]interface{} {
+	object := map[string]interface{}{
+		"name":     param.Name,
+		"in":       param.In,
+		"required": param.Required,
+		"schema":   map[string]interface{}{"type": param.Type},
+	}
+	if param.Description != "" {
+		object["description"] = param.Description
+	}
+	return object
+}
+
+// schemaOf returns the schema of a value's type, adding the named structs
+// it uses to schemas
+func schemaOf(value interface{}, schemas map[string]interface{}) map[string]interface{} {
+	if alternatives, ok := value.(apiOneOf); ok {
+		oneOf := make([]interface{}, len(alternatives))
+		for i, alternative := range alternatives {
+			oneOf[i] = schemaOf(alternative, schemas)
+		}
+		return map[string]interface{}{"oneOf": oneOf}
+	}
+	return typeSchema(reflect.TypeOf(value), schemas)
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// typeSchema returns the schema of how encoding/json encodes t. Named
+// structs are referenced from schemas.
+func typeSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{"description": "Any JSON value"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema := map[string]interface{}{"type": "integer"}
+		if t.Size() == 8 {
+			schema["format"] = "int64"
+		}
+		return schema
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), schemas)}
+	case reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), schemas), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := t.Name()
+		if name == "" {
+			return structSchema(t, schemas)
+		}
+		name = strings.ToUpper(name[:1]) + name[1:]
+		if _, ok := schemas[name]; !ok {
+			// Reserve the name first, for types that refer to themselves
+			schemas[name] = nil
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema returns the object schema of a struct, with the fields of
+// untagged embedded structs inlined as encoding/json does. Fields without
+// omitempty are always present, so they are required, unless they come
+// from an embedded pointer that can be nil.
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	var addFields func(t reflect.Type, optional bool)
+	addFields = func(t reflect.Type, optional bool) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, options, _ := strings.Cut(tag, ",")
+			if field.Anonymous && name == "" {
+				embedded, pointer := field.Type, field.Type.Kind() == reflect.Pointer
+				if pointer {
+					embedded = embedded.Elem()
+				}
+				if embedded.Kind() == reflect.Struct {
+					addFields(embedded, optional || pointer)
+					continue
+				}
+			}
+			if !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = typeSchema(field.Type, schemas)
+			if !optional && !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+				required = append(required, name)
+			}
+		}
+	}
+	addFields(t, false)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// APIDocsHandler serves the OpenAPI document of the HTTP API and a Swagger
+// UI page to browse it
+type APIDocsHandler struct {
+	spec []byte
+}
+
+// NewAPIDocsHandler creates an APIDocsHandler for the public HTTP API
+func NewAPIDocsHandler() *APIDocsHandler {
+	spec, err := json.MarshalIndent(openAPIDocument(apiRoutes), "", "  ")
+	if err != nil {
+		panic("openapi: " + err.Error())
+	}
+	return &APIDocsHandler{spec: spec}
+}
+
+// ServeSpec handles GET /api/openapi.json
+func (h *APIDocsHandler) ServeSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	w.Write(h.spec)
+}
+
+// apiDocsPage is the Swagger UI page, which loads the document from
+// /api/openapi.json. Swagger UI is pinned to an exact release so the page
+// doesn't change under us.
+const apiDocsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Super Kiro World API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5.17.14/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/api/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+// ServeDocs handles GET /api/docs
+func (h *APIDocsHandler) ServeDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	w.Write([]byte(apiDocsPage))
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	gotoken "go/token"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Test the OpenAPI document lists the API and every schema it refers to
+func TestOpenAPIDocument(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewAPIDocsHandler().ServeSpec(w, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var doc struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage        `json:"paths"`
+		Components struct{ Schemas map[string]json.RawMessage } `json:"components"`
+	}
+	body := w.Body.String()
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatalf("Failed to decode document: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("Expected OpenAPI 3, got %q", doc.OpenAPI)
+	}
+
+	tests := []struct {
+		path   string
+		method string
+	}{
+		{"/api/leaderboard", "get"},
+		{"/api/leaderboard", "post"},
+		{"/api/leaderboard/{id}", "get"},
+		{"/api/boards/{board}/leaderboard", "post"},
+		{"/api/boards/{board}/leaderboard/changes", "get"},
+		{"/api/players/{name}", "get"},
+		{"/api/friends/{name}", "delete"},
+	}
+	for _, tt := range tests {
+		if _, ok := doc.Paths[tt.path][tt.method]; !ok {
+			t.Errorf("Expected %s %s to be documented", strings.ToUpper(tt.method), tt.path)
+		}
+	}
+
+	for _, ref := range strings.Split(body, `"$ref": "#/components/schemas/`)[1:] {
+		name, _, _ := strings.Cut(ref, `"`)
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("Expected a schema for %s", name)
+		}
+	}
+}
+
+// Test every public route main registers is documented, and every
+// documented operation is registered. Routes behind auth.Require are admin
+// routes, which the document leaves out.
+func TestOpenAPIRoutes(t *testing.T) {
+	file, err := parser.ParseFile(gotoken.NewFileSet(), "server.go", nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse server.go: %v", err)
+	}
+
+	registered := map[string]bool{"GET /api/boards": true}
+	for _, route := range boardEndpoints {
+		registered[route.pattern] = true
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 {
+			return true
+		}
+		if fun, ok := call.Fun.(*ast.SelectorExpr); !ok || (fun.Sel.Name != "HandleFunc" && fun.Sel.Name != "Handle") {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok {
+			return true
+		}
+		if inner, ok := call.Args[1].(*ast.CallExpr); ok {
+			if fun, ok := inner.Fun.(*ast.SelectorExpr); ok && fun.Sel.Name == "Require" {
+				return true
+			}
+		}
+		pattern, _ := strconv.Unquote(lit.Value)
+		if strings.Contains(pattern, " ") {
+			registered[pattern] = true
+		}
+		return true
+	})
+	delete(registered, "GET /api/openapi.json")
+	delete(registered, "GET /api/docs")
+
+	documented := map[string]bool{}
+	for _, route := range apiRoutes {
+		documented[route.Method+" "+route.Path] = true
+		if route.PerBoard {
+			documented[route.Method+" /api/boards/{board}"+strings.TrimPrefix(route.Path, "/api")] = true
+		}
+	}
+
+	for pattern := range registered {
+		if !documented[pattern] {
+			t.Errorf("Expected %s to be documented", pattern)
+		}
+	}
+	for pattern := range documented {
+		if !registered[pattern] {
+			t.Errorf("Expected documented %s to be registered", pattern)
+		}
+	}
+}
+
+// Test the schemas follow the JSON encoding of their types
+func TestOpenAPISchemas(t *testing.T) {
+	schemas := map[string]interface{}{}
+	typeSchema(reflect.TypeOf(SubmissionResult{}), schemas)
+	result := schemas["SubmissionResult"].(map[string]interface{})
+	properties := result["properties"].(map[string]interface{})
+
+	// Embedded entries and standings are inlined, as encoding/json does
+	for _, name := range []string{"id", "playerName", "rank", "personalBest", "previousBest"} {
+		if _, ok := properties[name]; !ok {
+			t.Errorf("Expected property %s, got %v", name, properties)
+		}
+	}
+
+	// Fields that are always encoded are required, and optional ones,
+	// including those of a nil standing, are not
+	required := result["required"].([]string)
+	for name, want := range map[string]bool{"playerName": true, "personalBest": true, "difficulty": false, "rank": false} {
+		got := false
+		for _, r := range required {
+			got = got || r == name
+		}
+		if got != want {
+			t.Errorf("%s: expected required %v, got %v", name, want, got)
+		}
+	}
+
+	if got := properties["timestamp"]; !reflect.DeepEqual(got, map[string]interface{}{"type": "string", "format": "date-time"}) {
+		t.Errorf("Expected timestamp as a date-time string, got %v", got)
+	}
+
+	// Every key of an encoded response is documented
+	for _, route := range apiRoutes {
+		if route.Response == nil || reflect.TypeOf(route.Response).Kind() != reflect.Struct {
+			continue
+		}
+		encoded, _ := json.Marshal(route.Response)
+		var keys map[string]interface{}
+		json.Unmarshal(encoded, &keys)
+		schema := schemas[reflect.TypeOf(route.Response).Name()]
+		if schema == nil {
+			typeSchema(reflect.TypeOf(route.Response), schemas)
+			schema = schemas[reflect.TypeOf(route.Response).Name()]
+		}
+		properties := schema.(map[string]interface{})["properties"].(map[string]interface{})
+		for key := range keys {
+			if _, ok := properties[key]; !ok {
+				t.Errorf("%s %s: expected %s to be documented", route.Method, route.Path, key)
+			}
+		}
+	}
+}
+
+// Test the docs page loads the OpenAPI document
+func TestAPIDocsPage(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewAPIDocsHandler().ServeDocs(w, httptest.NewRequest("GET", "/api/docs", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "/api/openapi.json") {
+		t.Errorf("Expected a page loading /api/openapi.json, got %d: %s", w.Code, w.Body.String())
+	}
+}
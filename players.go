@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PlayerProfile summarizes a player's runs on the public board
+type PlayerProfile struct {
+	PlayerName   string    `json:"playerName"`
+	TotalRuns    int       `json:"totalRuns"`
+	BestScore    int       `json:"bestScore"`
+	AverageScore float64   `json:"averageScore"`
+	FirstPlayed  time.Time `json:"firstPlayed"`
+	LastPlayed   time.Time `json:"lastPlayed"`
+	// Rank is the current rank of the player's best run, zero if it is
+	// not on the board
+	Rank int `json:"rank,omitempty"`
+	// RankHistory holds the rank each run placed at when it was submitted,
+	// oldest first
+	RankHistory []RankPoint `json:"rankHistory"`
+}
+
+// RankPoint is the rank a run placed at when it was submitted
+type RankPoint struct {
+	EntryID string    `json:"entryId"`
+	Score   int       `json:"score"`
+	Rank    int       `json:"rank"`
+	At      time.Time `json:"at"`
+}
+
+// GetPlayerProfile aggregates a player's visible runs. The second return
+// value is false if the player has none.
+func (s *ScoreStore) GetPlayerProfile(ctx context.Context, playerName string) (PlayerProfile, bool) {
+	now := s.clock.Now()
+	st, snap := s.sortedBoard(now)
+	config := st.config
+	board := snap.board
+	rank := st.boardIndex(board, playerName, now)
+	for rank > 0 && board[rank-1].Score == board[rank].Score {
+		rank--
+	}
+	var visible []ScoreEntry
+	for _, entry := range st.entries {
+		if (ScoreQuery{}).matches(entry, now) {
+			visible = append(visible, entry)
+		}
+	}
+
+	sort.SliceStable(visible, func(i, j int) bool {
+		return visible[i].Timestamp.Before(visible[j].Timestamp)
+	})
+
+	profile := PlayerProfile{PlayerName: playerName, RankHistory: []RankPoint{}}
+	total := 0
+	for i, entry := range visible {
+		if entry.PlayerName != playerName {
+			continue
+		}
+		if profile.TotalRuns == 0 {
+			profile.FirstPlayed = entry.Timestamp
+		}
+		if profile.TotalRuns == 0 || config.beats(entry.Score, profile.BestScore) {
+			profile.BestScore = entry.Score
+		}
+		profile.LastPlayed = entry.Timestamp
+		profile.TotalRuns++
+		total += entry.Score
+
+		profile.RankHistory = append(profile.RankHistory, RankPoint{
+			EntryID: entry.ID,
+			Score:   entry.Score,
+			Rank:    rankAmong(visible[:i+1], entry, config),
+			At:      entry.Timestamp,
+		})
+	}
+	if profile.TotalRuns == 0 {
+		return PlayerProfile{}, false
+	}
+	profile.AverageScore = float64(total) / float64(profile.TotalRuns)
+	profile.Rank = rank + 1
+	return profile, true
+}
+
+// GetPlayerEntries returns every entry of a player, including hidden and
+// pending ones, in insertion order
+func (s *ScoreStore) GetPlayerEntries(ctx context.Context, playerName string) []ScoreEntry {
+	st := s.load()
+	positions := st.playerPositions(playerName)
+	entries := make([]ScoreEntry, 0, len(positions))
+	for _, i := range positions {
+		entries = append(entries, st.entries[i])
+	}
+	return entries
+}
+
+// GetLookalikeEntries returns every entry, including hidden and pending
+// ones, of every player whose name looks the same as playerName, as
+// decided by NameSkeleton, playerName's own entries included
+func (s *ScoreStore) GetLookalikeEntries(ctx context.Context, playerName string) []ScoreEntry {
+	st := s.load()
+	var entries []ScoreEntry
+	for _, name := range st.lookalikes(playerName) {
+		for _, i := range st.playerPositions(name) {
+			entries = append(entries, st.entries[i])
+		}
+	}
+	return entries
+}
+
+// DeletePlayer permanently removes every entry of a player and returns
+// them in insertion order
+func (s *ScoreStore) DeletePlayer(ctx context.Context, playerName string) []ScoreEntry {
+	return s.removeEntries(func(st *boardState) []string {
+		positions := st.playerPositions(playerName)
+		ids := make([]string, 0, len(positions))
+		for _, i := range positions {
+			ids = append(ids, st.entries[i].ID)
+		}
+		return ids
+	})
+}
+
+// boardIndex returns the position of the player's entry on board, a
+// snapshot of the state's public board at now, or -1 if they have none
+func (st *boardState) boardIndex(board []ScoreEntry, playerName string, now time.Time) int {
+	var best ScoreEntry
+	found := false
+	for _, i := range st.playerPositions(playerName) {
+		entry := st.entries[i]
+		if entry.listed(now) && (!found || st.config.before(entry, best)) {
+			best, found = entry, true
+		}
+	}
+	if !found {
+		return -1
+	}
+
+	// The player's best listed run is their place on the board
+	index := sort.Search(len(board), func(i int) bool {
+		return !st.config.before(board[i], best)
+	})
+	if index == len(board) || board[index].ID != best.ID {
+		return -1
+	}
+	return index
+}
+
+// rankAmong returns the rank entry would have among entries, where tied
+// entries share a rank. On best-per-player boards each other player counts
+// once.
+func rankAmong(entries []ScoreEntry, entry ScoreEntry, config BoardConfig) int {
+	ahead := make(map[string]bool)
+	rank := 1
+	for _, other := range entries {
+		if !config.outranks(other, entry) {
+			continue
+		}
+		if config.BestPerPlayer {
+			if other.PlayerName == entry.PlayerName || ahead[other.PlayerName] {
+				continue
+			}
+			ahead[other.PlayerName] = true
+		}
+		rank++
+	}
+	return rank
+}
+
+// SearchPlayers returns board entries whose player name contains query,
+// ignoring case, with their standings. Names starting with the query come
+// first, then other matches, each in board order. It also returns the
+// number of matches before offset and limit were applied.
+func (s *ScoreStore) SearchPlayers(ctx context.Context, query string, offset, limit int) ([]RankedEntry, int) {
+	board := s.QueryScores(ctx, ScoreQuery{})
+	query = strings.ToLower(query)
+	config := s.Config()
+
+	var prefixed, contained []RankedEntry
+	for i, entry := range board {
+		name := strings.ToLower(entry.PlayerName)
+		if !strings.Contains(name, query) {
+			continue
+		}
+		standing := config.standingAt(board, i)
+		ranked := RankedEntry{ScoreEntry: entry.public(), Standing: &standing}
+		if strings.HasPrefix(name, query) {
+			prefixed = append(prefixed, ranked)
+		} else {
+			contained = append(contained, ranked)
+		}
+	}
+
+	matches := append(prefixed, contained...)
+	total := len(matches)
+	matches = matches[min(offset, total):]
+	if limit > 0 && limit < len(matches) {
+		matches = matches[:limit]
+	}
+	if matches == nil {
+		matches = []RankedEntry{}
+	}
+	return matches, total
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test player profiles aggregate visible runs and the rank each placed at
+func TestGetPlayerProfile(t *testing.T) {
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 1000, "Leader")
+	first := store.AddScore(context.Background(), 200, "Kiro")
+	store.AddScore(context.Background(), 800, "RunnerUp")
+	second := store.AddScore(context.Background(), 900, "Kiro")
+	store.AddEntry(context.Background(), ScoreEntry{Score: 5000, PlayerName: "Kiro", Hidden: true})
+
+	profile, ok := store.GetPlayerProfile(context.Background(), "Kiro")
+	if !ok {
+		t.Fatal("Expected a profile for Kiro")
+	}
+	if profile.TotalRuns != 2 || profile.BestScore != 900 || profile.AverageScore != 550 {
+		t.Errorf("Expected 2 runs, best 900, average 550, got %+v", profile)
+	}
+	if !profile.FirstPlayed.Equal(first.Timestamp) || !profile.LastPlayed.Equal(second.Timestamp) {
+		t.Errorf("Expected first and last played to match the runs, got %v and %v", profile.FirstPlayed, profile.LastPlayed)
+	}
+	if profile.Rank != 2 {
+		t.Errorf("Expected current rank 2, got %d", profile.Rank)
+	}
+
+	wantHistory := []RankPoint{{EntryID: first.ID, Rank: 2}, {EntryID: second.ID, Rank: 2}}
+	if len(profile.RankHistory) != len(wantHistory) {
+		t.Fatalf("Expected %d rank history points, got %d", len(wantHistory), len(profile.RankHistory))
+	}
+	for i, point := range profile.RankHistory {
+		if point.EntryID != wantHistory[i].EntryID || point.Rank != wantHistory[i].Rank {
+			t.Errorf("Expected %s at rank %d, got %+v", wantHistory[i].EntryID, wantHistory[i].Rank, point)
+		}
+	}
+
+	handler := NewLeaderboardHandler(store)
+	tests := []struct {
+		name     string
+		player   string
+		wantCode int
+	}{
+		{"existing player", "Kiro", http.StatusOK},
+		{"unknown player", "Nobody", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetPlayerProfile(w, routedRequest(t, "GET /api/players/{name}", "/api/players/"+tt.player, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code == http.StatusOK {
+				var result PlayerProfile
+				json.NewDecoder(w.Body).Decode(&result)
+				if result.PlayerName != tt.player || result.TotalRuns != 2 {
+					t.Errorf("Expected profile of %s with 2 runs, got %+v", tt.player, result)
+				}
+			}
+		})
+	}
+}
+
+// Test player search matches names case-insensitively, prefixes first
+func TestSearchPlayers(t *testing.T) {
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 900, "MegaKiro")
+	store.AddScore(context.Background(), 800, "kiroFan")
+	store.AddScore(context.Background(), 700, "Byte")
+	store.AddScore(context.Background(), 600, "Kiro")
+	store.AddEntry(context.Background(), ScoreEntry{Score: 500, PlayerName: "KiroHidden", Hidden: true})
+	handler := NewLeaderboardHandler(store)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantNames []string
+		wantTotal string
+	}{
+		{"prefix before substring", "?q=KIRO", http.StatusOK, []string{"kiroFan", "Kiro", "MegaKiro"}, "3"},
+		{"paginated", "?q=kiro&offset=1&limit=1", http.StatusOK, []string{"Kiro"}, "3"},
+		{"no matches", "?q=zzz", http.StatusOK, []string{}, "0"},
+		{"empty query", "?q=", http.StatusBadRequest, nil, ""},
+		{"invalid offset", "?q=kiro&offset=-2", http.StatusBadRequest, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.SearchPlayers(w, httptest.NewRequest("GET", "/api/leaderboard/search"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			if total := w.Header().Get("X-Total-Count"); total != tt.wantTotal {
+				t.Errorf("Expected X-Total-Count %s, got %s", tt.wantTotal, total)
+			}
+
+			var entries []RankedEntry
+			json.NewDecoder(w.Body).Decode(&entries)
+			if len(entries) != len(tt.wantNames) {
+				t.Fatalf("Expected %d entries, got %d", len(tt.wantNames), len(entries))
+			}
+			for i, entry := range entries {
+				if entry.PlayerName != tt.wantNames[i] {
+					t.Errorf("Expected %s at %d, got %s", tt.wantNames[i], i, entry.PlayerName)
+				}
+				if entry.Standing == nil {
+					t.Errorf("Expected a rank for %s", entry.PlayerName)
+				}
+			}
+		})
+	}
+}
+
+// Test players export every entry of their claimed name as JSON or CSV,
+// and nobody else can
+func TestExportPlayer(t *testing.T) {
+	claims := NewNameClaims(filepath.Join(t.TempDir(), "claims.json"))
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store, WithNameClaims(claims))
+	token, _ := claims.Claim("Kiro")
+	other, _ := claims.Claim("Mario")
+
+	store.AddScore(context.Background(), 500, "Kiro")
+	store.AddEntry(context.Background(), ScoreEntry{Score: 900, PlayerName: "Kiro", Hidden: true, Metadata: json.RawMessage(`{"note":"=cmd"}`)})
+	store.AddScore(context.Background(), 700, "Mario")
+
+	tests := []struct {
+		name     string
+		path     string
+		token    string
+		wantCode int
+		wantType string
+	}{
+		{"json", "/api/players/Kiro/export", token, http.StatusOK, "application/json"},
+		{"csv", "/api/players/Kiro/export?format=csv", token, http.StatusOK, "text/csv; charset=utf-8"},
+		{"no token", "/api/players/Kiro/export", "", http.StatusUnauthorized, ""},
+		{"another player's token", "/api/players/Kiro/export", other, http.StatusUnauthorized, ""},
+		{"unknown format", "/api/players/Kiro/export?format=xml", token, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := routedRequest(t, "GET /api/players/{name}/export", tt.path, nil)
+			req.Header.Set(PlayerTokenHeader, tt.token)
+			w := httptest.NewRecorder()
+			handler.ExportPlayer(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Expected content type %q, got %q", tt.wantType, got)
+			}
+			if !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment") {
+				t.Errorf("Expected a download, got %q", w.Header().Get("Content-Disposition"))
+			}
+
+			if tt.wantType == "application/json" {
+				var export PlayerExport
+				json.NewDecoder(w.Body).Decode(&export)
+				if export.PlayerName != "Kiro" || len(export.Entries) != 2 {
+					t.Errorf("Expected Kiro's 2 entries, got %+v", export)
+				}
+				return
+			}
+
+			rows, err := csv.NewReader(w.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("Expected valid CSV, got %v", err)
+			}
+			if len(rows) != 3 || rows[0][0] != "id" || rows[2][1] != "Kiro" || rows[2][10] != "true" {
+				t.Errorf("Expected a header and Kiro's 2 entries, got %v", rows)
+			}
+			if metadata := rows[2][12]; metadata != `{"note":"=cmd"}` {
+				t.Errorf("Expected metadata as JSON, got %q", metadata)
+			}
+		})
+	}
+}
+
+// Test CSV cells that a spreadsheet would run as a formula are quoted
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Kiro", "Kiro"},
+		{"=HYPERLINK(\"x\")", "'=HYPERLINK(\"x\")"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := csvCell(tt.text); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// boardNamePattern restricts the board and lobby names presence is tracked for
+var boardNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// PresenceLimits caps how many clients a PresenceTracker tracks
+type PresenceLimits struct {
+	// Total caps the clients tracked across all boards
+	Total int
+	// PerBoard caps the clients tracked for one board
+	PerBoard int
+	// PerAddress caps the client IDs tracked for one remote address, so a
+	// single client can't inflate a count by inventing IDs
+	PerAddress int
+}
+
+// PresenceTracker counts clients watching each board. Clients announce
+// themselves with periodic heartbeats and stop counting once they miss
+// heartbeats for longer than the TTL. Expired clients are dropped by a
+// periodic prune, so heartbeats and counts take constant time.
+type PresenceTracker struct {
+	ttl       time.Duration
+	limits    PresenceLimits
+	viewers   map[string]map[string]viewer
+	total     int
+	addresses map[string]int
+	mu        sync.Mutex
+}
+
+// viewer is a tracked client of one board
+type viewer struct {
+	seen    time.Time
+	address string
+}
+
+// NewPresenceTracker creates a PresenceTracker that forgets clients after
+// ttl and tracks clients within limits
+func NewPresenceTracker(ttl time.Duration, limits PresenceLimits) *PresenceTracker {
+	return &PresenceTracker{
+		ttl:       ttl,
+		limits:    limits,
+		viewers:   make(map[string]map[string]viewer),
+		addresses: make(map[string]int),
+	}
+}
+
+// Start prunes expired clients in the background, four times per TTL
+func (p *PresenceTracker) Start() {
+	go func() {
+		ticker := time.NewTicker(p.ttl / 4)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			p.Prune(now)
+		}
+	}()
+}
+
+// Heartbeat records that a client at a remote address is watching a board
+// and returns the board's current viewer count. New clients are not
+// tracked once the tracker, the board or the address is full.
+func (p *PresenceTracker) Heartbeat(board, clientID, address string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	clients := p.viewers[board]
+	if v, known := clients[clientID]; known {
+		v.seen = now
+		clients[clientID] = v
+		return len(clients)
+	}
+	if p.total >= p.limits.Total || len(clients) >= p.limits.PerBoard || p.addresses[address] >= p.limits.PerAddress {
+		return len(clients)
+	}
+
+	if clients == nil {
+		clients = make(map[string]viewer)
+		p.viewers[board] = clients
+	}
+	clients[clientID] = viewer{seen: now, address: address}
+	p.total++
+	p.addresses[address]++
+	return len(clients)
+}
+
+// Count returns the number of clients watching a board. Clients that
+// stopped sending heartbeats count until the next prune.
+func (p *PresenceTracker) Count(board string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.viewers[board])
+}
+
+// Prune drops clients whose last heartbeat is older than the TTL
+func (p *PresenceTracker) Prune(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for board, clients := range p.viewers {
+		for id, v := range clients {
+			if now.Sub(v.seen) <= p.ttl {
+				continue
+			}
+			delete(clients, id)
+			p.total--
+			if p.addresses[v.address]--; p.addresses[v.address] <= 0 {
+				delete(p.addresses, v.address)
+			}
+		}
+		if len(clients) == 0 {
+			delete(p.viewers, board)
+		}
+	}
+}
+
+// Presence is the viewer count of a board or lobby
+type Presence struct {
+	Board    string `json:"board,omitempty"`
+	Lobby    string `json:"lobby,omitempty"`
+	Watching int    `json:"watching"`
+}
+
+// PresenceHeartbeat is the body of a presence heartbeat
+type PresenceHeartbeat struct {
+	ClientID string `json:"clientId"`
+}
+
+// PresenceHandler serves viewer counts of the boards of a BoardManager and
+// accepts heartbeats. Clients subscribed to a board or in a lobby over the
+// live WebSockets count as watching it.
+type PresenceHandler struct {
+	tracker *PresenceTracker
+	boards  *BoardManager
+	live    *LiveHub
+}
+
+// NewPresenceHandler creates a PresenceHandler for a tracker. live may be
+// nil.
+func NewPresenceHandler(tracker *PresenceTracker, boards *BoardManager, live *LiveHub) *PresenceHandler {
+	return &PresenceHandler{tracker: tracker, boards: boards, live: live}
+}
+
+// board returns the board named by the {board} path value, answering 400
+// or 404 if there is none
+func (h *PresenceHandler) board(w http.ResponseWriter, r *http.Request) (string, bool) {
+	board := r.PathValue("board")
+	if !boardNamePattern.MatchString(board) {
+		httpError(w, r, "Invalid board name", http.StatusBadRequest)
+		return "", false
+	}
+	if _, ok := h.boards.Board(board); !ok {
+		httpError(w, r, "Board not found", http.StatusNotFound)
+		return "", false
+	}
+	return board, true
+}
+
+// watching returns the number of clients watching a board
+func (h *PresenceHandler) watching(board string) int {
+	n := h.tracker.Count(board)
+	if h.live != nil {
+		n += h.live.Watching(board)
+	}
+	return n
+}
+
+// GetPresence handles GET /api/leaderboard/{board}/presence, returning the
+// number of clients watching the board
+func (h *PresenceHandler) GetPresence(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	board, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(w).Encode(Presence{Board: board, Watching: h.watching(board)})
+}
+
+// GetLobbyPresence handles GET /api/lobbies/{lobby}/presence, returning the
+// number of players connected to the lobby
+func (h *PresenceHandler) GetLobbyPresence(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	lobby := r.PathValue("lobby")
+	if !boardNamePattern.MatchString(lobby) {
+		httpError(w, r, "Invalid lobby name", http.StatusBadRequest)
+		return
+	}
+	watching := 0
+	if h.live != nil {
+		watching = h.live.LobbySize(lobby)
+	}
+	json.NewEncoder(w).Encode(Presence{Lobby: lobby, Watching: watching})
+}
+
+// Heartbeat handles POST /api/leaderboard/{board}/presence, which records a
+// heartbeat of the {"clientId": "..."} body and returns the updated count
+func (h *PresenceHandler) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	board, ok := h.board(w, r)
+	if !ok {
+		return
+	}
+
+	var req PresenceHeartbeat
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ClientID == "" || len(req.ClientID) > 64 {
+		httpError(w, r, "A client ID of up to 64 characters is required", http.StatusBadRequest)
+		return
+	}
+	h.tracker.Heartbeat(board, req.ClientID, clientAddress(r))
+	json.NewEncoder(w).Encode(Presence{Board: board, Watching: h.watching(board)})
+}
+
+// clientAddress returns the IP address a request came from
+func clientAddress(r *http.Request) string {
+	address, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return address
+}
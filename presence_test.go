@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test viewers are counted per board within the tracker's limits and
+// expire after the TTL
+func TestPresenceTracker(t *testing.T) {
+	tracker := NewPresenceTracker(time.Minute, PresenceLimits{Total: 4, PerBoard: 3, PerAddress: 2})
+
+	tracker.Heartbeat("main", "a", "10.0.0.1")
+	tracker.Heartbeat("main", "b", "10.0.0.1")
+	tracker.Heartbeat("main", "a", "10.0.0.1")
+	tracker.Heartbeat("speedrun", "c", "10.0.0.2")
+
+	if got := tracker.Count("main"); got != 2 {
+		t.Errorf("Expected 2 watching main, got %d", got)
+	}
+	if got := tracker.Count("speedrun"); got != 1 {
+		t.Errorf("Expected 1 watching speedrun, got %d", got)
+	}
+
+	tests := []struct {
+		name     string
+		board    string
+		clientID string
+		address  string
+		want     int
+	}{
+		{"address full", "main", "d", "10.0.0.1", 2},
+		{"board has room", "main", "d", "10.0.0.3", 3},
+		{"board full", "main", "e", "10.0.0.4", 3},
+		{"tracker full", "speedrun", "e", "10.0.0.4", 1},
+		{"known client", "main", "a", "10.0.0.1", 3},
+	}
+	for _, tt := range tests {
+		if got := tracker.Heartbeat(tt.board, tt.clientID, tt.address); got != tt.want {
+			t.Errorf("%s: Expected %d watching %s, got %d", tt.name, tt.want, tt.board, got)
+		}
+	}
+
+	// Expire everyone, which frees the tracker for new clients
+	tracker.Prune(time.Now().Add(2 * time.Minute))
+	if got := tracker.Count("main"); got != 0 {
+		t.Errorf("Expected expired viewers to be dropped, got %d", got)
+	}
+	if got := tracker.Heartbeat("speedrun", "e", "10.0.0.1"); got != 1 {
+		t.Errorf("Expected a new client to be counted after the prune, got %d", got)
+	}
+}
+
+// Test the presence endpoints record heartbeats and report counts,
+// including live-update subscribers, for boards that exist
+func TestServePresence(t *testing.T) {
+	boards := NewBoardManager()
+	boards.Add(MainBoard, NewLeaderboardHandler(NewScoreStore()))
+	live := NewLiveHub(boards, nil, LiveConfig{})
+	live.watch(MainBoard)
+	handler := NewPresenceHandler(NewPresenceTracker(time.Minute, PresenceLimits{Total: 100, PerBoard: 100, PerAddress: 10}), boards, live)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/leaderboard/{board}/presence", handler.GetPresence)
+	mux.HandleFunc("POST /api/leaderboard/{board}/presence", handler.Heartbeat)
+	mux.HandleFunc("GET /api/lobbies/{lobby}/presence", handler.GetLobbyPresence)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		watching int
+	}{
+		{"heartbeat", "POST", "/api/leaderboard/main/presence", `{"clientId":"a"}`, http.StatusOK, 2},
+		{"second client", "POST", "/api/leaderboard/main/presence", `{"clientId":"b"}`, http.StatusOK, 3},
+		{"count", "GET", "/api/leaderboard/main/presence", "", http.StatusOK, 3},
+		{"lobby", "GET", "/api/lobbies/speedrun/presence", "", http.StatusOK, 0},
+		{"unknown board", "POST", "/api/leaderboard/missing/presence", `{"clientId":"a"}`, http.StatusNotFound, 0},
+		{"missing client", "POST", "/api/leaderboard/main/presence", `{}`, http.StatusBadRequest, 0},
+		{"bad board", "GET", "/api/leaderboard/a.b/presence", "", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var presence Presence
+			json.NewDecoder(w.Body).Decode(&presence)
+			if presence.Watching != tt.watching {
+				t.Errorf("Expected %d watching, got %d", tt.watching, presence.Watching)
+			}
+		})
+	}
+}
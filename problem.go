@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ProblemContentType is the media type of error responses, which are
+// RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// Problem is the body of an error response. Type identifies the kind of
+// error so clients can branch on it; Detail is a human-readable message
+// for this occurrence.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// RequestID matches the X-Request-Id header, for finding the request
+	// in the server logs
+	RequestID string `json:"requestId,omitempty"`
+	// Violations lists each invalid field of a rejected request body
+	Violations []FieldViolation `json:"violations,omitempty"`
+	// Suggestion is a free player name, offered when the one asked for is
+	// claimed by another player
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// FieldViolation is a problem with one field of a request body, so clients
+// can point players at the field to fix
+type FieldViolation struct {
+	// Field is the field's JSON key
+	Field string `json:"field"`
+	// Code is the kind of problem, one of the Violation constants
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Codes of field violations
+const (
+	ViolationRequired = "required"
+	ViolationNegative = "negative"
+	ViolationFormat   = "invalid_format"
+	ViolationInvalid  = "invalid"
+	ViolationUnknown  = "unknown"
+	ViolationTooLarge = "too_large"
+)
+
+// problemTypes names the kind of error of each status code. Type URIs are
+// /problems/{name}.
+var problemTypes = map[int]string{
+	http.StatusBadRequest:            "invalid-request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not-found",
+	http.StatusMethodNotAllowed:      "method-not-allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusGone:                  "gone",
+	http.StatusRequestEntityTooLarge: "too-large",
+	http.StatusUnprocessableEntity:   "unprocessable",
+	http.StatusTooManyRequests:       "rate-limited",
+	http.StatusInternalServerError:   "internal",
+	http.StatusNotImplemented:        "not-implemented",
+	http.StatusServiceUnavailable:    "unavailable",
+}
+
+// problemType returns the type URI of errors with a status code
+func problemType(status int) string {
+	if name, ok := problemTypes[status]; ok {
+		return "/problems/" + name
+	}
+	return "about:blank"
+}
+
+// httpError responds with a problem of the given status, like http.Error
+// but machine-readable. detail is the message shown to players.
+func httpError(w http.ResponseWriter, r *http.Request, detail string, status int) {
+	writeProblem(w, Problem{
+		Type:      problemType(status),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: requestIDFrom(r.Context()),
+	})
+}
+
+// methodNotAllowed responds 405 Method Not Allowed, listing the methods the
+// resource supports in the Allow header
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+// notFound responds 404 Not Found for API paths that don't exist, rather
+// than falling through to the game's page
+func notFound(w http.ResponseWriter, r *http.Request) {
+	httpError(w, r, "No such endpoint: "+r.URL.Path, http.StatusNotFound)
+}
+
+// corsAllowHeaders are the request headers browsers may send to the API
+// from other origins
+const corsAllowHeaders = "Content-Type, " + PlayerTokenHeader + ", " + IdempotencyKeyHeader + ", " + ReplayTokenHeader
+
+// routedMethods are the methods unrouted looks for other routes of a path
+// with
+var routedMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// unrouted handles the requests no route of mux matches. If the path has
+// routes for other methods, CORS preflight requests get the headers
+// allowing them and other requests 405 Method Not Allowed; unknown paths
+// get 404 Not Found.
+func unrouted(mux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, fallback := mux.Handler(r)
+		var allowed []string
+		for _, method := range routedMethods {
+			probe := r.Clone(r.Context())
+			probe.Method = method
+			if _, pattern := mux.Handler(probe); pattern != fallback {
+				allowed = append(allowed, method)
+			}
+		}
+		if len(allowed) == 0 {
+			notFound(w, r)
+			return
+		}
+		allowed = append(allowed, "OPTIONS")
+
+		if r.Method == "OPTIONS" {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(allowed, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		methodNotAllowed(w, r, allowed...)
+	}
+}
+
+// writeProblem responds with problem
+func writeProblem(w http.ResponseWriter, problem Problem) {
+	// Drop headers meant for the body the handler was going to send
+	w.Header().Del("Content-Length")
+	w.Header().Del("Content-Disposition")
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// RequestIDHeader carries the ID of a request, which is echoed in the
+// response and in problem details
+const RequestIDHeader = "X-Request-Id"
+
+// validRequestID matches request IDs clients and proxies may choose
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+// withRequestID gives each request an ID, keeping one set by a client or
+// proxy in the X-Request-Id header if it is valid, so errors can be
+// matched with the request that caused them
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newUUID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestIDFrom returns the ID of the request ctx belongs to, empty
+// outside withRequestID
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
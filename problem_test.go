@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test errors are answered with problem details
+func TestHTTPErrorProblem(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/leaderboard", NewLeaderboardHandler(NewScoreStore()).SubmitScore)
+	mux.HandleFunc("/api/", unrouted(mux))
+	handler := withRequestID(mux)
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantType   string
+		wantDetail string
+	}{
+		{"invalid body", "POST", "{", http.StatusBadRequest, "/problems/invalid-request", "Invalid request body"},
+		{"missing name", "POST", `{"score":10}`, http.StatusBadRequest, "/problems/invalid-request", "Player name is required"},
+		{"wrong method", "PATCH", "", http.StatusMethodNotAllowed, "/problems/method-not-allowed", "Method not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/leaderboard", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != ProblemContentType {
+				t.Errorf("Expected Content-Type %s, got %s", ProblemContentType, ct)
+			}
+
+			var problem Problem
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatalf("Failed to decode problem: %v", err)
+			}
+			// Violations are covered by TestSubmitScoreViolations
+			problem.Violations = nil
+			want := Problem{
+				Type:      tt.wantType,
+				Title:     http.StatusText(tt.wantStatus),
+				Status:    tt.wantStatus,
+				Detail:    tt.wantDetail,
+				Instance:  "/api/leaderboard",
+				RequestID: w.Header().Get(RequestIDHeader),
+			}
+			if !reflect.DeepEqual(problem, want) || problem.RequestID == "" {
+				t.Errorf("Expected %+v, got %+v", want, problem)
+			}
+		})
+	}
+}
+
+// Test request IDs set by clients are kept only if they are valid
+func TestWithRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantKept bool
+	}{
+		{"none", "", false},
+		{"valid", "req-42.a_b", true},
+		{"invalid characters", "id with spaces", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = requestIDFrom(r.Context())
+			}))
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if id == "" || id != seen {
+				t.Fatalf("Expected the response header to match the request's ID, got %q and %q", id, seen)
+			}
+			if kept := id == tt.incoming; kept != tt.wantKept {
+				t.Errorf("Expected kept %v, got ID %q", tt.wantKept, id)
+			}
+		})
+	}
+}
+
+// Test rejected submissions list every invalid field
+func TestSubmitScoreViolations(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{DifficultyMultipliers: map[string]float64{"normal": 1}, DefaultDifficulty: "normal"})
+	handler := NewLeaderboardHandler(store)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		want       []FieldViolation
+	}{
+		{
+			"missing name",
+			`{"score":10}`, http.StatusBadRequest,
+			[]FieldViolation{{"playerName", ViolationRequired, "Player name is required"}},
+		},
+		{
+			"several fields",
+			`{"playerName":"Kiro","score":-1,"durationMs":-5,"country":"Canada","difficulty":"nightmare"}`, http.StatusBadRequest,
+			[]FieldViolation{
+				{"score", ViolationNegative, "Score must be non-negative"},
+				{"durationMs", ViolationNegative, "Duration must be non-negative"},
+				{"country", ViolationFormat, "Country must be a two-letter ISO 3166-1 code"},
+				{"difficulty", ViolationUnknown, "Unknown difficulty"},
+			},
+		},
+		{
+			"metadata",
+			`{"playerName":"Kiro","score":10,"metadata":[1],"runId":"a b"}`, http.StatusBadRequest,
+			[]FieldViolation{
+				{"runId", ViolationFormat, "Run ID may only contain letters, digits, - and _, up to 64 characters"},
+				{"metadata", ViolationInvalid, "Metadata must be a JSON object"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+
+			var problem Problem
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatalf("Failed to decode problem: %v", err)
+			}
+			if !reflect.DeepEqual(problem.Violations, tt.want) {
+				t.Errorf("Expected violations %+v, got %+v", tt.want, problem.Violations)
+			}
+			if problem.Detail != tt.want[0].Message {
+				t.Errorf("Expected detail %q, got %q", tt.want[0].Message, problem.Detail)
+			}
+		})
+	}
+}
+
+// Test unknown API paths and unsupported methods get JSON errors, with the
+// supported methods in the Allow header
+func TestAPINotFoundAndMethodNotAllowed(t *testing.T) {
+	mux := newTestAPIMux(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{"unknown path", "GET", "/api/nope", http.StatusNotFound, ""},
+		{"unknown board resource", "GET", "/api/boards/main/nope", http.StatusNotFound, ""},
+		{"board", "DELETE", "/api/leaderboard", http.StatusMethodNotAllowed, "GET, POST, OPTIONS"},
+		{"named board", "DELETE", "/api/boards/main/leaderboard", http.StatusMethodNotAllowed, "GET, POST, OPTIONS"},
+		{"friend list", "PUT", "/api/friends", http.StatusMethodNotAllowed, "GET, POST, OPTIONS"},
+		{"friend", "GET", "/api/friends/Luigi", http.StatusMethodNotAllowed, "DELETE, OPTIONS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != ProblemContentType {
+				t.Errorf("Expected Content-Type %s, got %s", ProblemContentType, ct)
+			}
+			if allow := w.Header().Get("Allow"); allow != tt.wantAllow {
+				t.Errorf("Expected Allow %q, got %q", tt.wantAllow, allow)
+			}
+		})
+	}
+}
+
+// Test CORS preflight requests are answered with the methods of the path
+func TestAPIPreflight(t *testing.T) {
+	mux := newTestAPIMux(t)
+
+	tests := []struct {
+		name        string
+		path        string
+		wantStatus  int
+		wantMethods string
+	}{
+		{"board", "/api/leaderboard", http.StatusOK, "GET, POST, OPTIONS"},
+		{"friend", "/api/friends/Luigi", http.StatusOK, "DELETE, OPTIONS"},
+		{"unknown path", "/api/nope", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("OPTIONS", tt.path, nil)
+			req.Header.Set("Origin", "https://example.com")
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if methods := w.Header().Get("Access-Control-Allow-Methods"); methods != tt.wantMethods {
+				t.Errorf("Expected allowed methods %q, got %q", tt.wantMethods, methods)
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), PlayerTokenHeader) {
+				t.Errorf("Expected %s to be an allowed header, got %q", PlayerTokenHeader, w.Header().Get("Access-Control-Allow-Headers"))
+			}
+		})
+	}
+}
+
+// newTestAPIMux routes the main board's leaderboard and friend endpoints
+// and every board's endpoints like the server does
+func newTestAPIMux(t *testing.T) *http.ServeMux {
+	dir := t.TempDir()
+	leaderboard := NewLeaderboardHandler(NewScoreStore(), WithFriends(NewFriends(filepath.Join(dir, "friends.json"))))
+	boards := NewBoardManager()
+	boards.Add(MainBoard, leaderboard)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/leaderboard", leaderboard.GetLeaderboard)
+	mux.HandleFunc("POST /api/leaderboard", leaderboard.SubmitScore)
+	mux.HandleFunc("GET /api/friends", leaderboard.Friends)
+	mux.HandleFunc("POST /api/friends", leaderboard.AddFriend)
+	mux.HandleFunc("DELETE /api/friends/{name}", leaderboard.RemoveFriend)
+	boards.Routes(mux)
+	mux.HandleFunc("/api/", unrouted(mux))
+	return mux
+}
+
+// routedRequest returns a request for target with the path values pattern
+// gives it, for calling the pattern's handler directly
+func routedRequest(t *testing.T, pattern, target string, body io.Reader) *http.Request {
+	t.Helper()
+	method, _, _ := strings.Cut(pattern, " ")
+	var routed *http.Request
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) { routed = r })
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, target, body))
+	if routed == nil {
+		t.Fatalf("Expected %s to match %s", target, pattern)
+	}
+	return routed
+}
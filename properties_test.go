@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// quickRun is a randomly generated submission for property tests
+type quickRun struct {
+	Score  uint16
+	Player uint8
+	Hidden bool
+}
+
+// storeFromRuns builds a store from generated runs, spread across a
+// handful of players so that ties and repeat players are common
+func storeFromRuns(runs []quickRun, bestPerPlayer bool) *ScoreStore {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{BestPerPlayer: bestPerPlayer})
+	for _, run := range runs {
+		store.AddEntry(context.Background(), ScoreEntry{
+			Score:      int(run.Score % 50),
+			PlayerName: fmt.Sprintf("Player%d", run.Player%8),
+			Hidden:     run.Hidden,
+		})
+	}
+	return store
+}
+
+// **Feature: leaderboard-api, Property 1: Rank consistency**
+// For any board, the rank of every listed entry is one more than the number
+// of listed entries scoring strictly higher
+func TestRankConsistency(t *testing.T) {
+	config := &quick.Config{MaxCount: 100}
+
+	property := func(runs []quickRun, bestPerPlayer bool) bool {
+		store := storeFromRuns(runs, bestPerPlayer)
+		board := store.GetTopScores(context.Background(), 0)
+
+		for _, entry := range board {
+			higher := 0
+			for _, other := range board {
+				if other.Score > entry.Score {
+					higher++
+				}
+			}
+			standing, ok := store.GetStanding(context.Background(), entry.ID)
+			if !ok || standing.Rank != higher+1 {
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
+
+// **Feature: leaderboard-api, Property 2: Around-me windows match the board**
+// For any player on the board, the around view is the slice of the full
+// listing centred on the player's best entry
+func TestAroundMatchesBoard(t *testing.T) {
+	config := &quick.Config{MaxCount: 100}
+
+	property := func(runs []quickRun, player, window uint8) bool {
+		store := storeFromRuns(runs, false)
+		board := store.GetTopScores(context.Background(), 0)
+		name := fmt.Sprintf("Player%d", player%8)
+		size := int(window % 10)
+
+		around, ok := store.GetAround(context.Background(), name, size)
+		index := -1
+		for i, entry := range board {
+			if entry.PlayerName == name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return !ok
+		}
+
+		start := max(index-size, 0)
+		end := min(index+size+1, len(board))
+		if len(around) != end-start {
+			return false
+		}
+		for i, entry := range around {
+			if entry.ID != board[start+i].ID {
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
+
+// **Feature: leaderboard-api, Property 3: One entry per player**
+// For any sequence of submissions to a best-per-player board, no player is
+// listed twice and each listed entry is the player's best visible score
+func TestBestPerPlayerProperty(t *testing.T) {
+	config := &quick.Config{MaxCount: 100}
+
+	property := func(runs []quickRun) bool {
+		store := storeFromRuns(runs, true)
+
+		best := make(map[string]int)
+		for _, entry := range store.GetAllEntries(context.Background()) {
+			if !entry.Hidden && entry.Score >= best[entry.PlayerName] {
+				best[entry.PlayerName] = entry.Score
+			}
+		}
+
+		seen := make(map[string]bool)
+		for _, entry := range store.GetTopScores(context.Background(), 0) {
+			if seen[entry.PlayerName] || entry.Score != best[entry.PlayerName] {
+				return false
+			}
+			seen[entry.PlayerName] = true
+		}
+		return true
+	}
+
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
+
+// **Feature: leaderboard-api, Property 4: Percentile agrees with the board**
+// For any score, the percentile is the share of listed entries scoring
+// strictly less
+func TestPercentileProperty(t *testing.T) {
+	config := &quick.Config{MaxCount: 100}
+
+	property := func(runs []quickRun, score uint16) bool {
+		store := storeFromRuns(runs, false)
+		board := store.GetTopScores(context.Background(), 0)
+		value := int(score % 60)
+
+		below := 0
+		for _, entry := range board {
+			if entry.Score < value {
+				below++
+			}
+		}
+
+		result := store.GetPercentile(context.Background(), value)
+		if len(board) == 0 {
+			return result.Percentile == 0 && result.Total == 0
+		}
+		want := float64(below) / float64(len(board)) * 100
+		return result.Total == len(board) && result.Percentile >= want-0.05 && result.Percentile <= want+0.05
+	}
+
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
+
+// **Feature: leaderboard-api, Property 5: Pages concatenate to the listing**
+// For any page size, reading the board page by page yields the full
+// listing in order
+func TestPaginationProperty(t *testing.T) {
+	config := &quick.Config{MaxCount: 100}
+
+	property := func(runs []quickRun, pageSize uint8, bestPerPlayer bool) bool {
+		store := storeFromRuns(runs, bestPerPlayer)
+		board := store.GetTopScores(context.Background(), 0)
+		size := int(pageSize%10) + 1
+
+		var pages []ScoreEntry
+		for offset := 0; ; offset += size {
+			page, total := store.QueryPage(context.Background(), ScoreQuery{Limit: size, Offset: offset})
+			if total != len(board) {
+				return false
+			}
+			if len(page) == 0 {
+				break
+			}
+			pages = append(pages, page...)
+		}
+
+		if len(pages) != len(board) {
+			return false
+		}
+		for i := range board {
+			if pages[i].ID != board[i].ID {
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
+
+// **Feature: leaderboard-api, Property 6: Periods partition the board**
+// For any split time, the runs before it and the runs from it onwards
+// together make up the all-time board, with no run in both
+func TestPeriodPartitionProperty(t *testing.T) {
+	config := &quick.Config{MaxCount: 100}
+
+	property := func(runs []quickRun, split uint8) bool {
+		store := NewScoreStore()
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i, run := range runs {
+			store.restoreEntry(ScoreEntry{
+				ID:         fmt.Sprintf("run%d", i),
+				Score:      int(run.Score % 50),
+				PlayerName: fmt.Sprintf("Player%d", run.Player%8),
+				Hidden:     run.Hidden,
+				Timestamp:  start.Add(time.Duration(run.Player) * time.Hour),
+			})
+		}
+		at := start.Add(time.Duration(split) * time.Hour)
+
+		before := store.QueryScores(context.Background(), ScoreQuery{Until: at})
+		after := store.QueryScores(context.Background(), ScoreQuery{Since: at})
+		all := store.QueryScores(context.Background(), ScoreQuery{})
+		if len(before)+len(after) != len(all) {
+			return false
+		}
+
+		seen := make(map[string]bool)
+		for _, entry := range append(before, after...) {
+			if seen[entry.ID] {
+				return false
+			}
+			seen[entry.ID] = true
+		}
+		for _, entry := range all {
+			if !seen[entry.ID] {
+				return false
+			}
+		}
+		return true
+	}
+
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
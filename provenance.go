@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Provenance sources describing how an entry was created or changed
+const (
+	// SourceAPI entries were submitted by the game through the public API
+	SourceAPI = "api"
+	// SourceImport entries were loaded by a batch import
+	SourceImport = "import"
+	// SourceMigration entries predate provenance tracking
+	SourceMigration = "migration"
+	// SourceAdmin steps record edits made by moderators and admins
+	SourceAdmin = "admin"
+)
+
+// ProvenanceStep records one step in the history of an entry: how it was
+// created, or a later edit
+type ProvenanceStep struct {
+	Source string `json:"source"`
+	// Action names the edit made in admin steps, such as "hide"
+	Action string `json:"action,omitempty"`
+	// Credential identifies the API key, player token or admin that was
+	// used, never the secret itself
+	Credential string    `json:"credential,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// Origin returns the step that created an entry
+func (e ScoreEntry) Origin() ProvenanceStep {
+	if len(e.Provenance) == 0 {
+		return ProvenanceStep{Source: SourceMigration, At: e.Timestamp}
+	}
+	return e.Provenance[0]
+}
+
+// lastChanged returns when the last step in an entry's history was taken,
+// such as a moderator hiding it
+func (e ScoreEntry) lastChanged() time.Time {
+	if len(e.Provenance) == 0 {
+		return e.Timestamp
+	}
+	return e.Provenance[len(e.Provenance)-1].At
+}
+
+// public returns the entry as shown on the public board, without the
+// provenance kept for moderators
+func (e ScoreEntry) public() ScoreEntry {
+	e.Provenance = nil
+	return e
+}
+
+// publicEntries strips moderator-only data from a list of entries
+func publicEntries(entries []ScoreEntry) []ScoreEntry {
+	public := make([]ScoreEntry, len(entries))
+	for i, entry := range entries {
+		public[i] = entry.public()
+	}
+	return public
+}
+
+// playerCredential identifies a player token by a prefix of its hash
+func playerCredential(token string) string {
+	if token == "" {
+		return "anonymous"
+	}
+	return "player-token:" + hashToken(token)[:12]
+}
+
+// ProvenanceFilter selects entries by how they were created
+type ProvenanceFilter struct {
+	// Sources keeps entries created by one of these sources; empty keeps all
+	Sources []string
+	// ExcludeSources drops entries created by any of these sources
+	ExcludeSources []string
+	// Credential keeps entries with any step using this credential
+	Credential string
+}
+
+// parseSourceList splits a comma-separated list of sources
+func parseSourceList(list string) []string {
+	var sources []string
+	for _, source := range strings.Split(list, ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// matches reports whether an entry passes the filter
+func (f ProvenanceFilter) matches(entry ScoreEntry) bool {
+	origin := entry.Origin().Source
+	if len(f.Sources) > 0 && !containsString(f.Sources, origin) {
+		return false
+	}
+	if containsString(f.ExcludeSources, origin) {
+		return false
+	}
+	if f.Credential != "" {
+		for _, step := range entry.Provenance {
+			if step.Credential == f.Credential {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// Test entries record how they were created and edited, and moderators can
+// filter by it while the public board never shows it
+func TestEntryProvenance(t *testing.T) {
+	useTempSaveFiles(t)
+	store := NewScoreStore()
+	leaderboard := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))
+	t.Cleanup(store.Close)
+	admin := NewAdminHandler(store, NewRuleSet(), "")
+	auth := NewAuthenticator()
+	auth.AddNamedToken("alice", "mod-token", RoleModerator)
+	search := auth.Require(RoleModerator, admin.SearchEntries)
+	action := auth.Require(RoleModerator, admin.EntryAction)
+
+	body, _ := json.Marshal(map[string]interface{}{"score": 500, "playerName": "Kiro"})
+	w := httptest.NewRecorder()
+	leaderboard.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body)))
+	var submitted ScoreEntry
+	json.NewDecoder(w.Body).Decode(&submitted)
+	if submitted.Provenance != nil {
+		t.Error("Expected provenance to be left out of the submission response")
+	}
+
+	legacy := store.AddScore(context.Background(), 900, "Legacy")
+	imported := store.AddEntry(context.Background(), ScoreEntry{Score: 700, PlayerName: "Old", Provenance: []ProvenanceStep{{Source: SourceImport, Credential: "import-2023"}}})
+
+	w = httptest.NewRecorder()
+	leaderboard.GetLeaderboard(w, httptest.NewRequest("GET", "/api/leaderboard", nil))
+	if bytes.Contains(w.Body.Bytes(), []byte("provenance")) {
+		t.Error("Expected provenance to be left out of the leaderboard")
+	}
+
+	req := routedRequest(t, "POST /api/admin/entries/{id}/{action}", "/api/admin/entries/"+submitted.ID+"/hide", nil)
+	req.Header.Set("Authorization", "Bearer mod-token")
+	action(httptest.NewRecorder(), req)
+
+	tests := []struct {
+		name    string
+		query   string
+		wantIDs []string
+	}{
+		{"all entries including hidden", "", []string{submitted.ID, legacy.ID, imported.ID}},
+		{"api only", "?source=api", []string{submitted.ID}},
+		{"exclude legacy scores", "?excludeSource=import,migration", []string{submitted.ID}},
+		{"by credential", "?credential=import-2023", []string{imported.ID}},
+		{"by admin edit", "?credential=alice", []string{submitted.ID}},
+		{"by player", "?player=Legacy", []string{legacy.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/admin/entries"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer mod-token")
+			w := httptest.NewRecorder()
+			search(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+			}
+
+			var entries []ScoreEntry
+			json.NewDecoder(w.Body).Decode(&entries)
+			if len(entries) != len(tt.wantIDs) {
+				t.Fatalf("Expected %d entries, got %d", len(tt.wantIDs), len(entries))
+			}
+			for i, entry := range entries {
+				if entry.ID != tt.wantIDs[i] {
+					t.Errorf("Expected entry %s at %d, got %s", tt.wantIDs[i], i, entry.ID)
+				}
+			}
+		})
+	}
+
+	entry, _ := store.GetEntry(context.Background(), submitted.ID)
+	if len(entry.Provenance) != 2 || entry.Provenance[0].Credential != "anonymous" ||
+		entry.Provenance[1].Action != "hide" || entry.Provenance[1].Credential != "alice" {
+		t.Errorf("Expected an API origin followed by alice's hide, got %+v", entry.Provenance)
+	}
+}
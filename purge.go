@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// EntryFilter selects entries for a bulk deletion, such as the runs of a
+// cheating spree. Every set field must match; the zero filter matches
+// nothing so a mistyped request cannot empty the board.
+type EntryFilter struct {
+	// PlayerName matches one player's entries exactly
+	PlayerName string
+	// MinScore and MaxScore match scores in the inclusive range; nil leaves
+	// that end open
+	MinScore *int
+	MaxScore *int
+	// Since and Until match entries submitted in [Since, Until); zero
+	// values leave that end open
+	Since time.Time
+	Until time.Time
+}
+
+// empty reports whether the filter has no conditions
+func (f EntryFilter) empty() bool {
+	return f.PlayerName == "" && f.MinScore == nil && f.MaxScore == nil && f.Since.IsZero() && f.Until.IsZero()
+}
+
+// matches reports whether an entry passes every condition of the filter
+func (f EntryFilter) matches(entry ScoreEntry) bool {
+	switch {
+	case f.empty():
+		return false
+	case f.PlayerName != "" && entry.PlayerName != f.PlayerName:
+		return false
+	case f.MinScore != nil && entry.Score < *f.MinScore:
+		return false
+	case f.MaxScore != nil && entry.Score > *f.MaxScore:
+		return false
+	case !f.Since.IsZero() && entry.Timestamp.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !entry.Timestamp.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// parseEntryFilter reads a filter from ?player=, ?minScore=, ?maxScore=,
+// ?since= and ?until= (RFC 3339 times)
+func parseEntryFilter(query url.Values) (EntryFilter, error) {
+	filter := EntryFilter{PlayerName: query.Get("player")}
+
+	scores := []struct {
+		name  string
+		bound **int
+	}{{"minScore", &filter.MinScore}, {"maxScore", &filter.MaxScore}}
+	for _, param := range scores {
+		if value := query.Get(param.name); value != "" {
+			score, err := strconv.Atoi(value)
+			if err != nil {
+				return filter, newKindError(ErrValidation, param.name+" must be an integer")
+			}
+			*param.bound = &score
+		}
+	}
+	if filter.MinScore != nil && filter.MaxScore != nil && *filter.MinScore > *filter.MaxScore {
+		return filter, newKindError(ErrValidation, "minScore must not be above maxScore")
+	}
+
+	times := []struct {
+		name  string
+		bound *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}}
+	for _, param := range times {
+		if value := query.Get(param.name); value != "" {
+			at, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, newKindError(ErrValidation, "Invalid "+param.name+": use an RFC3339 time")
+			}
+			*param.bound = at
+		}
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		return filter, newKindError(ErrValidation, "since must be before until")
+	}
+
+	if filter.empty() {
+		return filter, newKindError(ErrValidation, "at least one of player, minScore, maxScore, since or until is required")
+	}
+	return filter, nil
+}
+
+// matching returns the entries in st the filter matches, in insertion order
+func (f EntryFilter) matching(st *boardState) []ScoreEntry {
+	var entries []ScoreEntry
+	for _, entry := range st.entries {
+		if f.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// FindEntries returns every entry the filter matches, including hidden and
+// pending ones
+func (s *ScoreStore) FindEntries(ctx context.Context, filter EntryFilter) []ScoreEntry {
+	return filter.matching(s.load())
+}
+
+// DeleteEntries permanently removes the entries with the given IDs and
+// returns those that were still stored
+func (s *ScoreStore) DeleteEntries(ctx context.Context, ids []string) []ScoreEntry {
+	return s.removeEntries(func(*boardState) []string { return ids })
+}
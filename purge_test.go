@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Test entries match only when they pass every condition of a filter, with
+// a half-open time range, and that an empty filter matches nothing
+func TestEntryFilterMatches(t *testing.T) {
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := since.Add(24 * time.Hour)
+	low, high := 100, 500
+	entry := func(name string, score int, at time.Time) ScoreEntry {
+		return ScoreEntry{PlayerName: name, Score: score, Timestamp: at}
+	}
+
+	tests := []struct {
+		name   string
+		filter EntryFilter
+		entry  ScoreEntry
+		want   bool
+	}{
+		{"empty filter", EntryFilter{}, entry("Kiro", 300, since), false},
+		{"player", EntryFilter{PlayerName: "Kiro"}, entry("Kiro", 300, since), true},
+		{"other player", EntryFilter{PlayerName: "Kiro"}, entry("Luigi", 300, since), false},
+		{"min score bound", EntryFilter{MinScore: &low}, entry("Kiro", 100, since), true},
+		{"below min score", EntryFilter{MinScore: &low}, entry("Kiro", 99, since), false},
+		{"max score bound", EntryFilter{MaxScore: &high}, entry("Kiro", 500, since), true},
+		{"above max score", EntryFilter{MaxScore: &high}, entry("Kiro", 501, since), false},
+		{"at since", EntryFilter{Since: since}, entry("Kiro", 300, since), true},
+		{"before since", EntryFilter{Since: since}, entry("Kiro", 300, since.Add(-time.Nanosecond)), false},
+		{"just before until", EntryFilter{Until: until}, entry("Kiro", 300, until.Add(-time.Nanosecond)), true},
+		{"at until", EntryFilter{Until: until}, entry("Kiro", 300, until), false},
+		{"every condition", EntryFilter{PlayerName: "Kiro", MinScore: &low, MaxScore: &high, Since: since, Until: until}, entry("Kiro", 300, since.Add(time.Hour)), true},
+		{"one condition fails", EntryFilter{PlayerName: "Kiro", MinScore: &low, MaxScore: &high, Since: since, Until: until}, entry("Kiro", 600, since.Add(time.Hour)), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(tt.entry); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
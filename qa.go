@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Score distributions for simulated traffic
+const (
+	DistributionNormal      = "normal"
+	DistributionUniform     = "uniform"
+	DistributionExponential = "exponential"
+)
+
+// Submission patterns for simulated traffic
+const (
+	// PatternSteady spreads submissions evenly over the duration
+	PatternSteady = "steady"
+	// PatternBurst sends submissions in groups of BurstSize at once
+	PatternBurst = "burst"
+)
+
+// maxSimulatedSubmissions caps a single simulation run
+const maxSimulatedSubmissions = 10000
+
+// SimulationRequest describes synthetic traffic to generate
+type SimulationRequest struct {
+	// Players is the number of distinct simulated players. A few of them
+	// submit most of the runs, as on a real board. Defaults to 20.
+	Players int `json:"players,omitempty"`
+	// Submissions is the total number of runs to submit. Defaults to 100.
+	Submissions int `json:"submissions,omitempty"`
+	// Distribution of scores: normal (default), uniform or exponential
+	Distribution string `json:"distribution,omitempty"`
+	// MeanScore centres normal and exponential scores. Defaults to 1000.
+	MeanScore float64 `json:"meanScore,omitempty"`
+	// StdDevScore spreads normal scores. Defaults to a third of the mean.
+	StdDevScore float64 `json:"stdDevScore,omitempty"`
+	// MaxScore caps every score and bounds uniform scores. Defaults to
+	// twice the mean.
+	MaxScore int `json:"maxScore,omitempty"`
+	// Pattern is steady (default) or burst
+	Pattern string `json:"pattern,omitempty"`
+	// BurstSize is the number of submissions per burst. Defaults to 10.
+	BurstSize int `json:"burstSize,omitempty"`
+	// DurationSeconds spreads the traffic over this long; zero submits
+	// everything at once
+	DurationSeconds int `json:"durationSeconds,omitempty"`
+	// Seed makes a run reproducible; zero picks one at random
+	Seed int64 `json:"seed,omitempty"`
+}
+
+// withDefaults fills in unset fields and checks the request
+func (r SimulationRequest) withDefaults() (SimulationRequest, error) {
+	if r.Players <= 0 {
+		r.Players = 20
+	}
+	if r.Submissions <= 0 {
+		r.Submissions = 100
+	}
+	if r.Submissions > maxSimulatedSubmissions {
+		return r, fmt.Errorf("at most %d submissions per run", maxSimulatedSubmissions)
+	}
+	if r.Distribution == "" {
+		r.Distribution = DistributionNormal
+	}
+	switch r.Distribution {
+	case DistributionNormal, DistributionUniform, DistributionExponential:
+	default:
+		return r, fmt.Errorf("unknown distribution %q", r.Distribution)
+	}
+	if r.MeanScore <= 0 {
+		r.MeanScore = 1000
+	}
+	if r.StdDevScore <= 0 {
+		r.StdDevScore = r.MeanScore / 3
+	}
+	if r.MaxScore <= 0 {
+		r.MaxScore = int(2 * r.MeanScore)
+	}
+	if r.Pattern == "" {
+		r.Pattern = PatternSteady
+	}
+	if r.Pattern != PatternSteady && r.Pattern != PatternBurst {
+		return r, fmt.Errorf("unknown pattern %q", r.Pattern)
+	}
+	if r.BurstSize <= 0 {
+		r.BurstSize = 10
+	}
+	if r.DurationSeconds < 0 {
+		return r, fmt.Errorf("duration must be non-negative")
+	}
+	if r.Seed == 0 {
+		r.Seed = time.Now().UnixNano()
+	}
+	return r, nil
+}
+
+// SimulationStatus reports the progress of a simulation run
+type SimulationStatus struct {
+	Request   SimulationRequest `json:"request"`
+	StartedAt time.Time         `json:"startedAt"`
+	Running   bool              `json:"running"`
+	Submitted int               `json:"submitted"`
+	// Responses counts submissions by response status code
+	Responses map[int]int `json:"responses"`
+}
+
+// TrafficSimulator submits synthetic runs through the normal submission
+// path, so they are validated, flagged and queued like real traffic
+type TrafficSimulator struct {
+	handler *LeaderboardHandler
+	status  *SimulationStatus
+	mu      sync.Mutex
+}
+
+// NewTrafficSimulator creates a TrafficSimulator submitting to handler
+func NewTrafficSimulator(handler *LeaderboardHandler) *TrafficSimulator {
+	return &TrafficSimulator{handler: handler}
+}
+
+// Start begins a simulation run in the background. It fails if the
+// request is invalid or another run is in progress.
+func (s *TrafficSimulator) Start(req SimulationRequest) (SimulationStatus, error) {
+	req, err := req.withDefaults()
+	if err != nil {
+		return SimulationStatus{}, newKindError(ErrValidation, err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status != nil && s.status.Running {
+		return SimulationStatus{}, newKindError(ErrConflict, "a simulation is already running")
+	}
+
+	s.status = &SimulationStatus{Request: req, StartedAt: time.Now(), Running: true, Responses: map[int]int{}}
+	go s.run(req)
+	return s.snapshot(), nil
+}
+
+// Status returns the progress of the most recent run. The second return
+// value is false if nothing has been simulated yet.
+func (s *TrafficSimulator) Status() (SimulationStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status == nil {
+		return SimulationStatus{}, false
+	}
+	return s.snapshot(), true
+}
+
+// snapshot copies the status; callers hold mu
+func (s *TrafficSimulator) snapshot() SimulationStatus {
+	status := *s.status
+	status.Responses = make(map[int]int, len(s.status.Responses))
+	for code, count := range s.status.Responses {
+		status.Responses[code] = count
+	}
+	return status
+}
+
+// run submits every simulated run, sleeping between batches to follow the
+// requested pattern
+func (s *TrafficSimulator) run(req SimulationRequest) {
+	rng := rand.New(rand.NewSource(req.Seed))
+	// A Zipf distribution makes a few players far more active than the rest
+	players := rand.NewZipf(rng, 1.2, 1, uint64(req.Players-1))
+
+	batch := 1
+	if req.Pattern == PatternBurst {
+		batch = req.BurstSize
+	}
+	batches := (req.Submissions + batch - 1) / batch
+	var pause time.Duration
+	if batches > 1 {
+		pause = time.Duration(req.DurationSeconds) * time.Second / time.Duration(batches-1)
+	}
+
+	for sent := 0; sent < req.Submissions; sent++ {
+		if sent > 0 && sent%batch == 0 && pause > 0 {
+			time.Sleep(pause)
+		}
+
+		result, err := s.handler.submitRun(context.Background(), ScoreSubmission{
+			Score:      simulatedScore(rng, req),
+			PlayerName: fmt.Sprintf("QA-Player-%03d", players.Uint64()+1),
+		}, http.Header{})
+
+		s.mu.Lock()
+		s.status.Submitted++
+		s.status.Responses[submissionStatus(result, err)]++
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	s.status.Running = false
+	s.mu.Unlock()
+}
+
+// simulatedScore draws a score from the requested distribution, clamped to
+// [0, MaxScore]
+func simulatedScore(rng *rand.Rand, req SimulationRequest) int {
+	var score float64
+	switch req.Distribution {
+	case DistributionUniform:
+		score = rng.Float64() * float64(req.MaxScore)
+	case DistributionExponential:
+		score = rng.ExpFloat64() * req.MeanScore
+	default:
+		score = req.MeanScore + rng.NormFloat64()*req.StdDevScore
+	}
+	return int(math.Max(0, math.Min(math.Round(score), float64(req.MaxScore))))
+}
+
+// QAHandler serves the simulation endpoint, which is only registered when
+// the server runs in QA mode
+type QAHandler struct {
+	simulator *TrafficSimulator
+}
+
+// NewQAHandler creates a QAHandler for simulator
+func NewQAHandler(simulator *TrafficSimulator) *QAHandler {
+	return &QAHandler{simulator: simulator}
+}
+
+// GetSimulation handles GET /api/qa/simulate, which reports the
+// progress of the latest run
+func (h *QAHandler) GetSimulation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	status, ok := h.simulator.Status()
+	if !ok {
+		httpError(w, r, "No simulation has run", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// Simulate handles POST /api/qa/simulate, which starts generating traffic
+// described by a SimulationRequest body
+func (h *QAHandler) Simulate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req SimulationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	status, err := h.simulator.Start(req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(status)
+}
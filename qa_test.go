@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test simulated traffic goes through the submission path into the store
+func TestTrafficSimulator(t *testing.T) {
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{ReviewThreshold: 1500})
+	simulator := NewTrafficSimulator(NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json"))))
+	t.Cleanup(store.Close)
+
+	status, err := simulator.Start(SimulationRequest{Players: 5, Submissions: 50, Pattern: PatternBurst, Seed: 42})
+	if err != nil {
+		t.Fatalf("Expected the simulation to start, got %v", err)
+	}
+	if status.Request.Distribution != DistributionNormal || status.Request.MaxScore != 2000 {
+		t.Errorf("Expected defaults to be filled in, got %+v", status.Request)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for status.Running && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		status, _ = simulator.Status()
+	}
+	if status.Running || status.Submitted != 50 || status.Responses[http.StatusCreated] != 50 {
+		t.Fatalf("Expected 50 accepted submissions, got %+v", status)
+	}
+
+	players := make(map[string]bool)
+	pending := 0
+	for _, entry := range store.GetAllEntries(context.Background()) {
+		players[entry.PlayerName] = true
+		if entry.Score < 0 || entry.Score > 2000 {
+			t.Errorf("Expected scores within [0, 2000], got %d", entry.Score)
+		}
+		if entry.Pending {
+			pending++
+		}
+	}
+	if len(players) < 2 || len(players) > 5 {
+		t.Errorf("Expected between 2 and 5 simulated players, got %d", len(players))
+	}
+	if pending == 0 {
+		t.Error("Expected some simulated scores to reach the moderation queue")
+	}
+}
+
+// Test invalid simulation requests are rejected
+func TestSimulateHandler(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewQAHandler(NewTrafficSimulator(NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")))))
+	t.Cleanup(store.Close)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/qa/simulate", handler.GetSimulation)
+	mux.HandleFunc("POST /api/qa/simulate", handler.Simulate)
+
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		wantCode int
+	}{
+		{"no run yet", "GET", "", http.StatusNotFound},
+		{"unknown distribution", "POST", `{"distribution": "bimodal"}`, http.StatusBadRequest},
+		{"unknown pattern", "POST", `{"pattern": "sawtooth"}`, http.StatusBadRequest},
+		{"too many submissions", "POST", `{"submissions": 1000000}`, http.StatusBadRequest},
+		{"invalid body", "POST", `{`, http.StatusBadRequest},
+		{"valid", "POST", `{"submissions": 1}`, http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, "/api/qa/simulate", bytes.NewBufferString(tt.body)))
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// RecordHoldConfig holds back scores that would take first place, so
+// moderators can catch fake records before they headline the board
+type RecordHoldConfig struct {
+	// Seconds is how long a record-breaking score is hidden before it is
+	// released automatically; zero disables the hold
+	Seconds int `json:"seconds,omitempty"`
+	// WebhookURL receives a POST for every held score
+	WebhookURL string `json:"webhookUrl,omitempty"`
+}
+
+// Enabled reports whether record-breaking scores are held
+func (c RecordHoldConfig) Enabled() bool {
+	return c.Seconds > 0
+}
+
+// RecordHold is the payload posted to the webhook for a held score
+type RecordHold struct {
+	Entry          ScoreEntry `json:"entry"`
+	PreviousRecord int        `json:"previousRecord"`
+	ReleasesAt     time.Time  `json:"releasesAt"`
+}
+
+// RecordHoldNotifier tells moderators about held scores through a webhook
+type RecordHoldNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewRecordHoldNotifier creates a notifier posting to url
+func NewRecordHoldNotifier(url string) *RecordHoldNotifier {
+	return &RecordHoldNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify posts a held score in the background. Failures are logged; the
+// score is still released when its hold expires.
+func (n *RecordHoldNotifier) Notify(hold RecordHold) {
+	go func() {
+		if err := n.post(hold); err != nil {
+			log.Printf("Warning: record hold webhook for %s failed: %v", hold.Entry.ID, err)
+		}
+	}()
+}
+
+// post sends a single webhook request
+func (n *RecordHoldNotifier) post(hold RecordHold) error {
+	body, err := json.Marshal(hold)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
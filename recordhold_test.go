@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test scores that would take first place are held and moderators notified
+func TestSubmitScoreRecordHold(t *testing.T) {
+	notified := make(chan RecordHold, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var hold RecordHold
+		json.NewDecoder(r.Body).Decode(&hold)
+		notified <- hold
+	}))
+	defer webhook.Close()
+
+	store := NewScoreStore()
+	store.SetConfig(BoardConfig{RecordHold: RecordHoldConfig{Seconds: 300}})
+	store.AddScore(context.Background(), 1000, "Champion")
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithRecordHoldWebhook(NewRecordHoldNotifier(webhook.URL)))
+	t.Cleanup(store.Close)
+
+	submit := func(score int) ScoreEntry {
+		body, _ := json.Marshal(map[string]interface{}{"score": score, "playerName": "Streamer"})
+		w := httptest.NewRecorder()
+		handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body)))
+		var entry ScoreEntry
+		json.NewDecoder(w.Body).Decode(&entry)
+		return entry
+	}
+
+	if entry := submit(900); entry.HeldUntil != nil {
+		t.Error("Expected a score below the record not to be held")
+	}
+
+	record := submit(99999)
+	if record.HeldUntil == nil {
+		t.Fatal("Expected a record-breaking score to be held")
+	}
+
+	select {
+	case hold := <-notified:
+		if hold.Entry.ID != record.ID || hold.PreviousRecord != 1000 {
+			t.Errorf("Expected webhook for %s replacing 1000, got %+v", record.ID, hold)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Expected the webhook to be notified")
+	}
+
+	if top := store.GetTopScores(context.Background(), 1); top[0].ID == record.ID {
+		t.Error("Expected the held score to be hidden from the leaderboard")
+	}
+	if queue := store.GetPendingEntries(context.Background()); len(queue) != 1 || queue[0].ID != record.ID {
+		t.Errorf("Expected the held score in the moderation queue, got %+v", queue)
+	}
+
+	// Approving releases the hold early
+	store.ApproveEntry(context.Background(), record.ID)
+	if top := store.GetTopScores(context.Background(), 1); top[0].ID != record.ID {
+		t.Error("Expected the approved record to lead the leaderboard")
+	}
+}
+
+// Test held scores appear on their own once the hold expires
+func TestRecordHoldExpires(t *testing.T) {
+	store := NewScoreStore()
+	expired := time.Now().Add(-time.Second)
+	entry := store.AddEntry(context.Background(), ScoreEntry{Score: 500, PlayerName: "Kiro", HeldUntil: &expired})
+
+	if top := store.GetTopScores(context.Background(), 0); len(top) != 1 || top[0].ID != entry.ID {
+		t.Error("Expected an expired hold to be released")
+	}
+	if queue := store.GetPendingEntries(context.Background()); len(queue) != 0 {
+		t.Errorf("Expected no held entries in the queue, got %d", len(queue))
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Errors returned by ReplayStore
+var (
+	ErrReplayTooLarge     = newKindError(ErrValidation, "replay exceeds maximum size")
+	ErrReplayNotGzip      = newKindError(ErrValidation, "replay must be gzip compressed")
+	ErrReplayExists       = newKindError(ErrConflict, "replay already uploaded")
+	ErrReplayNotFound     = newKindError(ErrNotFound, "replay not found")
+	ErrReplayInvalidEntry = newKindError(ErrValidation, "invalid entry ID")
+)
+
+// ReplayConfig configures storage of input replays
+type ReplayConfig struct {
+	// Dir is where replay blobs are stored, one file per entry
+	Dir string `json:"dir,omitempty"`
+	// MaxBytes is the largest accepted compressed replay
+	MaxBytes int `json:"maxBytes,omitempty"`
+	// UploadWindowMinutes is how long after submission a replay may be
+	// uploaded separately
+	UploadWindowMinutes int `json:"uploadWindowMinutes,omitempty"`
+	// MaxFrames is the longest replay that will be re-simulated
+	MaxFrames int `json:"maxFrames,omitempty"`
+	// SigningKey signs replay download links. When empty a random key is
+	// generated at startup, so links stop working after a restart.
+	SigningKey string `json:"signingKey,omitempty"`
+	// LinkTTLSeconds is how long a signed download link is valid.
+	// Defaults to 300.
+	LinkTTLSeconds int `json:"linkTtlSeconds,omitempty"`
+	// BytesPerSecond caps the bandwidth of each download. Defaults to
+	// 256 KiB/s.
+	BytesPerSecond int `json:"bytesPerSecond,omitempty"`
+	// MaxConcurrentDownloads caps simultaneous downloads; further requests
+	// get 429 Too Many Requests. Defaults to 16.
+	MaxConcurrentDownloads int `json:"maxConcurrentDownloads,omitempty"`
+}
+
+// gzipMagic is the header every gzip stream starts with
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// entryIDPattern restricts entry IDs used in file names
+var entryIDPattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// ReplayStore stores compressed input replays alongside entries and signs
+// links for downloading them
+type ReplayStore struct {
+	config    ReplayConfig
+	key       []byte
+	downloads chan struct{}
+	// durability is the boards' durability setting; with fsync, replays
+	// are flushed to disk before Save returns
+	durability string
+}
+
+// NewReplayStore creates a ReplayStore, applying defaults for unset config
+func NewReplayStore(config ReplayConfig) *ReplayStore {
+	if config.Dir == "" {
+		config.Dir = "replays"
+	}
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = 1 << 20
+	}
+	if config.UploadWindowMinutes <= 0 {
+		config.UploadWindowMinutes = 10
+	}
+	if config.MaxFrames <= 0 {
+		config.MaxFrames = 300000
+	}
+	if config.LinkTTLSeconds <= 0 {
+		config.LinkTTLSeconds = 300
+	}
+	if config.BytesPerSecond <= 0 {
+		config.BytesPerSecond = 256 << 10
+	}
+	if config.MaxConcurrentDownloads <= 0 {
+		config.MaxConcurrentDownloads = 16
+	}
+
+	key := []byte(config.SigningKey)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+
+	return &ReplayStore{
+		config:    config,
+		key:       key,
+		downloads: make(chan struct{}, config.MaxConcurrentDownloads),
+	}
+}
+
+// SetDurability saves replays as durably as the boards' entries, one of
+// the Durability constants
+func (rs *ReplayStore) SetDurability(durability string) {
+	rs.durability = durability
+}
+
+// Validate checks a replay blob without storing it
+func (rs *ReplayStore) Validate(data []byte) error {
+	if len(data) > rs.config.MaxBytes {
+		return ErrReplayTooLarge
+	}
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return ErrReplayNotGzip
+	}
+	return nil
+}
+
+// path returns the file path of an entry's replay
+func (rs *ReplayStore) path(entryID string) (string, error) {
+	if !entryIDPattern.MatchString(entryID) {
+		return "", ErrReplayInvalidEntry
+	}
+	return filepath.Join(rs.config.Dir, entryID+".replay.gz"), nil
+}
+
+// Save stores a replay for an entry. Replays are write-once.
+func (rs *ReplayStore) Save(entryID string, data []byte) error {
+	if err := rs.Validate(data); err != nil {
+		return err
+	}
+
+	path, err := rs.path(entryID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(rs.config.Dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return ErrReplayExists
+		}
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("writing replay: %w", err)
+	}
+	if rs.durability == DurabilityFsync {
+		if err := file.Sync(); err != nil {
+			os.Remove(path)
+			return fmt.Errorf("writing replay: %w", err)
+		}
+		return syncDir(rs.config.Dir)
+	}
+	return nil
+}
+
+// Delete removes an entry's replay, if it has one
+func (rs *ReplayStore) Delete(entryID string) error {
+	path, err := rs.path(entryID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Open returns the path of an entry's stored replay
+func (rs *ReplayStore) Open(entryID string) (string, error) {
+	path, err := rs.path(entryID)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrReplayNotFound
+	}
+	return path, nil
+}
+
+// Verify re-simulates an entry's stored replay against its level
+func (rs *ReplayStore) Verify(entryID string) (SimulationResult, error) {
+	path, err := rs.Open(entryID)
+	if err != nil {
+		return SimulationResult{}, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return SimulationResult{}, err
+	}
+	defer file.Close()
+
+	return SimulateReplay(file, rs.config.MaxFrames)
+}
+
+// signature returns the signature of a download link for an entry's replay
+func (rs *ReplayStore) signature(entryID string, expires int64) string {
+	mac := hmac.New(sha256.New, rs.key)
+	fmt.Fprintf(mac, "%s\n%d", entryID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignedURL returns a download link for an entry's replay and when it
+// expires
+func (rs *ReplayStore) SignedURL(entryID string, now time.Time) (string, time.Time) {
+	return rs.signedURL(entryID, now, time.Duration(rs.config.LinkTTLSeconds)*time.Second)
+}
+
+// signedURL returns a download link for an entry's replay valid for ttl,
+// and when it expires
+func (rs *ReplayStore) signedURL(entryID string, now time.Time, ttl time.Duration) (string, time.Time) {
+	expiresAt := now.Add(ttl).Truncate(time.Second)
+	query := url.Values{
+		"expires": {strconv.FormatInt(expiresAt.Unix(), 10)},
+		"sig":     {rs.signature(entryID, expiresAt.Unix())},
+	}
+	return "/api/leaderboard/" + entryID + "/replay?" + query.Encode(), expiresAt
+}
+
+// UploadToken returns the token that authorizes uploading a replay for an
+// entry after it was submitted. It is handed only to the submitter, so no
+// one else can attach a replay to their run.
+func (rs *ReplayStore) UploadToken(entryID string) string {
+	mac := hmac.New(sha256.New, rs.key)
+	fmt.Fprintf(mac, "upload\n%s", entryID)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckUploadToken reports whether token authorizes uploading a replay for
+// an entry
+func (rs *ReplayStore) CheckUploadToken(entryID, token string) bool {
+	return token != "" && hmac.Equal([]byte(token), []byte(rs.UploadToken(entryID)))
+}
+
+// CheckSignature reports whether a download link's expiry and signature
+// are valid for an entry at the given time
+func (rs *ReplayStore) CheckSignature(entryID, expires, sig string, now time.Time) bool {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(rs.signature(entryID, unix)))
+}
+
+// acquireDownload reserves a download slot, reporting false if all are in
+// use. Call releaseDownload when the download finishes.
+func (rs *ReplayStore) acquireDownload() bool {
+	select {
+	case rs.downloads <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseDownload frees a slot reserved by acquireDownload
+func (rs *ReplayStore) releaseDownload() {
+	<-rs.downloads
+}
+
+// throttledWriter caps the rate a response body is written at
+type throttledWriter struct {
+	http.ResponseWriter
+	bytesPerSecond int
+	start          time.Time
+	written        int
+}
+
+// newThrottledWriter wraps w to write at most bytesPerSecond
+func newThrottledWriter(w http.ResponseWriter, bytesPerSecond int) *throttledWriter {
+	return &throttledWriter{ResponseWriter: w, bytesPerSecond: bytesPerSecond, start: time.Now()}
+}
+
+// Write writes p in small chunks, sleeping whenever the writer gets ahead
+// of its rate
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	chunkSize := t.bytesPerSecond / 10
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+
+	total := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+		n, err := t.ResponseWriter.Write(chunk)
+		total += n
+		t.written += n
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+
+		due := time.Duration(float64(t.written) / float64(t.bytesPerSecond) * float64(time.Second))
+		if wait := due - time.Since(t.start); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gzipBytes compresses data for use as a replay blob
+func gzipBytes(data string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(data))
+	zw.Close()
+	return buf.Bytes()
+}
+
+// Test replays submitted inline can be downloaded
+func TestSubmitScoreWithReplay(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithReplays(NewReplayStore(ReplayConfig{Dir: t.TempDir()})))
+	t.Cleanup(store.Close)
+	replay := gzipBytes("RRRJJLLR")
+
+	body, _ := json.Marshal(map[string]interface{}{"score": 100, "playerName": "Speedy", "replay": replay})
+	req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.SubmitScore(w, req)
+
+	var entry ScoreEntry
+	json.NewDecoder(w.Body).Decode(&entry)
+	if w.Code != http.StatusCreated || !entry.HasReplay {
+		t.Fatalf("Expected 201 with hasReplay, got %d %+v", w.Code, entry)
+	}
+
+	req = routedRequest(t, "GET /api/leaderboard/{id}/replay/url", "/api/leaderboard/"+entry.ID+"/replay/url", nil)
+	w = httptest.NewRecorder()
+	handler.ReplayURL(w, req)
+	var link ReplayLink
+	json.NewDecoder(w.Body).Decode(&link)
+	if w.Code != http.StatusOK || link.URL == "" {
+		t.Fatalf("Expected a signed replay link, got %d %+v", w.Code, link)
+	}
+
+	req = routedRequest(t, "GET /api/leaderboard/{id}/replay", link.URL, nil)
+	w = httptest.NewRecorder()
+	handler.Replay(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	got, _ := io.ReadAll(w.Body)
+	if !bytes.Equal(got, replay) {
+		t.Errorf("Expected stored replay to be returned unchanged")
+	}
+
+	// Uncompressed replays are rejected
+	body, _ = json.Marshal(map[string]interface{}{"score": 100, "playerName": "Speedy", "replay": []byte("raw")})
+	req = httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	handler.SubmitScore(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+// Test replays can follow the submission once
+func TestUploadReplayFollowUp(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithReplays(NewReplayStore(ReplayConfig{Dir: t.TempDir(), MaxBytes: 64})))
+	t.Cleanup(store.Close)
+
+	// The submission returns the token for uploading its replay
+	w := httptest.NewRecorder()
+	handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", strings.NewReader(`{"playerName":"Later","score":100}`)))
+	var result SubmissionResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil || result.ReplayUploadToken == "" {
+		t.Fatalf("Expected an upload token in the submission result, got %+v (%v)", result, err)
+	}
+	entry := result.ScoreEntry
+	other := store.AddScore(context.Background(), 50, "Other")
+	url := "/api/leaderboard/" + entry.ID + "/replay"
+
+	tests := []struct {
+		name     string
+		token    string
+		body     []byte
+		wantCode int
+	}{
+		{"no token", "", gzipBytes("RRJ"), http.StatusForbidden},
+		{"another entry's token", handler.replays.UploadToken(other.ID), gzipBytes("RRJ"), http.StatusForbidden},
+		{"too large", result.ReplayUploadToken, append(gzipBytes(""), make([]byte, 100)...), http.StatusRequestEntityTooLarge},
+		{"first upload", result.ReplayUploadToken, gzipBytes("RRJ"), http.StatusOK},
+		{"second upload", result.ReplayUploadToken, gzipBytes("LLJ"), http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		req := routedRequest(t, "PUT /api/leaderboard/{id}/replay", url, bytes.NewReader(tt.body))
+		req.Header.Set(ReplayTokenHeader, tt.token)
+		w := httptest.NewRecorder()
+		handler.UploadReplay(w, req)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.wantCode, w.Code)
+		}
+	}
+
+	if stored, _ := store.GetEntry(context.Background(), entry.ID); !stored.HasReplay {
+		t.Error("Expected entry to be marked as having a replay")
+	}
+
+	req := routedRequest(t, "GET /api/leaderboard/{id}/replay", "/api/leaderboard/missing/replay", nil)
+	w = httptest.NewRecorder()
+	handler.Replay(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+// Test replay downloads need a valid, unexpired signed link
+func TestReplayDownloadSignature(t *testing.T) {
+	store := NewScoreStore()
+	replays := NewReplayStore(ReplayConfig{Dir: t.TempDir(), SigningKey: "secret", MaxConcurrentDownloads: 1})
+	handler := NewLeaderboardHandler(store, WithReplays(replays))
+	entry := store.AddScore(context.Background(), 100, "Linked")
+	other := store.AddScore(context.Background(), 50, "Other")
+	replays.Save(entry.ID, gzipBytes("RRJ"))
+
+	valid, _ := replays.SignedURL(entry.ID, time.Now())
+	expired, _ := replays.SignedURL(entry.ID, time.Now().Add(-time.Hour))
+	otherLink, _ := replays.SignedURL(other.ID, time.Now())
+	_, otherQuery, _ := strings.Cut(otherLink, "?")
+
+	tests := []struct {
+		name     string
+		url      string
+		wantCode int
+	}{
+		{"valid", valid, http.StatusOK},
+		{"unsigned", "/api/leaderboard/" + entry.ID + "/replay", http.StatusForbidden},
+		{"expired", expired, http.StatusForbidden},
+		{"tampered", strings.Replace(valid, "sig=", "sig=0", 1), http.StatusForbidden},
+		{"signed for another entry", "/api/leaderboard/" + entry.ID + "/replay?" + otherQuery, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.Replay(w, routedRequest(t, "GET /api/leaderboard/{id}/replay", tt.url, nil))
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+
+	// Links can't be issued for entries without a replay
+	w := httptest.NewRecorder()
+	handler.ReplayURL(w, routedRequest(t, "GET /api/leaderboard/{id}/replay/url", "/api/leaderboard/"+other.ID+"/replay/url", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	// Downloads beyond the concurrency limit are turned away
+	replays.acquireDownload()
+	w = httptest.NewRecorder()
+	handler.Replay(w, routedRequest(t, "GET /api/leaderboard/{id}/replay", valid, nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	replays.releaseDownload()
+}
+
+// Test only moderators get links to, or download through old links, the
+// replays of runs off the public board
+func TestReplayVisibility(t *testing.T) {
+	store := NewScoreStore()
+	replays := NewReplayStore(ReplayConfig{Dir: t.TempDir()})
+	handler := NewLeaderboardHandler(store, WithReplays(replays))
+	auth := NewAuthenticator()
+	auth.AddToken("mod-token", RoleModerator)
+
+	held := time.Now().Add(time.Hour)
+	listed := store.AddEntry(context.Background(), ScoreEntry{Score: 100, PlayerName: "Listed", HasReplay: true})
+	hidden := store.AddEntry(context.Background(), ScoreEntry{Score: 100, PlayerName: "Hidden", HasReplay: true, Hidden: true})
+	holding := store.AddEntry(context.Background(), ScoreEntry{Score: 100, PlayerName: "Held", HasReplay: true, HeldUntil: &held})
+	for _, entry := range []ScoreEntry{listed, hidden, holding} {
+		replays.Save(entry.ID, gzipBytes("RRJ"))
+	}
+
+	tests := []struct {
+		name      string
+		entry     ScoreEntry
+		token     string
+		wantLink  int
+		wantFetch int
+	}{
+		{"listed", listed, "", http.StatusOK, http.StatusOK},
+		{"hidden", hidden, "", http.StatusNotFound, http.StatusNotFound},
+		{"hidden to a moderator", hidden, "mod-token", http.StatusOK, http.StatusOK},
+		{"held", holding, "", http.StatusNotFound, http.StatusOK},
+		{"held to a moderator", holding, "mod-token", http.StatusOK, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			send := func(endpoint http.HandlerFunc, pattern, target string) int {
+				req := routedRequest(t, pattern, target, nil)
+				if tt.token != "" {
+					req.Header.Set("Authorization", "Bearer "+tt.token)
+				}
+				w := httptest.NewRecorder()
+				auth.Identify(endpoint)(w, req)
+				return w.Code
+			}
+
+			if code := send(handler.ReplayURL, "GET /api/leaderboard/{id}/replay/url", "/api/leaderboard/"+tt.entry.ID+"/replay/url"); code != tt.wantLink {
+				t.Errorf("Expected link status %d, got %d", tt.wantLink, code)
+			}
+			// Links signed earlier, or for the anti-cheat service
+			link, _ := replays.SignedURL(tt.entry.ID, time.Now())
+			if code := send(handler.Replay, "GET /api/leaderboard/{id}/replay", link); code != tt.wantFetch {
+				t.Errorf("Expected download status %d, got %d", tt.wantFetch, code)
+			}
+		})
+	}
+}
+
+// Test throttled downloads are written no faster than their rate
+func TestThrottledWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	throttled := newThrottledWriter(w, 1000)
+
+	start := time.Now()
+	n, err := throttled.Write(make([]byte, 200))
+	if err != nil || n != 200 {
+		t.Fatalf("Expected 200 bytes written, got %d %v", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("Expected 200 bytes at 1000 B/s to take about 200ms, took %v", elapsed)
+	}
+	if w.Body.Len() != 200 {
+		t.Errorf("Expected 200 bytes in the response, got %d", w.Body.Len())
+	}
+}
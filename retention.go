@@ -0,0 +1,175 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RetentionConfig limits how many entries a board keeps and for how long,
+// so its save file and memory use don't grow without bound. Entries under
+// review are never removed.
+type RetentionConfig struct {
+	// MaxEntries keeps only this many entries, removing the lowest ranked
+	// first; zero keeps every entry
+	MaxEntries int `json:"maxEntries,omitempty"`
+	// MaxAgeDays removes entries submitted more than this many days ago;
+	// zero keeps entries of any age
+	MaxAgeDays int `json:"maxAgeDays,omitempty"`
+	// IntervalMinutes between prunes. Defaults to 60.
+	IntervalMinutes int `json:"intervalMinutes,omitempty"`
+}
+
+// enabled reports whether the policy ever removes entries
+func (c RetentionConfig) enabled() bool {
+	return c.MaxEntries > 0 || c.MaxAgeDays > 0
+}
+
+// prunable returns the IDs of the entries in st the policy removes at now:
+// expired entries oldest first, then those beyond the cap lowest ranked
+// first
+func (c RetentionConfig) prunable(st *boardState, now time.Time) []string {
+	var cutoff time.Time
+	if c.MaxAgeDays > 0 {
+		cutoff = now.AddDate(0, 0, -c.MaxAgeDays)
+	}
+
+	// Runs awaiting a moderator or the anti-cheat service keep their place
+	// until they are settled
+	var expired, candidates []ScoreEntry
+	for _, entry := range st.entries {
+		switch {
+		case entry.Pending || entry.held(now) || entry.Verification == VerificationPending:
+		case entry.Timestamp.Before(cutoff):
+			expired = append(expired, entry)
+		default:
+			candidates = append(candidates, entry)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].Timestamp.Before(expired[j].Timestamp)
+	})
+
+	ids := make([]string, 0, len(expired))
+	for _, entry := range expired {
+		ids = append(ids, entry.ID)
+	}
+
+	if remaining := len(st.entries) - len(expired); c.MaxEntries > 0 && remaining > c.MaxEntries {
+		sort.Slice(candidates, func(i, j int) bool {
+			return st.config.before(candidates[j], candidates[i])
+		})
+		for _, entry := range candidates[:min(remaining-c.MaxEntries, len(candidates))] {
+			ids = append(ids, entry.ID)
+		}
+	}
+	return ids
+}
+
+// PruneResult reports one prune
+type PruneResult struct {
+	DryRun    bool      `json:"dryRun"`
+	StartedAt time.Time `json:"startedAt"`
+	// Pruned holds the IDs of the entries removed, lowest ranked first
+	Pruned []string `json:"pruned"`
+}
+
+// RetentionStatus reports the most recent prune and totals since startup
+type RetentionStatus struct {
+	LastRun     *PruneResult `json:"lastRun,omitempty"`
+	TotalPruned int          `json:"totalPruned"`
+}
+
+// Retention removes entries beyond a board's retention policy and saves
+// the board afterwards
+type Retention struct {
+	store  *ScoreStore
+	file   string
+	config RetentionConfig
+	status RetentionStatus
+	mu     sync.Mutex
+}
+
+// NewRetention creates a Retention for the board saved to file
+func NewRetention(store *ScoreStore, file string, config RetentionConfig) *Retention {
+	if config.IntervalMinutes <= 0 {
+		config.IntervalMinutes = 60
+	}
+
+	return &Retention{
+		store:  store,
+		file:   file,
+		config: config,
+	}
+}
+
+// Start prunes every configured interval in the background
+func (r *Retention) Start() {
+	if !r.config.enabled() {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(r.config.IntervalMinutes) * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			if result := r.Prune(r.store.Now(), false); len(result.Pruned) > 0 {
+				log.Printf("Retention removed %d entries", len(result.Pruned))
+			}
+		}
+	}()
+}
+
+// Prune removes the entries the retention policy no longer keeps. With
+// dryRun it only reports them.
+func (r *Retention) Prune(now time.Time, dryRun bool) PruneResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := PruneResult{DryRun: dryRun, StartedAt: now, Pruned: []string{}}
+	pick := func(st *boardState) []string {
+		return r.config.prunable(st, now)
+	}
+
+	if dryRun {
+		result.Pruned = append(result.Pruned, pick(r.store.load())...)
+	} else {
+		for _, entry := range r.store.removeEntries(pick) {
+			result.Pruned = append(result.Pruned, entry.ID)
+		}
+		if len(result.Pruned) > 0 {
+			if err := r.store.SaveToFile(r.file); err != nil {
+				log.Printf("Warning: Could not save leaderboard after retention: %v", err)
+			}
+		}
+		r.status.TotalPruned += len(result.Pruned)
+	}
+
+	r.status.LastRun = &result
+	return result
+}
+
+// Prunable returns the entries a prune at now would remove, in the order
+// it removes them
+func (r *Retention) Prunable(now time.Time) []ScoreEntry {
+	st := r.store.load()
+	byID := make(map[string]ScoreEntry, len(st.entries))
+	for _, entry := range st.entries {
+		byID[entry.ID] = entry
+	}
+
+	ids := r.config.prunable(st, now)
+	entries := make([]ScoreEntry, len(ids))
+	for i, id := range ids {
+		entries[i] = byID[id]
+	}
+	return entries
+}
+
+// Status returns the most recent prune and totals
+func (r *Retention) Status() RetentionStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
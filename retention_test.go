@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test the board is cut down to its cap lowest ranked first, keeping runs
+// under review, and dry runs only report what would go
+func TestRetentionMaxEntries(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "leaderboard.json")
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 900, "First")
+	low := store.AddScore(context.Background(), 100, "Low")
+	store.AddScore(context.Background(), 500, "Middle")
+	pending := store.AddEntry(context.Background(), ScoreEntry{Score: 50, PlayerName: "Pending", Pending: true})
+	lowest := store.AddScore(context.Background(), 10, "Lowest")
+
+	retention := NewRetention(store, file, RetentionConfig{MaxEntries: 3})
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		dryRun      bool
+		wantPruned  []string
+		wantEntries int
+	}{
+		{"dry run", true, []string{lowest.ID, low.ID}, 5},
+		{"prune", false, []string{lowest.ID, low.ID}, 3},
+		{"within cap", false, []string{}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := retention.Prune(now, tt.dryRun)
+			if result.DryRun != tt.dryRun {
+				t.Errorf("Expected dry run %v, got %v", tt.dryRun, result.DryRun)
+			}
+			if len(result.Pruned) != len(tt.wantPruned) {
+				t.Fatalf("Expected %d pruned, got %v", len(tt.wantPruned), result.Pruned)
+			}
+			for i, id := range tt.wantPruned {
+				if result.Pruned[i] != id {
+					t.Errorf("Expected %s pruned at %d, got %s", id, i, result.Pruned[i])
+				}
+			}
+			if n := len(store.GetAllEntries(context.Background())); n != tt.wantEntries {
+				t.Errorf("Expected %d entries left, got %d", tt.wantEntries, n)
+			}
+		})
+	}
+
+	if _, ok := store.GetEntry(context.Background(), pending.ID); !ok {
+		t.Error("Expected the run under review to be kept")
+	}
+
+	reloaded := NewScoreStore()
+	if err := reloaded.LoadFromFile(file); err != nil {
+		t.Fatalf("Failed to load pruned board: %v", err)
+	}
+	if n := len(reloaded.GetAllEntries(context.Background())); n != 3 {
+		t.Errorf("Expected the pruned board to be saved, got %d entries", n)
+	}
+}
+
+// Test entries older than the maximum age are pruned through the admin
+// endpoint, which reports them first on a dry run
+func TestRetentionMaxAge(t *testing.T) {
+	store := NewScoreStore()
+	old := ScoreEntry{ID: "old", Score: 900, PlayerName: "Veteran", Timestamp: time.Now().AddDate(0, 0, -100)}
+	store.restoreEntry(old)
+	store.AddScore(context.Background(), 100, "Newcomer")
+
+	retention := NewRetention(store, filepath.Join(t.TempDir(), "leaderboard.json"), RetentionConfig{MaxAgeDays: 90})
+	handler := NewAdminHandler(store, NewRuleSet(), "", WithRetention(retention))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/admin/retention", handler.GetRetention)
+	mux.HandleFunc("POST /api/admin/retention", handler.Retention)
+	mux.HandleFunc("/api/", unrouted(mux))
+
+	tests := []struct {
+		name        string
+		method      string
+		query       string
+		wantCode    int
+		wantPruned  []string
+		wantEntries int
+	}{
+		{"dry run", "POST", "?dryRun=true", http.StatusOK, []string{old.ID}, 2},
+		{"prune", "POST", "", http.StatusOK, []string{old.ID}, 1},
+		{"nothing left to prune", "POST", "", http.StatusOK, []string{}, 1},
+		{"wrong method", "DELETE", "", http.StatusMethodNotAllowed, nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(tt.method, "/api/admin/retention"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if n := len(store.GetAllEntries(context.Background())); n != tt.wantEntries {
+				t.Errorf("Expected %d entries left, got %d", tt.wantEntries, n)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var result PruneResult
+			if tt.query == "?dryRun=true" {
+				var dryRun DryRunResult
+				json.NewDecoder(w.Body).Decode(&dryRun)
+				for _, entry := range dryRun.Sample {
+					result.Pruned = append(result.Pruned, entry.ID)
+				}
+			} else {
+				json.NewDecoder(w.Body).Decode(&result)
+			}
+			if len(result.Pruned) != len(tt.wantPruned) || (len(tt.wantPruned) > 0 && result.Pruned[0] != tt.wantPruned[0]) {
+				t.Errorf("Expected %v pruned, got %v", tt.wantPruned, result.Pruned)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	handler.GetRetention(w, httptest.NewRequest("GET", "/api/admin/retention", nil))
+	var status RetentionStatus
+	json.NewDecoder(w.Body).Decode(&status)
+	if status.TotalPruned != 1 || status.LastRun == nil {
+		t.Errorf("Expected one entry pruned in total, got %+v", status)
+	}
+}
+
+// Test retention runs wait for a second admin under two-person approval
+func TestRetentionNeedsApproval(t *testing.T) {
+	store := NewScoreStore()
+	store.restoreEntry(ScoreEntry{ID: "old", Score: 900, PlayerName: "Veteran", Timestamp: time.Now().AddDate(0, 0, -100)})
+	retention := NewRetention(store, filepath.Join(t.TempDir(), "leaderboard.json"), RetentionConfig{MaxAgeDays: 90})
+	handler := NewAdminHandler(store, NewRuleSet(), "", WithRetention(retention), WithApprovals(NewApprovalQueue(time.Minute, SystemClock, IDGeneratorFunc(newUUID))))
+
+	w := httptest.NewRecorder()
+	handler.Retention(w, httptest.NewRequest("POST", "/api/admin/retention", nil))
+
+	var action PendingAction
+	json.NewDecoder(w.Body).Decode(&action)
+	if w.Code != http.StatusAccepted || action.Kind != "retention" || action.Affected != 1 {
+		t.Errorf("Expected the prune of 1 entry to be queued, got %d: %+v", w.Code, action)
+	}
+	if n := len(store.GetAllEntries(context.Background())); n != 1 {
+		t.Errorf("Expected nothing removed before approval, got %d entries", n)
+	}
+}
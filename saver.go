@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// maxSaveBackoff caps the delay between retries of a background save
+const maxSaveBackoff = 30 * time.Second
+
+// saver writes a store to one file in the background. Saves requested
+// while one is waiting or running are folded into the next, which writes
+// the board as it is then, so a burst of changes costs few writes and an
+// older board never overwrites a newer one. Failed saves are retried with
+// exponential backoff; each failure counts towards the store's
+// persistence status, so a save that keeps failing marks the store
+// unhealthy and closes its WriteGate.
+type saver struct {
+	store    *ScoreStore
+	filename string
+	requests chan struct{}
+	attempts int
+	backoff  time.Duration
+	// quit is closed to stop the worker, which closes done once it has
+	// written the last save requested
+	quit chan struct{}
+	done chan struct{}
+}
+
+// newSaver creates a saver and starts its worker
+func newSaver(store *ScoreStore, filename string, config PersistenceConfig) *saver {
+	sv := &saver{
+		store:    store,
+		filename: filename,
+		requests: make(chan struct{}, 1),
+		attempts: config.SaveAttempts,
+		backoff:  time.Duration(config.SaveBackoffMs) * time.Millisecond,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if sv.attempts <= 0 {
+		sv.attempts = 5
+	}
+	if sv.backoff <= 0 {
+		sv.backoff = 500 * time.Millisecond
+	}
+	go func() {
+		defer close(sv.done)
+		for {
+			select {
+			case <-sv.requests:
+				sv.save()
+			case <-sv.quit:
+				select {
+				case <-sv.requests:
+					sv.save()
+				default:
+				}
+				return
+			}
+		}
+	}()
+	return sv
+}
+
+// stop writes a save that was requested but not yet written, then stops
+// the worker. A save being retried gets one more attempt instead of
+// waiting out its backoff.
+func (sv *saver) stop() {
+	close(sv.quit)
+	<-sv.done
+}
+
+// request asks for a save without blocking
+func (sv *saver) request() {
+	select {
+	case sv.requests <- struct{}{}:
+	default:
+		// A save is already waiting and will include this change
+	}
+}
+
+// save writes the file, retrying failures until it has tried attempts
+// times. The next request tries again after it gives up.
+func (sv *saver) save() {
+	backoff := sv.backoff
+	attempts := sv.attempts
+	for attempt := 1; ; attempt++ {
+		// This attempt writes every change requested so far
+		select {
+		case <-sv.requests:
+		default:
+		}
+
+		err := sv.store.SaveToFile(sv.filename)
+		if err == nil {
+			return
+		}
+		if attempt >= attempts {
+			sv.store.persistence.abandon()
+			log.Printf("Error: Gave up saving %s after %d attempts; the next change will try again", sv.filename, attempt)
+			return
+		}
+
+		sv.store.persistence.retry()
+		log.Printf("Warning: Retrying save of %s in %s (attempt %d/%d)", sv.filename, backoff, attempt, attempts)
+		select {
+		case <-time.After(backoff):
+		case <-sv.quit:
+			// Stopping: make the next attempt the last
+			attempts = attempt + 1
+		}
+		backoff = min(backoff*2, maxSaveBackoff)
+	}
+}
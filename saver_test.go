@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitFor polls until done returns true, failing the test after a few
+// seconds
+func waitFor(t *testing.T, description string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s", description)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// savedEntries returns how many entries filename holds, or -1 if it can't
+// be loaded
+func savedEntries(filename string) int {
+	saved := NewScoreStore()
+	if err := saved.LoadFromFile(filename); err != nil {
+		return -1
+	}
+	return len(saved.GetAllEntries(context.Background()))
+}
+
+// Test failed background saves are retried until storage recovers
+func TestSaveInBackgroundRetries(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	filename := filepath.Join(dir, "leaderboard.json")
+	store := NewScoreStore(WithPersistence(PersistenceConfig{SaveAttempts: 1000, SaveBackoffMs: 1}))
+	t.Cleanup(store.Close)
+	store.AddScore(context.Background(), 100, "Kiro")
+
+	// The data directory does not exist yet, so the first attempts fail
+	store.SaveInBackground(filename)
+	waitFor(t, "a retry", func() bool { return store.PersistenceStatus().TotalRetries > 0 })
+
+	os.MkdirAll(dir, 0755)
+	waitFor(t, "the entry to be saved", func() bool { return savedEntries(filename) == 1 })
+
+	status := store.PersistenceStatus()
+	if status.ConsecutiveFailures != 0 || status.AbandonedSaves != 0 {
+		t.Errorf("Expected the save to recover, got %+v", status)
+	}
+}
+
+// Test a background save is given up on after its last attempt, marking
+// the store unhealthy
+func TestSaveInBackgroundGivesUp(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "missing", "leaderboard.json")
+	config := PersistenceConfig{FailureThreshold: 3, SaveAttempts: 3, SaveBackoffMs: 1}
+	store := NewScoreStore(WithPersistence(config))
+	t.Cleanup(store.Close)
+	gate := NewWriteGate(store, filename, config)
+
+	store.SaveInBackground(filename)
+	waitFor(t, "the save to be abandoned", func() bool { return store.PersistenceStatus().AbandonedSaves == 1 })
+
+	status := store.PersistenceStatus()
+	if status.TotalSaves != 3 || status.TotalRetries != 2 {
+		t.Errorf("Expected 3 attempts and 2 retries, got %+v", status)
+	}
+	if gate.Healthy() {
+		t.Error("Expected the gate to be unhealthy")
+	}
+}
+
+// Test closing a store writes the saves already requested, and stops a
+// save that is waiting to retry instead of waiting out its backoff
+func TestCloseFlushesSaves(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "leaderboard.json")
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 100, "Kiro")
+	store.SaveInBackground(filename)
+	store.Close()
+	if saved := savedEntries(filename); saved != 1 {
+		t.Errorf("Expected the requested save to be written by Close, got %d entries", saved)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing", "leaderboard.json")
+	retrying := NewScoreStore(WithPersistence(PersistenceConfig{SaveAttempts: 5, SaveBackoffMs: 3600000}))
+	retrying.SaveInBackground(missing)
+	waitFor(t, "a retry", func() bool { return retrying.PersistenceStatus().TotalRetries > 0 })
+
+	closed := make(chan struct{})
+	go func() {
+		retrying.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Close to stop the retrying save")
+	}
+	if status := retrying.PersistenceStatus(); status.AbandonedSaves != 1 || status.TotalSaves != 2 {
+		t.Errorf("Expected one last attempt before giving up, got %+v", status)
+	}
+}
+
+// Test saves replace the board file whole: a reader of the old file sees
+// it complete, and no temporary files are left behind, even when the
+// rename fails
+func TestSaveToFileReplacesWhole(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "leaderboard.json")
+	store := NewScoreStore(WithPersistence(PersistenceConfig{Durability: DurabilityFsync}))
+	store.AddScore(context.Background(), 100, "Kiro")
+	if err := store.SaveToFile(filename); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	old, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open the board: %v", err)
+	}
+	defer old.Close()
+	store.AddScore(context.Background(), 200, "Luigi")
+	if err := store.SaveToFile(filename); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	var entries []ScoreEntry
+	if err := json.NewDecoder(old).Decode(&entries); err != nil || len(entries) != 1 {
+		t.Errorf("Expected the old file to still hold 1 entry, got %d (%v)", len(entries), err)
+	}
+	if saved := savedEntries(filename); saved != 2 {
+		t.Errorf("Expected 2 entries saved, got %d", saved)
+	}
+	if info, err := os.Stat(filename); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("Expected the board to be readable by all, got %v (%v)", info.Mode(), err)
+	}
+
+	// A directory in the board's place can't be renamed over
+	blocked := filepath.Join(dir, "blocked")
+	os.MkdirAll(filepath.Join(blocked, "child"), 0755)
+	if err := store.SaveToFile(blocked); err == nil {
+		t.Error("Expected the save to fail")
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 2 {
+		t.Errorf("Expected only the board and the directory, got %d files", len(files))
+	}
+}
+
+// Test concurrent background saves end with the latest board saved
+func TestSaveInBackgroundLatestWins(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "leaderboard.json")
+	store := NewScoreStore()
+	t.Cleanup(store.Close)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store.AddScore(context.Background(), i, fmt.Sprintf("Player%d", i))
+			store.SaveInBackground(filename)
+		}(i)
+	}
+	wg.Wait()
+
+	waitFor(t, "every entry to be saved", func() bool { return savedEntries(filename) == 50 })
+}
+
+// Test submissions are only accepted once saved when durability asks for
+// it
+func TestSubmitScoreDurability(t *testing.T) {
+	tests := []struct {
+		durability string
+		// wantStatus and wantKept are the status and entries on the board
+		// after a submission that could not be saved
+		wantStatus int
+		wantKept   int
+	}{
+		{DurabilityAsync, http.StatusCreated, 1},
+		{DurabilitySync, http.StatusServiceUnavailable, 0},
+		{DurabilityFsync, http.StatusServiceUnavailable, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.durability, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "data")
+			filename := filepath.Join(dir, "leaderboard.json")
+			store := NewScoreStore(WithPersistence(PersistenceConfig{Durability: tt.durability, SaveAttempts: 1}))
+			handler := NewLeaderboardHandler(store, WithSaveFile(filename))
+			t.Cleanup(store.Close)
+			submit := func(name string) int {
+				w := httptest.NewRecorder()
+				handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", strings.NewReader(`{"playerName":"`+name+`","score":100}`)))
+				return w.Code
+			}
+
+			// The data directory does not exist, so saves fail
+			if code := submit("Kiro"); code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, code)
+			}
+			if kept := len(store.GetAllEntries(context.Background())); kept != tt.wantKept {
+				t.Errorf("Expected %d entries on the board, got %d", tt.wantKept, kept)
+			}
+
+			os.MkdirAll(dir, 0755)
+			if code := submit("Luigi"); code != http.StatusCreated {
+				t.Fatalf("Expected status %d once storage recovers, got %d", http.StatusCreated, code)
+			}
+			if saved := savedEntries(filename); tt.durability != DurabilityAsync && saved != tt.wantKept+1 {
+				t.Errorf("Expected %d entries saved before responding, got %d", tt.wantKept+1, saved)
+			}
+		})
+	}
+}
+
+// Test a submission that could not be saved is taken back whole: the runs
+// it replaced are put back and its replay and ghost are removed
+func TestSubmitScoreRollback(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "missing", "leaderboard.json")
+	store := NewScoreStore(WithPersistence(PersistenceConfig{Durability: DurabilitySync, SaveAttempts: 1}))
+	store.SetConfig(BoardConfig{BestPerPlayer: true})
+	t.Cleanup(store.Close)
+	best := store.AddScore(context.Background(), 100, "Kiro")
+
+	replayDir, ghostDir := t.TempDir(), t.TempDir()
+	handler := NewLeaderboardHandler(store, WithSaveFile(filename),
+		WithReplays(NewReplayStore(ReplayConfig{Dir: replayDir})),
+		WithGhosts(NewGhostStore(GhostConfig{Dir: ghostDir, MaxPoints: 10})))
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"playerName": "Kiro",
+		"score":      200,
+		"replay":     gzipBytes("RRRJJLLR"),
+		"ghost":      []GhostTrack{{Level: 1, IntervalMs: 100, Points: [][2]int{{0, 0}}}},
+	})
+	w := httptest.NewRecorder()
+	handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	entries := store.GetAllEntries(context.Background())
+	if len(entries) != 1 || entries[0].ID != best.ID {
+		t.Errorf("Expected the previous best back on the board, got %+v", entries)
+	}
+	for _, dir := range []string{replayDir, ghostDir} {
+		if files, _ := os.ReadDir(dir); len(files) != 0 {
+			t.Errorf("Expected no blobs left in %s, got %d", dir, len(files))
+		}
+	}
+}
+
+// Test a replay upload is only accepted once saved when durability asks
+// for it, and can be sent again after one that could not be
+func TestUploadReplayDurability(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	filename := filepath.Join(dir, "leaderboard.json")
+	store := NewScoreStore(WithPersistence(PersistenceConfig{Durability: DurabilityFsync, SaveAttempts: 1}))
+	t.Cleanup(store.Close)
+	entry := store.AddScore(context.Background(), 100, "Kiro")
+
+	replays := NewReplayStore(ReplayConfig{Dir: t.TempDir()})
+	replays.SetDurability(DurabilityFsync)
+	handler := NewLeaderboardHandler(store, WithSaveFile(filename), WithReplays(replays))
+	upload := func() int {
+		req := routedRequest(t, "PUT /api/leaderboard/{id}/replay", "/api/leaderboard/"+entry.ID+"/replay", bytes.NewReader(gzipBytes("RRJ")))
+		req.Header.Set(ReplayTokenHeader, replays.UploadToken(entry.ID))
+		w := httptest.NewRecorder()
+		handler.UploadReplay(w, req)
+		return w.Code
+	}
+
+	// The data directory does not exist, so saves fail
+	if code := upload(); code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status %d, got %d", http.StatusServiceUnavailable, code)
+	}
+	if stored, _ := store.GetEntry(context.Background(), entry.ID); stored.HasReplay {
+		t.Error("Expected the entry to be left without a replay")
+	}
+
+	os.MkdirAll(dir, 0755)
+	if code := upload(); code != http.StatusOK {
+		t.Fatalf("Expected status %d once storage recovers, got %d", http.StatusOK, code)
+	}
+	var saved []ScoreEntry
+	data, _ := os.ReadFile(filename)
+	if json.Unmarshal(data, &saved); len(saved) != 1 || !saved[0].HasReplay {
+		t.Errorf("Expected the replay to be saved before responding, got %+v", saved)
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Season states reported by GET /api/seasons
+const (
+	SeasonUpcoming = "upcoming"
+	SeasonActive   = "active"
+	SeasonEnded    = "ended"
+)
+
+// ErrSeasonNotFound is returned for operations on an unknown season ID
+var ErrSeasonNotFound = newKindError(ErrNotFound, "season not found")
+
+// Season is a stretch of time with its own board. When a season ends its
+// board is archived and the main board starts over.
+type Season struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name,omitempty"`
+	StartsAt time.Time `json:"startsAt"`
+	// EndsAt is when the season is scheduled to end; open-ended seasons
+	// run until an admin ends them
+	EndsAt *time.Time `json:"endsAt,omitempty"`
+	// EndedAt is when the season's board was archived
+	EndedAt *time.Time `json:"endedAt,omitempty"`
+}
+
+// state returns whether the season is upcoming, active or ended at now
+func (s Season) state(now time.Time) string {
+	switch {
+	case s.EndedAt != nil:
+		return SeasonEnded
+	case now.Before(s.StartsAt):
+		return SeasonUpcoming
+	default:
+		return SeasonActive
+	}
+}
+
+// end returns when the season ends or ended, zero if it is open-ended
+func (s Season) end() time.Time {
+	if s.EndedAt != nil {
+		return *s.EndedAt
+	}
+	if s.EndsAt != nil {
+		return *s.EndsAt
+	}
+	return time.Time{}
+}
+
+// SeasonListing is a season along with its current state
+type SeasonListing struct {
+	Season
+	State string `json:"state"`
+}
+
+// SeasonArchive is the saved board of an ended season. It keeps every
+// entry, including hidden ones, so the board can be queried like a live one.
+type SeasonArchive struct {
+	Season  Season       `json:"season"`
+	Entries []ScoreEntry `json:"entries"`
+}
+
+// SeasonManager schedules seasons on the main board and archives the board
+// at each rollover
+type SeasonManager struct {
+	store    *ScoreStore
+	filename string
+	dir      string
+	seasons  []Season
+	// archives caches the boards of ended seasons, which never change
+	archives map[string]*ScoreStore
+	mu       sync.Mutex
+}
+
+// NewSeasonManager creates a SeasonManager for store that saves the season
+// schedule to filename and archived boards to dir
+func NewSeasonManager(store *ScoreStore, filename, dir string) *SeasonManager {
+	return &SeasonManager{
+		store:    store,
+		filename: filename,
+		dir:      dir,
+		archives: make(map[string]*ScoreStore),
+	}
+}
+
+// save writes the season schedule. Callers hold the lock.
+func (m *SeasonManager) save() error {
+	data, err := json.MarshalIndent(m.seasons, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.filename, data, 0644)
+}
+
+// LoadFromFile loads the season schedule saved by a previous run. A
+// missing file leaves no seasons scheduled.
+func (m *SeasonManager) LoadFromFile() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := os.ReadFile(m.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(data, &m.seasons)
+}
+
+// Create schedules a new season. Seasons may not overlap, so it must start
+// no earlier than the end of the last season, which must have an end date.
+func (m *SeasonManager) Create(season Season) (Season, error) {
+	if !archiveNamePattern.MatchString(season.ID) {
+		return Season{}, newKindError(ErrValidation, "season ID may only contain letters, digits, - and _")
+	}
+	if season.StartsAt.IsZero() {
+		return Season{}, newKindError(ErrValidation, "startsAt is required")
+	}
+	if season.EndsAt != nil && !season.EndsAt.After(season.StartsAt) {
+		return Season{}, newKindError(ErrValidation, "endsAt must be after startsAt")
+	}
+	season.EndedAt = nil
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.seasons {
+		if existing.ID == season.ID {
+			return Season{}, newKindError(ErrConflict, "season "+season.ID+" already exists")
+		}
+	}
+	if len(m.seasons) > 0 {
+		last := m.seasons[len(m.seasons)-1]
+		if last.end().IsZero() {
+			return Season{}, newKindError(ErrConflict, "season "+last.ID+" has no end date")
+		}
+		if season.StartsAt.Before(last.end()) {
+			return Season{}, newKindError(ErrConflict, "season would overlap season "+last.ID)
+		}
+	}
+
+	m.seasons = append(m.seasons, season)
+	if err := m.save(); err != nil {
+		m.seasons = m.seasons[:len(m.seasons)-1]
+		return Season{}, err
+	}
+	return season, nil
+}
+
+// List returns every season, oldest first, with its state at now
+func (m *SeasonManager) List(now time.Time) []SeasonListing {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	listings := make([]SeasonListing, len(m.seasons))
+	for i, season := range m.seasons {
+		listings[i] = SeasonListing{Season: season, State: season.state(now)}
+	}
+	return listings
+}
+
+// Get returns a season by ID
+func (m *SeasonManager) Get(id string) (Season, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, season := range m.seasons {
+		if season.ID == id {
+			return season, true
+		}
+	}
+	return Season{}, false
+}
+
+// Board returns a season's board: the live board while the season is
+// active and its archive once it has ended
+func (m *SeasonManager) Board(id string, now time.Time) (*ScoreStore, error) {
+	season, ok := m.Get(id)
+	if !ok {
+		return nil, ErrSeasonNotFound
+	}
+
+	switch season.state(now) {
+	case SeasonUpcoming:
+		return nil, newKindError(ErrNotFound, "season "+id+" has not started")
+	case SeasonActive:
+		return m.store, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if board, ok := m.archives[id]; ok {
+		return board, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(m.dir, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var archive SeasonArchive
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, err
+	}
+
+	board := NewScoreStore()
+	board.SetConfig(m.store.Config())
+	for _, entry := range archive.Entries {
+		board.restoreEntry(entry)
+	}
+	m.archives[id] = board
+	return board, nil
+}
+
+// End archives an active season's board now, ahead of its scheduled end
+func (m *SeasonManager) End(id string, now time.Time) (Season, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, season := range m.seasons {
+		if season.ID != id {
+			continue
+		}
+		if season.state(now) != SeasonActive {
+			return Season{}, newKindError(ErrConflict, "season "+id+" is not active")
+		}
+		return m.rollover(i, now)
+	}
+	return Season{}, ErrSeasonNotFound
+}
+
+// Rollover archives the boards of active seasons whose scheduled end has
+// passed
+func (m *SeasonManager) Rollover(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, season := range m.seasons {
+		if season.state(now) == SeasonActive && season.EndsAt != nil && !now.Before(*season.EndsAt) {
+			if _, err := m.rollover(i, *season.EndsAt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rollover moves every entry on the board into the archive of season i and
+// marks it ended at endedAt. If the archive cannot be written the entries
+// are put back. Callers hold the lock.
+func (m *SeasonManager) rollover(i int, endedAt time.Time) (Season, error) {
+	season := m.seasons[i]
+	season.EndedAt = &endedAt
+
+	entries := m.store.TakeEntries(context.Background())
+	if err := m.writeArchive(SeasonArchive{Season: season, Entries: entries}); err != nil {
+		for _, entry := range entries {
+			m.store.restoreEntry(entry)
+		}
+		return Season{}, err
+	}
+	m.store.SaveInBackground(leaderboardFile)
+
+	m.seasons[i] = season
+	if err := m.save(); err != nil {
+		log.Printf("Warning: Could not save seasons: %v", err)
+	}
+	log.Printf("Season %s ended, archived %d entries", season.ID, len(entries))
+	return season, nil
+}
+
+// writeArchive saves an ended season's board. Existing archives are never
+// overwritten.
+func (m *SeasonManager) writeArchive(archive SeasonArchive) error {
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filepath.Join(m.dir, archive.Season.ID+".json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return newKindError(ErrConflict, "season "+archive.Season.ID+" is already archived")
+		}
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// Start checks for seasons due to end every interval in the background
+func (m *SeasonManager) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := m.Rollover(m.store.Now()); err != nil {
+				log.Printf("Warning: Could not roll over season: %v", err)
+			}
+		}
+	}()
+}
+
+// ListSeasons handles GET /api/seasons, listing every season, newest first,
+// with its state
+func (m *SeasonManager) ListSeasons(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	listings := m.List(m.store.Now())
+	sort.SliceStable(listings, func(i, j int) bool {
+		return listings[i].StartsAt.After(listings[j].StartsAt)
+	})
+	json.NewEncoder(w).Encode(listings)
+}
+
+// ServeSeason handles GET /api/seasons/{id}/leaderboard, which lists a
+// season's board with the same options as GET /api/leaderboard. Past
+// seasons are served from their archives.
+func (m *SeasonManager) ServeSeason(w http.ResponseWriter, r *http.Request) {
+	board, err := m.Board(r.PathValue("id"), m.store.Now())
+	if err != nil {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		writeError(w, r, err)
+		return
+	}
+	NewLeaderboardHandler(board).GetLeaderboard(w, r)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSeasons creates a SeasonManager saving to a temporary directory
+func newTestSeasons(t *testing.T, store *ScoreStore) *SeasonManager {
+	dir := t.TempDir()
+	return NewSeasonManager(store, filepath.Join(dir, "seasons.json"), filepath.Join(dir, "seasons"))
+}
+
+// Test seasons are validated and may not overlap
+func TestCreateSeason(t *testing.T) {
+	seasons := newTestSeasons(t, NewScoreStore())
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 3, 0)
+	later := end.AddDate(0, 3, 0)
+
+	tests := []struct {
+		name    string
+		season  Season
+		wantErr error
+	}{
+		{"first season", Season{ID: "s1", StartsAt: start, EndsAt: &end}, nil},
+		{"invalid ID", Season{ID: "season one", StartsAt: end}, ErrValidation},
+		{"missing start", Season{ID: "s2"}, ErrValidation},
+		{"ends before start", Season{ID: "s2", StartsAt: end, EndsAt: &start}, ErrValidation},
+		{"duplicate ID", Season{ID: "s1", StartsAt: later}, ErrConflict},
+		{"overlapping", Season{ID: "s2", StartsAt: start.AddDate(0, 1, 0)}, ErrConflict},
+		{"open-ended next season", Season{ID: "s2", StartsAt: end}, nil},
+		{"after open-ended season", Season{ID: "s3", StartsAt: later}, ErrConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := seasons.Create(tt.season)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Expected season to be created, got %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	reloaded := NewSeasonManager(NewScoreStore(), seasons.filename, seasons.dir)
+	if err := reloaded.LoadFromFile(); err != nil {
+		t.Fatalf("Failed to load seasons: %v", err)
+	}
+	if listings := reloaded.List(start); len(listings) != 2 || listings[0].State != SeasonActive || listings[1].State != SeasonUpcoming {
+		t.Errorf("Expected an active and an upcoming season after reload, got %+v", listings)
+	}
+}
+
+// Test seasons past their end are archived and the board starts over
+func TestSeasonRollover(t *testing.T) {
+	useTempSaveFiles(t)
+	store := NewScoreStore()
+	t.Cleanup(store.Close)
+	seasons := newTestSeasons(t, store)
+	now := time.Now()
+	end := now.Add(time.Hour)
+	seasons.Create(Season{ID: "s1", StartsAt: now.Add(-time.Hour), EndsAt: &end})
+
+	store.AddScore(context.Background(), 500, "Kiro")
+	store.AddEntry(context.Background(), ScoreEntry{Score: 900, PlayerName: "Cheater", Hidden: true})
+
+	if err := seasons.Rollover(now); err != nil {
+		t.Fatalf("Rollover failed: %v", err)
+	}
+	if n := len(store.GetAllEntries(context.Background())); n != 2 {
+		t.Fatalf("Expected the board to be kept before the season ends, got %d entries", n)
+	}
+
+	if err := seasons.Rollover(end); err != nil {
+		t.Fatalf("Rollover failed: %v", err)
+	}
+	if n := len(store.GetAllEntries(context.Background())); n != 0 {
+		t.Errorf("Expected a fresh board after the season ended, got %d entries", n)
+	}
+	season, _ := seasons.Get("s1")
+	if season.state(end) != SeasonEnded || !season.EndedAt.Equal(end) {
+		t.Errorf("Expected the season to have ended at its scheduled end, got %+v", season)
+	}
+
+	data, err := os.ReadFile(filepath.Join(seasons.dir, "s1.json"))
+	if err != nil {
+		t.Fatalf("Expected an archive of the season: %v", err)
+	}
+	var archive SeasonArchive
+	json.Unmarshal(data, &archive)
+	if archive.Season.ID != "s1" || len(archive.Entries) != 2 {
+		t.Errorf("Expected both entries archived for s1, got %+v", archive)
+	}
+
+	// Ended seasons are not archived again
+	store.AddScore(context.Background(), 100, "Next")
+	if err := seasons.Rollover(end.Add(time.Hour)); err != nil || len(store.GetAllEntries(context.Background())) != 1 {
+		t.Errorf("Expected later rollovers to leave the new board alone, got %v", err)
+	}
+}
+
+// Test admins can end the active season early and list seasons
+func TestEndSeason(t *testing.T) {
+	useTempSaveFiles(t)
+	store := NewScoreStore()
+	t.Cleanup(store.Close)
+	seasons := newTestSeasons(t, store)
+	handler := NewAdminHandler(store, NewRuleSet(), "", WithSeasons(seasons))
+	seasons.Create(Season{ID: "s1", Name: "Season One", StartsAt: time.Now().Add(-time.Hour)})
+	store.AddScore(context.Background(), 500, "Kiro")
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/admin/seasons/{id}/end", handler.EndSeason)
+	mux.HandleFunc("/api/", unrouted(mux))
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+	}{
+		{"dry run", "/api/admin/seasons/s1/end?dryRun=true", http.StatusOK},
+		{"end season", "/api/admin/seasons/s1/end", http.StatusOK},
+		{"already ended", "/api/admin/seasons/s1/end", http.StatusConflict},
+		{"unknown season", "/api/admin/seasons/s9/end", http.StatusNotFound},
+		{"unknown action", "/api/admin/seasons/s1/restart", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("POST", tt.path, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+
+	if n := len(store.GetAllEntries(context.Background())); n != 0 {
+		t.Errorf("Expected the board to be reset, got %d entries", n)
+	}
+
+	w := httptest.NewRecorder()
+	seasons.ListSeasons(w, httptest.NewRequest("GET", "/api/seasons", nil))
+	var listings []SeasonListing
+	json.NewDecoder(w.Body).Decode(&listings)
+	if len(listings) != 1 || listings[0].ID != "s1" || listings[0].State != SeasonEnded {
+		t.Errorf("Expected s1 to be listed as ended, got %+v", listings)
+	}
+
+	w = httptest.NewRecorder()
+	handler.CreateSeason(w, httptest.NewRequest("POST", "/api/admin/seasons", strings.NewReader(`{"id": "s2", "startsAt": "2099-01-01T00:00:00Z"}`)))
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+}
+
+// Test past seasons stay viewable from their archives after a reset
+func TestServeSeason(t *testing.T) {
+	useTempSaveFiles(t)
+	store := NewScoreStore()
+	t.Cleanup(store.Close)
+	seasons := newTestSeasons(t, store)
+	now := time.Now()
+	end := now.Add(-time.Minute)
+	seasons.Create(Season{ID: "s1", StartsAt: now.Add(-time.Hour), EndsAt: &end})
+	seasons.Create(Season{ID: "s2", StartsAt: end})
+	seasons.Create(Season{ID: "s3", StartsAt: now.Add(time.Hour)})
+
+	store.AddScore(context.Background(), 500, "Winner")
+	store.AddScore(context.Background(), 300, "RunnerUp")
+	store.AddEntry(context.Background(), ScoreEntry{Score: 900, PlayerName: "Cheater", Hidden: true})
+	if err := seasons.Rollover(now); err != nil {
+		t.Fatalf("Rollover failed: %v", err)
+	}
+	store.AddScore(context.Background(), 100, "Current")
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/seasons/{id}/leaderboard", seasons.ServeSeason)
+	mux.HandleFunc("/api/", unrouted(mux))
+
+	tests := []struct {
+		name      string
+		path      string
+		wantCode  int
+		wantNames []string
+	}{
+		{"past season", "/api/seasons/s1/leaderboard", http.StatusOK, []string{"Winner", "RunnerUp"}},
+		{"past season with options", "/api/seasons/s1/leaderboard?limit=1&offset=1", http.StatusOK, []string{"RunnerUp"}},
+		{"active season", "/api/seasons/s2/leaderboard", http.StatusOK, []string{"Current"}},
+		{"upcoming season", "/api/seasons/s3/leaderboard", http.StatusNotFound, nil},
+		{"unknown season", "/api/seasons/s9/leaderboard", http.StatusNotFound, nil},
+		{"unknown resource", "/api/seasons/s1/stats", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var scores []ScoreEntry
+			json.NewDecoder(w.Body).Decode(&scores)
+			if len(scores) != len(tt.wantNames) {
+				t.Fatalf("Expected %d scores, got %d", len(tt.wantNames), len(scores))
+			}
+			for i, score := range scores {
+				if score.PlayerName != tt.wantNames[i] {
+					t.Errorf("Expected %s at %d, got %s", tt.wantNames[i], i, score.PlayerName)
+				}
+			}
+		})
+	}
+}
@@ -1,26 +1,230 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"net"
 	"net/http"
+	"time"
 )
 
 func main() {
+	backfill := flag.Bool("backfill", false, "recompute derived data in leaderboard.json and exit")
+	dryRun := flag.Bool("dry-run", false, "with -backfill, report changes without writing them")
+	output := flag.String("output", "", "with -backfill, write the result to this file instead of leaderboard.json")
+	addr := flag.String("addr", ":3000", "address to listen on")
+	grpcAddr := flag.String("grpc-addr", "", "address to serve the gRPC API on, such as :3001; empty disables it")
+	qa := flag.Bool("qa", false, "use a separate test board and enable the traffic simulation endpoint")
+	flag.Parse()
+
+	// QA mode never touches the real leaderboard
+	if *qa {
+		leaderboardFile = "qa_leaderboard.json"
+		log.Printf("QA mode: using test board %s", leaderboardFile)
+	}
+
+	// Load server configuration
+	config, err := LoadConfig("config.json")
+	if err != nil {
+		log.Fatalf("Could not load config: %v", err)
+	}
+
+	if *backfill {
+		rules := NewRuleSet()
+		if err := rules.LoadFromFile("moderation_rules.json"); err != nil {
+			log.Fatalf("Could not load moderation rules: %v", err)
+		}
+		if err := runBackfillCommand(leaderboardFile, *output, *dryRun, config.Board, rules); err != nil {
+			log.Fatalf("Backfill failed: %v", err)
+		}
+		return
+	}
+
 	// Initialize leaderboard store
-	store := NewScoreStore()
+	ids, err := NewIDGenerator(config.IDs)
+	if err != nil {
+		log.Fatalf("Could not create ID generator: %v", err)
+	}
+	storeOpts := []StoreOption{WithIDGenerator(ids), WithPersistence(config.Persistence)}
+	store := NewScoreStore(storeOpts...)
+	store.SetConfig(config.Board)
 
 	// Load existing leaderboard data if available
-	if err := store.LoadFromFile("leaderboard.json"); err != nil {
+	if err := store.LoadFromFile(leaderboardFile); err != nil {
 		log.Printf("Warning: Could not load leaderboard data: %v", err)
 	}
 
+	// Load moderation rules evaluated on every submission
+	rules := NewRuleSet()
+	if err := rules.LoadFromFile("moderation_rules.json"); err != nil {
+		log.Fatalf("Could not load moderation rules: %v", err)
+	}
+
+	// Filter offensive player names
+	nameFilter, err := NewNameFilter(config.Names)
+	if err != nil {
+		log.Fatalf("Could not load blocked words: %v", err)
+	}
+
+	// Names players have claimed for their own use
+	nameClaims := NewNameClaims("claims.json")
+	if err := nameClaims.LoadFromFile(); err != nil {
+		log.Printf("Warning: Could not load name claims: %v", err)
+	}
+
+	// Friend lists of players with claimed names
+	friends := NewFriends("friends.json")
+	if err := friends.LoadFromFile(); err != nil {
+		log.Printf("Warning: Could not load friend lists: %v", err)
+	}
+
+	// Replay and ghost blobs, shared by every board
+	replays := NewReplayStore(config.Replay)
+	replays.SetDurability(config.Persistence.Durability)
+	ghosts := NewGhostStore(config.Ghost)
+
+	// Every board, the main one included, gets the same safety checks and
+	// upkeep, each working on the board's own store and save file
+	type boardUpkeep struct {
+		store     *ScoreStore
+		gate      *WriteGate
+		retention *Retention
+	}
+	upkeep := make(map[string]boardUpkeep)
+	boardServices := func(id string, store *ScoreStore, file string) []HandlerOption {
+		// Keep the board within its retention policy
+		retention := NewRetention(store, file, config.Retention)
+		retention.Start()
+
+		// Publish periodic public snapshots of the standings
+		NewArchiver(store, id, config.Archive).Start()
+
+		// Stop accepting scores while they cannot be saved
+		gate := NewWriteGate(store, file, config.Persistence)
+		upkeep[id] = boardUpkeep{store: store, gate: gate, retention: retention}
+
+		opts := []HandlerOption{
+			WithWriteGate(gate),
+			WithValidators(NewTelemetryVerifier(store)),
+			WithReplays(replays),
+			WithGhosts(ghosts),
+		}
+
+		// Tell moderators about record-breaking scores held for review
+		if url := store.Config().RecordHold.WebhookURL; url != "" {
+			opts = append(opts, WithRecordHoldWebhook(NewRecordHoldNotifier(url)))
+		}
+
+		// Forward flagged submissions to the anti-cheat service if configured
+		if config.AntiCheat.URL != "" {
+			antiCheat := NewAntiCheatClient(config.AntiCheat, store, file)
+			antiCheat.SetReplays(replays)
+			antiCheat.Start()
+			opts = append(opts, WithAntiCheat(antiCheat))
+		}
+		return opts
+	}
+
+	// Boards share name rules, friend lists and moderation rules
+	sharedOpts := []HandlerOption{
+		WithNameFilter(nameFilter),
+		WithNameClaims(nameClaims),
+		WithFriends(friends),
+		WithCountryHeader(config.Geo.CountryHeader),
+		WithCacheControl(config.Cache),
+		WithRuleSet(rules),
+	}
+
+	// Let clients retry submissions without creating duplicate entries.
+	// Keys are scoped to the board's path, so boards can share a cache.
+	if config.Idempotency.TTLSeconds >= 0 {
+		sharedOpts = append(sharedOpts, WithIdempotency(NewIdempotencyCache(config.Idempotency)))
+	}
+
+	// Announce top scores and records to webhook targets, and new leaders
+	// in the Discord channels of boards that have one
+	sharedOpts = append(sharedOpts, WithDiscord(NewDiscordNotifier()))
+	if len(config.Webhooks.Targets) > 0 {
+		webhooks := NewWebhooks(config.Webhooks)
+		webhooks.Start()
+		sharedOpts = append(sharedOpts, WithWebhooks(webhooks))
+	}
+
+	// Host the main board alongside the named boards, which keep their own
+	// entries. Name claims are checked against the entries of every board.
+	boards := NewBoardManager()
+
 	// Create leaderboard handler
-	leaderboardHandler := NewLeaderboardHandler(store)
+	handlerOpts := append([]HandlerOption{WithBoardID(MainBoard), WithPlayerBoards(boards)}, boardServices(MainBoard, store, leaderboardFile)...)
+	leaderboardHandler := NewLeaderboardHandler(store, append(handlerOpts, sharedOpts...)...)
+	boards.Add(MainBoard, leaderboardHandler)
+	for id, boardConfig := range config.Boards {
+		if _, err := boards.Open(id, boardConfig, storeOpts, boardServices, sharedOpts...); err != nil {
+			log.Fatalf("Could not load board %s: %v", id, err)
+		}
+	}
+
+	// Collect replay and ghost blobs whose entries are gone from every board
+	stores := make([]*ScoreStore, 0, len(upkeep))
+	for _, board := range upkeep {
+		stores = append(stores, board.store)
+	}
+	blobGC := NewBlobCollector(config.BlobGC, replays, ghosts, stores...)
+	blobGC.Start()
+
+	// Seasons reset the main board, archiving it, when they end
+	seasonsFile, seasonsDir := "seasons.json", "seasons"
+	if *qa {
+		seasonsFile, seasonsDir = "qa_seasons.json", "qa_seasons"
+	}
+	seasons := NewSeasonManager(store, seasonsFile, seasonsDir)
+	if err := seasons.LoadFromFile(); err != nil {
+		log.Printf("Warning: Could not load seasons: %v", err)
+	}
+	if err := seasons.Rollover(store.Now()); err != nil {
+		log.Printf("Warning: Could not roll over season: %v", err)
+	}
+	seasons.Start(time.Minute)
+
+	// Create admin handler and load role tokens
+	adminOpts := []AdminOption{WithBlobCollector(blobGC), WithRetention(upkeep[MainBoard].retention), WithAuditLog(NewAuditLog("audit.log")), WithSeasons(seasons), WithAdminNameFilter(nameFilter), WithPlayerAccounts(nameClaims, friends)}
+	for id := range config.Boards {
+		adminOpts = append(adminOpts, WithAdminBoard(id, upkeep[id].store, upkeep[id].retention))
+	}
+	if config.Admin.TwoPersonApproval {
+		window := time.Duration(config.Admin.ApprovalWindowMinutes) * time.Minute
+		adminOpts = append(adminOpts, WithApprovals(NewApprovalQueue(window, store, ids)))
+	}
+	adminHandler := NewAdminHandler(store, rules, "moderation_rules.json", adminOpts...)
+	auth := NewAuthenticatorFromEnv()
+
+	// Public snapshots of the standings
+	archiveHandler := NewArchiveHandler(config.Archive.Dir)
+
+	// Live updates of every board, and lobbies where players chat
+	liveHub := NewLiveHub(boards, nameClaims, config.Live)
+
+	// Count clients watching each board
+	presence := NewPresenceTracker(45*time.Second, PresenceLimits{Total: 100000, PerBoard: 50000, PerAddress: 20})
+	presence.Start()
+	presenceHandler := NewPresenceHandler(presence, boards, liveHub)
+
+	healthHandler := NewHealthHandler(store, upkeep[MainBoard].gate, blobGC, liveHub)
+	for _, id := range boards.IDs() {
+		if id != MainBoard {
+			healthHandler.AddBoard(id, upkeep[id].store, upkeep[id].gate)
+		}
+	}
 
 	// Static file server
 	fs := http.FileServer(http.Dir("./static"))
 	http.Handle("/static/", http.StripPrefix("/static/", fs))
 
+	// Requests no route matches get JSON errors rather than the game's
+	// page, and CORS preflight requests are answered for every route
+	http.HandleFunc("/api/", unrouted(http.DefaultServeMux))
+	http.HandleFunc("/archives/", unrouted(http.DefaultServeMux))
+
 	// Main page
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./static/index.html")
@@ -32,16 +236,132 @@ func main() {
 	})
 
 	// Leaderboard API endpoints
-	http.HandleFunc("/api/leaderboard", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "POST" || r.Method == "OPTIONS" {
-			leaderboardHandler.SubmitScore(w, r)
-		} else if r.Method == "GET" {
-			leaderboardHandler.GetLeaderboard(w, r)
-		} else {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	http.HandleFunc("GET /api/leaderboard", leaderboardHandler.GetLeaderboard)
+	http.HandleFunc("POST /api/leaderboard", leaderboardHandler.SubmitScore)
+
+	// Player name claims
+	http.HandleFunc("POST /api/names/claim", leaderboardHandler.ClaimName)
+
+	// Friend lists
+	http.HandleFunc("GET /api/friends", leaderboardHandler.Friends)
+	http.HandleFunc("POST /api/friends", leaderboardHandler.AddFriend)
+	http.HandleFunc("DELETE /api/friends/{name}", leaderboardHandler.RemoveFriend)
+
+	// Player profiles and data exports
+	http.HandleFunc("GET /api/players/{name}", leaderboardHandler.GetPlayerProfile)
+	http.HandleFunc("GET /api/players/{name}/export", leaderboardHandler.ExportPlayer)
+
+	// Ghost data of top runs
+	http.HandleFunc("GET /api/ghosts", leaderboardHandler.GetGhosts)
+
+	// How a score compares with the board
+	http.HandleFunc("GET /api/percentile", leaderboardHandler.GetPercentile)
+
+	// Entries around a player's best
+	http.HandleFunc("GET /api/leaderboard/around", leaderboardHandler.GetAround)
+
+	// Board statistics
+	http.HandleFunc("GET /api/leaderboard/stats", leaderboardHandler.GetStats)
+
+	// Player name search
+	http.HandleFunc("GET /api/leaderboard/search", leaderboardHandler.SearchPlayers)
+
+	// Regional boards
+	http.HandleFunc("GET /api/leaderboard/countries", leaderboardHandler.GetCountries)
+
+	// Board export
+	http.HandleFunc("GET /api/leaderboard/export", leaderboardHandler.ExportBoard)
+
+	// Entries added since a board version, for polling clients
+	http.HandleFunc("GET /api/leaderboard/changes", leaderboardHandler.GetChanges)
+
+	// Single entries and their replays
+	http.HandleFunc("GET /api/leaderboard/{id}", leaderboardHandler.GetEntry)
+	http.HandleFunc("DELETE /api/leaderboard/{id}", auth.Require(RoleModerator, adminHandler.DeleteEntry))
+	http.HandleFunc("GET /api/leaderboard/{id}/replay", auth.Identify(leaderboardHandler.Replay))
+	http.HandleFunc("PUT /api/leaderboard/{id}/replay", leaderboardHandler.UploadReplay)
+	http.HandleFunc("GET /api/leaderboard/{id}/replay/url", auth.Identify(leaderboardHandler.ReplayURL))
+
+	// Clients watching a board
+	http.HandleFunc("GET /api/leaderboard/{board}/presence", presenceHandler.GetPresence)
+	http.HandleFunc("POST /api/leaderboard/{board}/presence", presenceHandler.Heartbeat)
+
+	// WebSockets pushing board updates and relaying lobby chat
+	http.HandleFunc("GET /api/live", liveHub.ServeLive)
+	http.HandleFunc("GET /api/lobbies/{lobby}", liveHub.ServeLobby)
+	http.HandleFunc("GET /api/lobbies/{lobby}/presence", presenceHandler.GetLobbyPresence)
+
+	// Named boards, which serve the main board's endpoints
+	boards.Routes(http.DefaultServeMux)
+	http.HandleFunc("DELETE /api/boards/{board}/leaderboard/{id}", auth.Require(RoleModerator, adminHandler.DeleteEntry))
+
+	// OpenAPI document and interactive docs of the HTTP API
+	apiDocs := NewAPIDocsHandler()
+	http.HandleFunc("GET /api/openapi.json", apiDocs.ServeSpec)
+	http.HandleFunc("GET /api/docs", apiDocs.ServeDocs)
+
+	// GraphQL queries over every board
+	http.Handle("POST /api/graphql", NewGraphQLHandler(boards))
+
+	// Seasons
+	http.HandleFunc("GET /api/seasons", seasons.ListSeasons)
+	http.HandleFunc("GET /api/seasons/{id}/leaderboard", seasons.ServeSeason)
+
+	// Health check and metrics
+	http.HandleFunc("GET /api/health", healthHandler.ServeHealth)
+	http.HandleFunc("GET /metrics", healthHandler.ServeMetrics)
+
+	// Public snapshot archives
+	http.HandleFunc("GET /archives/{board}", archiveHandler.ListSnapshots)
+	http.HandleFunc("GET /archives/{board}/{file}", archiveHandler.ServeSnapshot)
+
+	// Moderator API endpoints
+	http.HandleFunc("GET /api/admin/flagged", auth.Require(RoleModerator, adminHandler.FlaggedEntries))
+	http.HandleFunc("GET /api/admin/queue", auth.Require(RoleModerator, adminHandler.PendingEntries))
+	http.HandleFunc("GET /api/admin/entries", auth.Require(RoleModerator, adminHandler.SearchEntries))
+	http.HandleFunc("POST /api/admin/entries/{id}/{action}", auth.Require(RoleModerator, adminHandler.EntryAction))
+	http.HandleFunc("GET /api/admin/hidden", auth.Require(RoleModerator, adminHandler.HiddenEntries))
+
+	// Admin API endpoints
+	http.HandleFunc("GET /api/admin/rules", auth.Require(RoleAdmin, adminHandler.Rules))
+	http.HandleFunc("POST /api/admin/rules", auth.Require(RoleAdmin, adminHandler.AddRule))
+	http.HandleFunc("DELETE /api/admin/rules/{name}", auth.Require(RoleAdmin, adminHandler.DeleteRule))
+	http.HandleFunc("GET /api/admin/backfill", auth.Require(RoleAdmin, adminHandler.GetBackfill))
+	http.HandleFunc("POST /api/admin/backfill", auth.Require(RoleAdmin, adminHandler.Backfill))
+	http.HandleFunc("GET /api/admin/gc", auth.Require(RoleAdmin, adminHandler.GetBlobGC))
+	http.HandleFunc("POST /api/admin/gc", auth.Require(RoleAdmin, adminHandler.BlobGC))
+	http.HandleFunc("GET /api/admin/retention", auth.Require(RoleAdmin, adminHandler.GetRetention))
+	http.HandleFunc("POST /api/admin/retention", auth.Require(RoleAdmin, adminHandler.Retention))
+	http.HandleFunc("DELETE /api/admin/players/{name}", auth.Require(RoleAdmin, adminHandler.DeletePlayer))
+	http.HandleFunc("DELETE /api/admin/purge", auth.Require(RoleAdmin, adminHandler.Purge))
+	http.HandleFunc("POST /api/admin/import", auth.Require(RoleAdmin, adminHandler.Import))
+	http.HandleFunc("GET /api/admin/audit", auth.Require(RoleAdmin, adminHandler.Audit))
+	http.HandleFunc("GET /api/admin/seasons", auth.Require(RoleAdmin, adminHandler.Seasons))
+	http.HandleFunc("POST /api/admin/seasons", auth.Require(RoleAdmin, adminHandler.CreateSeason))
+	http.HandleFunc("POST /api/admin/seasons/{id}/end", auth.Require(RoleAdmin, adminHandler.EndSeason))
+	http.HandleFunc("GET /api/admin/approvals", auth.Require(RoleAdmin, adminHandler.Approvals))
+	http.HandleFunc("POST /api/admin/approvals/{id}/approve", auth.Require(RoleAdmin, adminHandler.ApproveAction))
+	http.HandleFunc("DELETE /api/admin/approvals/{id}", auth.Require(RoleAdmin, adminHandler.CancelAction))
+
+	// Synthetic traffic for exercising the UI and moderation flows
+	if *qa {
+		qaHandler := NewQAHandler(NewTrafficSimulator(leaderboardHandler))
+		http.HandleFunc("GET /api/qa/simulate", auth.Require(RoleAdmin, qaHandler.GetSimulation))
+		http.HandleFunc("POST /api/qa/simulate", auth.Require(RoleAdmin, qaHandler.Simulate))
+	}
+
+	// Serve the gRPC API for native game-engine integrations
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatalf("Could not listen for gRPC: %v", err)
 		}
-	})
+		go func() {
+			log.Fatal(NewGRPCServer(boards).Serve(listener))
+		}()
+		log.Printf("gRPC server starting on %s", *grpcAddr)
+	}
 
-	log.Println("Server starting on http://localhost:3000")
-	log.Fatal(http.ListenAndServe(":3000", nil))
+	log.Printf("Server starting on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, withRequestID(http.DefaultServeMux)))
 }
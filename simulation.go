@@ -0,0 +1,433 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Replay input bits, one hex digit per frame
+const (
+	inputLeft  = 1
+	inputRight = 2
+	inputJump  = 4
+)
+
+// Errors returned when re-simulating replays
+var (
+	ErrReplayFormat     = errors.New("replay is not a valid input recording")
+	ErrReplayTooLong    = errors.New("replay exceeds maximum frame count")
+	ErrReplayBadStart   = errors.New("replay start state is not a valid level start")
+	ErrReplayUnfinished = errors.New("replay ends before the run does")
+)
+
+// ReplayData is the decompressed content of an input replay. Inputs holds
+// one hex digit per frame combining the inputLeft, inputRight and
+// inputJump bits. Start records the state of the moving parts of the level
+// when the run began, since restarting the game does not reset them.
+type ReplayData struct {
+	Version int         `json:"version"`
+	Level   int         `json:"level"`
+	Start   ReplayStart `json:"start"`
+	Inputs  string      `json:"inputs"`
+}
+
+// ReplayStart is the level state at the first frame of a replay
+type ReplayStart struct {
+	Player          ReplayPlayer  `json:"player"`
+	MovingPlatforms []ReplayMover `json:"movingPlatforms"`
+	Enemies         []ReplayMover `json:"enemies"`
+}
+
+// ReplayPlayer is the player state at the first frame of a replay
+type ReplayPlayer struct {
+	X              float64 `json:"x"`
+	Y              float64 `json:"y"`
+	VelocityX      float64 `json:"velocityX"`
+	VelocityY      float64 `json:"velocityY"`
+	OnGround       bool    `json:"onGround"`
+	JumpsRemaining int     `json:"jumpsRemaining"`
+}
+
+// ReplayMover is the position of a moving platform or enemy
+type ReplayMover struct {
+	X         float64 `json:"x"`
+	Direction float64 `json:"direction"`
+}
+
+// Rect is an axis-aligned box in level coordinates
+type Rect struct {
+	X, Y, Width, Height float64
+}
+
+// overlaps mirrors checkCollision in static/game.js
+func (r Rect) overlaps(o Rect) bool {
+	return r.X < o.X+o.Width &&
+		r.X+r.Width > o.X &&
+		r.Y < o.Y+o.Height &&
+		r.Y+r.Height > o.Y
+}
+
+// Mover is a platform or enemy patrolling between StartX and EndX
+type Mover struct {
+	Rect
+	StartX, EndX, Speed float64
+}
+
+// Level is the layout and physics of a level used to re-simulate replays.
+// It mirrors the level data in static/game.js and must be kept in sync.
+type Level struct {
+	Platforms       []Rect
+	MovingPlatforms []Mover
+	Enemies         []Mover
+	Coins           []Rect
+	ExtraLives      []Rect
+	EndFlag         Rect
+	Spawn           Rect
+	KillY           float64
+	Lives           int
+
+	// Physics values are variables rather than constants so products are
+	// rounded at run time exactly as the browser computes them
+	Speed, JumpPower, Gravity, Friction float64
+	CoinPoints, EnemyPoints, LifePoints int
+}
+
+// levels holds the levels replays can be simulated against, by number
+var levels = map[int]Level{
+	1: {
+		Platforms: []Rect{
+			{0, 550, 400, 50},
+			{500, 550, 300, 50},
+			{900, 550, 400, 50},
+			{1400, 550, 300, 50},
+			{1800, 550, 400, 50},
+			{2300, 550, 500, 50},
+			{600, 400, 150, 20},
+			{1000, 350, 150, 20},
+			{1500, 400, 150, 20},
+			{2000, 300, 150, 20},
+		},
+		MovingPlatforms: []Mover{
+			{Rect{1200, 450, 120, 20}, 1200, 1400, 2},
+			{Rect{2200, 400, 120, 20}, 2200, 2400, 1.5},
+		},
+		Enemies: []Mover{
+			{Rect{600, 520, 30, 30}, 500, 750, 1},
+			{Rect{1100, 520, 30, 30}, 900, 1250, 1.5},
+			{Rect{1900, 520, 30, 30}, 1800, 2100, 1.2},
+		},
+		Coins: []Rect{
+			{200, 500, 20, 20}, {250, 500, 20, 20}, {300, 500, 20, 20},
+			{650, 350, 20, 20}, {700, 350, 20, 20},
+			{1050, 300, 20, 20}, {1100, 300, 20, 20},
+			{1550, 350, 20, 20}, {1600, 350, 20, 20},
+			{2050, 250, 20, 20}, {2100, 250, 20, 20}, {2150, 250, 20, 20},
+			{2500, 500, 20, 20}, {2550, 500, 20, 20}, {2600, 500, 20, 20},
+		},
+		ExtraLives: []Rect{
+			{1300, 400, 25, 25},
+			{2300, 250, 25, 25},
+		},
+		EndFlag:     Rect{2700, 470, 40, 80},
+		Spawn:       Rect{100, 400, 40, 40},
+		KillY:       600,
+		Lives:       3,
+		Speed:       3.5,
+		JumpPower:   12,
+		Gravity:     0.45,
+		Friction:    0.8,
+		CoinPoints:  10,
+		EnemyPoints: 50,
+		LifePoints:  100,
+	},
+}
+
+// SimulationResult is the outcome of re-simulating a replay
+type SimulationResult struct {
+	Score         int  `json:"score"`
+	Frames        int  `json:"frames"`
+	LevelComplete bool `json:"levelComplete"`
+	GameOver      bool `json:"gameOver"`
+}
+
+// simulation is the mutable state of a level being replayed. Each step
+// mirrors one call of gameLoop in static/game.js.
+type simulation struct {
+	level         Level
+	player        ReplayPlayer
+	platforms     []Mover
+	enemies       []Mover
+	directions    []float64
+	enemyDirs     []float64
+	enemyAlive    []bool
+	coinTaken     []bool
+	lifeTaken     []bool
+	lives         int
+	score         int
+	gameOver      bool
+	levelComplete bool
+}
+
+// newSimulation sets up a level from a replay's start state
+func newSimulation(level Level, start ReplayStart) (*simulation, error) {
+	p := start.Player
+	if p.X != level.Spawn.X || p.Y != level.Spawn.Y || p.VelocityX != 0 || p.VelocityY != 0 || p.JumpsRemaining != 2 {
+		return nil, ErrReplayBadStart
+	}
+	if len(start.MovingPlatforms) != len(level.MovingPlatforms) || len(start.Enemies) != len(level.Enemies) {
+		return nil, ErrReplayBadStart
+	}
+
+	s := &simulation{
+		level:      level,
+		player:     p,
+		platforms:  append([]Mover(nil), level.MovingPlatforms...),
+		enemies:    append([]Mover(nil), level.Enemies...),
+		directions: make([]float64, len(level.MovingPlatforms)),
+		enemyDirs:  make([]float64, len(level.Enemies)),
+		enemyAlive: make([]bool, len(level.Enemies)),
+		coinTaken:  make([]bool, len(level.Coins)),
+		lifeTaken:  make([]bool, len(level.ExtraLives)),
+		lives:      level.Lives,
+	}
+
+	// Moving parts may start anywhere along their patrol
+	place := func(movers []Mover, dirs []float64, starts []ReplayMover) error {
+		for i, m := range starts {
+			if math.Abs(m.Direction) != 1 || m.X < movers[i].StartX-movers[i].Speed || m.X > movers[i].EndX+movers[i].Speed {
+				return ErrReplayBadStart
+			}
+			movers[i].X = m.X
+			dirs[i] = m.Direction
+		}
+		return nil
+	}
+	if err := place(s.platforms, s.directions, start.MovingPlatforms); err != nil {
+		return nil, err
+	}
+	if err := place(s.enemies, s.enemyDirs, start.Enemies); err != nil {
+		return nil, err
+	}
+	for i := range s.enemyAlive {
+		s.enemyAlive[i] = true
+	}
+	return s, nil
+}
+
+// playerRect returns the player's bounding box
+func (s *simulation) playerRect() Rect {
+	return Rect{s.player.X, s.player.Y, s.level.Spawn.Width, s.level.Spawn.Height}
+}
+
+// step advances the simulation by one frame with the given input bits
+func (s *simulation) step(input int) {
+	s.updatePlayer(input)
+	s.updateMovingPlatforms()
+	s.updateEnemies()
+	s.checkPickups()
+	if s.playerRect().overlaps(s.level.EndFlag) {
+		s.levelComplete = true
+	}
+}
+
+// updatePlayer mirrors updatePlayer, JumpController.handleJump and
+// AnimationController.applyFlyingPhysics
+func (s *simulation) updatePlayer(input int) {
+	p := &s.player
+	l := s.level
+
+	if input&inputLeft != 0 {
+		p.VelocityX = -l.Speed
+	} else if input&inputRight != 0 {
+		p.VelocityX = l.Speed
+	} else {
+		p.VelocityX *= l.Friction
+	}
+
+	if input&inputJump != 0 && p.JumpsRemaining > 0 {
+		p.VelocityY = -l.JumpPower
+		p.JumpsRemaining--
+		p.OnGround = false
+	}
+
+	// Explicit conversions stop the compiler fusing multiply-adds, which
+	// would round differently from the browser
+	if !p.OnGround {
+		if math.Abs(p.VelocityY) < 2 {
+			p.VelocityY += float64(l.Gravity * 0.3)
+		} else if p.VelocityY > 0 {
+			p.VelocityY += float64(l.Gravity * 0.7)
+		} else {
+			p.VelocityY += l.Gravity
+		}
+	} else {
+		p.VelocityY += l.Gravity
+	}
+
+	p.X += p.VelocityX
+	p.Y += p.VelocityY
+	p.OnGround = false
+
+	for _, platform := range l.Platforms {
+		if !s.playerRect().overlaps(platform) {
+			continue
+		}
+		if p.VelocityY > 0 && p.Y+l.Spawn.Height-p.VelocityY <= platform.Y {
+			p.Y = platform.Y - l.Spawn.Height
+			p.VelocityY = 0
+			p.OnGround = true
+			p.JumpsRemaining = 2
+		} else if p.VelocityY < 0 && p.Y-p.VelocityY >= platform.Y+platform.Height {
+			p.Y = platform.Y + platform.Height
+			p.VelocityY = 0
+		} else if p.VelocityX > 0 {
+			p.X = platform.X - l.Spawn.Width
+		} else if p.VelocityX < 0 {
+			p.X = platform.X + platform.Width
+		}
+	}
+
+	for i, platform := range s.platforms {
+		if !s.playerRect().overlaps(platform.Rect) {
+			continue
+		}
+		if p.VelocityY > 0 && p.Y+l.Spawn.Height-p.VelocityY <= platform.Y {
+			p.Y = platform.Y - l.Spawn.Height
+			p.VelocityY = 0
+			p.OnGround = true
+			p.X += float64(platform.Speed * s.directions[i])
+			p.JumpsRemaining = 2
+		}
+	}
+
+	if p.Y > l.KillY {
+		s.loseLife()
+	}
+}
+
+// updateMovingPlatforms mirrors updateMovingPlatforms
+func (s *simulation) updateMovingPlatforms() {
+	for i := range s.platforms {
+		platform := &s.platforms[i]
+		platform.X += float64(platform.Speed * s.directions[i])
+		if platform.X >= platform.EndX || platform.X <= platform.StartX {
+			s.directions[i] *= -1
+		}
+	}
+}
+
+// updateEnemies mirrors updateEnemies
+func (s *simulation) updateEnemies() {
+	p := &s.player
+	for i := range s.enemies {
+		if !s.enemyAlive[i] {
+			continue
+		}
+		enemy := &s.enemies[i]
+		enemy.X += float64(enemy.Speed * s.enemyDirs[i])
+		if enemy.X >= enemy.EndX || enemy.X <= enemy.StartX {
+			s.enemyDirs[i] *= -1
+		}
+
+		if !s.playerRect().overlaps(enemy.Rect) {
+			continue
+		}
+		if p.VelocityY > 0 && p.Y+s.level.Spawn.Height-p.VelocityY <= enemy.Y+10 {
+			s.enemyAlive[i] = false
+			p.VelocityY = -8
+			s.score += s.level.EnemyPoints
+			p.JumpsRemaining = 2
+		} else {
+			s.loseLife()
+		}
+	}
+}
+
+// checkPickups mirrors checkCoins and checkExtraLives
+func (s *simulation) checkPickups() {
+	for i, coin := range s.level.Coins {
+		if !s.coinTaken[i] && s.playerRect().overlaps(coin) {
+			s.coinTaken[i] = true
+			s.score += s.level.CoinPoints
+		}
+	}
+	for i, life := range s.level.ExtraLives {
+		if !s.lifeTaken[i] && s.playerRect().overlaps(life) {
+			s.lifeTaken[i] = true
+			s.lives++
+			s.score += s.level.LifePoints
+		}
+	}
+}
+
+// loseLife mirrors loseLife; like the game it leaves OnGround as it was
+func (s *simulation) loseLife() {
+	s.lives--
+	if s.lives <= 0 {
+		s.gameOver = true
+		return
+	}
+	p := &s.player
+	p.X = s.level.Spawn.X
+	p.Y = s.level.Spawn.Y
+	p.VelocityX = 0
+	p.VelocityY = 0
+	p.JumpsRemaining = 2
+}
+
+// SimulateReplay decompresses a replay and plays its inputs against the
+// level it was recorded on, stopping when the run ends as the game does.
+// Replays longer than maxFrames are rejected.
+func SimulateReplay(compressed io.Reader, maxFrames int) (SimulationResult, error) {
+	zr, err := gzip.NewReader(compressed)
+	if err != nil {
+		return SimulationResult{}, ErrReplayFormat
+	}
+	defer zr.Close()
+
+	// Bound decompression so a small blob cannot expand without limit
+	var data ReplayData
+	limit := int64(maxFrames) + 64*1024
+	if err := json.NewDecoder(io.LimitReader(zr, limit)).Decode(&data); err != nil {
+		return SimulationResult{}, ErrReplayFormat
+	}
+	if len(data.Inputs) > maxFrames {
+		return SimulationResult{}, ErrReplayTooLong
+	}
+
+	level, ok := levels[data.Level]
+	if data.Version != 1 || !ok {
+		return SimulationResult{}, fmt.Errorf("%w: unsupported version or level", ErrReplayFormat)
+	}
+
+	sim, err := newSimulation(level, data.Start)
+	if err != nil {
+		return SimulationResult{}, err
+	}
+
+	result := SimulationResult{}
+	for _, c := range data.Inputs {
+		if sim.gameOver || sim.levelComplete {
+			break
+		}
+		input, err := strconv.ParseUint(string(c), 16, 8)
+		if err != nil {
+			return SimulationResult{}, ErrReplayFormat
+		}
+		sim.step(int(input))
+		result.Frames++
+	}
+
+	result.Score = sim.score
+	result.GameOver = sim.gameOver
+	result.LevelComplete = sim.levelComplete
+	if !sim.gameOver && !sim.levelComplete {
+		return result, ErrReplayUnfinished
+	}
+	return result, nil
+}
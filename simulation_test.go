@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// levelStart is the level state when the game is first loaded
+var levelStart = ReplayStart{
+	Player:          ReplayPlayer{X: 100, Y: 400, JumpsRemaining: 2},
+	MovingPlatforms: []ReplayMover{{1200, 1}, {2200, 1}},
+	Enemies:         []ReplayMover{{600, 1}, {1100, 1}, {1900, 1}},
+}
+
+// replayBlob builds a compressed replay of level 1
+func replayBlob(start ReplayStart, inputs string) []byte {
+	data, _ := json.Marshal(ReplayData{Version: 1, Level: 1, Start: start, Inputs: inputs})
+	return gzipBytes(string(data))
+}
+
+// hopInputs holds right and jumps every 40 frames, after an idle first
+// frame like a run started by a key press
+func hopInputs(frames int) string {
+	var b strings.Builder
+	b.WriteByte('0')
+	for i := 1; i < frames; i++ {
+		if i%40 == 0 {
+			b.WriteByte('6')
+		} else {
+			b.WriteByte('2')
+		}
+	}
+	return b.String()
+}
+
+// Test replays reproduce the outcome of the same inputs in the browser
+func TestSimulateReplay(t *testing.T) {
+	badStart := levelStart
+	badStart.Player.X = 2650
+
+	tests := []struct {
+		name       string
+		blob       []byte
+		maxFrames  int
+		wantErr    error
+		wantScore  int
+		wantFrames int
+	}{
+		{"hold right", replayBlob(levelStart, "0"+strings.Repeat("2", 5000)), 10000, nil, 30, 343},
+		{"hop right", replayBlob(levelStart, hopInputs(5000)), 10000, nil, 150, 1895},
+		{"unfinished", replayBlob(levelStart, strings.Repeat("2", 100)), 10000, ErrReplayUnfinished, 30, 100},
+		{"start at the flag", replayBlob(badStart, "2"), 10000, ErrReplayBadStart, 0, 0},
+		{"too long", replayBlob(levelStart, strings.Repeat("0", 1001)), 1000, ErrReplayTooLong, 0, 0},
+		{"bad input", replayBlob(levelStart, "2x"), 10000, ErrReplayFormat, 0, 0},
+		{"not gzip", []byte("{}"), 10000, ErrReplayFormat, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := SimulateReplay(bytes.NewReader(tt.blob), tt.maxFrames)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if result.Score != tt.wantScore || result.Frames != tt.wantFrames {
+				t.Errorf("Expected score %d after %d frames, got %d after %d", tt.wantScore, tt.wantFrames, result.Score, result.Frames)
+			}
+		})
+	}
+}
+
+// Test entries are replay-verified only when the replay reproduces the score
+func TestSubmitScoreReplayVerified(t *testing.T) {
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithReplays(NewReplayStore(ReplayConfig{Dir: t.TempDir()})))
+	t.Cleanup(store.Close)
+	replay := replayBlob(levelStart, hopInputs(5000))
+
+	for _, score := range []int{150, 9000} {
+		body, _ := json.Marshal(map[string]interface{}{"score": score, "playerName": "Hopper", "replay": replay})
+		req := httptest.NewRequest("POST", "/api/leaderboard", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.SubmitScore(w, req)
+
+		var entry ScoreEntry
+		json.NewDecoder(w.Body).Decode(&entry)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+		if entry.ReplayVerified != (score == 150) {
+			t.Errorf("Score %d: expected replayVerified=%v, got %v", score, score == 150, entry.ReplayVerified)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/leaderboard?verified=true", nil)
+	w := httptest.NewRecorder()
+	handler.GetLeaderboard(w, req)
+
+	var scores []ScoreEntry
+	json.NewDecoder(w.Body).Decode(&scores)
+	if len(scores) != 1 || scores[0].Score != 150 {
+		t.Errorf("Expected only the verified run, got %v", scores)
+	}
+}
@@ -1442,22 +1442,80 @@ const WorldMap = {
 // LeaderboardAPI - Handles communication with backend API
 const LeaderboardAPI = {
     BASE_URL: '/api/leaderboard',
+    CLAIM_URL: '/api/names/claim',
+    TOKENS_KEY: 'superKiroWorld_playerTokens',
+    DEVICE_TOKEN_KEY: 'superKiroWorld_deviceToken',
     TIMEOUT_MS: 5000,
     
-    // Submit score to backend
-    async submitScore(score, playerName) {
+    // A secret this browser sends with runs under unclaimed names, so it can
+    // later prove they are its own when claiming the name
+    getDeviceToken() {
+        let token = localStorage.getItem(this.DEVICE_TOKEN_KEY);
+        if (!token) {
+            const bytes = crypto.getRandomValues(new Uint8Array(16));
+            token = Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('');
+            localStorage.setItem(this.DEVICE_TOKEN_KEY, token);
+        }
+        return token;
+    },
+    
+    // Tokens for names this browser has claimed, keyed by name
+    getPlayerTokens() {
+        try {
+            return JSON.parse(localStorage.getItem(this.TOKENS_KEY)) || {};
+        } catch (error) {
+            return {};
+        }
+    },
+    
+    // Claim a player name so only this browser can submit scores under it
+    async claimName(playerName) {
+        try {
+            const response = await fetch(this.CLAIM_URL, {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    'X-Player-Token': this.getDeviceToken()
+                },
+                body: JSON.stringify({ playerName: playerName })
+            });
+            
+            if (response.status === 409) {
+                const conflict = await response.json();
+                throw new Error(`Name is taken${conflict.suggestion ? ` - try ${conflict.suggestion}` : ''}`);
+            } else if (!response.ok) {
+                const message = await this.errorDetail(response);
+                throw new Error(message || `Failed to claim name (${response.status})`);
+            }
+            
+            const claim = await response.json();
+            const tokens = this.getPlayerTokens();
+            tokens[claim.playerName] = claim.token;
+            localStorage.setItem(this.TOKENS_KEY, JSON.stringify(tokens));
+            return claim;
+        } catch (error) {
+            return this.handleError(error);
+        }
+    },
+    
+    // Submit score to backend, optionally with run telemetry for verification
+    async submitScore(score, playerName, run = null) {
         try {
             const controller = new AbortController();
             const timeoutId = setTimeout(() => controller.abort(), this.TIMEOUT_MS);
             
+            const headers = {
+                'Content-Type': 'application/json'
+            };
+            headers['X-Player-Token'] = this.getPlayerTokens()[playerName] || this.getDeviceToken();
+            
             const response = await fetch(this.BASE_URL, {
                 method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json'
-                },
+                headers: headers,
                 body: JSON.stringify({
                     score: score,
-                    playerName: playerName
+                    playerName: playerName,
+                    ...(run || {})
                 }),
                 signal: controller.signal
             });
@@ -1471,7 +1529,14 @@ const LeaderboardAPI = {
                 } else if (response.status === 429) {
                     throw new Error('Too many requests - please wait a moment');
                 } else if (response.status === 400) {
-                    throw new Error('Invalid score data');
+                    // The server explains what was wrong, such as a disallowed name
+                    const message = await this.errorDetail(response);
+                    throw new Error(message || 'Invalid score data');
+                } else if (response.status === 409) {
+                    const conflict = await response.json();
+                    throw new Error(`Name is claimed by another player${conflict.suggestion ? ` - try ${conflict.suggestion}` : ''}`);
+                } else if (response.status === 422) {
+                    throw new Error('Score rejected - exceeds what the game allows');
                 } else {
                     throw new Error(`Failed to submit score (${response.status})`);
                 }
@@ -1484,14 +1549,18 @@ const LeaderboardAPI = {
         }
     },
     
-    // Get top leaderboard entries
-    async getLeaderboard(limit = 10) {
+    // Get top leaderboard entries, optionally from one country
+    async getLeaderboard(limit = 10, country = null) {
         try {
             const controller = new AbortController();
             const timeoutId = setTimeout(() => controller.abort(), this.TIMEOUT_MS);
             
-            const response = await fetch(`${this.BASE_URL}?limit=${limit}`, {
+            const countryParam = country ? `&country=${encodeURIComponent(country)}` : '';
+            // Revalidate rather than reuse a cached board, which may predate
+            // the player's own submission; unchanged boards answer 304
+            const response = await fetch(`${this.BASE_URL}?limit=${limit}${countryParam}`, {
                 method: 'GET',
+                cache: 'no-cache',
                 signal: controller.signal
             });
             
@@ -1513,6 +1582,39 @@ const LeaderboardAPI = {
         }
     },
     
+    // Announce this client as watching a board and get the viewer count
+    async sendPresence(board, clientId) {
+        try {
+            const response = await fetch(`${this.BASE_URL}/${board}/presence`, {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json'
+                },
+                body: JSON.stringify({ clientId: clientId })
+            });
+            
+            if (!response.ok) {
+                throw new Error(`Failed to send presence (${response.status})`);
+            }
+            
+            const data = await response.json();
+            return data;
+        } catch (error) {
+            return this.handleError(error);
+        }
+    },
+    
+    // Read the message of an error response, which is a problem+json
+    // document whose detail explains what was wrong
+    async errorDetail(response) {
+        const body = (await response.text()).trim();
+        try {
+            return JSON.parse(body).detail || '';
+        } catch {
+            return body;
+        }
+    },
+    
     // Handle API errors
     handleError(error) {
         let userMessage;
@@ -1539,13 +1641,39 @@ const LeaderboardAPI = {
     }
 };
 
+// Presence - Tells the server this client is watching the board
+const Presence = {
+    BOARD: 'main',
+    HEARTBEAT_MS: 20000,
+    clientId: Math.random().toString(36).slice(2) + Date.now().toString(36),
+    watching: 0,
+    
+    // Send a heartbeat now and then periodically
+    start() {
+        this.heartbeat();
+        setInterval(() => this.heartbeat(), this.HEARTBEAT_MS);
+    },
+    
+    async heartbeat() {
+        const result = await LeaderboardAPI.sendPresence(this.BOARD, this.clientId);
+        if (!result.error) {
+            this.watching = result.watching;
+        }
+    }
+};
+
 // LeaderboardUI - Manages leaderboard display interface
 const LeaderboardUI = {
     currentSessionId: null,
+    submission: null,
+    // Country whose board is shown, or null for everyone
+    country: null,
     
-    // Show leaderboard
-    async show(currentScore, currentSessionId) {
+    // Show leaderboard, optionally with where a just-submitted run placed
+    async show(currentScore, currentSessionId, submission = null) {
         this.currentSessionId = currentSessionId;
+        this.submission = submission;
+        this.country = null;
         const overlay = document.getElementById('leaderboardOverlay');
         const content = document.getElementById('leaderboardContent');
         
@@ -1575,7 +1703,20 @@ const LeaderboardUI = {
         }
         
         let html = '<div class="leaderboard-list">';
-        html += '<h2>Top Scores</h2>';
+        if (this.country) {
+            html += `<h2>Top Scores in ${this.countryFlag(this.country)} ${this.country}</h2>`;
+            html += '<button class="all-countries" onclick="LeaderboardUI.showCountry(null)">All countries</button>';
+        } else {
+            html += '<h2>Top Scores</h2>';
+        }
+        if (Presence.watching > 1) {
+            html += `<div class="watching">${Presence.watching.toLocaleString()} watching</div>`;
+        }
+        // Placements are global, so they are only shown on the global board
+        const placement = this.country ? '' : this.formatPlacement(this.submission);
+        if (placement) {
+            html += `<div class="placement">${placement}</div>`;
+        }
         html += '<div class="leaderboard-entries">';
         
         entries.forEach((entry, index) => {
@@ -1589,6 +1730,26 @@ const LeaderboardUI = {
         content.innerHTML = html;
     },
     
+    // Describe where a submitted run placed, e.g. "You placed #7!"
+    formatPlacement(submission) {
+        if (!submission) {
+            return '';
+        }
+        const parts = [];
+        if (submission.rank) {
+            parts.push(`You placed #${submission.rank}!`);
+            if (submission.pointsToNextRank) {
+                parts.push(`${submission.pointsToNextRank} more points for #${submission.rank - 1}.`);
+            }
+        }
+        if (submission.personalBest) {
+            parts.push('New personal best!');
+        } else if (submission.previousBest) {
+            parts.push(`Your best is ${submission.previousBest}.`);
+        }
+        return parts.join(' ');
+    },
+    
     // Format a single leaderboard entry
     formatEntry(entry, rank, isCurrent) {
         const highlightClass = isCurrent ? 'current-session' : '';
@@ -1598,13 +1759,41 @@ const LeaderboardUI = {
         return `
             <div class="leaderboard-entry ${highlightClass}">
                 <span class="rank">#${rank}</span>
-                <span class="player-name">${this.escapeHtml(entry.playerName)}</span>
+                <span class="player-name">${this.formatCountry(entry.country)}${this.escapeHtml(entry.playerName)}</span>
                 <span class="score">${entry.score}</span>
                 <span class="date">${dateStr}</span>
             </div>
         `;
     },
     
+    // Format a clickable flag that opens the country's board
+    formatCountry(country) {
+        if (!/^[A-Z]{2}$/.test(country || '')) {
+            return '';
+        }
+        return `<button class="flag" title="${country}" onclick="LeaderboardUI.showCountry('${country}')">${this.countryFlag(country)}</button>`;
+    },
+    
+    // Turn a country code such as "CA" into its flag emoji
+    countryFlag(country) {
+        return String.fromCodePoint(...[...country].map(c => 0x1F1E6 + c.charCodeAt(0) - 65));
+    },
+    
+    // Show the board of one country, or of everyone for null
+    async showCountry(country) {
+        this.country = country;
+        const content = document.getElementById('leaderboardContent');
+        content.innerHTML = '<div class="loading">Loading leaderboard...</div>';
+        
+        const result = await LeaderboardAPI.getLeaderboard(10, country);
+        
+        if (result.error) {
+            this.showError(result.message);
+        } else {
+            this.renderLeaderboard(result, null);
+        }
+    },
+    
     // Show error message
     showError(message) {
         const content = document.getElementById('leaderboardContent');
@@ -1622,7 +1811,7 @@ const LeaderboardUI = {
         const content = document.getElementById('leaderboardContent');
         content.innerHTML = '<div class="loading">Loading leaderboard...</div>';
         
-        const result = await LeaderboardAPI.getLeaderboard(10);
+        const result = await LeaderboardAPI.getLeaderboard(10, this.country);
         
         if (result.error) {
             this.showError(result.message);
@@ -1978,6 +2167,52 @@ const AudioManager = {
     }
 };
 
+// Time the current run started, for submission telemetry
+let runStartTime = Date.now();
+
+// Player positions sampled during the run, submitted as ghost data
+const GHOST_INTERVAL_MS = 100;
+let ghostPoints = [];
+
+// Record the player's position once per ghost interval
+function recordGhostPoint() {
+    const elapsed = Date.now() - runStartTime;
+    if (elapsed >= ghostPoints.length * GHOST_INTERVAL_MS) {
+        ghostPoints.push([Math.round(player.x), Math.round(player.y)]);
+    }
+}
+
+// Inputs recorded each frame for the replay, one hex digit per frame, and
+// the state of the level's moving parts when the run started
+let replayInputs = [];
+let replayStart = null;
+
+// Start recording a replay from the current level state
+function beginReplay() {
+    replayInputs = [];
+    replayStart = {
+        player: {
+            x: player.x,
+            y: player.y,
+            velocityX: player.velocityX,
+            velocityY: player.velocityY,
+            onGround: player.onGround,
+            jumpsRemaining: player.jumpsRemaining
+        },
+        movingPlatforms: movingPlatforms.map(platform => ({ x: platform.x, direction: platform.direction })),
+        enemies: enemies.map(enemy => ({ x: enemy.x, direction: enemy.direction }))
+    };
+}
+
+// Record this frame's input (1 = left, 2 = right, 4 = jump)
+function recordReplayFrame() {
+    let input = 0;
+    if (keys['ArrowLeft'] || keys['a']) input |= 1;
+    if (keys['ArrowRight'] || keys['d']) input |= 2;
+    if (keys['ArrowUp'] || keys['w'] || keys[' ']) input |= 4;
+    replayInputs.push(input.toString(16));
+}
+
 // Game state
 let gameState = {
     score: 0,
@@ -2442,6 +2677,7 @@ function gameLoop() {
     if (gameState.gameOver || gameState.levelComplete) return;
     
     // Update
+    recordReplayFrame();
     updatePlayer();
     updateMovingPlatforms();
     updateEnemies();
@@ -2449,6 +2685,7 @@ function gameLoop() {
     checkExtraLives();
     checkLevelComplete();
     updateCamera();
+    recordGhostPoint();
     ParticleSystem.update(); // Update all particles
     
     // Clear canvas
@@ -2494,7 +2731,12 @@ async function submitScoreWithName() {
     statusDiv.style.color = 'white';
     
     // Submit score to backend
-    const result = await LeaderboardAPI.submitScore(gameState.score, playerName);
+    const run = buildRunTelemetry();
+    const replay = await buildReplay();
+    if (replay) {
+        run.replay = replay;
+    }
+    const result = await LeaderboardAPI.submitScore(gameState.score, playerName, run);
     
     if (result.error) {
         // Show error but allow retry
@@ -2507,7 +2749,43 @@ async function submitScoreWithName() {
     document.getElementById('namePrompt').classList.add('hidden');
     
     // Show leaderboard with current session highlighted
-    await LeaderboardUI.show(gameState.score, result.id);
+    await LeaderboardUI.show(gameState.score, result.id, result);
+}
+
+// Describe the current run so the server can verify the score
+function buildRunTelemetry() {
+    const durationMs = Date.now() - runStartTime;
+    return {
+        durationMs: durationMs,
+        endedAt: new Date().toISOString(),
+        telemetry: [{
+            level: 1,
+            coins: coins.filter(coin => coin.collected).length,
+            enemies: enemies.filter(enemy => !enemy.alive).length,
+            extraLives: extraLives.filter(life => life.collected).length,
+            timeMs: durationMs
+        }],
+        ghost: [{
+            level: 1,
+            intervalMs: GHOST_INTERVAL_MS,
+            points: ghostPoints
+        }]
+    };
+}
+
+// Compress the recorded replay as base64 gzip, or null if unsupported
+async function buildReplay() {
+    if (typeof CompressionStream === 'undefined' || !replayStart) {
+        return null;
+    }
+    const json = JSON.stringify({ version: 1, level: 1, start: replayStart, inputs: replayInputs.join('') });
+    const stream = new Blob([json]).stream().pipeThrough(new CompressionStream('gzip'));
+    const bytes = new Uint8Array(await new Response(stream).arrayBuffer());
+    let binary = '';
+    for (let i = 0; i < bytes.length; i++) {
+        binary += String.fromCharCode(bytes[i]);
+    }
+    return btoa(binary);
 }
 
 // Skip leaderboard submission
@@ -2542,6 +2820,9 @@ function restartGame() {
     coins.forEach(coin => coin.collected = false);
     extraLives.forEach(life => life.collected = false);
     enemies.forEach(enemy => enemy.alive = true);
+    runStartTime = Date.now();
+    ghostPoints = [];
+    beginReplay();
     
     ParticleSystem.clear(); // Clear all particles on restart
     
@@ -2569,6 +2850,10 @@ let gameStarted = false;
 function startGame() {
     if (!gameStarted) {
         gameStarted = true;
+        runStartTime = Date.now();
+        ghostPoints = [];
+        beginReplay();
+        Presence.start();
         AudioManager.playMusic();
         updateHUD();
         gameLoop();
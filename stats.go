@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+// BoardStats summarizes the scores on the public board
+type BoardStats struct {
+	Count  int     `json:"count"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P90    int     `json:"p90"`
+	P99    int     `json:"p99"`
+	Max    int     `json:"max"`
+	// SubmissionsPerDay counts the board's entries by UTC day, oldest
+	// first, over the requested number of days ending today. Days without
+	// submissions are included with a zero count.
+	SubmissionsPerDay []DayCount `json:"submissionsPerDay"`
+}
+
+// DayCount is the number of submissions on one day
+type DayCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// GetStats computes statistics over the public board, with submission
+// counts for the days days up to and including now
+func (s *ScoreStore) GetStats(ctx context.Context, now time.Time, days int) BoardStats {
+	board := s.QueryScores(ctx, ScoreQuery{})
+	config := s.Config()
+
+	stats := BoardStats{Count: len(board), SubmissionsPerDay: []DayCount{}}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	first := today.AddDate(0, 0, -(days - 1))
+	index := make(map[string]int, days)
+	for day := first; !day.After(today); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		index[date] = len(stats.SubmissionsPerDay)
+		stats.SubmissionsPerDay = append(stats.SubmissionsPerDay, DayCount{Date: date})
+	}
+
+	if len(board) == 0 {
+		return stats
+	}
+
+	scores := make([]int, len(board))
+	total := 0
+	for i, entry := range board {
+		scores[i] = entry.Score
+		total += entry.Score
+
+		if day, ok := index[config.EffectiveTime(entry).UTC().Format("2006-01-02")]; ok {
+			stats.SubmissionsPerDay[day].Count++
+		}
+	}
+	sort.Ints(scores)
+
+	n := len(scores)
+	stats.Mean = math.Round(float64(total)/float64(n)*100) / 100
+	if n%2 == 1 {
+		stats.Median = float64(scores[n/2])
+	} else {
+		stats.Median = float64(scores[n/2-1]+scores[n/2]) / 2
+	}
+	stats.P90 = nearestRank(scores, 90)
+	stats.P99 = nearestRank(scores, 99)
+	stats.Max = scores[n-1]
+	return stats
+}
+
+// nearestRank returns the pth percentile of sorted scores using the
+// nearest-rank method
+func nearestRank(sorted []int, p float64) int {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Test board statistics over visible entries
+func TestGetStats(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	store := NewScoreStore()
+	for i := 1; i <= 100; i++ {
+		store.restoreEntry(ScoreEntry{ID: strconv.Itoa(i), Score: i * 10, PlayerName: "Player", Timestamp: now.AddDate(0, 0, -(i % 3))})
+	}
+	store.restoreEntry(ScoreEntry{ID: "hidden", Score: 99999, PlayerName: "Cheater", Timestamp: now, Hidden: true})
+
+	stats := store.GetStats(context.Background(), now, 2)
+	if stats.Count != 100 || stats.Max != 1000 {
+		t.Errorf("Expected 100 entries with max 1000, got %d with max %d", stats.Count, stats.Max)
+	}
+	if stats.Mean != 505 || stats.Median != 505 {
+		t.Errorf("Expected mean and median 505, got %v and %v", stats.Mean, stats.Median)
+	}
+	if stats.P90 != 900 || stats.P99 != 990 {
+		t.Errorf("Expected p90 900 and p99 990, got %d and %d", stats.P90, stats.P99)
+	}
+
+	wantDays := []DayCount{{"2024-03-09", 34}, {"2024-03-10", 33}}
+	if len(stats.SubmissionsPerDay) != len(wantDays) {
+		t.Fatalf("Expected %d days, got %+v", len(wantDays), stats.SubmissionsPerDay)
+	}
+	for i, day := range stats.SubmissionsPerDay {
+		if day != wantDays[i] {
+			t.Errorf("Expected %+v, got %+v", wantDays[i], day)
+		}
+	}
+
+	empty := NewScoreStore().GetStats(context.Background(), now, 7)
+	if empty.Count != 0 || len(empty.SubmissionsPerDay) != 7 {
+		t.Errorf("Expected no entries and 7 empty days, got %+v", empty)
+	}
+}
+
+// Test the stats endpoint limits the days requested
+func TestGetStatsHandler(t *testing.T) {
+	store := NewScoreStore()
+	store.AddScore(context.Background(), 100, "Kiro")
+	handler := NewLeaderboardHandler(store)
+
+	tests := []struct {
+		name     string
+		query    string
+		wantDays int
+	}{
+		{"default days", "", 30},
+		{"custom days", "?days=7", 7},
+		{"capped days", "?days=100000", maxStatsDays},
+		{"invalid days", "?days=-1", 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.GetStats(w, httptest.NewRequest("GET", "/api/leaderboard/stats"+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+			}
+
+			var stats BoardStats
+			json.NewDecoder(w.Body).Decode(&stats)
+			if stats.Count != 1 || len(stats.SubmissionsPerDay) != tt.wantDays {
+				t.Errorf("Expected 1 entry over %d days, got %d over %d", tt.wantDays, stats.Count, len(stats.SubmissionsPerDay))
+			}
+			if last := stats.SubmissionsPerDay[len(stats.SubmissionsPerDay)-1]; last.Count != 1 {
+				t.Errorf("Expected today's submission to be counted, got %+v", last)
+			}
+		})
+	}
+}
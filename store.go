@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// BoardReader reads a board: its settings, standings and players
+type BoardReader interface {
+	Config() BoardConfig
+	// Now is the board's current time
+	Now() time.Time
+	Version(now time.Time) string
+	GetEntry(ctx context.Context, id string) (ScoreEntry, bool)
+	GetStanding(ctx context.Context, id string) (Standing, bool)
+	GetTopScores(ctx context.Context, limit int) []ScoreEntry
+	QueryScores(ctx context.Context, query ScoreQuery) []ScoreEntry
+	QueryPage(ctx context.Context, query ScoreQuery) ([]ScoreEntry, int)
+	GetPercentile(ctx context.Context, score int) Percentile
+	GetAround(ctx context.Context, playerName string, window int) ([]RankedEntry, bool)
+	GetStats(ctx context.Context, now time.Time, days int) BoardStats
+	GetCountries(ctx context.Context) []CountryStanding
+	SearchPlayers(ctx context.Context, query string, offset, limit int) ([]RankedEntry, int)
+	GetPlayerProfile(ctx context.Context, playerName string) (PlayerProfile, bool)
+	GetPlayerEntries(ctx context.Context, playerName string) []ScoreEntry
+	GetLookalikeEntries(ctx context.Context, playerName string) []ScoreEntry
+	FindLookalike(ctx context.Context, playerName string) (string, bool)
+	GetPlayerHistory(ctx context.Context, playerName string) (PlayerHistory, bool)
+	GetRecentScores(ctx context.Context, n int) []int
+	GetScoreDistribution(ctx context.Context) ScoreDistribution
+	WalkBoard(ctx context.Context, fn func(rank int, entry ScoreEntry) error) error
+	WaitForChanges(ctx context.Context, version string, wait time.Duration) (BoardChanges, error)
+}
+
+// Persister saves a board and reports how recent saves went
+type Persister interface {
+	SaveToFile(filename string) error
+	PersistenceStatus() PersistenceStatus
+}
+
+// LeaderboardStore is what a LeaderboardHandler needs of its board.
+// *ScoreStore implements it; tests can substitute a fake to reach error
+// paths, such as failing saves, that a real store rarely takes.
+type LeaderboardStore interface {
+	BoardReader
+	Persister
+	// SaveInBackground saves the board without waiting, retrying failures
+	SaveInBackground(filename string)
+	// Persist saves the board as the durability setting requires
+	Persist(filename string) error
+	// Close stops background saves, writing those already requested
+	Close()
+	AddEntry(ctx context.Context, entry ScoreEntry) ScoreEntry
+	SetHasReplay(ctx context.Context, id string, hasReplay bool) (ScoreEntry, error)
+	SetReplayVerified(ctx context.Context, id string, verified bool) (ScoreEntry, error)
+	SetHasGhost(ctx context.Context, id string, hasGhost bool) (ScoreEntry, error)
+	SetVerification(ctx context.Context, id, state string) (ScoreEntry, error)
+	DeleteEntry(ctx context.Context, id string) (ScoreEntry, error)
+	// WithdrawEntry takes back an entry AddEntry returned, putting back
+	// the runs it replaced
+	WithdrawEntry(ctx context.Context, entry ScoreEntry) error
+}
+
+var _ LeaderboardStore = (*ScoreStore)(nil)
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStore is a LeaderboardStore whose failures tests choose. Methods it
+// doesn't implement panic through the nil embedded interface, so a test
+// fails loudly if the handler reaches for one it didn't expect.
+type fakeStore struct {
+	LeaderboardStore
+
+	// changesErr is returned by WaitForChanges
+	changesErr error
+	// saveErr is returned by SaveToFile
+	saveErr error
+
+	mu     sync.Mutex
+	added  []ScoreEntry
+	status PersistenceStatus
+}
+
+func (f *fakeStore) AddEntry(ctx context.Context, entry ScoreEntry) ScoreEntry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.added = append(f.added, entry)
+	return entry
+}
+
+func (f *fakeStore) WaitForChanges(ctx context.Context, version string, wait time.Duration) (BoardChanges, error) {
+	return BoardChanges{Version: version, Entries: []ScoreEntry{}}, f.changesErr
+}
+
+func (f *fakeStore) SaveToFile(filename string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status.TotalSaves++
+	if f.saveErr != nil {
+		f.status.ConsecutiveFailures++
+		f.status.TotalFailures++
+		return fmt.Errorf("%w: %v", ErrStorageUnavailable, f.saveErr)
+	}
+	f.status.ConsecutiveFailures = 0
+	return nil
+}
+
+// SaveInBackground saves right away, so tests see the outcome when the
+// handler returns
+func (f *fakeStore) SaveInBackground(filename string) {
+	f.SaveToFile(filename)
+}
+
+func (f *fakeStore) Persist(filename string) error {
+	return f.SaveToFile(filename)
+}
+
+func (f *fakeStore) Close() {}
+
+func (f *fakeStore) PersistenceStatus() PersistenceStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status
+}
+
+// Test store errors are answered with the status of their kind, without
+// leaking the details of unexpected ones
+func TestHandlerStoreErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantDetail string
+	}{
+		{"none", nil, http.StatusOK, ""},
+		{"invalid version", newKindError(ErrValidation, "since must be a board version"), http.StatusBadRequest, "since must be a board version"},
+		{"storage unavailable", fmt.Errorf("%w: disk offline", ErrStorageUnavailable), http.StatusServiceUnavailable, "storage unavailable: disk offline"},
+		{"unexpected", errors.New("index corrupted at page 7"), http.StatusInternalServerError, "Operation failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewLeaderboardHandler(&fakeStore{changesErr: tt.err})
+			w := httptest.NewRecorder()
+			handler.GetChanges(w, httptest.NewRequest("GET", "/api/leaderboard/changes?since=v1", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.err == nil {
+				return
+			}
+			var problem Problem
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatalf("Failed to decode problem: %v", err)
+			}
+			if problem.Detail != tt.wantDetail {
+				t.Errorf("Expected detail %q, got %q", tt.wantDetail, problem.Detail)
+			}
+		})
+	}
+}
+
+// Test submissions are turned away while the board cannot be saved
+func TestSubmitScoreWhileSavesFail(t *testing.T) {
+	store := &fakeStore{saveErr: errors.New("read-only file system")}
+	store.SaveToFile("leaderboard.json")
+	gate := NewWriteGate(store, "leaderboard.json", PersistenceConfig{FailureThreshold: 1})
+	handler := NewLeaderboardHandler(store, WithWriteGate(gate))
+
+	w := httptest.NewRecorder()
+	handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", strings.NewReader(`{"playerName":"Kiro","score":100}`)))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header")
+	}
+	if len(store.added) != 0 {
+		t.Errorf("Expected nothing stored, got %+v", store.added)
+	}
+	// The gate retried the save to see whether storage had recovered
+	if status := store.PersistenceStatus(); status.TotalSaves != 2 || status.ConsecutiveFailures != 2 {
+		t.Errorf("Expected a second failed save, got %+v", status)
+	}
+}
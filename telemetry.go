@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxTelemetryLevels caps how many levels a run's telemetry can hold
+const maxTelemetryLevels = 1000
+
+// LevelTelemetry records what a player achieved on one level of a run
+type LevelTelemetry struct {
+	Level      int   `json:"level"`
+	Coins      int   `json:"coins"`
+	Enemies    int   `json:"enemies"`
+	ExtraLives int   `json:"extraLives"`
+	TimeMs     int64 `json:"timeMs"`
+}
+
+// ScoringConfig holds the game's point values, used to recompute a run's
+// score from its telemetry
+type ScoringConfig struct {
+	CoinPoints      int `json:"coinPoints,omitempty"`
+	EnemyPoints     int `json:"enemyPoints,omitempty"`
+	ExtraLifePoints int `json:"extraLifePoints,omitempty"`
+	// RequireTelemetry rejects submissions that do not include telemetry
+	RequireTelemetry bool `json:"requireTelemetry,omitempty"`
+}
+
+// withDefaults fills in the point values used by the game client
+func (c ScoringConfig) withDefaults() ScoringConfig {
+	if c.CoinPoints == 0 {
+		c.CoinPoints = 10
+	}
+	if c.EnemyPoints == 0 {
+		c.EnemyPoints = 50
+	}
+	if c.ExtraLifePoints == 0 {
+		c.ExtraLifePoints = 100
+	}
+	return c
+}
+
+// ExpectedScore recomputes the score a run should have from its telemetry
+func ExpectedScore(telemetry []LevelTelemetry, scoring ScoringConfig) int {
+	scoring = scoring.withDefaults()
+
+	total := 0
+	for _, level := range telemetry {
+		total += level.Coins*scoring.CoinPoints +
+			level.Enemies*scoring.EnemyPoints +
+			level.ExtraLives*scoring.ExtraLifePoints
+	}
+	return total
+}
+
+// TelemetryVerifier is a ScoreValidator that rejects submissions whose
+// score does not match the score recomputed from their run telemetry
+type TelemetryVerifier struct {
+	store *ScoreStore
+}
+
+// NewTelemetryVerifier creates a verifier using the store's scoring config
+func NewTelemetryVerifier(store *ScoreStore) *TelemetryVerifier {
+	return &TelemetryVerifier{store: store}
+}
+
+// ValidateScore implements ScoreValidator
+func (v *TelemetryVerifier) ValidateScore(submission ScoreSubmission) error {
+	scoring := v.store.Config().Scoring
+
+	if len(submission.Telemetry) == 0 {
+		if scoring.RequireTelemetry {
+			return errors.New("Run telemetry is required")
+		}
+		return nil
+	}
+
+	if len(submission.Telemetry) > maxTelemetryLevels {
+		return fmt.Errorf("Run telemetry is limited to %d levels", maxTelemetryLevels)
+	}
+
+	var totalTime int64
+	for _, level := range submission.Telemetry {
+		if level.Coins < 0 || level.Enemies < 0 || level.ExtraLives < 0 || level.TimeMs < 0 {
+			return fmt.Errorf("Invalid telemetry for level %d", level.Level)
+		}
+		totalTime += level.TimeMs
+	}
+
+	if submission.DurationMs > 0 && totalTime > submission.DurationMs {
+		return errors.New("Level times exceed run duration")
+	}
+
+	if expected := ExpectedScore(submission.Telemetry, scoring); expected != submission.Score {
+		return fmt.Errorf("Score does not match run telemetry (expected %d)", expected)
+	}
+
+	return nil
+}
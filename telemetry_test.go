@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+// Test telemetry verification recomputes the expected score
+func TestTelemetryVerifier(t *testing.T) {
+	store := NewScoreStore()
+	verifier := NewTelemetryVerifier(store)
+
+	run := []LevelTelemetry{
+		{Level: 1, Coins: 12, Enemies: 3, ExtraLives: 1, TimeMs: 45000},
+		{Level: 2, Coins: 8, Enemies: 2, TimeMs: 50000},
+	}
+
+	tests := []struct {
+		name       string
+		submission ScoreSubmission
+		wantErr    bool
+	}{
+		{"matching score", ScoreSubmission{Score: 550, Telemetry: run}, false},
+		{"mismatched score", ScoreSubmission{Score: 5500, Telemetry: run}, true},
+		{"no telemetry", ScoreSubmission{Score: 5500}, false},
+		{"levels longer than run", ScoreSubmission{Score: 550, DurationMs: 60000, Telemetry: run}, true},
+		{"negative counts", ScoreSubmission{Score: 0, Telemetry: []LevelTelemetry{{Level: 1, Coins: -5, Enemies: 1}}}, true},
+		{"too many levels", ScoreSubmission{Score: 0, Telemetry: make([]LevelTelemetry, maxTelemetryLevels+1)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifier.ValidateScore(tt.submission)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	store.SetConfig(BoardConfig{Scoring: ScoringConfig{RequireTelemetry: true}})
+	if err := verifier.ValidateScore(ScoreSubmission{Score: 100}); err == nil {
+		t.Error("Expected submissions without telemetry to be rejected")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// topBoard is the first entries of the public board in board order. Runs
+// are inserted into it as AddEntry stores them, so reading the top of the
+// board after a submission neither copies nor sorts the whole board. Any
+// other change leaves it out of date, and the next snapshot rebuilds it.
+type topBoard struct {
+	version uint64
+	// entries is the start of the board, at most the configured size
+	entries []ScoreEntry
+	// complete is set when entries holds the whole board
+	complete bool
+	// total is the number of entries on the whole board
+	total int
+	// players is who is on the board, kept on best-per-player boards where
+	// each player counts once
+	players map[string]bool
+	// expires is when the next held entry is released, zero if none are
+	expires time.Time
+}
+
+// newTopBoard keeps the first size entries of a snapshot's board
+func newTopBoard(snap *boardSnapshot, size int, bestPerPlayer bool) *topBoard {
+	top := &topBoard{
+		version:  snap.version,
+		entries:  append(make([]ScoreEntry, 0, size+1), snap.board[:min(size, len(snap.board))]...),
+		complete: len(snap.board) <= size,
+		total:    len(snap.board),
+		expires:  snap.expires,
+	}
+	if bestPerPlayer {
+		top.players = make(map[string]bool, len(snap.board))
+		for _, entry := range snap.board {
+			top.players[entry.PlayerName] = true
+		}
+	}
+	return top
+}
+
+// current reports whether the top of the board is up to date with the
+// board at version and no held entry has been released since
+func (t *topBoard) current(version uint64, now time.Time) bool {
+	return t.version == version && (t.expires.IsZero() || now.Before(t.expires))
+}
+
+// addToTop brings the top of the board up to date with the state AddEntry
+// just published for entry, given the runs it removed to make room for
+// it, or drops the top of the board if that can't be done in place.
+// Callers hold the snapshot lock.
+func (s *ScoreStore) addToTop(st *boardState, entry ScoreEntry, removed []ScoreEntry, now time.Time) {
+	top := s.top
+	if top == nil || !top.current(st.version-1, now) {
+		s.top = nil
+		return
+	}
+	top.version = st.version
+
+	if entry.held(now) && (top.expires.IsZero() || entry.HeldUntil.Before(top.expires)) {
+		top.expires = *entry.HeldUntil
+	}
+	if !entry.listed(now) {
+		return
+	}
+
+	i := sort.Search(len(top.entries), func(i int) bool {
+		return st.config.before(entry, top.entries[i])
+	})
+	replaced := false
+	if st.config.BestPerPlayer && top.players[entry.PlayerName] {
+		// The player is already on the board, so the new run takes their
+		// place only if it is better
+		for j, kept := range top.entries {
+			if kept.PlayerName != entry.PlayerName {
+				continue
+			}
+			if j < i {
+				// Removing their better run would leave a gap that only
+				// the rest of the board can fill
+				for _, run := range removed {
+					if run.ID == kept.ID {
+						s.top = nil
+					}
+				}
+				return
+			}
+			top.entries = append(top.entries[:j], top.entries[j+1:]...)
+			replaced = true
+			break
+		}
+	} else {
+		top.total++
+		if top.players != nil {
+			top.players[entry.PlayerName] = true
+		}
+	}
+
+	if i == len(top.entries) && !top.complete && !replaced {
+		return
+	}
+	top.entries = append(top.entries, ScoreEntry{})
+	copy(top.entries[i+1:], top.entries[i:])
+	top.entries[i] = entry
+	if size := st.config.topCacheSize(); len(top.entries) > size {
+		top.entries = top.entries[:size]
+		top.complete = false
+	}
+}
+
+// topPage returns a page of the whole board and the board's size if the
+// page lies within the top of the board
+func (s *ScoreStore) topPage(query ScoreQuery, now time.Time) ([]ScoreEntry, int, bool) {
+	s.snapshotMu.Lock()
+	defer s.snapshotMu.Unlock()
+
+	st := s.load()
+	top := s.top
+	if top == nil || !top.current(st.version, now) || !query.wholeBoard(st.config) {
+		return nil, 0, false
+	}
+	page := st.config.page(top.entries, query)
+	// A short page may continue past the kept entries
+	if !top.complete && (query.Limit <= 0 || len(page) < query.Limit) {
+		return nil, 0, false
+	}
+	return append([]ScoreEntry(nil), page...), top.total, true
+}
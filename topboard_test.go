@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// Test the top of the board kept up to date on submission matches the
+// board sorted from scratch, on every kind of board
+func TestTopBoard(t *testing.T) {
+	tests := []struct {
+		name   string
+		config BoardConfig
+	}{
+		{"every run", BoardConfig{TopCacheSize: 5}},
+		{"best per player", BoardConfig{TopCacheSize: 5, BestPerPlayer: true}},
+		{"lower is better", BoardConfig{TopCacheSize: 5, Direction: DirectionAscending}},
+		{"ranked by time", BoardConfig{TopCacheSize: 5, BestPerPlayer: true, TieBreak: TieBreakTime}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			store := NewScoreStore()
+			store.SetConfig(tt.config)
+			store.GetTopScores(context.Background(), 0)
+
+			releasesAt := time.Now().Add(time.Hour)
+			for i := 0; i < 200; i++ {
+				entry := ScoreEntry{
+					Score:      1 + rng.Intn(50),
+					PlayerName: fmt.Sprintf("Player%d", rng.Intn(12)),
+					Character:  []string{"kiro", "ghost"}[rng.Intn(2)],
+					DurationMs: int64(rng.Intn(5)) * 1000,
+				}
+				switch rng.Intn(10) {
+				case 0:
+					entry.Pending = true
+				case 1:
+					entry.HeldUntil = &releasesAt
+				}
+				store.AddEntry(context.Background(), entry)
+
+				if store.top == nil || store.top.version != store.load().version {
+					t.Fatalf("Expected the top of the board to be kept after submission %d", i)
+				}
+				want, wantTotal := store.QueryPage(context.Background(), ScoreQuery{MinScore: 1})
+				for offset := 0; offset <= 6; offset++ {
+					got, total := store.QueryPage(context.Background(), ScoreQuery{Offset: offset, Limit: 2})
+					if total != wantTotal {
+						t.Fatalf("Expected total %d, got %d", wantTotal, total)
+					}
+					for j, entry := range got {
+						if entry.ID != want[offset+j].ID {
+							t.Fatalf("Expected %+v at %d after submission %d, got %+v", want[offset+j], offset+j, i, entry)
+						}
+					}
+					if len(got) != min(2, max(0, len(want)-offset)) {
+						t.Fatalf("Expected a full page at offset %d, got %d entries", offset, len(got))
+					}
+				}
+			}
+		})
+	}
+}
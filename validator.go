@@ -0,0 +1,17 @@
+package main
+
+// ScoreValidator checks a submission before it is added to the store.
+// Returning an error rejects the submission with 422 Unprocessable Entity
+// and the error text as the message, so game-specific rules such as level
+// maxima can be plugged in without changing the handler.
+type ScoreValidator interface {
+	ValidateScore(submission ScoreSubmission) error
+}
+
+// ScoreValidatorFunc adapts an ordinary function to a ScoreValidator
+type ScoreValidatorFunc func(submission ScoreSubmission) error
+
+// ValidateScore calls f(submission)
+func (f ScoreValidatorFunc) ValidateScore(submission ScoreSubmission) error {
+	return f(submission)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Leaderboard events sent to webhook targets
+const (
+	// WebhookEventTopTen is sent when a score lands in the top ten
+	WebhookEventTopTen = "score.top10"
+	// WebhookEventRecord is sent when a score takes first place from a
+	// worse one, or is the first on the board
+	WebhookEventRecord = "score.record"
+)
+
+// Headers of webhook deliveries. The signature is the hex HMAC-SHA256,
+// keyed with the target's secret, of the timestamp, a dot and the body.
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// webhookTopRanks is how high a score must place to be announced
+const webhookTopRanks = 10
+
+// WebhookTarget is a URL that receives leaderboard events
+type WebhookTarget struct {
+	URL string `json:"url"`
+	// Secret signs deliveries so the target can tell they are genuine
+	Secret string `json:"secret"`
+	// Events lists the events sent to the target; empty means all of them
+	Events []string `json:"events,omitempty"`
+}
+
+// wants reports whether the target subscribed to an event
+func (t WebhookTarget) wants(event string) bool {
+	return len(t.Events) == 0 || slices.Contains(t.Events, event)
+}
+
+// WebhookConfig configures outgoing webhooks
+type WebhookConfig struct {
+	Targets []WebhookTarget `json:"targets,omitempty"`
+	// Attempts is how many times a delivery is tried. Defaults to 5.
+	Attempts int `json:"attempts,omitempty"`
+	// BackoffSeconds is the wait before the first retry, doubled before
+	// each further one. Defaults to 2.
+	BackoffSeconds int `json:"backoffSeconds,omitempty"`
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// WebhookEvent is the body posted to webhook targets
+type WebhookEvent struct {
+	ID    string     `json:"id"`
+	Type  string     `json:"type"`
+	Board string     `json:"board"`
+	At    time.Time  `json:"at"`
+	Entry ScoreEntry `json:"entry"`
+	Rank  int        `json:"rank"`
+	// PreviousRecord is the entry that held first place before, on record
+	// events for boards that weren't empty
+	PreviousRecord *ScoreEntry `json:"previousRecord,omitempty"`
+}
+
+// webhookDelivery is an event waiting to be posted to one target
+type webhookDelivery struct {
+	event WebhookEvent
+	body  []byte
+}
+
+// Webhooks posts signed leaderboard events to the configured targets in
+// the background. Each target has its own queue, so one that is down
+// doesn't hold up deliveries to the others.
+type Webhooks struct {
+	config   WebhookConfig
+	client   *http.Client
+	queues   []chan webhookDelivery
+	attempts int
+	backoff  time.Duration
+}
+
+// NewWebhooks creates webhooks for the configured targets. Call Start to
+// begin delivering events.
+func NewWebhooks(config WebhookConfig) *Webhooks {
+	timeout := time.Duration(config.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	attempts := config.Attempts
+	if attempts <= 0 {
+		attempts = 5
+	}
+	backoff := time.Duration(config.BackoffSeconds) * time.Second
+	if backoff <= 0 {
+		backoff = 2 * time.Second
+	}
+
+	queues := make([]chan webhookDelivery, len(config.Targets))
+	for i := range queues {
+		queues[i] = make(chan webhookDelivery, 100)
+	}
+	return &Webhooks{
+		config:   config,
+		client:   &http.Client{Timeout: timeout},
+		queues:   queues,
+		attempts: attempts,
+		backoff:  backoff,
+	}
+}
+
+// Start launches a background worker per target
+func (w *Webhooks) Start() {
+	for i, target := range w.config.Targets {
+		go func(target WebhookTarget, queue chan webhookDelivery) {
+			for delivery := range queue {
+				w.deliver(target, delivery)
+			}
+		}(target, w.queues[i])
+	}
+}
+
+// ScoreAdded announces an entry just added to a board if it placed in the
+// top ten, and as a record if it took first place from leader, the entry
+// ranked first before it was added (nil if the board was empty). Entries
+// held for review are announced when they are released, if they are on
+// the board then.
+func (w *Webhooks) ScoreAdded(board string, store BoardReader, entry ScoreEntry, leader *ScoreEntry) {
+	afterRelease(entry, store.Now(), func() {
+		w.announce(board, store, entry.ID, leader)
+	})
+}
+
+// afterRelease calls announce once entry is released, right away unless
+// it is still held for review at now, the board's time. That may be long
+// after the submission's request is over, so announcements read the board
+// with a background context.
+func afterRelease(entry ScoreEntry, now time.Time, announce func()) {
+	if entry.HeldUntil != nil {
+		if wait := entry.HeldUntil.Sub(now); wait > 0 {
+			time.AfterFunc(wait, announce)
+			return
+		}
+	}
+	announce()
+}
+
+// announce sends the events for an entry where it stands now
+func (w *Webhooks) announce(board string, store BoardReader, id string, leader *ScoreEntry) {
+	ctx := context.Background()
+	entry, ok := store.GetEntry(ctx, id)
+	if !ok {
+		return
+	}
+	standing, ok := store.GetStanding(ctx, id)
+	if !ok || standing.Rank > webhookTopRanks {
+		return
+	}
+
+	event := WebhookEvent{Board: board, At: store.Now().UTC(), Entry: entry.public(), Rank: standing.Rank}
+	w.publish(WebhookEventTopTen, event)
+	if standing.Rank == 1 && (leader == nil || store.Config().outranks(entry, *leader)) {
+		if leader != nil {
+			previous := leader.public()
+			event.PreviousRecord = &previous
+		}
+		w.publish(WebhookEventRecord, event)
+	}
+}
+
+// publish queues an event for every target subscribed to it without
+// blocking. Events are dropped with a warning if a target's queue is full.
+func (w *Webhooks) publish(eventType string, event WebhookEvent) {
+	event.ID = newUUID()
+	event.Type = eventType
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Warning: Could not encode webhook event %s: %v", event.ID, err)
+		return
+	}
+
+	for i, target := range w.config.Targets {
+		if !target.wants(eventType) {
+			continue
+		}
+		select {
+		case w.queues[i] <- webhookDelivery{event: event, body: body}:
+		default:
+			log.Printf("Warning: webhook queue for %s full, event %s dropped", target.URL, event.ID)
+		}
+	}
+}
+
+// deliver posts a delivery to a target, retrying with exponential backoff
+// until it is accepted, refused outright or the attempts run out
+func (w *Webhooks) deliver(target WebhookTarget, delivery webhookDelivery) {
+	backoff := w.backoff
+	for attempt := 1; attempt <= w.attempts; attempt++ {
+		retry, err := w.post(target, delivery, time.Now())
+		if err == nil {
+			return
+		}
+
+		log.Printf("Warning: webhook %s to %s failed (attempt %d/%d): %v", delivery.event.ID, target.URL, attempt, w.attempts, err)
+		if !retry {
+			return
+		}
+		if attempt < w.attempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+// post sends a single delivery. The boolean reports whether a failure is
+// worth retrying: client errors other than timeouts and rate limiting
+// will fail the same way again.
+func (w *Webhooks) post(target WebhookTarget, delivery webhookDelivery, now time.Time) (bool, error) {
+	req, err := http.NewRequest("POST", target.URL, bytes.NewReader(delivery.body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.event.Type)
+	req.Header.Set(WebhookDeliveryHeader, delivery.event.ID)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+webhookSignature(target.Secret, timestamp, delivery.body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// webhookSignature signs a delivery's timestamp and body with secret
+func webhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
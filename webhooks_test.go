@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Test top scores and records are posted, signed, to the targets
+// subscribed to them, retrying failed deliveries
+func TestWebhooks(t *testing.T) {
+	type delivery struct {
+		target string
+		event  WebhookEvent
+	}
+	deliveries := make(chan delivery, 100)
+	var mu sync.Mutex
+	failed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		target := strings.TrimPrefix(r.URL.Path, "/")
+		signature := "sha256=" + webhookSignature("secret-"+target, r.Header.Get(WebhookTimestampHeader), body)
+		if r.Header.Get(WebhookSignatureHeader) != signature {
+			t.Errorf("Expected signature %s, got %s", signature, r.Header.Get(WebhookSignatureHeader))
+		}
+
+		// The first delivery fails and must be retried
+		mu.Lock()
+		fail := !failed
+		failed = true
+		mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var event WebhookEvent
+		json.Unmarshal(body, &event)
+		if r.Header.Get(WebhookEventHeader) != event.Type || r.Header.Get(WebhookDeliveryHeader) != event.ID {
+			t.Errorf("Expected the event type and ID in the headers, got %v", r.Header)
+		}
+		deliveries <- delivery{target, event}
+	}))
+	defer server.Close()
+
+	webhooks := NewWebhooks(WebhookConfig{Targets: []WebhookTarget{
+		{URL: server.URL + "/all", Secret: "secret-all"},
+		{URL: server.URL + "/records", Secret: "secret-records", Events: []string{WebhookEventRecord}},
+	}})
+	webhooks.backoff = 0
+	webhooks.Start()
+
+	store := NewScoreStore()
+	handler := NewLeaderboardHandler(store, WithSaveFile(filepath.Join(t.TempDir(), "leaderboard.json")), WithBoardID("speedrun"), WithWebhooks(webhooks))
+	t.Cleanup(store.Close)
+	submit := func(name string, score int) {
+		w := httptest.NewRecorder()
+		handler.SubmitScore(w, httptest.NewRequest("POST", "/api/leaderboard", strings.NewReader(`{"playerName":"`+name+`","score":`+strconv.Itoa(score)+`}`)))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+	}
+	submit("Mario", 100)
+	submit("Luigi", 50)
+	submit("Kiro", 500)
+	for i := 0; i < 10; i++ {
+		store.AddScore(context.Background(), 1000+i, "Filler")
+	}
+	submit("Toad", 10)
+
+	want := []string{
+		"all score.top10 Mario 1 -",
+		"all score.record Mario 1 -",
+		"all score.top10 Luigi 2 -",
+		"all score.top10 Kiro 1 -",
+		"all score.record Kiro 1 Mario",
+		"records score.record Mario 1 -",
+		"records score.record Kiro 1 Mario",
+	}
+	got := make(map[string][]string)
+	for i := 0; i < len(want); i++ {
+		select {
+		case d := <-deliveries:
+			if d.event.Board != "speedrun" {
+				t.Errorf("Expected the board to be named, got %q", d.event.Board)
+			}
+			previous := "-"
+			if d.event.PreviousRecord != nil {
+				previous = d.event.PreviousRecord.PlayerName
+			}
+			got[d.target] = append(got[d.target], d.target+" "+d.event.Type+" "+d.event.Entry.PlayerName+" "+strconv.Itoa(d.event.Rank)+" "+previous)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected %d deliveries, got %v", len(want), got)
+		}
+	}
+	if all := strings.Join(append(got["all"], got["records"]...), "\n"); all != strings.Join(want, "\n") {
+		t.Errorf("Expected deliveries:\n%s\ngot:\n%s", strings.Join(want, "\n"), all)
+	}
+
+	select {
+	case d := <-deliveries:
+		t.Errorf("Expected nothing for a score outside the top ten, got %+v", d)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// Test held entries are announced once released by the board's clock,
+// not the wall clock
+func TestAfterRelease(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	released, held := now.Add(-time.Minute), now.Add(50*time.Millisecond)
+
+	tests := []struct {
+		name      string
+		heldUntil *time.Time
+		wantNow   bool
+	}{
+		{"not held", nil, true},
+		{"released", &released, true},
+		{"held", &held, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			announced := make(chan struct{}, 1)
+			afterRelease(ScoreEntry{HeldUntil: tt.heldUntil}, now, func() { announced <- struct{}{} })
+
+			select {
+			case <-announced:
+				if !tt.wantNow {
+					t.Fatal("Expected the held entry not to be announced yet")
+				}
+				return
+			default:
+				if tt.wantNow {
+					t.Fatal("Expected the entry to be announced right away")
+				}
+			}
+			select {
+			case <-announced:
+			case <-time.After(5 * time.Second):
+				t.Error("Expected the entry to be announced once released")
+			}
+		})
+	}
+}
+
+// Test which failed deliveries are retried
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		status    int
+		wantErr   bool
+		wantRetry bool
+	}{
+		{http.StatusNoContent, false, false},
+		{http.StatusInternalServerError, true, true},
+		{http.StatusTooManyRequests, true, true},
+		{http.StatusRequestTimeout, true, true},
+		{http.StatusBadRequest, true, false},
+		{http.StatusGone, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			webhooks := NewWebhooks(WebhookConfig{})
+			retry, err := webhooks.post(WebhookTarget{URL: server.URL}, webhookDelivery{body: []byte(`{}`)}, time.Now())
+			if (err != nil) != tt.wantErr || retry != tt.wantRetry {
+				t.Errorf("Expected error %v and retry %v, got %v and %v", tt.wantErr, tt.wantRetry, err, retry)
+			}
+		})
+	}
+}